@@ -0,0 +1,75 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilosa
+
+import (
+	"sync"
+	"time"
+)
+
+// Usage is the cost of the queries made for an account on a node, as
+// returned by API.AccountUsage.
+type Usage struct {
+	// Number of queries received.
+	Queries uint64 `json:"queries"`
+
+	// Number of shards the queries' calls were mapped to, locally and
+	// remotely. Calls which make several passes over the data count each
+	// shard once per pass.
+	Shards uint64 `json:"shards"`
+
+	// Total time taken to execute the queries, including any remote
+	// execution.
+	Duration time.Duration `json:"duration"`
+}
+
+// accountUsages accumulates the usage of each account which has made
+// queries to the node.
+type accountUsages struct {
+	mu     sync.Mutex
+	usages map[string]Usage
+}
+
+// add records a query made for account.
+func (a *accountUsages) add(account string, shards uint64, d time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.usages == nil {
+		a.usages = make(map[string]Usage)
+	}
+	u := a.usages[account]
+	u.Queries++
+	u.Shards += shards
+	u.Duration += d
+	a.usages[account] = u
+}
+
+// get returns the usage of account, which is zero if it has made no queries.
+func (a *accountUsages) get(account string) Usage {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.usages[account]
+}
+
+// reset clears the usage of account, or of every account if it is empty.
+func (a *accountUsages) reset(account string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if account == "" {
+		a.usages = nil
+		return
+	}
+	delete(a.usages, account)
+}
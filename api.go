@@ -17,20 +17,30 @@
 package pilosa
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
+	"path"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/pilosa/pilosa/encoding/parquet"
 	"github.com/pilosa/pilosa/pql"
 	"github.com/pilosa/pilosa/roaring"
 	"github.com/pilosa/pilosa/stats"
 	"github.com/pilosa/pilosa/tracing"
 	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -41,6 +51,20 @@ type API struct {
 	cluster *cluster
 	server  *Server
 
+	// imports tracks in-progress imports registered with BeginImport.
+	imports importRegistry
+
+	// importSequencer orders imports to each shard if the server orders
+	// imports.
+	importSequencer *importSequencer
+
+	// importTransforms holds transforms registered with
+	// RegisterImportTransform.
+	importTransforms importTransformRegistry
+
+	// queryCache holds the responses of queries made with CacheResults.
+	queryCache *queryCache
+
 	Serializer Serializer
 }
 
@@ -59,7 +83,11 @@ func OptAPIServer(s *Server) apiOption {
 
 // NewAPI returns a new API instance.
 func NewAPI(opts ...apiOption) (*API, error) {
-	api := &API{}
+	api := &API{
+		importSequencer: newImportSequencer(),
+		queryCache:      newQueryCache(defaultQueryCacheSize),
+	}
+	api.importSequencer.resync = api.resyncImportShard
 
 	for _, opt := range opts {
 		err := opt(api)
@@ -107,24 +135,434 @@ func (api *API) Query(ctx context.Context, req *QueryRequest) (QueryResponse, er
 		return QueryResponse{}, errors.Wrap(err, "validating api method")
 	}
 
+	return api.query(ctx, req, nil)
+}
+
+// ExplainAnalyze executes the query in req like Query, and also returns what
+// happened while executing each call: the shards it was mapped to, the rows
+// and containers it read and how long it took. Results are never served from
+// or stored in the query cache.
+func (api *API) ExplainAnalyze(ctx context.Context, req *QueryRequest) (QueryResponse, ExecutionStats, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "API.ExplainAnalyze")
+	defer span.Finish()
+
+	if err := api.validate(apiExplainAnalyze); err != nil {
+		return QueryResponse{}, ExecutionStats{}, errors.Wrap(err, "validating api method")
+	}
+
+	var stats ExecutionStats
+	resp, err := api.query(ctx, req, &stats)
+	if err != nil {
+		return QueryResponse{}, ExecutionStats{}, err
+	}
+	return resp, stats, nil
+}
+
+// query executes req, recording the actuals of each call in stats if it is
+// not nil.
+func (api *API) query(ctx context.Context, req *QueryRequest, stats *ExecutionStats) (QueryResponse, error) {
+	// Hold new queries while queries are paused. Remote queries belong to
+	// queries which were already running on another node, so they proceed.
+	if !req.Remote {
+		if err := api.server.queryPause.wait(ctx); err != nil {
+			return QueryResponse{}, errors.Wrap(err, "waiting for queries to resume")
+		}
+	}
+
+	// Remote queries were counted by the node which received them.
+	if !req.Remote && !api.server.queryRateLimits.allow(req.Index) {
+		return QueryResponse{}, ErrRateLimited
+	}
+
+	// Record the cost of the query for its account. Remote queries are part
+	// of a query recorded by the node which received it.
+	var shardsScanned *uint64
+	if req.AccountID != "" && !req.Remote {
+		shardsScanned = new(uint64)
+		defer func(start time.Time) {
+			api.holder.accountUsage.add(req.AccountID, atomic.LoadUint64(shardsScanned), time.Since(start))
+		}(time.Now())
+	}
+
 	q, err := pql.NewParser(strings.NewReader(req.Query)).Parse()
 	if err != nil {
 		return QueryResponse{}, errors.Wrap(err, "parsing")
 	}
+
+	// Queries from other nodes were authorized by the node which received
+	// them from the client.
+	if !isInternalRequest(ctx) {
+		if err := api.authorizeQuery(req.Index, req.Principal, q); err != nil {
+			return QueryResponse{}, err
+		}
+	}
+
+	shards := req.Shards
+	if req.SingleShard != nil {
+		if len(req.Shards) > 0 {
+			return QueryResponse{}, NewBadRequestError(errors.New("shards and single shard are mutually exclusive"))
+		}
+		shards = []uint64{*req.SingleShard}
+	}
+
+	if req.MaxParallelism < 0 {
+		return QueryResponse{}, NewBadRequestError(errors.New("max parallelism must not be negative"))
+	}
+
+	for k, v := range req.AttrFilter {
+		switch v.(type) {
+		case string, bool, int64, float64:
+		default:
+			return QueryResponse{}, NewBadRequestError(errors.Errorf("invalid attr filter value for %q: %T", k, v))
+		}
+	}
+
 	execOpts := &execOptions{
-		Remote:          req.Remote,
-		ExcludeRowAttrs: req.ExcludeRowAttrs, // NOTE: Kept for Pilosa 1.x compat.
-		ExcludeColumns:  req.ExcludeColumns,  // NOTE: Kept for Pilosa 1.x compat.
-		ColumnAttrs:     req.ColumnAttrs,     // NOTE: Kept for Pilosa 1.x compat.
+		Remote:              req.Remote,
+		ExcludeRowAttrs:     req.ExcludeRowAttrs, // NOTE: Kept for Pilosa 1.x compat.
+		ExcludeColumns:      req.ExcludeColumns,  // NOTE: Kept for Pilosa 1.x compat.
+		ColumnAttrs:         req.ColumnAttrs,     // NOTE: Kept for Pilosa 1.x compat.
+		View:                req.View,
+		AttrFilter:          req.AttrFilter,
+		IncludeKeys:         req.IncludeKeys,
+		TreatMissingAsEmpty: req.TreatMissingAsEmpty,
+		SortResults:         req.SortResults,
+		AttrsBestEffort:     req.AttrsBestEffort,
+		IncludeRowAttrs:     req.IncludeRowAttrs,
+		MaxParallelism:      req.MaxParallelism,
+		Stats:               stats,
+		shardsScanned:       shardsScanned,
+	}
+
+	// Writes invalidate cached results once they have been applied. Results
+	// of queries which write are never cached. Writes applied by other nodes
+	// don't invalidate this node's cache, so results are only cached on
+	// single node clusters.
+	var cacheKey string
+	if q.WriteCallN() > 0 {
+		defer api.touchIndex(req.Index)
+	} else if req.CacheResults && !req.Remote && stats == nil && len(api.cluster.Nodes()) == 1 {
+		if index := api.holder.Index(req.Index); index != nil {
+			cacheKey = queryCacheKey(req, index.Generation())
+			if resp, ok := api.queryCache.get(cacheKey); ok {
+				return resp, nil
+			}
+		}
 	}
-	resp, err := api.server.executor.Execute(ctx, req.Index, q, req.Shards, execOpts)
+
+	// Keep the index open until the query is done. See ReloadIndex.
+	_, release := api.holder.holdIndex(req.Index)
+	defer release()
+
+	resp, err := api.server.executor.Execute(ctx, req.Index, q, shards, execOpts)
 	if err != nil {
 		return QueryResponse{}, errors.Wrap(err, "executing")
 	}
 
+	if cacheKey != "" {
+		api.queryCache.add(cacheKey, resp)
+	}
 	return resp, nil
 }
 
+// Subscribe executes the query in req immediately and then once per interval,
+// sending each response on the returned channel until ctx is canceled. The
+// channel is closed when the subscription ends. Errors are sent in the Err
+// field of the response and do not end the subscription. If the receiver
+// falls behind, executions are skipped rather than queued. Queries which
+// write are rejected.
+func (api *API) Subscribe(ctx context.Context, req *QueryRequest, interval time.Duration) (<-chan QueryResponse, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.Subscribe")
+	defer span.Finish()
+
+	if err := api.validate(apiSubscribe); err != nil {
+		return nil, errors.Wrap(err, "validating api method")
+	}
+
+	if interval <= 0 {
+		return nil, NewBadRequestError(errors.New("interval must be positive"))
+	}
+	q, err := pql.NewParser(strings.NewReader(req.Query)).Parse()
+	if err != nil {
+		return nil, NewBadRequestError(errors.Wrap(err, "parsing"))
+	} else if q.WriteCallN() > 0 {
+		// Re-executing a write on every interval would repeat it forever.
+		return nil, NewBadRequestError(errors.New("subscribed queries must be read-only"))
+	}
+
+	// Copy the request so the caller may reuse it.
+	r := *req
+	ch := make(chan QueryResponse)
+	go func() {
+		defer close(ch)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			resp, err := api.Query(ctx, &r)
+			if err != nil {
+				resp = QueryResponse{Err: err}
+			}
+			select {
+			case ch <- resp:
+			case <-ctx.Done():
+				return
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// WatchSchema returns a channel which receives an event each time an index,
+// field or view is created or deleted, whether on this node or on another
+// node of the cluster. The channel is closed when ctx is canceled. Events are
+// buffered, but if the receiver falls too far behind, events are dropped
+// rather than delaying schema changes.
+func (api *API) WatchSchema(ctx context.Context) (<-chan SchemaEvent, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.WatchSchema")
+	defer span.Finish()
+
+	if err := api.validate(apiWatchSchema); err != nil {
+		return nil, errors.Wrap(err, "validating api method")
+	}
+
+	ch := api.server.schemaWatchers.add()
+	go func() {
+		<-ctx.Done()
+		api.server.schemaWatchers.remove(ch)
+	}()
+	return ch, nil
+}
+
+// contextKey is the type of the keys of values which the API stores in
+// contexts.
+type contextKey int
+
+const (
+	contextKeyInternal contextKey = iota
+	contextKeyPrincipal
+)
+
+// InternalContext returns a copy of ctx marking the request as made by
+// another node of the cluster, if token is the token the nodes send with
+// such requests. Otherwise ctx is returned unchanged. Field access control
+// lists are not checked again for internal requests, since the node which
+// received the client's request has already checked them.
+func (api *API) InternalContext(ctx context.Context, token string) context.Context {
+	if token == "" || token != api.cluster.internalRequestToken() {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKeyInternal, true)
+}
+
+// isInternalRequest returns true if ctx was marked by InternalContext.
+func isInternalRequest(ctx context.Context) bool {
+	internal, _ := ctx.Value(contextKeyInternal).(bool)
+	return internal
+}
+
+// WithPrincipal returns a copy of ctx carrying the principal on whose behalf
+// exports and import batches are made. Field access control lists are
+// checked against it.
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, contextKeyPrincipal, principal)
+}
+
+// principalFromContext returns the principal set by WithPrincipal.
+func principalFromContext(ctx context.Context) string {
+	principal, _ := ctx.Value(contextKeyPrincipal).(string)
+	return principal
+}
+
+// authorizeField returns ErrForbidden if principal may not access the field,
+// unless the request was made by another node of the cluster.
+func authorizeField(ctx context.Context, f *Field, principal string) error {
+	if isInternalRequest(ctx) || f.allows(principal) {
+		return nil
+	}
+	return errors.Wrapf(ErrForbidden, "field %s", f.Name())
+}
+
+// authorizeQuery returns ErrForbidden if q references a field of the index
+// which principal is not allowed to access.
+func (api *API) authorizeQuery(indexName, principal string, q *pql.Query) error {
+	index := api.holder.Index(indexName)
+	if index == nil {
+		return nil
+	}
+
+	m := make(map[string]struct{})
+	for _, c := range q.Calls {
+		callFieldRefs(c, m)
+	}
+	for name := range m {
+		if f := index.Field(name); f != nil && !f.allows(principal) {
+			return errors.Wrapf(ErrForbidden, "field %s", name)
+		}
+	}
+	return nil
+}
+
+// AggregateAcrossIndexes executes the query concurrently against every index
+// containing the named field and returns the response for each index by name.
+// Indexes without the field are skipped.
+func (api *API) AggregateAcrossIndexes(ctx context.Context, fieldName, pqlString string) (map[string]QueryResponse, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "API.AggregateAcrossIndexes")
+	defer span.Finish()
+
+	if err := api.validate(apiAggregateAcrossIndexes); err != nil {
+		return nil, errors.Wrap(err, "validating api method")
+	}
+
+	// Parse once up front so a bad query fails before any fan-out.
+	if _, err := pql.NewParser(strings.NewReader(pqlString)).Parse(); err != nil {
+		return nil, NewBadRequestError(errors.Wrap(err, "parsing"))
+	}
+
+	var mu sync.Mutex
+	var eg errgroup.Group
+	results := make(map[string]QueryResponse)
+	for _, index := range api.holder.Indexes() {
+		if index.Field(fieldName) == nil {
+			continue
+		}
+		indexName := index.Name()
+		eg.Go(func() error {
+			resp, err := api.Query(ctx, &QueryRequest{Index: indexName, Query: pqlString})
+			if err != nil {
+				return errors.Wrapf(err, "querying index %s", indexName)
+			}
+			mu.Lock()
+			results[indexName] = resp
+			mu.Unlock()
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// QueryFieldRefs parses a PQL query and returns the sorted names of the
+// fields it references, without executing it.
+func (api *API) QueryFieldRefs(ctx context.Context, query string) ([]string, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.QueryFieldRefs")
+	defer span.Finish()
+
+	if err := api.validate(apiQueryFieldRefs); err != nil {
+		return nil, errors.Wrap(err, "validating api method")
+	}
+
+	q, err := pql.NewParser(strings.NewReader(query)).Parse()
+	if err != nil {
+		return nil, NewBadRequestError(errors.Wrap(err, "parsing"))
+	}
+
+	m := make(map[string]struct{})
+	for _, c := range q.Calls {
+		callFieldRefs(c, m)
+	}
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// callFieldRefs adds the names of the fields referenced by c, its children and
+// any calls passed as arguments to m.
+func callFieldRefs(c *pql.Call, m map[string]struct{}) {
+	for _, key := range []string{"field", "_field"} {
+		if name, ok := c.Args[key].(string); ok && name != "" {
+			m[name] = struct{}{}
+		}
+	}
+
+	// These calls take the field as the name of a non-reserved argument.
+	// Other calls, such as SetColumnAttrs, use those arguments for attributes.
+	switch c.Name {
+	case "Set", "Clear", "ClearRow", "Row", "Range", "Store":
+		for arg := range c.Args {
+			if !pql.IsReservedArg(arg) {
+				m[arg] = struct{}{}
+			}
+		}
+	}
+
+	for _, v := range c.Args {
+		if child, ok := v.(*pql.Call); ok {
+			callFieldRefs(child, m)
+		}
+	}
+	for _, child := range c.Children {
+		callFieldRefs(child, m)
+	}
+}
+
+// QueryShardCount returns the number of shards a query on the named index
+// would be executed against, without executing it. Calls wrapped in Options()
+// with a shards argument only count those shards. Queries which only set or
+// clear bits and attributes are not executed across shards and return zero.
+func (api *API) QueryShardCount(ctx context.Context, indexName string, query string) (int, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.QueryShardCount")
+	defer span.Finish()
+
+	if err := api.validate(apiQueryShardCount); err != nil {
+		return 0, errors.Wrap(err, "validating api method")
+	}
+
+	index := api.holder.Index(indexName)
+	if index == nil {
+		return 0, newNotFoundError(ErrIndexNotFound)
+	}
+
+	q, err := pql.NewParser(strings.NewReader(query)).Parse()
+	if err != nil {
+		return 0, NewBadRequestError(errors.Wrap(err, "parsing"))
+	}
+
+	// Mirror how the executor chooses shards for each call.
+	shards := make(map[uint64]struct{})
+	for _, call := range q.Calls {
+		if !needsShards([]*pql.Call{call}) {
+			continue
+		}
+		if call.Name == "Options" {
+			if arg, ok := call.Args["shards"]; ok {
+				optShards, ok := arg.([]interface{})
+				if !ok {
+					return 0, NewBadRequestError(errors.New("Query(): shards must be a list of unsigned integers"))
+				}
+				for _, s := range optShards {
+					shard, ok := s.(int64)
+					if !ok {
+						return 0, NewBadRequestError(errors.New("Query(): shards must be a list of unsigned integers"))
+					}
+					shards[uint64(shard)] = struct{}{}
+				}
+				continue
+			}
+		}
+
+		available := index.AvailableShards().Slice()
+		if len(available) == 0 {
+			available = []uint64{0}
+		}
+		for _, shard := range available {
+			shards[shard] = struct{}{}
+		}
+	}
+	return len(shards), nil
+}
+
 // CreateIndex makes a new Pilosa index.
 func (api *API) CreateIndex(ctx context.Context, indexName string, options IndexOptions) (*Index, error) {
 	span, _ := tracing.StartSpanFromContext(ctx, "API.CreateIndex")
@@ -148,10 +586,82 @@ func (api *API) CreateIndex(ctx context.Context, indexName string, options Index
 	if err != nil {
 		return nil, errors.Wrap(err, "sending CreateIndex message")
 	}
-	api.holder.Stats.Count("createIndex", 1, 1.0)
+	api.holder.statsClient().Count("createIndex", 1, 1.0)
+	return index, nil
+}
+
+// PrepareCreateIndex is the first phase of a two-phase index creation. It
+// validates the index name and reserves it with the given options on every
+// node. The index is created by CommitCreateIndex or the reservation is
+// released by AbortCreateIndex. If any node fails to reserve the name, the
+// reservation is aborted on all nodes.
+func (api *API) PrepareCreateIndex(ctx context.Context, indexName string, options IndexOptions) error {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.PrepareCreateIndex")
+	defer span.Finish()
+
+	if err := api.validate(apiPrepareCreateIndex); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+
+	if err := api.holder.prepareIndex(indexName, options); err != nil {
+		return errors.Wrap(err, "preparing index")
+	}
+	err := api.server.SendSync(
+		&PrepareCreateIndexMessage{
+			Index: indexName,
+			Meta:  &options,
+		})
+	if err != nil {
+		api.holder.abortIndex(indexName)
+		if aerr := api.server.SendSync(&AbortCreateIndexMessage{Index: indexName}); aerr != nil {
+			api.server.subsystemLogger(LogSubsystemBroadcast).Printf("problem sending AbortCreateIndex message: %s", aerr)
+		}
+		return errors.Wrap(err, "sending PrepareCreateIndex message")
+	}
+	return nil
+}
+
+// CommitCreateIndex is the second phase of a two-phase index creation. It
+// creates an index reserved by PrepareCreateIndex on every node. Committing
+// an index which has already been committed is not an error, so a failed
+// commit may be retried.
+func (api *API) CommitCreateIndex(ctx context.Context, indexName string) (*Index, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.CommitCreateIndex")
+	defer span.Finish()
+
+	if err := api.validate(apiCommitCreateIndex); err != nil {
+		return nil, errors.Wrap(err, "validating api method")
+	}
+
+	index, err := api.holder.commitIndex(indexName)
+	if err != nil {
+		return nil, errors.Wrap(err, "committing index")
+	}
+	err = api.server.SendSync(&CommitCreateIndexMessage{Index: indexName})
+	if err != nil {
+		return nil, errors.Wrap(err, "sending CommitCreateIndex message")
+	}
+	api.holder.statsClient().Count("createIndex", 1, 1.0)
 	return index, nil
 }
 
+// AbortCreateIndex releases an index name reserved by PrepareCreateIndex on
+// every node. Aborting a name which is not reserved does nothing.
+func (api *API) AbortCreateIndex(ctx context.Context, indexName string) error {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.AbortCreateIndex")
+	defer span.Finish()
+
+	if err := api.validate(apiAbortCreateIndex); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+
+	api.holder.abortIndex(indexName)
+	if err := api.server.SendSync(&AbortCreateIndexMessage{Index: indexName}); err != nil {
+		return errors.Wrap(err, "sending AbortCreateIndex message")
+	}
+	return nil
+}
+
 // Index retrieves the named index.
 func (api *API) Index(ctx context.Context, indexName string) (*Index, error) {
 	span, _ := tracing.StartSpanFromContext(ctx, "API.Index")
@@ -183,16 +693,19 @@ func (api *API) DeleteIndex(ctx context.Context, indexName string) error {
 	if err != nil {
 		return errors.Wrap(err, "deleting index")
 	}
+	// Don't apply the index's query rate limit to an index created later
+	// with the same name.
+	api.server.queryRateLimits.set(indexName, 0)
 	// Send the delete index message to all nodes.
 	err = api.server.SendSync(
 		&DeleteIndexMessage{
 			Index: indexName,
 		})
 	if err != nil {
-		api.server.logger.Printf("problem sending DeleteIndex message: %s", err)
+		api.server.subsystemLogger(LogSubsystemBroadcast).Printf("problem sending DeleteIndex message: %s", err)
 		return errors.Wrap(err, "sending DeleteIndex message")
 	}
-	api.holder.Stats.Count("deleteIndex", 1, 1.0)
+	api.holder.statsClient().Count("deleteIndex", 1, 1.0)
 	return nil
 }
 
@@ -222,9 +735,14 @@ func (api *API) CreateField(ctx context.Context, indexName string, fieldName str
 		return nil, newNotFoundError(ErrIndexNotFound)
 	}
 
+	// Other nodes create the field with the options it inherits.
+	fo = fo.withDefaults(index.DefaultFieldOptions())
+
 	// Create field.
 	field, err := index.CreateField(fieldName, opts...)
-	if err != nil {
+	if err == ErrTooManyFields {
+		return nil, NewBadRequestError(err)
+	} else if err != nil {
 		return nil, errors.Wrap(err, "creating field")
 	}
 
@@ -236,13 +754,48 @@ func (api *API) CreateField(ctx context.Context, indexName string, fieldName str
 			Meta:  &fo,
 		})
 	if err != nil {
-		api.server.logger.Printf("problem sending CreateField message: %s", err)
+		api.server.subsystemLogger(LogSubsystemBroadcast).Printf("problem sending CreateField message: %s", err)
 		return nil, errors.Wrap(err, "sending CreateField message")
 	}
-	api.holder.Stats.CountWithCustomTags("createField", 1, 1.0, []string{fmt.Sprintf("index:%s", indexName)})
+	api.holder.statsClient().CountWithCustomTags("createField", 1, 1.0, []string{fmt.Sprintf("index:%s", indexName)})
 	return field, nil
 }
 
+// SetDefaultFieldOptions sets the options inherited by fields created in the
+// index from now on. Fields take each option they are not created with from
+// opts. Options which depend on the field type are only inherited by fields
+// of the same type as opts, or by fields created without a type.
+func (api *API) SetDefaultFieldOptions(ctx context.Context, indexName string, opts FieldOptions) error {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.SetDefaultFieldOptions")
+	defer span.Finish()
+
+	if err := api.validate(apiSetDefaultFieldOptions); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+
+	index := api.holder.Index(indexName)
+	if index == nil {
+		return newNotFoundError(ErrIndexNotFound)
+	}
+
+	if err := index.SetDefaultFieldOptions(opts); err != nil {
+		switch errors.Cause(err) {
+		case ErrInvalidFieldType, ErrInvalidCacheType, ErrInvalidCompression, ErrInvalidTimeQuantum:
+			return NewBadRequestError(err)
+		}
+		return errors.Wrap(err, "setting default field options")
+	}
+
+	// Send the default options to all nodes.
+	if err := api.server.SendSync(&SetDefaultFieldOptionsMessage{
+		Index:   indexName,
+		Options: &opts,
+	}); err != nil {
+		return errors.Wrap(err, "sending SetDefaultFieldOptions message")
+	}
+	return nil
+}
+
 // Field retrieves the named field.
 func (api *API) Field(ctx context.Context, indexName, fieldName string) (*Field, error) {
 	span, _ := tracing.StartSpanFromContext(ctx, "API.Field")
@@ -295,12 +848,22 @@ func (api *API) ImportRoaring(ctx context.Context, indexName, fieldName string,
 		return errors.Wrap(err, "validating api method")
 	}
 
+	if !remote {
+		if err := api.waitImportResume(ctx); err != nil {
+			return err
+		}
+	}
+
+	defer api.touchIndex(indexName)
+
 	nodes := api.cluster.shardNodes(indexName, shard)
 	var eg errgroup.Group
 
 	field := api.holder.Field(indexName, fieldName)
 	if field == nil {
 		return newNotFoundError(ErrFieldNotFound)
+	} else if err := authorizeField(ctx, field, req.Principal); err != nil {
+		return err
 	}
 
 	// only set and time fields are supported
@@ -308,6 +871,18 @@ func (api *API) ImportRoaring(ctx context.Context, indexName, fieldName string,
 		return NewBadRequestError(errors.New("roaring import is only supported for set and time fields"))
 	}
 
+	// Reject rows which the field does not allow before importing any view.
+	if fieldOptions := field.Options(); len(fieldOptions.AllowedRows) > 0 {
+		for _, viewData := range req.Views {
+			rowIDs, err := roaringRows(viewData)
+			if err != nil {
+				return NewBadRequestError(errors.Wrap(err, "decoding roaring data"))
+			} else if rows := fieldOptions.rowsNotAllowed(rowIDs); len(rows) > 0 {
+				return NewBadRequestError(errors.Errorf("rows not allowed in field %s: %v", fieldName, rows))
+			}
+		}
+	}
+
 	for _, node := range nodes {
 		node := node
 		if node.ID == api.server.nodeID {
@@ -343,44 +918,210 @@ func (api *API) ImportRoaring(ctx context.Context, indexName, fieldName string,
 	return eg.Wait()
 }
 
-// DeleteField removes the named field from the named index. If the index is not
-// found, an error is returned. If the field is not found, it is ignored and no
-// action is taken.
-func (api *API) DeleteField(ctx context.Context, indexName string, fieldName string) error {
-	span, _ := tracing.StartSpanFromContext(ctx, "API.DeleteField")
+// roaringRows returns the rows with bits in data, a roaring bitmap of a
+// shard's bits in either the official or Pilosa's roaring format.
+func roaringRows(data []byte) ([]uint64, error) {
+	bm := roaring.NewBitmap()
+	if err := bm.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+
+	var rowIDs []uint64
+	itr, _ := bm.Containers.Iterator(0)
+	for itr.Next() {
+		key, _ := itr.Value()
+		if rowID := key >> shardVsContainerExponent; len(rowIDs) == 0 || rowIDs[len(rowIDs)-1] != rowID {
+			rowIDs = append(rowIDs, rowID)
+		}
+	}
+	return rowIDs, nil
+}
+
+// SetFieldACL sets the principals allowed to query and import into the field
+// across the cluster. An empty list removes the restriction.
+func (api *API) SetFieldACL(ctx context.Context, indexName, fieldName string, allowed []string) error {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.SetFieldACL")
 	defer span.Finish()
 
-	if err := api.validate(apiDeleteField); err != nil {
+	if err := api.validate(apiSetFieldACL); err != nil {
 		return errors.Wrap(err, "validating api method")
 	}
 
-	// Find index.
-	index := api.holder.Index(indexName)
-	if index == nil {
-		return newNotFoundError(ErrIndexNotFound)
+	f := api.holder.Field(indexName, fieldName)
+	if f == nil {
+		return newNotFoundError(ErrFieldNotFound)
 	}
-
-	// Delete field from the index.
-	if err := index.DeleteField(fieldName); err != nil {
-		return errors.Wrap(err, "deleting field")
+	if err := f.SetACL(allowed); err != nil {
+		return errors.Wrap(err, "setting acl")
 	}
 
-	// Send the delete field message to all nodes.
-	err := api.server.SendSync(
-		&DeleteFieldMessage{
-			Index: indexName,
-			Field: fieldName,
-		})
-	if err != nil {
-		api.server.logger.Printf("problem sending DeleteField message: %s", err)
-		return errors.Wrap(err, "sending DeleteField message")
+	// Send the acl to all nodes.
+	if err := api.server.SendSync(&SetFieldACLMessage{
+		Index:   indexName,
+		Field:   fieldName,
+		Allowed: allowed,
+	}); err != nil {
+		return errors.Wrap(err, "sending SetFieldACL message")
 	}
-	api.holder.Stats.CountWithCustomTags("deleteField", 1, 1.0, []string{fmt.Sprintf("index:%s", indexName)})
 	return nil
 }
 
-// DeleteAvailableShard a shard ID from the available shard set cache.
-func (api *API) DeleteAvailableShard(_ context.Context, indexName, fieldName string, shardID uint64) error {
+// ExportFieldMeta returns the definition of the named field, its options and
+// view names, as a blob which can be passed to ImportFieldMeta. No data is
+// included.
+func (api *API) ExportFieldMeta(ctx context.Context, indexName, fieldName string) ([]byte, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.ExportFieldMeta")
+	defer span.Finish()
+
+	if err := api.validate(apiExportFieldMeta); err != nil {
+		return nil, errors.Wrap(err, "validating api method")
+	}
+
+	field := api.holder.Field(indexName, fieldName)
+	if field == nil {
+		return nil, newNotFoundError(ErrFieldNotFound)
+	}
+	return field.marshalDefinition()
+}
+
+// ImportFieldMeta creates the named field from a blob returned by
+// ExportFieldMeta, along with the views it lists. The field must not already
+// exist.
+func (api *API) ImportFieldMeta(ctx context.Context, indexName, fieldName string, blob []byte) (*Field, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "API.ImportFieldMeta")
+	defer span.Finish()
+
+	if err := api.validate(apiImportFieldMeta); err != nil {
+		return nil, errors.Wrap(err, "validating api method")
+	}
+
+	opt, views, err := unmarshalFieldDefinition(blob)
+	if err != nil {
+		return nil, NewBadRequestError(errors.Wrap(err, "decoding field meta"))
+	}
+
+	field, err := api.CreateField(ctx, indexName, fieldName, optFieldOptions(opt))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range views {
+		if _, err := field.createViewIfNotExists(name); err != nil {
+			return nil, errors.Wrapf(err, "creating view %s", name)
+		}
+	}
+	return field, nil
+}
+
+// ImportHistory returns up to n of the most recent imports into a field on
+// this node, newest first, with one record per shard imported. If n is not
+// positive, every remembered import is returned. Each field remembers a
+// bounded number of imports in memory, so the history is lost on restart.
+func (api *API) ImportHistory(ctx context.Context, indexName, fieldName string, n int) ([]ImportRecord, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.ImportHistory")
+	defer span.Finish()
+
+	if err := api.validate(apiImportHistory); err != nil {
+		return nil, errors.Wrap(err, "validating api method")
+	}
+
+	field := api.holder.Field(indexName, fieldName)
+	if field == nil {
+		if api.holder.Index(indexName) == nil {
+			return nil, newNotFoundError(ErrIndexNotFound)
+		}
+		return nil, newNotFoundError(ErrFieldNotFound)
+	}
+	return field.importHistory.last(n), nil
+}
+
+// DeleteField removes the named field from the named index. If the index is not
+// found, an error is returned. If the field is not found, it is ignored and no
+// action is taken.
+func (api *API) DeleteField(ctx context.Context, indexName string, fieldName string) error {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.DeleteField")
+	defer span.Finish()
+
+	if err := api.validate(apiDeleteField); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+
+	// Find index.
+	index := api.holder.Index(indexName)
+	if index == nil {
+		return newNotFoundError(ErrIndexNotFound)
+	}
+
+	if index.Field(fieldName) == nil {
+		return newNotFoundError(ErrFieldNotFound)
+	}
+
+	// Delete the field's row keys before the field, so that the keys never
+	// outlive it. If this node's translate store is a read-only replica,
+	// the primary deletes the keys when it receives the broadcast, and the
+	// deletion reaches the replicas through the translate log. The keys of
+	// a field moved to the trash are kept until it is purged.
+	if !api.holder.TrashDeletedFields() {
+		if err := api.holder.translateFile.DeleteFieldKeys(indexName, fieldName); err != nil && err != ErrTranslateStoreReadOnly {
+			return errors.Wrap(err, "deleting field keys")
+		}
+	}
+
+	// Delete field from the index.
+	if err := index.DeleteField(fieldName); err != nil {
+		return errors.Wrap(err, "deleting field")
+	}
+
+	// Send the delete field message to all nodes.
+	err := api.server.SendSync(
+		&DeleteFieldMessage{
+			Index: indexName,
+			Field: fieldName,
+		})
+	if err != nil {
+		api.server.subsystemLogger(LogSubsystemBroadcast).Printf("problem sending DeleteField message: %s", err)
+		return errors.Wrap(err, "sending DeleteField message")
+	}
+	api.holder.statsClient().CountWithCustomTags("deleteField", 1, 1.0, []string{fmt.Sprintf("index:%s", indexName)})
+	return nil
+}
+
+// RestoreField brings back the most recently deleted field of the given name
+// from the trash of this node, if deleted fields are kept. The field is only
+// restored on this node, so it must be restored on every node, within the
+// grace period of PurgeTrash.
+func (api *API) RestoreField(ctx context.Context, indexName, fieldName string) error {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.RestoreField")
+	defer span.Finish()
+
+	if err := api.validate(apiRestoreField); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+
+	if err := api.holder.restoreField(indexName, fieldName); err != nil {
+		return errors.Wrap(err, "restoring field")
+	}
+	return nil
+}
+
+// PurgeTrash permanently removes the fields on this node which were deleted
+// more than olderThan ago, along with their row keys.
+func (api *API) PurgeTrash(ctx context.Context, olderThan time.Duration) error {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.PurgeTrash")
+	defer span.Finish()
+
+	if err := api.validate(apiPurgeTrash); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+
+	if olderThan < 0 {
+		return NewBadRequestError(errors.Errorf("invalid grace period: %s", olderThan))
+	}
+	return errors.Wrap(api.holder.purgeTrash(olderThan), "purging trash")
+}
+
+// DeleteAvailableShard a shard ID from the available shard set cache.
+func (api *API) DeleteAvailableShard(_ context.Context, indexName, fieldName string, shardID uint64) error {
 	if err := api.validate(apiDeleteAvailableShard); err != nil {
 		return errors.Wrap(err, "validating api method")
 	}
@@ -404,15 +1145,17 @@ func (api *API) DeleteAvailableShard(_ context.Context, indexName, fieldName str
 			ShardID: shardID,
 		})
 	if err != nil {
-		api.server.logger.Printf("problem sending DeleteAvailableShard message: %s", err)
+		api.server.subsystemLogger(LogSubsystemBroadcast).Printf("problem sending DeleteAvailableShard message: %s", err)
 		return errors.Wrap(err, "sending DeleteAvailableShard message")
 	}
-	api.holder.Stats.CountWithCustomTags("deleteAvailableShard", 1, 1.0, []string{fmt.Sprintf("index:%s", indexName), fmt.Sprintf("field:%s", fieldName)})
+	api.holder.statsClient().CountWithCustomTags("deleteAvailableShard", 1, 1.0, []string{fmt.Sprintf("index:%s", indexName), fmt.Sprintf("field:%s", fieldName)})
 	return nil
 }
 
 // ExportCSV encodes the fragment designated by the index,field,shard as
-// CSV of the form <row>,<col>
+// CSV of the form <row>,<col>. Keys are written in place of IDs if the field
+// or index uses keys; an ID without a key, or whose key cannot be read, is
+// written as a number.
 func (api *API) ExportCSV(ctx context.Context, indexName string, fieldName string, shard uint64, w io.Writer) error {
 	span, _ := tracing.StartSpanFromContext(ctx, "API.ExportCSV")
 	defer span.Finish()
@@ -423,12 +1166,13 @@ func (api *API) ExportCSV(ctx context.Context, indexName string, fieldName strin
 
 	// Validate that this handler owns the shard.
 	if !api.cluster.ownsShard(api.Node().ID, indexName, shard) {
-		api.server.logger.Printf("node %s does not own shard %d of index %s", api.Node().ID, shard, indexName)
+		api.server.subsystemLogger(LogSubsystemQuery).Printf("node %s does not own shard %d of index %s", api.Node().ID, shard, indexName)
 		return ErrClusterDoesNotOwnShard
 	}
 
-	// Find index.
-	index := api.holder.Index(indexName)
+	// Find index, and keep it open until the export is done.
+	index, release := api.holder.holdIndex(indexName)
+	defer release()
 	if index == nil {
 		return newNotFoundError(ErrIndexNotFound)
 	}
@@ -437,6 +1181,8 @@ func (api *API) ExportCSV(ctx context.Context, indexName string, fieldName strin
 	field := index.Field(fieldName)
 	if field == nil {
 		return newNotFoundError(ErrFieldNotFound)
+	} else if err := authorizeField(ctx, field, principalFromContext(ctx)); err != nil {
+		return err
 	}
 
 	// Find the fragment.
@@ -444,32 +1190,28 @@ func (api *API) ExportCSV(ctx context.Context, indexName string, fieldName strin
 	if f == nil {
 		return ErrFragmentNotFound
 	}
+	api.holder.prefetchNextFragment(indexName, fieldName, viewStandard, shard)
 
 	// Wrap writer with a CSV writer.
 	cw := csv.NewWriter(w)
 
 	// Define the function to write each bit as a string,
-	// translating to keys where necessary.
+	// translating to keys where necessary. Data may have been written by
+	// ID to a keyed field, so fall back to the ID rather than failing.
 	var n int
 	fn := func(rowID, columnID uint64) error {
-		var rowStr string
-		var colStr string
-		var err error
-
+		rowStr := strconv.FormatUint(rowID, 10)
 		if field.keys() {
-			if rowStr, err = api.holder.translateFile.TranslateRowToString(index.Name(), field.Name(), rowID); err != nil {
-				return errors.Wrap(err, "translating row")
+			if key, err := api.holder.translateFile.TranslateRowToString(index.Name(), field.Name(), rowID); err == nil && key != "" {
+				rowStr = key
 			}
-		} else {
-			rowStr = strconv.FormatUint(rowID, 10)
 		}
 
+		colStr := strconv.FormatUint(columnID, 10)
 		if index.Keys() {
-			if colStr, err = api.holder.translateFile.TranslateColumnToString(index.Name(), columnID); err != nil {
-				return errors.Wrap(err, "translating column")
+			if key, err := api.holder.translateFile.TranslateColumnToString(index.Name(), columnID); err == nil && key != "" {
+				colStr = key
 			}
-		} else {
-			colStr = strconv.FormatUint(columnID, 10)
 		}
 
 		n++
@@ -489,217 +1231,2187 @@ func (api *API) ExportCSV(ctx context.Context, indexName string, fieldName strin
 	return nil
 }
 
-// ShardNodes returns the node and all replicas which should contain a shard's data.
-func (api *API) ShardNodes(ctx context.Context, indexName string, shard uint64) ([]*Node, error) {
-	span, _ := tracing.StartSpanFromContext(ctx, "API.ShardNodes")
+// ForEachBit calls fn with the row and column id of each bit set in the
+// standard view of a field in the given shard, ordered by row then column.
+// Iteration stops at the first error returned by fn or when ctx is canceled,
+// and that error is returned. Keys are not translated. The fragment is locked
+// while fn runs, so fn must not write to the same field and shard.
+func (api *API) ForEachBit(ctx context.Context, indexName, fieldName string, shard uint64, fn func(rowID, columnID uint64) error) error {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.ForEachBit")
 	defer span.Finish()
 
-	if err := api.validate(apiShardNodes); err != nil {
-		return nil, errors.Wrap(err, "validating api method")
+	if err := api.validate(apiForEachBit); err != nil {
+		return errors.Wrap(err, "validating api method")
 	}
 
-	return api.cluster.shardNodes(indexName, shard), nil
-}
-
-// FragmentBlockData is an endpoint for internal usage. It is not guaranteed to
-// return anything useful. Currently it returns protobuf encoded row and column
-// ids from a "block" which is a subdivision of a fragment.
-func (api *API) FragmentBlockData(ctx context.Context, body io.Reader) ([]byte, error) {
-	span, _ := tracing.StartSpanFromContext(ctx, "API.FragmentBlockData")
-	defer span.Finish()
-
-	if err := api.validate(apiFragmentBlockData); err != nil {
-		return nil, errors.Wrap(err, "validating api method")
+	// Validate that this handler owns the shard.
+	if !api.cluster.ownsShard(api.Node().ID, indexName, shard) {
+		api.server.subsystemLogger(LogSubsystemQuery).Printf("node %s does not own shard %d of index %s", api.Node().ID, shard, indexName)
+		return ErrClusterDoesNotOwnShard
 	}
 
-	reqBytes, err := ioutil.ReadAll(body)
-	if err != nil {
-		return nil, NewBadRequestError(errors.Wrap(err, "read body error"))
-	}
-	var req BlockDataRequest
-	if err := api.Serializer.Unmarshal(reqBytes, &req); err != nil {
-		return nil, NewBadRequestError(errors.Wrap(err, "unmarshal body error"))
+	if api.holder.Field(indexName, fieldName) == nil {
+		return newNotFoundError(ErrFieldNotFound)
 	}
 
-	// Retrieve fragment from holder.
-	f := api.holder.fragment(req.Index, req.Field, req.View, req.Shard)
+	// A shard without a fragment has no bits.
+	f := api.holder.fragment(indexName, fieldName, viewStandard, shard)
 	if f == nil {
-		return nil, ErrFragmentNotFound
-	}
-
-	var resp = BlockDataResponse{}
-	resp.RowIDs, resp.ColumnIDs = f.blockData(int(req.Block))
-
-	// Encode response.
-	buf, err := api.Serializer.Marshal(&resp)
-	if err != nil {
-		return nil, errors.Wrap(err, "merge block response encoding error")
+		return nil
 	}
+	api.holder.prefetchNextFragment(indexName, fieldName, viewStandard, shard)
 
-	return buf, nil
+	var n int
+	err := f.forEachBit(func(rowID, columnID uint64) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n++
+		return fn(rowID, columnID)
+	})
+	span.LogKV("n", n)
+	return err
 }
 
-// FragmentBlocks returns the checksums and block ids for all blocks in the specified fragment.
-func (api *API) FragmentBlocks(ctx context.Context, indexName, fieldName, viewName string, shard uint64) ([]FragmentBlock, error) {
-	span, _ := tracing.StartSpanFromContext(ctx, "API.FragmentBlocks")
+// ExportParquet encodes the fragment designated by the index,field,shard as a
+// Parquet file and writes it to w. The file has two required INT64 columns,
+// "row" and "column", with one row per set bit ordered by row then column.
+// Row and column IDs are written even if the field or index uses keys. If the
+// shard has no data for the field, a file with no rows is written.
+func (api *API) ExportParquet(ctx context.Context, indexName string, fieldName string, shard uint64, w io.Writer) error {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.ExportParquet")
 	defer span.Finish()
 
-	if err := api.validate(apiFragmentBlocks); err != nil {
-		return nil, errors.Wrap(err, "validating api method")
+	if err := api.validate(apiExportParquet); err != nil {
+		return errors.Wrap(err, "validating api method")
 	}
 
-	// Retrieve fragment from holder.
-	f := api.holder.fragment(indexName, fieldName, viewName, shard)
-	if f == nil {
-		return nil, ErrFragmentNotFound
+	// Validate that this handler owns the shard.
+	if !api.cluster.ownsShard(api.Node().ID, indexName, shard) {
+		api.server.subsystemLogger(LogSubsystemQuery).Printf("node %s does not own shard %d of index %s", api.Node().ID, shard, indexName)
+		return ErrClusterDoesNotOwnShard
 	}
 
-	// Retrieve blocks.
-	blocks := f.Blocks()
-	return blocks, nil
-}
-
-// FragmentData returns all data in the specified fragment.
-func (api *API) FragmentData(ctx context.Context, indexName, fieldName, viewName string, shard uint64) (io.WriterTo, error) {
-	span, _ := tracing.StartSpanFromContext(ctx, "API.FragmentData")
-	defer span.Finish()
+	// Keep the index open until the export is done.
+	_, release := api.holder.holdIndex(indexName)
+	defer release()
 
-	if err := api.validate(apiFragmentData); err != nil {
-		return nil, errors.Wrap(err, "validating api method")
+	if field := api.holder.Field(indexName, fieldName); field == nil {
+		return newNotFoundError(ErrFieldNotFound)
+	} else if err := authorizeField(ctx, field, principalFromContext(ctx)); err != nil {
+		return err
 	}
 
-	// Retrieve fragment from holder.
-	f := api.holder.fragment(indexName, fieldName, viewName, shard)
-	if f == nil {
-		return nil, ErrFragmentNotFound
+	api.holder.prefetchNextFragment(indexName, fieldName, viewStandard, shard)
+
+	pw := parquet.NewWriter(w, "row", "column")
+	var n int
+	if f := api.holder.fragment(indexName, fieldName, viewStandard, shard); f != nil {
+		if err := f.forEachBit(func(rowID, columnID uint64) error {
+			n++
+			return pw.Write(int64(rowID), int64(columnID))
+		}); err != nil {
+			return errors.Wrap(err, "writing Parquet")
+		}
+	}
+	if err := pw.Close(); err != nil {
+		return errors.Wrap(err, "closing Parquet writer")
 	}
-	return f, nil
-}
 
-// Hosts returns a list of the hosts in the cluster including their ID,
-// URL, and which is the coordinator.
-func (api *API) Hosts(ctx context.Context) []*Node {
-	span, _ := tracing.StartSpanFromContext(ctx, "API.Hosts")
-	defer span.Finish()
-	return api.cluster.Nodes()
-}
+	span.LogKV("n", n)
 
-// Node gets the ID, URI and coordinator status for this particular node.
-func (api *API) Node() *Node {
-	node := api.server.node()
-	return &node
+	return nil
 }
 
-// RecalculateCaches forces all TopN caches to be updated. Used mainly for integration tests.
-func (api *API) RecalculateCaches(ctx context.Context) error {
-	span, _ := tracing.StartSpanFromContext(ctx, "API.RecalculateCaches")
+// ExportNDJSON encodes the fragment designated by the index,field,shard as
+// newline-delimited JSON, with one object of the form {"row":<row>,"col":<col>}
+// per set bit, ordered by row then column. Row and column IDs are written even
+// if the field or index uses keys. If the shard has no data for the field,
+// nothing is written.
+func (api *API) ExportNDJSON(ctx context.Context, indexName string, fieldName string, shard uint64, w io.Writer) error {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.ExportNDJSON")
 	defer span.Finish()
 
-	if err := api.validate(apiRecalculateCaches); err != nil {
+	if err := api.validate(apiExportNDJSON); err != nil {
 		return errors.Wrap(err, "validating api method")
 	}
 
-	err := api.server.SendSync(&RecalculateCaches{})
-	if err != nil {
-		return errors.Wrap(err, "broacasting message")
+	// Validate that this handler owns the shard.
+	if !api.cluster.ownsShard(api.Node().ID, indexName, shard) {
+		api.server.subsystemLogger(LogSubsystemQuery).Printf("node %s does not own shard %d of index %s", api.Node().ID, shard, indexName)
+		return ErrClusterDoesNotOwnShard
 	}
-	api.holder.recalculateCaches()
-	return nil
-}
 
-// PostClusterMessage is for internal use. It decodes a protobuf message out of
-// the body and forwards it to the BroadcastHandler.
-func (api *API) ClusterMessage(ctx context.Context, reqBody io.Reader) error {
-	span, _ := tracing.StartSpanFromContext(ctx, "API.ClusterMessage")
-	defer span.Finish()
+	// Keep the index open until the export is done.
+	_, release := api.holder.holdIndex(indexName)
+	defer release()
 
-	if err := api.validate(apiClusterMessage); err != nil {
-		return errors.Wrap(err, "validating api method")
+	if field := api.holder.Field(indexName, fieldName); field == nil {
+		return newNotFoundError(ErrFieldNotFound)
+	} else if err := authorizeField(ctx, field, principalFromContext(ctx)); err != nil {
+		return err
 	}
 
-	// Read entire body.
-	body, err := ioutil.ReadAll(reqBody)
-	if err != nil {
-		return errors.Wrap(err, "reading body")
+	f := api.holder.fragment(indexName, fieldName, viewStandard, shard)
+	if f == nil {
+		return nil
 	}
+	api.holder.prefetchNextFragment(indexName, fieldName, viewStandard, shard)
 
-	typ := body[0]
-	msg := getMessage(typ)
-	err = api.server.serializer.Unmarshal(body[1:], msg)
-	if err != nil {
-		return errors.Wrap(err, "deserializing cluster message")
+	bw := bufio.NewWriter(w)
+	var n int
+	var buf []byte
+	if err := f.forEachBit(func(rowID, columnID uint64) error {
+		buf = append(buf[:0], `{"row":`...)
+		buf = strconv.AppendUint(buf, rowID, 10)
+		buf = append(buf, `,"col":`...)
+		buf = strconv.AppendUint(buf, columnID, 10)
+		buf = append(buf, "}\n"...)
+		n++
+		_, err := bw.Write(buf)
+		return err
+	}); err != nil {
+		return errors.Wrap(err, "writing NDJSON")
 	}
-
-	// Forward the error message.
-	if err := api.server.receiveMessage(msg); err != nil {
-		return errors.Wrap(err, "receiving message")
+	if err := bw.Flush(); err != nil {
+		return errors.Wrap(err, "flushing NDJSON")
 	}
+
+	span.LogKV("n", n)
+
 	return nil
 }
 
-// Schema returns information about each index in Pilosa including which fields
-// they contain.
-func (api *API) Schema(ctx context.Context) []*IndexInfo {
-	span, _ := tracing.StartSpanFromContext(ctx, "API.Schema")
-	defer span.Finish()
-	return api.holder.limitedSchema()
-}
+// Histogram dimensions.
+const (
+	HistogramDimensionRow    = "row"
+	HistogramDimensionColumn = "column"
+)
 
-// Views returns the views in the given field.
-func (api *API) Views(ctx context.Context, indexName string, fieldName string) ([]*view, error) {
-	span, _ := tracing.StartSpanFromContext(ctx, "API.Views")
+// FieldHistogram returns the distribution of bit counts in the standard view
+// of a field. For the "row" dimension, the result maps a bit count to the
+// number of rows having that many bits set; for the "column" dimension, it
+// maps a bit count to the number of columns.
+//
+// Only shards for which this node is the primary owner are considered, so
+// the histograms returned by every node in the cluster can be merged by
+// summing their values. Note that for the row dimension, a row's bits are
+// counted separately on each node holding shards of that row.
+func (api *API) FieldHistogram(ctx context.Context, indexName, fieldName string, dimension string) (map[uint64]uint64, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.FieldHistogram")
+	defer span.Finish()
+
+	if err := api.validate(apiFieldHistogram); err != nil {
+		return nil, errors.Wrap(err, "validating api method")
+	}
+
+	if dimension != HistogramDimensionRow && dimension != HistogramDimensionColumn {
+		return nil, NewBadRequestError(errors.Errorf("invalid histogram dimension: %q", dimension))
+	}
+
+	field := api.holder.Field(indexName, fieldName)
+	if field == nil {
+		return nil, newNotFoundError(ErrFieldNotFound)
+	}
+
+	// Sum the bit counts of each row or column across the local shards.
+	counts := make(map[uint64]uint64)
+	if view := field.view(viewStandard); view != nil {
+		for _, frag := range view.allFragments() {
+			nodes := api.cluster.shardNodes(indexName, frag.shard)
+			if len(nodes) == 0 || nodes[0].ID != api.server.nodeID {
+				continue
+			}
+			if err := frag.forEachBit(func(rowID, columnID uint64) error {
+				if dimension == HistogramDimensionRow {
+					counts[rowID]++
+				} else {
+					counts[columnID]++
+				}
+				return nil
+			}); err != nil {
+				return nil, errors.Wrap(err, "counting bits")
+			}
+		}
+	}
+
+	histogram := make(map[uint64]uint64)
+	for _, n := range counts {
+		histogram[n]++
+	}
+	return histogram, nil
+}
+
+// ValueHistogram returns the number of values of an int field in each of
+// buckets equal-width buckets covering min to max inclusive. Values outside
+// that range are not counted. Values are read in a single pass over each
+// fragment.
+//
+// Only shards for which this node is the primary owner are considered, so
+// the histograms returned by every node in the cluster can be merged by
+// summing their buckets.
+func (api *API) ValueHistogram(ctx context.Context, indexName, fieldName string, min, max int64, buckets int) ([]uint64, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.ValueHistogram")
+	defer span.Finish()
+
+	if err := api.validate(apiValueHistogram); err != nil {
+		return nil, errors.Wrap(err, "validating api method")
+	}
+
+	if buckets <= 0 {
+		return nil, NewBadRequestError(errors.Errorf("invalid number of buckets: %d", buckets))
+	} else if min > max {
+		return nil, NewBadRequestError(errors.Errorf("min %d is greater than max %d", min, max))
+	}
+
+	field := api.holder.Field(indexName, fieldName)
+	if field == nil {
+		return nil, newNotFoundError(ErrFieldNotFound)
+	}
+	bsig := field.bsiGroup(fieldName)
+	if bsig == nil {
+		return nil, NewBadRequestError(errors.Errorf("value histogram is not supported for %s fields", field.Type()))
+	}
+
+	// The width is computed in floating point since max-min+1 may not fit
+	// in an int64.
+	width := (float64(max) - float64(min) + 1) / float64(buckets)
+	counts := make([]uint64, buckets)
+	if view := field.view(viewBSIGroupPrefix + fieldName); view != nil {
+		for _, frag := range view.allFragments() {
+			nodes := api.cluster.shardNodes(indexName, frag.shard)
+			if len(nodes) == 0 || nodes[0].ID != api.server.nodeID {
+				continue
+			}
+			frag.forEachValue(bsig.BitDepth(), func(columnID, value uint64) {
+				v := int64(value) + bsig.Min
+				if v < min || v > max {
+					return
+				}
+				i := int((float64(v) - float64(min)) / width)
+				if i >= buckets {
+					i = buckets - 1
+				}
+				counts[i]++
+			})
+		}
+	}
+	return counts, nil
+}
+
+// ColumnCount is the number of rows set in a column, as returned by
+// API.TopColumns.
+type ColumnCount struct {
+	ID    uint64 `json:"id"`
+	Count uint64 `json:"count"`
+}
+
+// TopColumns returns the n columns of a field with the most rows set, sorted
+// by descending count and then ascending column id. Only shards for which
+// this node is the primary owner are counted. Each column belongs to a single
+// shard, so the results from every node can be combined and sorted again to
+// give the top columns of the whole cluster.
+func (api *API) TopColumns(ctx context.Context, indexName, fieldName string, n int) ([]ColumnCount, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.TopColumns")
+	defer span.Finish()
+
+	if err := api.validate(apiTopColumns); err != nil {
+		return nil, errors.Wrap(err, "validating api method")
+	}
+
+	if n <= 0 {
+		return nil, NewBadRequestError(errors.New("n must be positive"))
+	}
+
+	field := api.holder.Field(indexName, fieldName)
+	if field == nil {
+		return nil, newNotFoundError(ErrFieldNotFound)
+	}
+
+	// Keep the top n columns of each shard, then the top n of those.
+	var counts []ColumnCount
+	if view := field.view(viewStandard); view != nil {
+		for _, frag := range view.allFragments() {
+			nodes := api.cluster.shardNodes(indexName, frag.shard)
+			if len(nodes) == 0 || nodes[0].ID != api.server.nodeID {
+				continue
+			}
+
+			m := make(map[uint64]uint64)
+			if err := frag.forEachBit(func(rowID, columnID uint64) error {
+				m[columnID]++
+				return nil
+			}); err != nil {
+				return nil, errors.Wrap(err, "counting bits")
+			}
+			shardCounts := make([]ColumnCount, 0, len(m))
+			for id, count := range m {
+				shardCounts = append(shardCounts, ColumnCount{ID: id, Count: count})
+			}
+			counts = append(counts, topColumnCounts(shardCounts, n)...)
+		}
+	}
+	return topColumnCounts(counts, n), nil
+}
+
+// topColumnCounts sorts counts by descending count and then ascending id, and
+// returns the first n.
+func topColumnCounts(counts []ColumnCount, n int) []ColumnCount {
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].ID < counts[j].ID
+	})
+	if len(counts) > n {
+		counts = counts[:n]
+	}
+	return counts
+}
+
+// ShardNodes returns the node and all replicas which should contain a shard's data.
+func (api *API) ShardNodes(ctx context.Context, indexName string, shard uint64) ([]*Node, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.ShardNodes")
+	defer span.Finish()
+
+	if err := api.validate(apiShardNodes); err != nil {
+		return nil, errors.Wrap(err, "validating api method")
+	}
+
+	return api.cluster.shardNodes(indexName, shard), nil
+}
+
+// OwnershipMap returns the IDs of the nodes which own each shard of the named
+// index, from shard zero through the index's max shard. The primary owner is
+// listed first.
+func (api *API) OwnershipMap(ctx context.Context, indexName string) (map[uint64][]string, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.OwnershipMap")
+	defer span.Finish()
+
+	if err := api.validate(apiOwnershipMap); err != nil {
+		return nil, errors.Wrap(err, "validating api method")
+	}
+
+	index := api.holder.Index(indexName)
+	if index == nil {
+		return nil, newNotFoundError(ErrIndexNotFound)
+	}
+
+	m := make(map[uint64][]string)
+	shards := index.AvailableShards()
+	if shards.Count() == 0 {
+		return m, nil
+	}
+	for shard := uint64(0); shard <= shards.Max(); shard++ {
+		nodes := api.cluster.shardNodes(indexName, shard)
+		ids := make([]string, len(nodes))
+		for i, node := range nodes {
+			ids[i] = node.ID
+		}
+		m[shard] = ids
+	}
+	return m, nil
+}
+
+// RecomputeMaxShard rescans the fragment files on disk for every field in the
+// named index and returns the highest shard stored locally. Fragments found on
+// disk which were not open, such as after a failed resize, are opened and
+// announced to the cluster so that queries include them.
+func (api *API) RecomputeMaxShard(ctx context.Context, indexName string) (uint64, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.RecomputeMaxShard")
+	defer span.Finish()
+
+	if err := api.validate(apiRecomputeMaxShard); err != nil {
+		return 0, errors.Wrap(err, "validating api method")
+	}
+
+	index := api.holder.Index(indexName)
+	if index == nil {
+		return 0, newNotFoundError(ErrIndexNotFound)
+	}
+
+	var maxShard uint64
+	for _, field := range index.Fields() {
+		for _, view := range field.views() {
+			shards, err := view.openMissingFragments()
+			if err != nil {
+				return 0, errors.Wrapf(err, "opening fragments: field=%s, view=%s", field.Name(), view.name)
+			}
+			for _, shard := range shards {
+				api.server.subsystemLogger(LogSubsystemCluster).Printf("opened missing fragment: index=%s, field=%s, view=%s, shard=%d", indexName, field.Name(), view.name, shard)
+				if err := api.server.SendSync(&CreateShardMessage{
+					Index: indexName,
+					Field: field.Name(),
+					Shard: shard,
+				}); err != nil {
+					return 0, errors.Wrap(err, "sending CreateShard message")
+				}
+			}
+
+			if max := view.availableShards().Max(); max > maxShard {
+				maxShard = max
+			}
+		}
+	}
+
+	return maxShard, nil
+}
+
+// UnderReplicatedShards returns each shard of the named index which currently
+// has fewer live replicas than the cluster's replica count.
+func (api *API) UnderReplicatedShards(ctx context.Context, indexName string) ([]ShardReplication, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.UnderReplicatedShards")
+	defer span.Finish()
+
+	if err := api.validate(apiUnderReplicatedShards); err != nil {
+		return nil, errors.Wrap(err, "validating api method")
+	}
+
+	index := api.holder.Index(indexName)
+	if index == nil {
+		return nil, newNotFoundError(ErrIndexNotFound)
+	}
+
+	shards := index.AvailableShards()
+	if shards.Count() == 0 {
+		return nil, nil
+	}
+	return api.cluster.underReplicatedShards(indexName, shards.Max()), nil
+}
+
+// EstimateRowCount returns the number of columns set in a row across the
+// shards for which this node is the primary owner. Cached row counts are used
+// where available, so the row is never materialized. Summing the results from
+// every node gives the count for the whole cluster.
+func (api *API) EstimateRowCount(ctx context.Context, indexName, fieldName string, rowID uint64) (uint64, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.EstimateRowCount")
+	defer span.Finish()
+
+	if err := api.validate(apiEstimateRowCount); err != nil {
+		return 0, errors.Wrap(err, "validating api method")
+	}
+
+	field := api.holder.Field(indexName, fieldName)
+	if field == nil {
+		return 0, newNotFoundError(ErrFieldNotFound)
+	}
+
+	var n uint64
+	if view := field.view(viewStandard); view != nil {
+		for _, frag := range view.allFragments() {
+			nodes := api.cluster.shardNodes(indexName, frag.shard)
+			if len(nodes) == 0 || nodes[0].ID != api.server.nodeID {
+				continue
+			}
+			n += frag.rowCountEstimate(rowID)
+		}
+	}
+	return n, nil
+}
+
+// FragmentBlockData is an endpoint for internal usage. It is not guaranteed to
+// return anything useful. Currently it returns protobuf encoded row and column
+// ids from a "block" which is a subdivision of a fragment.
+func (api *API) FragmentBlockData(ctx context.Context, body io.Reader) ([]byte, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.FragmentBlockData")
+	defer span.Finish()
+
+	if err := api.validate(apiFragmentBlockData); err != nil {
+		return nil, errors.Wrap(err, "validating api method")
+	}
+
+	reqBytes, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, NewBadRequestError(errors.Wrap(err, "read body error"))
+	}
+	var req BlockDataRequest
+	if err := api.Serializer.Unmarshal(reqBytes, &req); err != nil {
+		return nil, NewBadRequestError(errors.Wrap(err, "unmarshal body error"))
+	}
+
+	// Retrieve fragment from holder.
+	f := api.holder.fragment(req.Index, req.Field, req.View, req.Shard)
+	if f == nil {
+		return nil, ErrFragmentNotFound
+	}
+
+	var resp = BlockDataResponse{}
+	resp.RowIDs, resp.ColumnIDs = f.blockData(int(req.Block))
+
+	// Encode response.
+	buf, err := api.Serializer.Marshal(&resp)
+	if err != nil {
+		return nil, errors.Wrap(err, "merge block response encoding error")
+	}
+
+	return buf, nil
+}
+
+// FragmentBlocks returns the checksums and block ids for all blocks in the specified fragment.
+func (api *API) FragmentBlocks(ctx context.Context, indexName, fieldName, viewName string, shard uint64) ([]FragmentBlock, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.FragmentBlocks")
+	defer span.Finish()
+
+	if err := api.validate(apiFragmentBlocks); err != nil {
+		return nil, errors.Wrap(err, "validating api method")
+	}
+
+	// Retrieve fragment from holder.
+	f := api.holder.fragment(indexName, fieldName, viewName, shard)
+	if f == nil {
+		return nil, ErrFragmentNotFound
+	}
+
+	// Retrieve blocks.
+	blocks := f.Blocks()
+	return blocks, nil
+}
+
+// IndexMerkleRoot returns the root of a Merkle tree over the block checksums
+// of every fragment in an index, ordered by field, view, shard and block. The
+// blocks of each shard are read from its primary owner, so every node returns
+// the same root for the same data. Storing the root and comparing it later
+// detects any change to the index.
+func (api *API) IndexMerkleRoot(ctx context.Context, indexName string) ([]byte, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "API.IndexMerkleRoot")
+	defer span.Finish()
+
+	if err := api.validate(apiIndexMerkleRoot); err != nil {
+		return nil, errors.Wrap(err, "validating api method")
+	}
+
+	index := api.holder.Index(indexName)
+	if index == nil {
+		return nil, newNotFoundError(ErrIndexNotFound)
+	}
+
+	shards := index.AvailableShards().Slice()
+	var leaves [][]byte
+	for _, field := range index.Fields() {
+		views := field.views()
+		sort.Slice(views, func(i, j int) bool { return views[i].name < views[j].name })
+		for _, view := range views {
+			for _, shard := range shards {
+				if err := ctx.Err(); err != nil {
+					return nil, err
+				}
+				blocks, err := api.primaryFragmentBlocks(ctx, indexName, field.Name(), view.name, shard)
+				if err != nil {
+					return nil, errors.Wrapf(err, "getting blocks: field=%s, view=%s, shard=%d", field.Name(), view.name, shard)
+				}
+				for _, blk := range blocks {
+					leaves = append(leaves, fragmentBlockLeaf(field.Name(), view.name, shard, blk))
+				}
+			}
+		}
+	}
+	return merkleRoot(leaves), nil
+}
+
+// RebuildSchemaFromDisk reconstructs the schema of an index from the field
+// and view directories under its path, for recovery after its meta files are
+// lost or corrupted. Field directories which are not open are opened, and
+// fields without a meta file are given options inferred from their views:
+// int fields are assumed to have a minimum of zero, and set, mutex and bool
+// fields are all recovered as set fields. The inferred meta is written to
+// disk and the resulting schema returned.
+func (api *API) RebuildSchemaFromDisk(ctx context.Context, indexName string) (*IndexInfo, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.RebuildSchemaFromDisk")
+	defer span.Finish()
+
+	if err := api.validate(apiRebuildSchemaFromDisk); err != nil {
+		return nil, errors.Wrap(err, "validating api method")
+	}
+
+	index := api.holder.Index(indexName)
+	if index == nil {
+		return nil, newNotFoundError(ErrIndexNotFound)
+	}
+	if err := index.rebuildSchemaFromDisk(); err != nil {
+		return nil, errors.Wrap(err, "rebuilding schema")
+	}
+
+	info := &IndexInfo{Name: index.Name(), Options: index.Options()}
+	for _, field := range index.Fields() {
+		fi := &FieldInfo{Name: field.Name(), Options: field.Options()}
+		for _, view := range field.views() {
+			fi.Views = append(fi.Views, &ViewInfo{Name: view.name})
+		}
+		sort.Sort(viewInfoSlice(fi.Views))
+		info.Fields = append(info.Fields, fi)
+	}
+	sort.Sort(fieldInfoSlice(info.Fields))
+	return info, nil
+}
+
+// ReloadIndex closes an index on this node and opens it again from disk,
+// picking up fields and fragments which were changed by another process, such
+// as a restore. It waits for running queries and exports of the index to
+// finish, and new ones wait while the index is reopened. If the index cannot
+// be reopened, the old one is kept.
+func (api *API) ReloadIndex(ctx context.Context, indexName string) error {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.ReloadIndex")
+	defer span.Finish()
+
+	if err := api.validate(apiReloadIndex); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+
+	if err := api.holder.reloadIndex(indexName); err != nil {
+		return errors.Wrap(err, "reloading index")
+	}
+	return nil
+}
+
+// primaryFragmentBlocks returns the blocks of a fragment held by the primary
+// owner of its shard. Returns no blocks if the fragment does not exist.
+func (api *API) primaryFragmentBlocks(ctx context.Context, index, field, view string, shard uint64) ([]FragmentBlock, error) {
+	nodes := api.cluster.shardNodes(index, shard)
+	if len(nodes) == 0 {
+		return nil, nil
+	}
+	if nodes[0].ID == api.server.nodeID {
+		if frag := api.holder.fragment(index, field, view, shard); frag != nil {
+			return frag.Blocks(), nil
+		}
+		return nil, nil
+	}
+
+	blocks, err := api.server.defaultClient.FragmentBlocks(ctx, &nodes[0].URI, index, field, view, shard)
+	if err == ErrFragmentNotFound {
+		return nil, nil
+	}
+	return blocks, err
+}
+
+// FieldsEqual reports whether two fields in an index hold the same data on
+// this node, by comparing the block checksums of every view and shard which
+// this node owns. Only local data is compared, so callers checking a whole
+// cluster should AND the results from each node.
+func (api *API) FieldsEqual(ctx context.Context, indexName, fieldA, fieldB string) (bool, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.FieldsEqual")
+	defer span.Finish()
+
+	if err := api.validate(apiFieldsEqual); err != nil {
+		return false, errors.Wrap(err, "validating api method")
+	}
+
+	a := api.holder.Field(indexName, fieldA)
+	if a == nil {
+		return false, newNotFoundError(errors.Wrap(ErrFieldNotFound, fieldA))
+	}
+	b := api.holder.Field(indexName, fieldB)
+	if b == nil {
+		return false, newNotFoundError(errors.Wrap(ErrFieldNotFound, fieldB))
+	}
+
+	// Collect the local fragments of each view in either field.
+	type viewShard struct {
+		view  string
+		shard uint64
+	}
+	keys := make(map[viewShard]struct{})
+	for _, f := range []*Field{a, b} {
+		for _, v := range f.views() {
+			for _, frag := range v.allFragments() {
+				keys[viewShard{view: v.name, shard: frag.shard}] = struct{}{}
+			}
+		}
+	}
+
+	nodeID := api.Node().ID
+	for key := range keys {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		} else if !api.cluster.ownsShard(nodeID, indexName, key.shard) {
+			continue
+		}
+
+		var blocksA, blocksB []FragmentBlock
+		if frag := api.holder.fragment(indexName, fieldA, key.view, key.shard); frag != nil {
+			blocksA = frag.Blocks()
+		}
+		if frag := api.holder.fragment(indexName, fieldB, key.view, key.shard); frag != nil {
+			blocksB = frag.Blocks()
+		}
+		if !fragmentBlocksEqual(blocksA, blocksB) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// GetBits returns whether each bit, given by a row in rows and the column at
+// the same position in cols, is set in the standard view of a field. Bits are
+// grouped by shard and each fragment is read once. Every shard must be owned
+// by this node, otherwise ErrClusterDoesNotOwnShard is returned.
+func (api *API) GetBits(ctx context.Context, indexName, fieldName string, rows, cols []uint64) ([]bool, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.GetBits")
+	defer span.Finish()
+
+	if err := api.validate(apiGetBits); err != nil {
+		return nil, errors.Wrap(err, "validating api method")
+	}
+
+	if len(rows) != len(cols) {
+		return nil, NewBadRequestError(errors.Errorf("got %d rows and %d columns", len(rows), len(cols)))
+	}
+	field := api.holder.Field(indexName, fieldName)
+	if field == nil {
+		if api.holder.Index(indexName) == nil {
+			return nil, newNotFoundError(ErrIndexNotFound)
+		}
+		return nil, newNotFoundError(ErrFieldNotFound)
+	} else if field.Type() == FieldTypeInt {
+		return nil, NewBadRequestError(errors.New("bits cannot be read from int fields"))
+	}
+
+	// Group the positions of the bits by shard.
+	byShard := make(map[uint64][]int)
+	for i, col := range cols {
+		byShard[col/ShardWidth] = append(byShard[col/ShardWidth], i)
+	}
+	shards := make([]uint64, 0, len(byShard))
+	for shard := range byShard {
+		shards = append(shards, shard)
+	}
+	sort.Slice(shards, func(i, j int) bool { return shards[i] < shards[j] })
+
+	nodeID := api.Node().ID
+	for _, shard := range shards {
+		if !api.cluster.ownsShard(nodeID, indexName, shard) {
+			return nil, errors.Wrapf(ErrClusterDoesNotOwnShard, "shard %d", shard)
+		}
+	}
+
+	a := make([]bool, len(rows))
+	for _, shard := range shards {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		frag := api.holder.fragment(indexName, fieldName, viewStandard, shard)
+		if frag == nil {
+			continue
+		}
+
+		positions := byShard[shard]
+		rowIDs, columnIDs := make([]uint64, len(positions)), make([]uint64, len(positions))
+		for i, pos := range positions {
+			rowIDs[i], columnIDs[i] = rows[pos], cols[pos]
+		}
+		bits, err := frag.bits(rowIDs, columnIDs)
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading shard %d", shard)
+		}
+		for i, pos := range positions {
+			a[pos] = bits[i]
+		}
+	}
+	return a, nil
+}
+
+// IntersectRows returns the columns set in every one of the given rows of a
+// field's standard view, over the shards owned by this node. Column IDs are
+// returned even if the index uses keys. The rows returned by each node in the
+// cluster can be combined with Row.Merge.
+func (api *API) IntersectRows(ctx context.Context, indexName, fieldName string, rowIDs []uint64) (*Row, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.IntersectRows")
+	defer span.Finish()
+
+	if err := api.validate(apiIntersectRows); err != nil {
+		return nil, errors.Wrap(err, "validating api method")
+	}
+
+	if len(rowIDs) == 0 {
+		return nil, NewBadRequestError(errors.New("at least one row is required"))
+	}
+	field := api.holder.Field(indexName, fieldName)
+	if field == nil {
+		if api.holder.Index(indexName) == nil {
+			return nil, newNotFoundError(ErrIndexNotFound)
+		}
+		return nil, newNotFoundError(ErrFieldNotFound)
+	} else if field.Type() == FieldTypeInt {
+		return nil, NewBadRequestError(errors.New("rows cannot be read from int fields"))
+	}
+
+	result := NewRow()
+	view := field.view(viewStandard)
+	if view == nil {
+		return result, nil
+	}
+	nodeID := api.Node().ID
+	for _, frag := range view.allFragments() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if !api.cluster.ownsShard(nodeID, indexName, frag.shard) {
+			continue
+		}
+
+		row := frag.row(rowIDs[0])
+		for _, rowID := range rowIDs[1:] {
+			if row.IsEmpty() {
+				break
+			}
+			row = row.Intersect(frag.row(rowID))
+		}
+		result.Merge(row)
+	}
+	return result, nil
+}
+
+// fragmentBlocksEqual returns true if a and b have the same block ids and
+// checksums.
+func fragmentBlocksEqual(a, b []FragmentBlock) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].ID != b[i].ID || !bytes.Equal(a[i].Checksum, b[i].Checksum) {
+			return false
+		}
+	}
+	return true
+}
+
+// BackupNode writes a tar archive of everything this node holds to w: the
+// schema, column and row attributes, every fragment and the key translation
+// data. The node remains online; each fragment is copied consistently but
+// writes made during the backup may appear in some fragments and not others.
+func (api *API) BackupNode(ctx context.Context, w io.Writer) error {
+	span, ctx := tracing.StartSpanFromContext(ctx, "API.BackupNode")
+	defer span.Finish()
+
+	if err := api.validate(apiBackupNode); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+
+	return errors.Wrap(api.holder.backup(ctx, w), "backing up holder")
+}
+
+// RestoreNode loads an archive written by BackupNode. Missing indexes, fields
+// and views are created from the archived schema and archived fragments
+// replace existing ones. Key translation data is only restored if this node
+// holds the primary translate store.
+func (api *API) RestoreNode(ctx context.Context, r io.Reader) error {
+	span, ctx := tracing.StartSpanFromContext(ctx, "API.RestoreNode")
+	defer span.Finish()
+
+	if err := api.validate(apiRestoreNode); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+
+	err := api.holder.restore(ctx, r)
+	for _, index := range api.holder.Indexes() {
+		index.touch()
+	}
+	return errors.Wrap(err, "restoring holder")
+}
+
+// BackupIndex writes a stream of length-prefixed records of an index held by
+// this node to w: its schema, column and row attributes, column and row keys,
+// and the marshaled fragments of the shards this node owns. Each fragment
+// record holds its field, view and shard, so that RestoreIndex can place it.
+// Returns the number of bytes written. The index remains online, as with
+// BackupNode.
+func (api *API) BackupIndex(ctx context.Context, indexName string, w io.Writer) (int64, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "API.BackupIndex")
+	defer span.Finish()
+
+	if err := api.validate(apiBackupIndex); err != nil {
+		return 0, errors.Wrap(err, "validating api method")
+	}
+
+	nodeID := api.Node().ID
+	n, err := api.holder.backupIndex(ctx, indexName, w, func(shard uint64) bool {
+		return api.cluster.ownsShard(nodeID, indexName, shard)
+	})
+	span.LogKV("n", n)
+	return n, errors.Wrap(err, "backing up index")
+}
+
+// RestoreIndex loads a stream written by BackupIndex into the named index,
+// which need not be the index it was written from. The index is created with
+// the backed up options if it does not exist, as are missing fields and views.
+// Keys keep their ids, so they match the restored fragments. Fragments in the
+// stream replace existing ones, except those of shards this node does not own,
+// which are skipped; the same stream can be restored on every node. An error
+// names the record, such as the fragment, which failed to load.
+func (api *API) RestoreIndex(ctx context.Context, indexName string, r io.Reader) error {
+	span, ctx := tracing.StartSpanFromContext(ctx, "API.RestoreIndex")
+	defer span.Finish()
+
+	if err := api.validate(apiRestoreIndex); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+
+	nodeID := api.Node().ID
+	err := api.holder.restoreIndex(ctx, indexName, r, func(shard uint64) bool {
+		return api.cluster.ownsShard(nodeID, indexName, shard)
+	})
+	if index := api.holder.Index(indexName); index != nil {
+		index.touch()
+	}
+	return errors.Wrap(err, "restoring index")
+}
+
+// FragmentData returns all data in the specified fragment.
+func (api *API) FragmentData(ctx context.Context, indexName, fieldName, viewName string, shard uint64) (io.WriterTo, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.FragmentData")
+	defer span.Finish()
+
+	if err := api.validate(apiFragmentData); err != nil {
+		return nil, errors.Wrap(err, "validating api method")
+	}
+
+	// Retrieve fragment from holder.
+	f := api.holder.fragment(indexName, fieldName, viewName, shard)
+	if f == nil {
+		return nil, ErrFragmentNotFound
+	}
+	return f, nil
+}
+
+// FragmentModTime returns the time the standard view fragment for the given
+// shard was last modified on disk.
+func (api *API) FragmentModTime(ctx context.Context, indexName, fieldName string, shard uint64) (time.Time, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.FragmentModTime")
+	defer span.Finish()
+
+	if err := api.validate(apiFragmentModTime); err != nil {
+		return time.Time{}, errors.Wrap(err, "validating api method")
+	}
+
+	f := api.holder.fragment(indexName, fieldName, viewStandard, shard)
+	if f == nil {
+		return time.Time{}, newNotFoundError(ErrFragmentNotFound)
+	}
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "statting fragment")
+	}
+	return fi.ModTime(), nil
+}
+
+// ContainerStats describes the roaring containers which store a fragment,
+// by container type.
+type ContainerStats struct {
+	Array  ContainerTypeStats `json:"array"`
+	Bitmap ContainerTypeStats `json:"bitmap"`
+	Run    ContainerTypeStats `json:"run"`
+}
+
+// ContainerTypeStats holds the number of containers of one type, the number
+// of bits set in them and the bytes allocated for them.
+type ContainerTypeStats struct {
+	Containers int   `json:"containers"`
+	Bits       int64 `json:"bits"`
+	Bytes      int64 `json:"bytes"`
+}
+
+// FragmentContainerStats returns the container composition of the standard
+// view fragment for the given shard on this node.
+func (api *API) FragmentContainerStats(ctx context.Context, indexName, fieldName string, shard uint64) (ContainerStats, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.FragmentContainerStats")
+	defer span.Finish()
+
+	if err := api.validate(apiFragmentContainerStats); err != nil {
+		return ContainerStats{}, errors.Wrap(err, "validating api method")
+	}
+
+	if api.holder.Field(indexName, fieldName) == nil {
+		return ContainerStats{}, newNotFoundError(ErrFieldNotFound)
+	}
+	f := api.holder.fragment(indexName, fieldName, viewStandard, shard)
+	if f == nil {
+		return ContainerStats{}, newNotFoundError(ErrFragmentNotFound)
+	}
+	return f.containerStats(), nil
+}
+
+// FragmentModTimes returns the time each standard view fragment of a field
+// was last modified on disk, keyed by shard.
+func (api *API) FragmentModTimes(ctx context.Context, indexName, fieldName string) (map[uint64]time.Time, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.FragmentModTimes")
+	defer span.Finish()
+
+	if err := api.validate(apiFragmentModTime); err != nil {
+		return nil, errors.Wrap(err, "validating api method")
+	}
+
+	field := api.holder.Field(indexName, fieldName)
+	if field == nil {
+		return nil, newNotFoundError(ErrFieldNotFound)
+	}
+
+	modTimes := make(map[uint64]time.Time)
+	view := field.view(viewStandard)
+	if view == nil {
+		return modTimes, nil
+	}
+	for _, f := range view.allFragments() {
+		fi, err := os.Stat(f.path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "statting fragment %d", f.shard)
+		}
+		modTimes[f.shard] = fi.ModTime()
+	}
+	return modTimes, nil
+}
+
+// ChangedShards returns, in ascending order, the shards of an index in which
+// any fragment on this node was written after since. Write times are kept in
+// memory; after a restart each fragment starts from the modification time of
+// its data file.
+func (api *API) ChangedShards(ctx context.Context, indexName string, since time.Time) ([]uint64, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.ChangedShards")
+	defer span.Finish()
+
+	if err := api.validate(apiChangedShards); err != nil {
+		return nil, errors.Wrap(err, "validating api method")
+	}
+
+	index := api.holder.Index(indexName)
+	if index == nil {
+		return nil, newNotFoundError(ErrIndexNotFound)
+	}
+
+	changed := roaring.NewBitmap()
+	for _, field := range index.Fields() {
+		for _, view := range field.views() {
+			for _, frag := range view.allFragments() {
+				if frag.lastModified().After(since) {
+					changed.Add(frag.shard) // ignore error, no writer attached
+				}
+			}
+		}
+	}
+	return changed.Slice(), nil
+}
+
+// CacheStaleness reports, for each shard of a field held by this node,
+// whether the TopN cache of any of its fragments ranks rows by counts which
+// have changed since the cache was last recalculated. Stale caches are
+// brought up to date by RecalculateCaches.
+func (api *API) CacheStaleness(ctx context.Context, indexName, fieldName string) (map[uint64]bool, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.CacheStaleness")
+	defer span.Finish()
+
+	if err := api.validate(apiCacheStaleness); err != nil {
+		return nil, errors.Wrap(err, "validating api method")
+	}
+
+	field := api.holder.Field(indexName, fieldName)
+	if field == nil {
+		if api.holder.Index(indexName) == nil {
+			return nil, newNotFoundError(ErrIndexNotFound)
+		}
+		return nil, newNotFoundError(ErrFieldNotFound)
+	}
+
+	stale := make(map[uint64]bool)
+	for _, view := range field.views() {
+		for _, frag := range view.allFragments() {
+			stale[frag.shard] = stale[frag.shard] || frag.cacheStale()
+		}
+	}
+	return stale, nil
+}
+
+// IndexBitCount returns the number of bits set in the fragments of an index
+// held by this node, across every field and view. Bits of time fields are
+// counted once in each view they are stored in, and int values count each
+// bit of their encoding. Callers aggregate the counts of every node, which
+// include replicas.
+func (api *API) IndexBitCount(ctx context.Context, indexName string) (uint64, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.IndexBitCount")
+	defer span.Finish()
+
+	if err := api.validate(apiIndexBitCount); err != nil {
+		return 0, errors.Wrap(err, "validating api method")
+	}
+
+	index := api.holder.Index(indexName)
+	if index == nil {
+		return 0, newNotFoundError(ErrIndexNotFound)
+	}
+
+	var n uint64
+	for _, field := range index.Fields() {
+		for _, view := range field.views() {
+			for _, frag := range view.allFragments() {
+				n += frag.bitCount()
+			}
+		}
+	}
+	return n, nil
+}
+
+// SnapshotInfo describes the fragment files of an index on disk, as returned
+// by API.SnapshotInfo. Oldest and Newest are zero if there are no files.
+type SnapshotInfo struct {
+	Oldest    time.Time `json:"oldest"`
+	Newest    time.Time `json:"newest"`
+	Bytes     int64     `json:"bytes"`
+	Fragments int       `json:"fragments"`
+}
+
+// SnapshotInfo returns the modification times and total size of the fragment
+// files of an index on this node. Oldest is the time of the least recently
+// written fragment, which bounds how stale a backup of the files may be.
+func (api *API) SnapshotInfo(ctx context.Context, indexName string) (SnapshotInfo, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.SnapshotInfo")
+	defer span.Finish()
+
+	if err := api.validate(apiSnapshotInfo); err != nil {
+		return SnapshotInfo{}, errors.Wrap(err, "validating api method")
+	}
+
+	index := api.holder.Index(indexName)
+	if index == nil {
+		return SnapshotInfo{}, newNotFoundError(ErrIndexNotFound)
+	}
+
+	var info SnapshotInfo
+	for _, field := range index.Fields() {
+		for _, view := range field.views() {
+			for _, f := range view.allFragments() {
+				fi, err := os.Stat(f.path)
+				if os.IsNotExist(err) {
+					continue
+				} else if err != nil {
+					return SnapshotInfo{}, errors.Wrapf(err, "statting fragment %s/%s/%d", field.Name(), view.name, f.shard)
+				}
+				if info.Fragments == 0 || fi.ModTime().Before(info.Oldest) {
+					info.Oldest = fi.ModTime()
+				}
+				if fi.ModTime().After(info.Newest) {
+					info.Newest = fi.ModTime()
+				}
+				info.Bytes += fi.Size()
+				info.Fragments++
+			}
+		}
+	}
+	return info, nil
+}
+
+// NodeManifest describes the schema and fragments held by a node, without
+// their data, as returned by API.Manifest.
+type NodeManifest struct {
+	NodeID    string             `json:"nodeID"`
+	Schema    []*IndexInfo       `json:"schema"`
+	Fragments []FragmentManifest `json:"fragments"`
+}
+
+// FragmentManifest describes a fragment by its block checksums and the size of
+// its file on disk.
+type FragmentManifest struct {
+	Index  string          `json:"index"`
+	Field  string          `json:"field"`
+	View   string          `json:"view"`
+	Shard  uint64          `json:"shard"`
+	Blocks []FragmentBlock `json:"blocks"`
+	Bytes  int64           `json:"bytes"`
+}
+
+// Manifest returns the schema of this node and the block checksums and size of
+// every fragment it holds, sorted by index, field, view and shard. Manifests
+// from replicas, or from the same node over time, can be compared to check
+// that data has not changed.
+func (api *API) Manifest(ctx context.Context) (NodeManifest, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.Manifest")
+	defer span.Finish()
+
+	if err := api.validate(apiManifest); err != nil {
+		return NodeManifest{}, errors.Wrap(err, "validating api method")
+	}
+
+	m := NodeManifest{
+		NodeID: api.Node().ID,
+		Schema: api.holder.Schema(),
+	}
+	for _, index := range api.holder.Indexes() {
+		for _, field := range index.Fields() {
+			for _, view := range field.views() {
+				for _, f := range view.allFragments() {
+					if err := ctx.Err(); err != nil {
+						return NodeManifest{}, err
+					}
+
+					var size int64
+					if fi, err := os.Stat(f.path); err == nil {
+						size = fi.Size()
+					} else if !os.IsNotExist(err) {
+						return NodeManifest{}, errors.Wrapf(err, "statting fragment %s/%s/%s/%d", index.Name(), field.Name(), view.name, f.shard)
+					}
+
+					m.Fragments = append(m.Fragments, FragmentManifest{
+						Index:  index.Name(),
+						Field:  field.Name(),
+						View:   view.name,
+						Shard:  f.shard,
+						Blocks: f.Blocks(),
+						Bytes:  size,
+					})
+				}
+			}
+		}
+	}
+
+	sort.Slice(m.Fragments, func(i, j int) bool {
+		a, b := m.Fragments[i], m.Fragments[j]
+		if a.Index != b.Index {
+			return a.Index < b.Index
+		} else if a.Field != b.Field {
+			return a.Field < b.Field
+		} else if a.View != b.View {
+			return a.View < b.View
+		}
+		return a.Shard < b.Shard
+	})
+	return m, nil
+}
+
+// IndexGeneration returns the generation of an index on this node, which
+// increases whenever its data changes through this node. Returns zero if
+// the index does not exist.
+func (api *API) IndexGeneration(ctx context.Context, indexName string) uint64 {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.IndexGeneration")
+	defer span.Finish()
+
+	index := api.holder.Index(indexName)
+	if index == nil {
+		return 0
+	}
+	return index.Generation()
+}
+
+// touchIndex increments the generation of the named index, if it exists, so
+// that query results cached before a change are no longer used.
+func (api *API) touchIndex(indexName string) {
+	if index := api.holder.Index(indexName); index != nil {
+		index.touch()
+	}
+}
+
+// PingCluster sends a no-op message to every other node through the
+// broadcaster and returns the result for each node by ID. A nil error means
+// the node received the message; this node is always reported as reachable.
+// If ctx is canceled first, nodes which have not replied are reported with
+// the context's error, which is also returned.
+func (api *API) PingCluster(ctx context.Context) (map[string]error, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.PingCluster")
+	defer span.Finish()
+
+	if err := api.validate(apiPingCluster); err != nil {
+		return nil, errors.Wrap(err, "validating api method")
+	}
+
+	type result struct {
+		id  string
+		err error
+	}
+	nodes := api.cluster.Nodes()
+	results := make(map[string]error, len(nodes))
+	ch := make(chan result, len(nodes))
+	for _, node := range nodes {
+		if node.ID == api.server.nodeID {
+			results[node.ID] = nil
+			continue
+		}
+		go func(node *Node) {
+			ch <- result{id: node.ID, err: api.server.SendTo(node, &PingMessage{})}
+		}(node)
+	}
+
+	for len(results) < len(nodes) {
+		select {
+		case r := <-ch:
+			results[r.id] = r.err
+		case <-ctx.Done():
+			for _, node := range nodes {
+				if _, ok := results[node.ID]; !ok {
+					results[node.ID] = ctx.Err()
+				}
+			}
+			return results, ctx.Err()
+		}
+	}
+	return results, nil
+}
+
+// VerifySchemaConsistency gathers the schema held by each node in the cluster
+// and reports the indexes and fields which some nodes are missing. Keys are
+// index names, or "index/field" for fields of an index, and values are the
+// sorted IDs of the nodes missing them. The map is empty if every node holds
+// the same indexes and fields.
+func (api *API) VerifySchemaConsistency(ctx context.Context) (map[string][]string, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "API.VerifySchemaConsistency")
+	defer span.Finish()
+
+	if err := api.validate(apiVerifySchemaConsistency); err != nil {
+		return nil, errors.Wrap(err, "validating api method")
+	}
+
+	type result struct {
+		id     string
+		schema []*IndexInfo
+		err    error
+	}
+	nodes := api.cluster.Nodes()
+	ch := make(chan result, len(nodes))
+	for _, node := range nodes {
+		if node.ID == api.server.nodeID {
+			ch <- result{id: node.ID, schema: api.holder.limitedSchema()}
+			continue
+		}
+		go func(node *Node) {
+			schema, err := api.server.defaultClient.SchemaNode(ctx, &node.URI)
+			ch <- result{id: node.ID, schema: schema, err: err}
+		}(node)
+	}
+
+	schemas := make(map[string][]*IndexInfo, len(nodes))
+	for range nodes {
+		select {
+		case r := <-ch:
+			if r.err != nil {
+				return nil, errors.Wrapf(r.err, "getting schema from node %s", r.id)
+			}
+			schemas[r.id] = r.schema
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return schemaDiscrepancies(schemas), nil
+}
+
+// schemaDiscrepancies returns the sorted IDs of the nodes missing each index
+// or field held by any node, given the schema of each node by ID.
+func schemaDiscrepancies(schemas map[string][]*IndexInfo) map[string][]string {
+	holders := make(map[string]map[string]struct{})
+	hold := func(key, id string) {
+		if holders[key] == nil {
+			holders[key] = make(map[string]struct{})
+		}
+		holders[key][id] = struct{}{}
+	}
+	for id, schema := range schemas {
+		for _, ii := range schema {
+			hold(ii.Name, id)
+			for _, fi := range ii.Fields {
+				hold(ii.Name+"/"+fi.Name, id)
+			}
+		}
+	}
+
+	missing := make(map[string][]string)
+	for key, ids := range holders {
+		for id := range schemas {
+			if _, ok := ids[id]; !ok {
+				missing[key] = append(missing[key], id)
+			}
+		}
+		sort.Strings(missing[key])
+	}
+	return missing
+}
+
+// Hosts returns a list of the hosts in the cluster including their ID,
+// URL, and which is the coordinator.
+func (api *API) Hosts(ctx context.Context) []*Node {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.Hosts")
+	defer span.Finish()
+	return api.cluster.Nodes()
+}
+
+// ClusterMembershipView returns this node's view of the cluster: the nodes it
+// believes are members, the coordinator, and the cluster state. Comparing the
+// views reported by every node can be used to detect a split cluster.
+func (api *API) ClusterMembershipView(ctx context.Context) (ClusterView, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.ClusterMembershipView")
+	defer span.Finish()
+	return api.cluster.membershipView(), nil
+}
+
+// Coordinator returns the ID of the coordinator node along with the election
+// epoch in which it became coordinator. The epoch increases each time the
+// coordinator changes, so a client can discard a coordinator it learned about
+// in an earlier epoch.
+func (api *API) Coordinator(ctx context.Context) (nodeID string, epoch uint64, err error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.Coordinator")
+	defer span.Finish()
+
+	if err := api.validate(apiCoordinator); err != nil {
+		return "", 0, errors.Wrap(err, "validating api method")
+	}
+
+	nodeID, epoch = api.cluster.coordinatorWithEpoch()
+	return nodeID, epoch, nil
+}
+
+// Node gets the ID, URI and coordinator status for this particular node.
+func (api *API) Node() *Node {
+	node := api.server.node()
+	return &node
+}
+
+// SetMaxCacheBytes sets the approximate number of bytes all TopN caches on
+// this node may use. Caches of the least recently used fields are evicted
+// when the limit is exceeded. Zero or less removes the limit.
+func (api *API) SetMaxCacheBytes(n int64) {
+	api.holder.SetMaxTotalCacheBytes(n)
+}
+
+// SetPrefetch enables or disables reading ahead during exports. When enabled,
+// exporting a shard also reads the node's next fragment of the field in the
+// background, which speeds up exports which walk shards in order on slow
+// disks.
+func (api *API) SetPrefetch(v bool) {
+	api.holder.SetPrefetch(v)
+}
+
+// SetSnapshotCompressionLevel sets the gzip level used when this node
+// snapshots fragments of fields with gzip compression, from 1 for the least
+// CPU to 9 for the smallest files. Zero restores the default level.
+func (api *API) SetSnapshotCompressionLevel(ctx context.Context, n int) error {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.SetSnapshotCompressionLevel")
+	defer span.Finish()
+
+	if err := api.validate(apiSetSnapshotCompressionLevel); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+
+	if err := api.holder.SetSnapshotCompressionLevel(n); err != nil {
+		return NewBadRequestError(err)
+	}
+	return nil
+}
+
+// SetMaxFieldsPerIndex sets the maximum number of fields which may be created
+// in each index on this node. Zero or less removes the limit.
+func (api *API) SetMaxFieldsPerIndex(n int) {
+	api.holder.SetMaxFieldsPerIndex(n)
+}
+
+// CacheBytes returns the approximate number of bytes used by all TopN caches
+// on this node.
+func (api *API) CacheBytes() int64 {
+	return api.holder.cacheBytes()
+}
+
+// SetStatsClient replaces the stats client used by this node without a
+// restart. Indexes, fields and fragments are switched to clients derived from
+// sc with their usual tags. The previous client is left open; the caller may
+// close it once in-flight requests have finished.
+func (api *API) SetStatsClient(sc stats.StatsClient) error {
+	if sc == nil {
+		return NewBadRequestError(errors.New("stats client required"))
+	}
+	api.server.setStatsClient(sc)
+	return nil
+}
+
+// SetStatsSampleRate overrides the sample rate of stats named category, such
+// as "setBit" or "ImportBit", sent by this node. A rate of zero removes the
+// override so the stat is sampled at its default rate again. The override
+// also applies to clients installed later with SetStatsClient.
+func (api *API) SetStatsSampleRate(ctx context.Context, category string, rate float64) error {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.SetStatsSampleRate")
+	defer span.Finish()
+
+	if err := api.validate(apiSetStatsSampleRate); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+
+	if category == "" {
+		return NewBadRequestError(errors.New("stats category required"))
+	} else if rate < 0 || rate > 1 {
+		return NewBadRequestError(errors.Errorf("sample rate must be between 0 and 1: %v", rate))
+	}
+	api.server.statsSampleRates.Set(category, rate)
+	return nil
+}
+
+// SetLogLevel sets how much is logged by a subsystem of this node, one of
+// LogSubsystemBroadcast, LogSubsystemCluster, LogSubsystemImport or
+// LogSubsystemQuery. The level is one of LogLevelDebug, LogLevelInfo or
+// LogLevelNone and applies immediately.
+func (api *API) SetLogLevel(ctx context.Context, subsystem, level string) error {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.SetLogLevel")
+	defer span.Finish()
+
+	if err := api.validate(apiSetLogLevel); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+
+	if err := api.server.logLevels.set(subsystem, level); err != nil {
+		return NewBadRequestError(err)
+	}
+	return nil
+}
+
+// LogLevel returns the log level of a subsystem of this node.
+func (api *API) LogLevel(ctx context.Context, subsystem string) (string, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.LogLevel")
+	defer span.Finish()
+
+	if err := api.validate(apiLogLevel); err != nil {
+		return "", errors.Wrap(err, "validating api method")
+	}
+
+	return api.server.logLevels.level(subsystem).String(), nil
+}
+
+// PauseQueries pauses queries on every node in the cluster. New queries and
+// imports block until ResumeQueries is called or their context is done, while
+// those which are already running complete normally. The pause is held in
+// memory only: a node which restarts while queries are paused does not hold
+// them until PauseQueries is called again.
+func (api *API) PauseQueries(ctx context.Context) error {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.PauseQueries")
+	defer span.Finish()
+
+	if err := api.validate(apiPauseQueries); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+
+	api.server.queryPause.pause()
+	if err := api.server.SendSync(&PauseQueriesMessage{Paused: true}); err != nil {
+		return errors.Wrap(err, "broadcasting query pause")
+	}
+	return nil
+}
+
+// waitImportResume blocks while queries are paused. Imports forwarded by
+// other nodes belong to imports which were already running, so they proceed.
+func (api *API) waitImportResume(ctx context.Context) error {
+	if isInternalRequest(ctx) {
+		return nil
+	}
+	return errors.Wrap(api.server.queryPause.wait(ctx), "waiting for imports to resume")
+}
+
+// ResumeQueries resumes queries on every node in the cluster after
+// PauseQueries.
+func (api *API) ResumeQueries(ctx context.Context) error {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.ResumeQueries")
+	defer span.Finish()
+
+	if err := api.validate(apiResumeQueries); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+
+	api.server.queryPause.resume()
+	if err := api.server.SendSync(&PauseQueriesMessage{Paused: false}); err != nil {
+		return errors.Wrap(err, "broadcasting query resume")
+	}
+	return nil
+}
+
+// RecalculateCaches forces all TopN caches to be updated. Used mainly for integration tests.
+func (api *API) RecalculateCaches(ctx context.Context) error {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.RecalculateCaches")
+	defer span.Finish()
+
+	if err := api.validate(apiRecalculateCaches); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+
+	err := api.server.SendSync(&RecalculateCaches{})
+	if err != nil {
+		return errors.Wrap(err, "broacasting message")
+	}
+	api.holder.recalculateCaches()
+	return nil
+}
+
+// PostClusterMessage is for internal use. It decodes a protobuf message out of
+// the body and forwards it to the BroadcastHandler.
+func (api *API) ClusterMessage(ctx context.Context, reqBody io.Reader) error {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.ClusterMessage")
+	defer span.Finish()
+
+	if err := api.validate(apiClusterMessage); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+
+	// Read entire body.
+	body, err := ioutil.ReadAll(reqBody)
+	if err != nil {
+		return errors.Wrap(err, "reading body")
+	}
+
+	typ := body[0]
+	msg := getMessage(typ)
+	err = api.server.serializer.Unmarshal(body[1:], msg)
+	if err != nil {
+		return errors.Wrap(err, "deserializing cluster message")
+	}
+
+	// Forward the error message.
+	if err := api.server.receiveMessage(msg); err != nil {
+		return errors.Wrap(err, "receiving message")
+	}
+	return nil
+}
+
+// Schema returns information about each index in Pilosa including which fields
+// they contain.
+func (api *API) Schema(ctx context.Context) []*IndexInfo {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.Schema")
+	defer span.Finish()
+	return api.holder.limitedSchema()
+}
+
+// Views returns the views in the given field.
+func (api *API) Views(ctx context.Context, indexName string, fieldName string) ([]*view, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.Views")
 	defer span.Finish()
 
 	if err := api.validate(apiViews); err != nil {
 		return nil, errors.Wrap(err, "validating api method")
 	}
 
-	// Retrieve views.
+	// Retrieve views.
+	f := api.holder.Field(indexName, fieldName)
+	if f == nil {
+		return nil, ErrFieldNotFound
+	}
+
+	// Fetch views.
+	views := f.views()
+	return views, nil
+}
+
+// DeleteView removes the given view.
+func (api *API) DeleteView(ctx context.Context, indexName string, fieldName string, viewName string) error {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.DeleteView")
+	defer span.Finish()
+
+	if err := api.validate(apiDeleteView); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+
+	defer api.touchIndex(indexName)
+
+	// Retrieve field.
+	f := api.holder.Field(indexName, fieldName)
+	if f == nil {
+		return ErrFieldNotFound
+	}
+
+	// Delete the view.
+	if err := f.deleteView(viewName); err != nil {
+		// Ignore this error because views do not exist on all nodes due to shard distribution.
+		if err != ErrInvalidView {
+			return errors.Wrap(err, "deleting view")
+		}
+	}
+
+	// Send the delete view message to all nodes.
+	err := api.server.SendSync(
+		&DeleteViewMessage{
+			Index: indexName,
+			Field: fieldName,
+			View:  viewName,
+		})
+	if err != nil {
+		api.server.subsystemLogger(LogSubsystemBroadcast).Printf("problem sending DeleteView message: %s", err)
+	}
+
+	return errors.Wrap(err, "sending DeleteView message")
+}
+
+// DeleteViews removes the named views from a field and sends a single delete
+// message for all of them to the other nodes. Returns the views which were
+// removed from this node; views are not present on every node because of
+// shard distribution.
+func (api *API) DeleteViews(ctx context.Context, indexName, fieldName string, viewNames []string) ([]string, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.DeleteViews")
+	defer span.Finish()
+
+	if err := api.validate(apiDeleteViews); err != nil {
+		return nil, errors.Wrap(err, "validating api method")
+	}
+
+	defer api.touchIndex(indexName)
+
+	// Retrieve field.
+	f := api.holder.Field(indexName, fieldName)
+	if f == nil {
+		return nil, newNotFoundError(ErrFieldNotFound)
+	}
+	return api.deleteViews(f, viewNames)
+}
+
+// DeleteViewsMatching removes the views of a field whose names match pattern
+// and sends a single delete message for all of them to the other nodes. The
+// pattern has the syntax of path.Match, so the views of a time field for
+// January 2019 match "standard_201901*". Returns the views which were removed.
+func (api *API) DeleteViewsMatching(ctx context.Context, indexName, fieldName string, pattern string) ([]string, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.DeleteViewsMatching")
+	defer span.Finish()
+
+	if err := api.validate(apiDeleteViewsMatching); err != nil {
+		return nil, errors.Wrap(err, "validating api method")
+	}
+
+	defer api.touchIndex(indexName)
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, NewBadRequestError(errors.Wrapf(err, "invalid view pattern %q", pattern))
+	}
+
+	// Retrieve field.
 	f := api.holder.Field(indexName, fieldName)
 	if f == nil {
-		return nil, ErrFieldNotFound
+		return nil, newNotFoundError(ErrFieldNotFound)
 	}
 
-	// Fetch views.
-	views := f.views()
-	return views, nil
+	var viewNames []string
+	for _, v := range f.views() {
+		if ok, _ := path.Match(pattern, v.name); ok {
+			viewNames = append(viewNames, v.name)
+		}
+	}
+	sort.Strings(viewNames)
+	return api.deleteViews(f, viewNames)
 }
 
-// DeleteView removes the given view.
-func (api *API) DeleteView(ctx context.Context, indexName string, fieldName string, viewName string) error {
-	span, _ := tracing.StartSpanFromContext(ctx, "API.DeleteView")
+// deleteViews removes the named views from f and sends a single delete
+// message for them to the other nodes. Views missing from this node are
+// skipped, and the views which were removed are returned.
+func (api *API) deleteViews(f *Field, viewNames []string) ([]string, error) {
+	if len(viewNames) == 0 {
+		return nil, nil
+	}
+
+	var removed []string
+	for _, name := range viewNames {
+		if err := f.deleteView(name); err == ErrInvalidView {
+			continue
+		} else if err != nil {
+			return removed, errors.Wrapf(err, "deleting view %s", name)
+		}
+		removed = append(removed, name)
+	}
+
+	// Send the delete views message to all nodes.
+	if err := api.server.SendSync(&DeleteViewsMessage{
+		Index: f.Index(),
+		Field: f.Name(),
+		Views: viewNames,
+	}); err != nil {
+		return removed, errors.Wrap(err, "sending DeleteViews message")
+	}
+	return removed, nil
+}
+
+// SetIndexQueryRateLimit limits the queries to an index which this node
+// receives to qps per second, allowing bursts of up to qps queries. Queries
+// over the limit fail with ErrRateLimited. A limit of zero removes the limit.
+// Limits are not persisted or shared with other nodes, and are removed when
+// the index is deleted.
+func (api *API) SetIndexQueryRateLimit(ctx context.Context, indexName string, qps int) error {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.SetIndexQueryRateLimit")
 	defer span.Finish()
 
-	if err := api.validate(apiDeleteView); err != nil {
+	if err := api.validate(apiSetIndexQueryRateLimit); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+
+	if qps < 0 {
+		return NewBadRequestError(errors.New("query rate limit must not be negative"))
+	} else if api.holder.Index(indexName) == nil {
+		return newNotFoundError(ErrIndexNotFound)
+	}
+	api.server.queryRateLimits.set(indexName, qps)
+	return nil
+}
+
+// AccountUsage returns the cost of the queries this node has received for an
+// account, as set by QueryRequest.AccountID, since the node started or the
+// account's usage was last reset. Usage is kept in memory and is not shared
+// with other nodes, so the usage of an account across the cluster is the sum
+// of its usage on each node which received its queries.
+func (api *API) AccountUsage(ctx context.Context, accountID string) (Usage, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.AccountUsage")
+	defer span.Finish()
+
+	if err := api.validate(apiAccountUsage); err != nil {
+		return Usage{}, errors.Wrap(err, "validating api method")
+	}
+
+	if accountID == "" {
+		return Usage{}, NewBadRequestError(errors.New("account id required"))
+	}
+	return api.holder.accountUsage.get(accountID), nil
+}
+
+// ResetAccountUsage clears the usage recorded for an account on this node, or
+// for every account if accountID is empty.
+func (api *API) ResetAccountUsage(ctx context.Context, accountID string) error {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.ResetAccountUsage")
+	defer span.Finish()
+
+	if err := api.validate(apiResetAccountUsage); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+
+	api.holder.accountUsage.reset(accountID)
+	return nil
+}
+
+// RemapRows moves the bits of each source row in mapping to its target row in
+// every view of the field, ORing them into the target and clearing the source.
+// Rows are moved at once, so mapping may swap rows. Each node remaps the shards
+// it owns. Row attributes are not moved.
+func (api *API) RemapRows(ctx context.Context, indexName, fieldName string, mapping map[uint64]uint64) error {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.RemapRows")
+	defer span.Finish()
+
+	if err := api.validate(apiRemapRows); err != nil {
 		return errors.Wrap(err, "validating api method")
 	}
 
-	// Retrieve field.
 	f := api.holder.Field(indexName, fieldName)
 	if f == nil {
-		return ErrFieldNotFound
+		return newNotFoundError(ErrFieldNotFound)
+	}
+	switch f.Type() {
+	case FieldTypeSet, FieldTypeTime, FieldTypeMutex:
+	default:
+		return NewBadRequestError(errors.Errorf("cannot remap rows of %s field", f.Type()))
+	}
+	if len(mapping) == 0 {
+		return nil
 	}
 
-	// Delete the view.
-	if err := f.deleteView(viewName); err != nil {
-		// Ignore this error because views do not exist on all nodes due to shard distribution.
-		if err != ErrInvalidView {
-			return errors.Wrap(err, "deleting view")
+	defer api.touchIndex(indexName)
+
+	nodeID := api.Node().ID
+	if err := f.remapRows(mapping, func(shard uint64) bool {
+		return api.cluster.ownsShard(nodeID, indexName, shard)
+	}); err != nil {
+		return errors.Wrap(err, "remapping local rows")
+	}
+
+	// Send the remap rows message to all nodes.
+	if err := api.server.SendSync(&RemapRowsMessage{
+		Index:   indexName,
+		Field:   fieldName,
+		Mapping: mapping,
+	}); err != nil {
+		return errors.Wrap(err, "sending RemapRows message")
+	}
+	return nil
+}
+
+// MergeIndexesOptions holds the options for the API.MergeIndexes method.
+type MergeIndexesOptions struct {
+	// If true, the source index is deleted once it has been merged.
+	DeleteSource bool
+}
+
+// MergeIndexesOption is a functional option type for API.MergeIndexes.
+type MergeIndexesOption func(*MergeIndexesOptions) error
+
+func OptMergeIndexesDeleteSource(b bool) MergeIndexesOption {
+	return func(o *MergeIndexesOptions) error {
+		o.DeleteSource = b
+		return nil
+	}
+}
+
+// MergeIndexes imports the data of every field of the source index into the
+// target index, adding columnOffset to each column. Fields missing from the
+// target are created with the source field's options. Set, mutex and bool
+// fields are merged from their standard view, time fields also from their
+// finest time views, and int fields by value. Bits are read from the owners
+// of each source shard and imported into the owners of the shard the column
+// is moved to. Attributes are not merged. Indexes and fields with keys cannot
+// be merged, since their ids are only meaningful within their own index.
+func (api *API) MergeIndexes(ctx context.Context, targetIndex, sourceIndex string, columnOffset uint64, opts ...MergeIndexesOption) error {
+	span, ctx := tracing.StartSpanFromContext(ctx, "API.MergeIndexes")
+	defer span.Finish()
+
+	if err := api.validate(apiMergeIndexes); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+
+	options := MergeIndexesOptions{}
+	for _, opt := range opts {
+		if err := opt(&options); err != nil {
+			return errors.Wrap(err, "applying option")
 		}
 	}
 
-	// Send the delete view message to all nodes.
-	err := api.server.SendSync(
-		&DeleteViewMessage{
-			Index: indexName,
-			Field: fieldName,
-			View:  viewName,
+	target, source := api.holder.Index(targetIndex), api.holder.Index(sourceIndex)
+	if target == nil || source == nil {
+		return newNotFoundError(ErrIndexNotFound)
+	} else if targetIndex == sourceIndex {
+		return NewBadRequestError(errors.New("cannot merge an index into itself"))
+	} else if target.Keys() || source.Keys() {
+		return NewBadRequestError(errors.New("cannot merge indexes with keys"))
+	}
+
+	// Check every field before anything is written.
+	var fields []*Field
+	for _, f := range source.Fields() {
+		if f.keys() {
+			return NewBadRequestError(errors.Errorf("cannot merge field with keys: %s", f.Name()))
+		}
+		if tf := target.Field(f.Name()); tf != nil {
+			if tf.Type() != f.Type() {
+				return NewBadRequestError(errors.Errorf("field %s is a %s field in %s but a %s field in %s", f.Name(), tf.Type(), targetIndex, f.Type(), sourceIndex))
+			} else if tf.keys() {
+				return NewBadRequestError(errors.Errorf("cannot merge field with keys: %s", f.Name()))
+			}
+		} else if f.Name() == existenceFieldName {
+			// The target does not track existence.
+			continue
+		}
+		fields = append(fields, f)
+	}
+
+	defer api.touchIndex(targetIndex)
+
+	shards := source.AvailableShards().Slice()
+	for _, f := range fields {
+		if target.Field(f.Name()) == nil {
+			opt := f.Options()
+			if _, err := target.createFieldIfNotExists(f.Name(), opt); err != nil {
+				return errors.Wrapf(err, "creating field %s", f.Name())
+			}
+			if err := api.server.SendSync(&CreateFieldMessage{
+				Index: targetIndex,
+				Field: f.Name(),
+				Meta:  &opt,
+			}); err != nil {
+				return errors.Wrap(err, "sending CreateField message")
+			}
+		}
+
+		var err error
+		if f.Type() == FieldTypeInt {
+			err = api.mergeFieldValues(ctx, target.Name(), source.Name(), f, shards, columnOffset)
+		} else {
+			err = api.mergeFieldBits(ctx, target.Name(), source.Name(), f, shards, columnOffset)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "merging field %s", f.Name())
+		}
+	}
+
+	if options.DeleteSource {
+		if err := api.DeleteIndex(ctx, sourceIndex); err != nil {
+			return errors.Wrap(err, "deleting source index")
+		}
+	}
+	return nil
+}
+
+// mergeFieldBits imports the bits of f's standard view and finest time views
+// into the field of the same name in the target index.
+func (api *API) mergeFieldBits(ctx context.Context, target, source string, f *Field, shards []uint64, columnOffset uint64) error {
+	// Bits in coarser time views are also in the finest ones.
+	views := map[string]int64{viewStandard: 0}
+	if q := f.TimeQuantum(); q != "" {
+		unit := rune(q[len(q)-1])
+		for _, v := range f.views() {
+			if t, ok := viewTimeStart(viewStandard, v.name); ok && viewByTimeUnit(viewStandard, t, unit) == v.name {
+				views[v.name] = t.UnixNano()
+			}
+		}
+	}
+
+	for viewName, timestamp := range views {
+		for _, shard := range shards {
+			bm, err := api.shardBitmap(ctx, source, f.Name(), viewName, shard)
+			if err != nil {
+				return errors.Wrapf(err, "reading view %s shard %d", viewName, shard)
+			}
+
+			bits := make(map[uint64][]Bit)
+			bm.ForEach(func(i uint64) {
+				columnID := shard*ShardWidth + i%ShardWidth + columnOffset
+				bits[columnID/ShardWidth] = append(bits[columnID/ShardWidth], Bit{
+					RowID:     i / ShardWidth,
+					ColumnID:  columnID,
+					Timestamp: timestamp,
+				})
+			})
+			for targetShard, a := range bits {
+				if err := api.server.defaultClient.Import(ctx, target, f.Name(), targetShard, a); err != nil {
+					return errors.Wrapf(err, "importing shard %d", targetShard)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// mergeFieldValues imports the values of the int field f into the field of
+// the same name in the target index.
+func (api *API) mergeFieldValues(ctx context.Context, target, source string, f *Field, shards []uint64, columnOffset uint64) error {
+	bsig := f.bsiGroup(f.Name())
+	if bsig == nil {
+		return ErrBSIGroupNotFound
+	}
+	bitDepth := uint64(bsig.BitDepth())
+
+	for _, shard := range shards {
+		bm, err := api.shardBitmap(ctx, source, f.Name(), viewBSIGroupPrefix+f.Name(), shard)
+		if err != nil {
+			return errors.Wrapf(err, "reading shard %d", shard)
+		}
+
+		// Bits are visited by row, so every value bit of a column is seen
+		// before its existence bit.
+		values := make(map[uint64]uint64)
+		vals := make(map[uint64][]FieldValue)
+		bm.ForEach(func(i uint64) {
+			rowID, columnID := i/ShardWidth, shard*ShardWidth+i%ShardWidth
+			if rowID < bitDepth {
+				values[columnID] |= 1 << rowID
+			} else if rowID == bitDepth {
+				targetID := columnID + columnOffset
+				vals[targetID/ShardWidth] = append(vals[targetID/ShardWidth], FieldValue{
+					ColumnID: targetID,
+					Value:    int64(values[columnID]) + bsig.Min,
+				})
+			}
 		})
-	if err != nil {
-		api.server.logger.Printf("problem sending DeleteView message: %s", err)
+		for targetShard, a := range vals {
+			if err := api.server.defaultClient.ImportValue(ctx, target, f.Name(), targetShard, a); err != nil {
+				return errors.Wrapf(err, "importing shard %d", targetShard)
+			}
+		}
+	}
+	return nil
+}
+
+// shardBitmap returns a copy of the bitmap of a fragment, read from this node
+// if it owns the shard or from the shard's primary owner otherwise. Returns
+// an empty bitmap if the fragment does not exist.
+func (api *API) shardBitmap(ctx context.Context, indexName, fieldName, viewName string, shard uint64) (*roaring.Bitmap, error) {
+	if api.cluster.ownsShard(api.Node().ID, indexName, shard) {
+		if f := api.holder.fragment(indexName, fieldName, viewName, shard); f != nil {
+			return f.cloneStorage(), nil
+		}
+		return roaring.NewBitmap(), nil
 	}
 
-	return errors.Wrap(err, "sending DeleteView message")
+	nodes := api.cluster.shardNodes(indexName, shard)
+	if len(nodes) == 0 {
+		return nil, ErrClusterDoesNotOwnShard
+	}
+	rd, err := api.server.defaultClient.RetrieveShardFromURI(ctx, indexName, fieldName, viewName, shard, nodes[0].URI)
+	if err == ErrFragmentNotFound {
+		return roaring.NewBitmap(), nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "retrieving shard")
+	}
+	defer rd.Close()
+	return readArchiveStorage(rd)
+}
+
+// OpenFileCount returns the number of files the named index has open for its
+// fragments and attribute stores. If indexName is empty, it returns the
+// number of files open for all indexes and the key translation store.
+func (api *API) OpenFileCount(ctx context.Context, indexName string) (int, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.OpenFileCount")
+	defer span.Finish()
+
+	if err := api.validate(apiOpenFileCount); err != nil {
+		return 0, errors.Wrap(err, "validating api method")
+	}
+
+	if indexName == "" {
+		return api.holder.openFileCount(), nil
+	}
+
+	index := api.holder.Index(indexName)
+	if index == nil {
+		return 0, newNotFoundError(ErrIndexNotFound)
+	}
+	return index.openFileCount(), nil
+}
+
+// CompactTranslateStore rewrites the key translation store without the
+// mappings which are no longer referenced. Existing key/ID assignments are
+// not changed. The store is shared by all indexes, so compacting it for one
+// keyed index compacts it for all of them. Nodes which replicate the store
+// copy it again from the start once it is compacted.
+func (api *API) CompactTranslateStore(ctx context.Context, indexName string) error {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.CompactTranslateStore")
+	defer span.Finish()
+
+	if err := api.validate(apiCompactTranslateStore); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+
+	if api.holder.Index(indexName) == nil {
+		return newNotFoundError(ErrIndexNotFound)
+	}
+
+	switch err := api.holder.translateFile.Compact(); errors.Cause(err) {
+	case nil:
+		return nil
+	case ErrTranslateStoreReadOnly:
+		return newConflictError(err)
+	default:
+		return errors.Wrap(err, "compacting translate store")
+	}
+}
+
+// PruneTimeViews deletes the time quantum views of a field whose time bucket
+// ends at or before the given time, and returns the names of the deleted
+// views. The standard view is never deleted.
+func (api *API) PruneTimeViews(ctx context.Context, indexName string, fieldName string, before time.Time) ([]string, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.PruneTimeViews")
+	defer span.Finish()
+
+	if err := api.validate(apiPruneTimeViews); err != nil {
+		return nil, errors.Wrap(err, "validating api method")
+	}
+
+	defer api.touchIndex(indexName)
+
+	// Retrieve field.
+	f := api.holder.Field(indexName, fieldName)
+	if f == nil {
+		return nil, newNotFoundError(ErrFieldNotFound)
+	}
+
+	var pruned []string
+	for _, v := range f.views() {
+		end, ok := viewTimeEnd(viewStandard, v.name)
+		if !ok || end.After(before) {
+			continue
+		}
+
+		if err := f.deleteView(v.name); err != nil && err != ErrInvalidView {
+			return pruned, errors.Wrapf(err, "deleting view %s", v.name)
+		}
+		pruned = append(pruned, v.name)
+
+		// Send the delete view message to all nodes.
+		if err := api.server.SendSync(&DeleteViewMessage{
+			Index: indexName,
+			Field: fieldName,
+			View:  v.name,
+		}); err != nil {
+			return pruned, errors.Wrap(err, "sending DeleteView message")
+		}
+	}
+	sort.Strings(pruned)
+	return pruned, nil
 }
 
 // IndexAttrDiff
@@ -732,12 +3444,114 @@ func (api *API) IndexAttrDiff(ctx context.Context, indexName string, blocks []At
 			return nil, errors.Wrap(err, "getting block")
 		}
 
-		// Copy to index-wide struct.
-		for k, v := range m {
-			attrs[k] = v
+		// Copy to index-wide struct.
+		for k, v := range m {
+			attrs[k] = v
+		}
+	}
+	return attrs, nil
+}
+
+// ColumnAttrsFiltered returns the attributes of each column in columnIDs,
+// limited to the given keys. Columns without any of the keys are omitted. If
+// keys is empty, all attributes are returned.
+func (api *API) ColumnAttrsFiltered(ctx context.Context, indexName string, columnIDs []uint64, keys []string) ([]*ColumnAttrSet, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.ColumnAttrsFiltered")
+	defer span.Finish()
+
+	if err := api.validate(apiColumnAttrsFiltered); err != nil {
+		return nil, errors.Wrap(err, "validating api method")
+	}
+
+	index := api.holder.Index(indexName)
+	if index == nil {
+		return nil, newNotFoundError(ErrIndexNotFound)
+	}
+
+	sets := make([]*ColumnAttrSet, 0, len(columnIDs))
+	for _, id := range columnIDs {
+		attrs, err := index.ColumnAttrStore().Attrs(id)
+		if err != nil {
+			return nil, errors.Wrap(err, "getting attrs")
+		}
+
+		if len(keys) > 0 {
+			filtered := make(map[string]interface{}, len(keys))
+			for _, k := range keys {
+				if v, ok := attrs[k]; ok {
+					filtered[k] = v
+				}
+			}
+			attrs = filtered
+		}
+		if len(attrs) == 0 {
+			continue
+		}
+		sets = append(sets, &ColumnAttrSet{ID: id, Attrs: attrs})
+	}
+	return sets, nil
+}
+
+// ExportAttrSchema returns the types of the column attributes in the named
+// index. Types registered on the index take precedence over types inferred
+// from the stored attributes.
+func (api *API) ExportAttrSchema(ctx context.Context, indexName string) (AttrSchema, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.ExportAttrSchema")
+	defer span.Finish()
+
+	if err := api.validate(apiExportAttrSchema); err != nil {
+		return nil, errors.Wrap(err, "validating api method")
+	}
+
+	index := api.holder.Index(indexName)
+	if index == nil {
+		return nil, newNotFoundError(ErrIndexNotFound)
+	}
+
+	schema, err := inferAttrSchema(index.ColumnAttrStore())
+	if err != nil {
+		return nil, errors.Wrap(err, "inferring attr schema")
+	}
+	for k, typ := range index.AttrSchema() {
+		schema[k] = typ
+	}
+	return schema, nil
+}
+
+// ImportAttrSchema registers the column attribute types in schema on the
+// named index across the cluster. Subsequent attribute values must match the
+// registered types.
+func (api *API) ImportAttrSchema(ctx context.Context, indexName string, schema AttrSchema) error {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.ImportAttrSchema")
+	defer span.Finish()
+
+	if err := api.validate(apiImportAttrSchema); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+
+	index := api.holder.Index(indexName)
+	if index == nil {
+		return newNotFoundError(ErrIndexNotFound)
+	}
+
+	if err := index.RegisterAttrSchema(schema); err != nil {
+		switch errors.Cause(err) {
+		case ErrInvalidAttrSchemaType:
+			return NewBadRequestError(err)
+		case ErrAttrSchemaConflict:
+			return newConflictError(err)
 		}
+		return errors.Wrap(err, "registering attr schema")
 	}
-	return attrs, nil
+
+	// Send the attr schema to all nodes.
+	if err := api.server.SendSync(&SetAttrSchemaMessage{
+		Index:  indexName,
+		Schema: schema,
+	}); err != nil {
+		return errors.Wrap(err, "sending SetAttrSchema message")
+	}
+	return nil
 }
 
 func (api *API) FieldAttrDiff(ctx context.Context, indexName string, fieldName string, blocks []AttrBlock) (map[uint64]map[string]interface{}, error) {
@@ -781,6 +3595,19 @@ func (api *API) FieldAttrDiff(ctx context.Context, indexName string, fieldName s
 type ImportOptions struct {
 	Clear          bool
 	IgnoreKeyCheck bool
+
+	// If true, imports into time fields fail if a timestamp is outside the
+	// field's time range. Otherwise the timestamp is imported as is.
+	RejectOutOfRange bool
+
+	// Sequence is the sequence number assigned to the import by the
+	// primary owner of its shard. Replicas apply imports to a shard in
+	// sequence order if the server orders imports.
+	Sequence uint64
+
+	// ID associates the import with an import registered by
+	// API.BeginImport, allowing it to be canceled with API.CancelImport.
+	ID string
 }
 
 // ImportOption is a functional option type for API.Import.
@@ -800,24 +3627,356 @@ func OptImportOptionsIgnoreKeyCheck(b bool) ImportOption {
 	}
 }
 
+func OptImportOptionsRejectOutOfRange(b bool) ImportOption {
+	return func(o *ImportOptions) error {
+		o.RejectOutOfRange = b
+		return nil
+	}
+}
+
+func OptImportOptionsSequence(seq uint64) ImportOption {
+	return func(o *ImportOptions) error {
+		o.Sequence = seq
+		return nil
+	}
+}
+
+func OptImportOptionsID(id string) ImportOption {
+	return func(o *ImportOptions) error {
+		o.ID = id
+		return nil
+	}
+}
+
+// importRegistrationTTL is how long an import registered with BeginImport
+// may go without receiving a batch before it expires and is canceled.
+const importRegistrationTTL = time.Hour
+
+// importRegistry holds the cancel functions of in-progress imports keyed by
+// import ID.
+type importRegistry struct {
+	mu      sync.Mutex
+	imports map[string]*registeredImport
+}
+
+type registeredImport struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// Batches of the import hold a read lock while they are imported, so
+	// that canceling the import can wait for them to finish or be undone.
+	batches sync.RWMutex
+
+	// Time the import was registered or last received a batch.
+	lastUsed time.Time
+}
+
+// register generates a new import ID and associates it with a cancelable
+// context.
+func (r *importRegistry) register() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.imports == nil {
+		r.imports = make(map[string]*registeredImport)
+	}
+	now := time.Now()
+	r.expire(now)
+
+	id := uuid.NewV4().String()
+	ctx, cancel := context.WithCancel(context.Background())
+	r.imports[id] = &registeredImport{ctx: ctx, cancel: cancel, lastUsed: now}
+	return id
+}
+
+// expire cancels and removes the imports which have not been used within
+// importRegistrationTTL of now. r.mu must be held.
+func (r *importRegistry) expire(now time.Time) {
+	for id, imp := range r.imports {
+		if now.Sub(imp.lastUsed) > importRegistrationTTL {
+			imp.cancel()
+			delete(r.imports, id)
+		}
+	}
+}
+
+// remove unregisters the import and returns it, or returns nil if the
+// import does not exist.
+func (r *importRegistry) remove(id string) *registeredImport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	imp := r.imports[id]
+	delete(r.imports, id)
+	return imp
+}
+
+// bind returns the import with the given ID and a context which is canceled
+// when either ctx is done or the import is canceled. The import's batches
+// lock is held for reading until the returned release function is called.
+func (r *importRegistry) bind(ctx context.Context, id string) (*registeredImport, context.Context, func(), error) {
+	r.mu.Lock()
+	now := time.Now()
+	r.expire(now)
+	imp := r.imports[id]
+	if imp != nil {
+		imp.lastUsed = now
+	}
+	r.mu.Unlock()
+	if imp == nil {
+		return nil, nil, nil, ErrImportNotFound
+	}
+
+	imp.batches.RLock()
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-imp.ctx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return imp, ctx, func() {
+		cancel()
+		imp.batches.RUnlock()
+	}, nil
+}
+
+// importBatch is a batch of an import registered with BeginImport. It keeps
+// a copy of each fragment the batch writes to, so that the batch can be
+// undone if the import is canceled while it is being written.
+type importBatch struct {
+	imp     *registeredImport
+	release func()
+
+	shard     uint64
+	columnIDs []uint64
+	prev      map[importBatchView]*roaring.Bitmap
+}
+
+type importBatchView struct {
+	field *Field
+	view  string
+}
+
+// track copies the data of the given views of f in shard before the batch
+// writes the columns to them. It does nothing if the batch is nil, which is
+// the case for imports not registered with BeginImport.
+func (b *importBatch) track(f *Field, shard uint64, columnIDs []uint64, views ...string) {
+	if b == nil || f == nil {
+		return
+	}
+	b.shard, b.columnIDs = shard, columnIDs
+	for _, name := range views {
+		key := importBatchView{field: f, view: name}
+		if _, ok := b.prev[key]; ok {
+			continue
+		}
+		bm := roaring.NewBitmap()
+		if v := f.view(name); v != nil {
+			if frag := v.Fragment(shard); frag != nil {
+				bm = frag.storageCopy()
+			}
+		}
+		b.prev[key] = bm
+	}
+}
+
+// undoIfCanceled returns ErrImportCanceled, after restoring the batch's
+// columns in every tracked view, if the import was canceled while the batch
+// was written.
+func (b *importBatch) undoIfCanceled() error {
+	if b == nil || b.imp.ctx.Err() == nil {
+		return nil
+	}
+	for key, prev := range b.prev {
+		v := key.field.view(key.view)
+		if v == nil {
+			continue
+		}
+		frag := v.Fragment(b.shard)
+		if frag == nil {
+			continue
+		}
+		if err := frag.revertColumns(prev, b.columnIDs); err != nil {
+			return errors.Wrapf(err, "undoing view %s of field %s", key.view, key.field.Name())
+		}
+	}
+	return ErrImportCanceled
+}
+
+// done releases the batch.
+func (b *importBatch) done() {
+	if b != nil {
+		b.release()
+	}
+}
+
+// RegisterImportTransform registers a transform which imports can reference
+// by name using ImportRequest.Transform. Returns ErrImportTransformExists if
+// the name is already in use, including by the built in "rowOffset" and
+// "rowModulo" transforms.
+func (api *API) RegisterImportTransform(ctx context.Context, name string, fn ImportTransform) error {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.RegisterImportTransform")
+	defer span.Finish()
+
+	if err := api.validate(apiRegisterImportTransform); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+
+	if err := api.importTransforms.register(name, fn); err == ErrImportTransformExists {
+		return newConflictError(err)
+	} else if err != nil {
+		return NewBadRequestError(err)
+	}
+	return nil
+}
+
+// BeginImport registers a new import and returns its ID. The ID should be
+// passed to each batch of the import using OptImportOptionsID, and released
+// with FinishImport once the import is complete.
+func (api *API) BeginImport(ctx context.Context) (string, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.BeginImport")
+	defer span.Finish()
+
+	if err := api.validate(apiImport); err != nil {
+		return "", errors.Wrap(err, "validating api method")
+	}
+
+	return api.imports.register(), nil
+}
+
+// FinishImport releases an import registered with BeginImport.
+func (api *API) FinishImport(ctx context.Context, id string) error {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.FinishImport")
+	defer span.Finish()
+
+	if err := api.validate(apiImport); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+
+	imp := api.imports.remove(id)
+	if imp == nil {
+		return newNotFoundError(ErrImportNotFound)
+	}
+	imp.cancel()
+	return nil
+}
+
+// CancelImport cancels an import registered with BeginImport. Batches of the
+// import which are being written when it is canceled are undone before
+// CancelImport returns, and subsequent batches using the same ID are
+// rejected. Batches which had already been imported are kept. Only batches
+// imported by this node are canceled; batches forwarded to other nodes are
+// not bound to the import.
+//
+// Imports which receive no batch for an hour expire and are canceled.
+func (api *API) CancelImport(ctx context.Context, id string) error {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.CancelImport")
+	defer span.Finish()
+
+	if err := api.validate(apiCancelImport); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+
+	imp := api.imports.remove(id)
+	if imp == nil {
+		return newNotFoundError(ErrImportNotFound)
+	}
+	imp.cancel()
+
+	// Wait for batches being written to finish or be undone.
+	imp.batches.Lock()
+	imp.batches.Unlock() // nolint: staticcheck
+	api.server.subsystemLogger(LogSubsystemImport).Printf("import canceled: id=%s", id)
+	return nil
+}
+
+// bindImport returns a context tied to the import named in options, if any,
+// and the batch being imported. The batch is nil if options name no import.
+func (api *API) bindImport(ctx context.Context, options *ImportOptions) (context.Context, *importBatch, error) {
+	if options.ID == "" {
+		return ctx, nil, nil
+	}
+	imp, ctx, release, err := api.imports.bind(ctx, options.ID)
+	if err != nil {
+		return nil, nil, newNotFoundError(err)
+	}
+	return ctx, &importBatch{imp: imp, release: release, prev: make(map[importBatchView]*roaring.Bitmap)}, nil
+}
+
+// importCanceled returns ErrImportCanceled if the import's context is done.
+func importCanceled(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ErrImportCanceled
+	default:
+		return nil
+	}
+}
+
 // Import bulk imports data into a particular index,field,shard.
 func (api *API) Import(ctx context.Context, req *ImportRequest, opts ...ImportOption) error {
-	span, _ := tracing.StartSpanFromContext(ctx, "API.Import")
+	_, err := api.ImportWithResponse(ctx, req, opts...)
+	return err
+}
+
+// ImportWithResponse imports like Import and returns the response sent to
+// clients. If req.ReportDuplicates is set, up to maxImportDuplicates of the
+// imported bits which were already set are returned. Duplicates are only
+// found for bits imported by this node, not for imports forwarded to other
+// nodes.
+func (api *API) ImportWithResponse(ctx context.Context, req *ImportRequest, opts ...ImportOption) (_ *ImportResponse, err error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.ImportWithResponse")
 	defer span.Finish()
 
 	if err := api.validate(apiImport); err != nil {
-		return errors.Wrap(err, "validating api method")
+		return nil, errors.Wrap(err, "validating api method")
+	}
+
+	if err := api.waitImportResume(ctx); err != nil {
+		return nil, err
+	}
+
+	defer api.touchIndex(req.Index)
+
+	// Verify the payload before keys are translated into the request.
+	if req.PayloadChecksum != nil && !bytes.Equal(req.PayloadChecksum, req.Checksum()) {
+		return nil, ErrImportChecksumMismatch
 	}
 
 	// Set up import options.
 	options, err := setUpImportOptions(opts...)
 	if err != nil {
-		return errors.Wrap(err, "setting up import options")
+		return nil, errors.Wrap(err, "setting up import options")
+	}
+
+	ctx, batch, err := api.bindImport(ctx, options)
+	if err != nil {
+		return nil, errors.Wrap(err, "binding import")
 	}
+	defer batch.done()
 
 	index, field, err := api.indexField(req.Index, req.Field, req.Shard)
 	if err != nil {
-		return errors.Wrap(err, "getting index and field")
+		return nil, errors.Wrap(err, "getting index and field")
+	}
+
+	// Imports forwarded from another node were authorized by that node.
+	if err := authorizeField(ctx, field, req.Principal); err != nil {
+		return nil, err
+	}
+
+	// Look up the int field which receives the weights, if any.
+	var weightField *Field
+	if req.WeightField != "" {
+		if weightField = index.Field(req.WeightField); weightField == nil {
+			return nil, newNotFoundError(ErrFieldNotFound)
+		} else if weightField.Type() != FieldTypeInt {
+			return nil, NewBadRequestError(errors.Errorf("weight field %s is not an int field", req.WeightField))
+		} else if err := authorizeField(ctx, weightField, req.Principal); err != nil {
+			return nil, err
+		} else if len(req.Weights) != len(req.ColumnIDs)+len(req.ColumnKeys) {
+			return nil, NewBadRequestError(errors.New("weights and columns must have the same length"))
+		}
 	}
 
 	// Unless explicitly ignoring key validation (meaning keys have been
@@ -827,38 +3986,72 @@ func (api *API) Import(ctx context.Context, req *ImportRequest, opts ...ImportOp
 		// Translate row keys.
 		if field.keys() {
 			if len(req.RowIDs) != 0 {
-				return errors.New("row ids cannot be used because field uses string keys")
+				return nil, NewBadRequestError(errors.New("row ids cannot be used because field uses string keys"))
 			}
-			if req.RowIDs, err = api.holder.translateFile.TranslateRowsToUint64(index.Name(), field.Name(), req.RowKeys); err != nil {
-				return errors.Wrap(err, "translating rows")
+			if len(field.Options().AllowedRows) > 0 {
+				// Rows are restricted, so don't create keys for new rows.
+				var missing []string
+				if req.RowIDs, missing = api.holder.translateFile.findRowKeys(index.Name(), field.Name(), req.RowKeys); len(missing) > 0 {
+					return nil, NewBadRequestError(errors.Errorf("row keys not allowed in field %s: %v", req.Field, missing))
+				}
+			} else if req.RowIDs, err = api.holder.translateFile.TranslateRowsToUint64(index.Name(), field.Name(), req.RowKeys); err != nil {
+				return nil, errors.Wrap(err, "translating rows")
 			}
+		} else if len(req.RowKeys) != 0 {
+			return nil, NewBadRequestError(errors.New("row keys cannot be used because field does not use string keys"))
 		}
 
 		// Translate column keys.
 		if index.Keys() {
 			if len(req.ColumnIDs) != 0 {
-				return errors.New("column ids cannot be used because index uses string keys")
+				return nil, NewBadRequestError(errors.New("column ids cannot be used because index uses string keys"))
 			}
 			if req.ColumnIDs, err = api.holder.translateFile.TranslateColumnsToUint64(index.Name(), req.ColumnKeys); err != nil {
-				return errors.Wrap(err, "translating columns")
+				return nil, errors.Wrap(err, "translating columns")
 			}
+		} else if len(req.ColumnKeys) != 0 {
+			return nil, NewBadRequestError(errors.New("column keys cannot be used because index does not use string keys"))
+		}
+	}
+
+	// Apply the requested transform to the translated IDs. Imports
+	// forwarded to other nodes carry the transformed IDs and no transform.
+	if req.Transform != "" {
+		if err := api.importTransforms.apply(req.Transform, req.RowIDs, req.ColumnIDs); err != nil {
+			return nil, err
 		}
+	}
+
+	// Reject rows which the field does not allow before any bits are
+	// imported or forwarded.
+	fieldOptions := field.Options()
+	if rows := fieldOptions.rowsNotAllowed(req.RowIDs); len(rows) > 0 {
+		return nil, NewBadRequestError(errors.Errorf("rows not allowed in field %s: %v", req.Field, rows))
+	}
 
+	if !options.IgnoreKeyCheck {
 		// For translated data, map the columnIDs to shards. If
 		// this node does not own the shard, forward to the node that does.
 		if index.Keys() || field.keys() {
+			if len(req.RowIDs) != len(req.ColumnIDs) {
+				return nil, NewBadRequestError(errors.New("rows and columns must have the same length"))
+			}
 			m := make(map[uint64][]Bit)
+			weights := make(map[uint64][]FieldValue)
 
 			for i, colID := range req.ColumnIDs {
 				shard := colID / ShardWidth
 				if _, ok := m[shard]; !ok {
 					m[shard] = make([]Bit, 0)
 				}
-				m[shard] = append(m[shard], Bit{
-					RowID:     req.RowIDs[i],
-					ColumnID:  colID,
-					Timestamp: req.Timestamps[i],
-				})
+				bit := Bit{RowID: req.RowIDs[i], ColumnID: colID}
+				if i < len(req.Timestamps) {
+					bit.Timestamp = req.Timestamps[i]
+				}
+				m[shard] = append(m[shard], bit)
+				if weightField != nil {
+					weights[shard] = append(weights[shard], FieldValue{ColumnID: colID, Value: req.Weights[i]})
+				}
 			}
 
 			// Signal to the receiving nodes to ignore checking for key translation.
@@ -870,42 +4063,318 @@ func (api *API) Import(ctx context.Context, req *ImportRequest, opts ...ImportOp
 				shard := shard
 				bits := bits
 				eg.Go(func() error {
+					if vals := weights[shard]; len(vals) > 0 {
+						if err := api.server.defaultClient.ImportValue(ctx, req.Index, req.WeightField, shard, vals, opts...); err != nil {
+							return errors.Wrap(err, "importing weights")
+						}
+					}
 					return api.server.defaultClient.Import(ctx, req.Index, req.Field, shard, bits, opts...)
 				})
 			}
-			return eg.Wait()
+			if err := eg.Wait(); err != nil {
+				return nil, err
+			}
+			return &ImportResponse{}, nil
+		}
+	}
+
+	// Forward the import to the owners of the shard, if allowed. Any keys
+	// have already been translated.
+	if req.AllowForward && !api.cluster.ownsShard(api.Node().ID, req.Index, req.Shard) {
+		if len(req.RowIDs) != len(req.ColumnIDs) {
+			return nil, NewBadRequestError(errors.New("row and column ids must have the same length"))
+		}
+		bits := make([]Bit, len(req.ColumnIDs))
+		for i, colID := range req.ColumnIDs {
+			bits[i] = Bit{RowID: req.RowIDs[i], ColumnID: colID}
+			if i < len(req.Timestamps) {
+				bits[i].Timestamp = req.Timestamps[i]
+			}
+		}
+		opts = append(opts, OptImportOptionsIgnoreKeyCheck(true))
+		if weightField != nil {
+			vals := make([]FieldValue, len(req.ColumnIDs))
+			for i, colID := range req.ColumnIDs {
+				vals[i] = FieldValue{ColumnID: colID, Value: req.Weights[i]}
+			}
+			if err := api.server.defaultClient.ImportValue(ctx, req.Index, req.WeightField, req.Shard, vals, opts...); err != nil {
+				return nil, errors.Wrap(err, "forwarding weights")
+			}
 		}
+		if err := api.server.defaultClient.Import(ctx, req.Index, req.Field, req.Shard, bits, opts...); err != nil {
+			return nil, errors.Wrap(err, "forwarding import")
+		}
+		return &ImportResponse{}, nil
 	}
 
 	// Validate shard ownership.
 	if err := api.validateShardOwnership(req.Index, req.Shard); err != nil {
-		return errors.Wrap(err, "validating shard ownership")
+		return nil, errors.Wrap(err, "validating shard ownership")
+	}
+
+	// Apply imports to the shard in the order assigned by its primary owner.
+	resp := &ImportResponse{}
+	if api.server.orderedImports {
+		if options.Sequence != 0 {
+			resp.Sequence = options.Sequence
+			done, err := api.importSequencer.wait(ctx, req.Index, req.Shard, options.Sequence)
+			if err == ErrImportOutOfOrder {
+				// The import is recovered by syncing the shard with the
+				// other owners, which applied it in order.
+				return resp, nil
+			} else if err != nil {
+				return nil, errors.Wrap(err, "waiting for import sequence")
+			}
+			defer done()
+		} else if nodes := api.cluster.shardNodes(req.Index, req.Shard); len(nodes) > 0 && nodes[0].ID == api.server.nodeID {
+			seq, done, err := api.importSequencer.assign(ctx, req.Index, req.Shard)
+			if err != nil {
+				return nil, errors.Wrap(err, "assigning import sequence")
+			}
+			defer func() { done(err) }()
+			resp.Sequence = seq
+		}
+	}
+
+	// Convert timestamps to time.Time.
+	timestamps := make([]*time.Time, len(req.Timestamps))
+	for i, ts := range req.Timestamps {
+		if ts == 0 {
+			continue
+		}
+		t := time.Unix(0, ts).UTC()
+		if options.RejectOutOfRange && !fieldOptions.timeInRange(t) {
+			return nil, errors.Wrapf(ErrImportTimestampOutOfRange, "timestamp %d: %s", i, t.Format(time.RFC3339))
+		}
+		timestamps[i] = &t
+	}
+
+	// Discard the batch if its import has been canceled.
+	if err := importCanceled(ctx); err != nil {
+		return nil, err
+	}
+
+	// Keep the data the batch writes to, in case its import is canceled.
+	if batch != nil {
+		if !options.Clear {
+			batch.track(index.existenceField(), req.Shard, req.ColumnIDs, viewStandard)
+		}
+		q := field.TimeQuantum()
+		for _, t := range timestamps {
+			batch.track(field, req.Shard, req.ColumnIDs, field.importViews(t, q)...)
+		}
+		batch.track(field, req.Shard, req.ColumnIDs, viewStandard)
+		if weightField != nil {
+			batch.track(weightField, req.Shard, req.ColumnIDs, viewBSIGroupPrefix+weightField.Name())
+		}
+	}
+
+	// Import columnIDs into existence field.
+	if !options.Clear {
+		if err := importExistenceColumns(index, req.ColumnIDs); err != nil {
+			api.server.subsystemLogger(LogSubsystemImport).Printf("import existence error: index=%s, field=%s, shard=%d, columns=%d, err=%s", req.Index, req.Field, req.Shard, len(req.ColumnIDs), err)
+			return nil, errors.Wrap(err, "importing existence columns")
+		}
+	}
+
+	// Find bits which are already set before they are imported.
+	if req.ReportDuplicates && !options.Clear {
+		if resp.Duplicates, err = importDuplicates(field, req.Shard, req.RowIDs, req.ColumnIDs, timestamps); err != nil {
+			return nil, errors.Wrap(err, "finding duplicates")
+		}
+	}
+
+	// Import weights before bits, so that no bits are set if a weight is
+	// out of the weight field's range.
+	if weightField != nil {
+		if err := weightField.importValue(req.ColumnIDs, req.Weights, nil, options); err != nil {
+			return nil, errors.Wrap(err, "importing weights")
+		}
+	}
+
+	// Import into fragment.
+	err = field.Import(req.RowIDs, req.ColumnIDs, timestamps, opts...)
+	if err != nil {
+		api.server.subsystemLogger(LogSubsystemImport).Printf("import error: index=%s, field=%s, shard=%d, columns=%d, err=%s", req.Index, req.Field, req.Shard, len(req.ColumnIDs), err)
+		return nil, errors.Wrap(err, "importing")
+	}
+
+	// Undo the batch if its import was canceled while it was written.
+	if err := batch.undoIfCanceled(); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// resyncImportShard syncs a shard with its other owners after imports to it
+// were skipped by the import sequencer.
+func (api *API) resyncImportShard(index string, shard uint64) {
+	logger := api.server.subsystemLogger(LogSubsystemImport)
+	logger.Printf("syncing shard after skipped imports: index=%s, shard=%d", index, shard)
+	if err := api.server.syncer.syncShard(index, shard); err != nil {
+		logger.Printf("syncing shard after skipped imports: index=%s, shard=%d, err=%s", index, shard, err)
+	}
+}
+
+// maxImportDuplicates is the maximum number of duplicate bits returned by
+// API.ImportWithResponse.
+const maxImportDuplicates = 1000
+
+// importDuplicates returns up to maxImportDuplicates of the bits given by
+// rowIDs, columnIDs and timestamps which are already set in every view of
+// field that the import writes them to.
+func importDuplicates(field *Field, shard uint64, rowIDs, columnIDs []uint64, timestamps []*time.Time) ([]Bit, error) {
+	q := field.TimeQuantum()
+
+	var bits []Bit
+	for i := 0; i < len(rowIDs) && i < len(columnIDs); i++ {
+		var timestamp *time.Time
+		if i < len(timestamps) {
+			timestamp = timestamps[i]
+		}
+
+		dup := true
+		for _, name := range field.importViews(timestamp, q) {
+			ok, err := fieldBit(field, name, shard, rowIDs[i], columnIDs[i])
+			if err != nil {
+				return nil, errors.Wrap(err, "checking bit")
+			} else if !ok {
+				dup = false
+				break
+			}
+		}
+		if !dup {
+			continue
+		}
+
+		bits = append(bits, Bit{RowID: rowIDs[i], ColumnID: columnIDs[i]})
+		if len(bits) == maxImportDuplicates {
+			break
+		}
+	}
+	return bits, nil
+}
+
+// fieldBit returns true if the bit is set in the named view of field.
+func fieldBit(field *Field, name string, shard, rowID, columnID uint64) (bool, error) {
+	v := field.view(name)
+	if v == nil {
+		return false, nil
+	}
+	frag := v.Fragment(shard)
+	if frag == nil {
+		return false, nil
+	}
+	return frag.bit(rowID, columnID)
+}
+
+// Buffer sizes used by API.ImportReaderAuto. A shard's bits are imported once
+// it has importReaderFlushSize of them, and all buffered bits are imported
+// once there are importReaderMaxBuffered in total.
+const (
+	importReaderFlushSize   = 1 << 16
+	importReaderMaxBuffered = 1 << 20
+)
+
+// ImportReaderAuto imports bits read from r as CSV records of the form
+// <row>,<column>[,<timestamp>] in any order. Bits are grouped by shard and
+// each group is sent to every node which owns a replica of the shard, so at
+// most importReaderMaxBuffered bits are held in memory. The index and field
+// must not use keys. The field's access control list is checked against the
+// principal set with WithPrincipal.
+func (api *API) ImportReaderAuto(ctx context.Context, indexName, fieldName string, r io.Reader) error {
+	span, ctx := tracing.StartSpanFromContext(ctx, "API.ImportReaderAuto")
+	defer span.Finish()
+
+	if err := api.validate(apiImport); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+
+	if err := api.waitImportResume(ctx); err != nil {
+		return err
+	}
+
+	index, field, err := api.indexField(indexName, fieldName, 0)
+	if err != nil {
+		return errors.Wrap(err, "getting index and field")
+	} else if index.Keys() || field.keys() {
+		return NewBadRequestError(errors.New("keyed imports are not supported"))
+	} else if err := authorizeField(ctx, field, principalFromContext(ctx)); err != nil {
+		return err
+	}
+
+	// Each batch is sent through the client, which imports it on every node
+	// returned by shardNodes, including this one if it owns the shard.
+	bufs := make(map[uint64][]Bit)
+	var n int
+	flush := func(shard uint64) error {
+		bits := bufs[shard]
+		delete(bufs, shard)
+		n -= len(bits)
+		if err := api.server.defaultClient.Import(ctx, indexName, fieldName, shard, bits); err != nil {
+			return errors.Wrapf(err, "importing shard %d", shard)
+		}
+		return nil
 	}
 
-	// Convert timestamps to time.Time.
-	timestamps := make([]*time.Time, len(req.Timestamps))
-	for i, ts := range req.Timestamps {
-		if ts == 0 {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	for line := 1; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return NewBadRequestError(errors.Wrap(err, "reading"))
+		}
+
+		// Ignore blank rows.
+		if record[0] == "" {
 			continue
+		} else if len(record) < 2 {
+			return NewBadRequestError(errors.Errorf("bad column count on line %d: col=%d", line, len(record)))
 		}
-		t := time.Unix(0, ts).UTC()
-		timestamps[i] = &t
-	}
 
-	// Import columnIDs into existence field.
-	if !options.Clear {
-		if err := importExistenceColumns(index, req.ColumnIDs); err != nil {
-			api.server.logger.Printf("import existence error: index=%s, field=%s, shard=%d, columns=%d, err=%s", req.Index, req.Field, req.Shard, len(req.ColumnIDs), err)
-			return errors.Wrap(err, "importing existence columns")
+		rowID, err := strconv.ParseUint(record[0], 10, 64)
+		if err != nil {
+			return NewBadRequestError(errors.Errorf("invalid row id on line %d: %q", line, record[0]))
+		}
+		columnID, err := strconv.ParseUint(record[1], 10, 64)
+		if err != nil {
+			return NewBadRequestError(errors.Errorf("invalid column id on line %d: %q", line, record[1]))
+		}
+		var timestamp int64
+		if len(record) > 2 && record[2] != "" {
+			t, err := time.Parse(TimeFormat, record[2])
+			if err != nil {
+				return NewBadRequestError(errors.Errorf("invalid timestamp on line %d: %q", line, record[2]))
+			}
+			timestamp = t.UnixNano()
+		}
+
+		shard := columnID / ShardWidth
+		bufs[shard] = append(bufs[shard], Bit{RowID: rowID, ColumnID: columnID, Timestamp: timestamp})
+		n++
+
+		if len(bufs[shard]) >= importReaderFlushSize {
+			if err := flush(shard); err != nil {
+				return err
+			}
+		} else if n >= importReaderMaxBuffered {
+			for shard := range bufs {
+				if err := flush(shard); err != nil {
+					return err
+				}
+			}
 		}
 	}
 
-	// Import into fragment.
-	err = field.Import(req.RowIDs, req.ColumnIDs, timestamps, opts...)
-	if err != nil {
-		api.server.logger.Printf("import error: index=%s, field=%s, shard=%d, columns=%d, err=%s", req.Index, req.Field, req.Shard, len(req.ColumnIDs), err)
+	// Import the remaining bits.
+	for shard := range bufs {
+		if err := flush(shard); err != nil {
+			return err
+		}
 	}
-	return errors.Wrap(err, "importing")
+	return nil
 }
 
 // ImportValue bulk imports values into a particular field.
@@ -917,15 +4386,33 @@ func (api *API) ImportValue(ctx context.Context, req *ImportValueRequest, opts .
 		return errors.Wrap(err, "validating api method")
 	}
 
+	if err := api.waitImportResume(ctx); err != nil {
+		return err
+	}
+
+	defer api.touchIndex(req.Index)
+
 	// Set up import options.
 	options, err := setUpImportOptions(opts...)
 	if err != nil {
 		return errors.Wrap(err, "setting up import options")
 	}
 
+	ctx, batch, err := api.bindImport(ctx, options)
+	if err != nil {
+		return errors.Wrap(err, "binding import")
+	}
+	defer batch.done()
+
 	index, field, err := api.indexField(req.Index, req.Field, req.Shard)
 	if err != nil {
 		return errors.Wrap(err, "getting index and field")
+	} else if err := authorizeField(ctx, field, req.Principal); err != nil {
+		return err
+	}
+
+	if len(req.Null) != 0 && len(req.Null) != len(req.Values) {
+		return NewBadRequestError(fmt.Errorf("mismatch of null/value len: %d != %d", len(req.Null), len(req.Values)))
 	}
 
 	// Unless explicitly ignoring key validation (meaning keys have been
@@ -953,6 +4440,7 @@ func (api *API) ImportValue(ctx context.Context, req *ImportValueRequest, opts .
 				m[shard] = append(m[shard], FieldValue{
 					Value:    req.Values[i],
 					ColumnID: colID,
+					Null:     len(req.Null) != 0 && req.Null[i],
 				})
 			}
 
@@ -977,20 +4465,220 @@ func (api *API) ImportValue(ctx context.Context, req *ImportValueRequest, opts .
 		return errors.Wrap(err, "validating shard ownership")
 	}
 
-	// Import columnIDs into existence field.
+	// Discard the batch if its import has been canceled.
+	if err := importCanceled(ctx); err != nil {
+		return err
+	}
+
+	// Keep the data the batch writes to, in case its import is canceled.
+	if batch != nil {
+		if !options.Clear {
+			batch.track(index.existenceField(), req.Shard, req.ColumnIDs, viewStandard)
+		}
+		batch.track(field, req.Shard, req.ColumnIDs, viewBSIGroupPrefix+field.Name())
+	}
+
+	// Import columnIDs into existence field. Null columns are skipped since
+	// they have no value.
 	if !options.Clear {
-		if err := importExistenceColumns(index, req.ColumnIDs); err != nil {
-			api.server.logger.Printf("import existence error: index=%s, field=%s, shard=%d, columns=%d, err=%s", req.Index, req.Field, req.Shard, len(req.ColumnIDs), err)
+		columnIDs := req.ColumnIDs
+		if len(req.Null) != 0 {
+			columnIDs = make([]uint64, 0, len(req.ColumnIDs))
+			for i, colID := range req.ColumnIDs {
+				if !req.Null[i] {
+					columnIDs = append(columnIDs, colID)
+				}
+			}
+		}
+		if err := importExistenceColumns(index, columnIDs); err != nil {
+			api.server.subsystemLogger(LogSubsystemImport).Printf("import existence error: index=%s, field=%s, shard=%d, columns=%d, err=%s", req.Index, req.Field, req.Shard, len(req.ColumnIDs), err)
 			return errors.Wrap(err, "importing existence columns")
 		}
 	}
 
 	// Import into fragment.
-	err = field.importValue(req.ColumnIDs, req.Values, options)
+	err = field.importValue(req.ColumnIDs, req.Values, req.Null, options)
+	if err != nil {
+		api.server.subsystemLogger(LogSubsystemImport).Printf("import error: index=%s, field=%s, shard=%d, columns=%d, err=%s", req.Index, req.Field, req.Shard, len(req.ColumnIDs), err)
+		return errors.Wrap(err, "importing")
+	}
+
+	// Undo the batch if its import was canceled while it was written.
+	return batch.undoIfCanceled()
+}
+
+// ImportStream imports a stream of batches into a field, acknowledging each
+// batch once it has been imported so that callers can checkpoint their
+// progress. Each batch read from r is an ImportRequest, or an
+// ImportValueRequest for int fields, encoded with the API's serializer and
+// framed by WriteImportStreamFrame. The index and field of each batch are set
+// to indexName and fieldName. After each batch an ImportAck is written to w
+// as a line of JSON; w should not buffer acks. The stream stops at the first
+// batch which fails, which is acknowledged with its error, and that error is
+// returned. opts apply to every batch.
+func (api *API) ImportStream(ctx context.Context, indexName, fieldName string, r io.Reader, w io.Writer, opts ...ImportOption) error {
+	span, ctx := tracing.StartSpanFromContext(ctx, "API.ImportStream")
+	defer span.Finish()
+
+	if err := api.validate(apiImportStream); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+
+	if err := api.waitImportResume(ctx); err != nil {
+		return err
+	}
+
+	field := api.holder.Field(indexName, fieldName)
+	if field == nil {
+		return newNotFoundError(ErrFieldNotFound)
+	}
+
+	enc := json.NewEncoder(w)
+	for batch := uint64(0); ; batch++ {
+		bits, err := api.importStreamBatch(ctx, field, r, opts...)
+		if err == io.EOF {
+			span.LogKV("batches", batch)
+			return nil
+		} else if err != nil {
+			if encErr := enc.Encode(ImportAck{Batch: batch, Error: err.Error()}); encErr != nil {
+				api.server.subsystemLogger(LogSubsystemImport).Printf("import stream ack error: index=%s, field=%s, batch=%d, err=%s", indexName, fieldName, batch, encErr)
+			}
+			return errors.Wrapf(err, "batch %d", batch)
+		}
+		if err := enc.Encode(ImportAck{Batch: batch, Bits: bits}); err != nil {
+			return errors.Wrap(err, "writing ack")
+		}
+	}
+}
+
+// importStreamBatch reads the next batch of an import stream and imports it
+// into field, returning the number of bits in the batch.
+func (api *API) importStreamBatch(ctx context.Context, field *Field, r io.Reader, opts ...ImportOption) (uint64, error) {
+	buf, err := readImportStreamFrame(r)
 	if err != nil {
-		api.server.logger.Printf("import error: index=%s, field=%s, shard=%d, columns=%d, err=%s", req.Index, req.Field, req.Shard, len(req.ColumnIDs), err)
+		return 0, err
+	}
+
+	if field.Type() == FieldTypeInt {
+		req := &ImportValueRequest{}
+		if err := api.Serializer.Unmarshal(buf, req); err != nil {
+			return 0, NewBadRequestError(errors.Wrap(err, "decoding batch"))
+		}
+		req.Index, req.Field = field.Index(), field.Name()
+		if err := api.ImportValue(ctx, req, opts...); err != nil {
+			return 0, err
+		}
+		return uint64(len(req.Values)), nil
+	}
+
+	req := &ImportRequest{}
+	if err := api.Serializer.Unmarshal(buf, req); err != nil {
+		return 0, NewBadRequestError(errors.Wrap(err, "decoding batch"))
+	}
+	req.Index, req.Field = field.Index(), field.Name()
+	if err := api.Import(ctx, req, opts...); err != nil {
+		return 0, err
+	}
+	return uint64(len(req.ColumnIDs)), nil
+}
+
+// FieldImport holds the bits to import into a single field as part of an
+// API.ImportBatch call.
+type FieldImport struct {
+	Field     string
+	RowIDs    []uint64
+	ColumnIDs []uint64
+}
+
+// ImportBatch imports bits into several fields of an index as a single unit.
+// Every shard referenced by the batch must be owned by this node. If the
+// import into any of the fields fails, the bits already written to the other
+// fields are rolled back.
+func (api *API) ImportBatch(ctx context.Context, indexName string, batches []FieldImport) error {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.ImportBatch")
+	defer span.Finish()
+
+	if err := api.validate(apiImportBatch); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+
+	if err := api.waitImportResume(ctx); err != nil {
+		return err
+	}
+
+	defer api.touchIndex(indexName)
+
+	index := api.holder.Index(indexName)
+	if index == nil {
+		return newNotFoundError(ErrIndexNotFound)
+	}
+
+	// Validate every field before writing anything.
+	fields := make([]*Field, len(batches))
+	shards := make(map[uint64]struct{})
+	var columnIDs []uint64
+	for i, b := range batches {
+		field := index.Field(b.Field)
+		if field == nil {
+			return newNotFoundError(errors.Wrap(ErrFieldNotFound, b.Field))
+		} else if err := authorizeField(ctx, field, principalFromContext(ctx)); err != nil {
+			return err
+		}
+		switch field.Type() {
+		case FieldTypeSet, FieldTypeMutex, FieldTypeBool:
+		default:
+			return NewBadRequestError(errors.Errorf("batch import is not supported for %s fields: %s", field.Type(), b.Field))
+		}
+		if field.keys() {
+			return NewBadRequestError(errors.Errorf("batch import is not supported for keyed fields: %s", b.Field))
+		}
+		if len(b.RowIDs) != len(b.ColumnIDs) {
+			return NewBadRequestError(errors.Errorf("mismatch of row/column len for field %s: %d != %d", b.Field, len(b.RowIDs), len(b.ColumnIDs)))
+		}
+		for _, columnID := range b.ColumnIDs {
+			shards[columnID/ShardWidth] = struct{}{}
+		}
+		columnIDs = append(columnIDs, b.ColumnIDs...)
+		fields[i] = field
+	}
+
+	// Validate shard ownership once for the whole batch.
+	for shard := range shards {
+		if err := api.validateShardOwnership(indexName, shard); err != nil {
+			return errors.Wrap(err, "validating shard ownership")
+		}
+	}
+
+	// Import into each field, tracking how to revert each import.
+	undos := make([]*importUndo, 0, len(batches))
+	for i, b := range batches {
+		undo, err := fields[i].importUndo(b.RowIDs, b.ColumnIDs)
+		if err != nil {
+			api.rollbackImports(undos)
+			return errors.Wrap(err, "determining import undo")
+		}
+		undos = append(undos, undo)
+
+		if err := fields[i].Import(b.RowIDs, b.ColumnIDs, nil); err != nil {
+			api.server.subsystemLogger(LogSubsystemImport).Printf("batch import error: index=%s, field=%s, columns=%d, err=%s", indexName, b.Field, len(b.ColumnIDs), err)
+			api.rollbackImports(undos)
+			return errors.Wrap(err, "importing")
+		}
+	}
+
+	if err := importExistenceColumns(index, columnIDs); err != nil {
+		return errors.Wrap(err, "importing existence columns")
+	}
+	return nil
+}
+
+// rollbackImports reverts imports in the reverse order they were applied.
+func (api *API) rollbackImports(undos []*importUndo) {
+	for i := len(undos) - 1; i >= 0; i-- {
+		if err := undos[i].rollback(); err != nil {
+			api.server.subsystemLogger(LogSubsystemImport).Printf("batch import rollback error: field=%s, err=%s", undos[i].field.Name(), err)
+		}
 	}
-	return errors.Wrap(err, "importing")
 }
 
 func importExistenceColumns(index *Index, columnIDs []uint64) error {
@@ -1030,7 +4718,19 @@ func (api *API) StatsWithTags(tags []string) stats.StatsClient {
 	if api.holder == nil || api.cluster == nil {
 		return nil
 	}
-	return api.holder.Stats.WithTags(tags...)
+	return api.holder.statsClient().WithTags(tags...)
+}
+
+// RecentQueries returns up to n of the queries most recently executed by this
+// node, newest first.
+func (api *API) RecentQueries(ctx context.Context, n int) ([]QueryLogEntry, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.RecentQueries")
+	defer span.Finish()
+
+	if n < 0 {
+		return nil, NewBadRequestError(errors.New("query count must be non-negative"))
+	}
+	return api.server.executor.queryLog.recent(n), nil
 }
 
 // LongQueryTime returns the configured threshold for logging/statting
@@ -1045,40 +4745,64 @@ func (api *API) LongQueryTime() time.Duration {
 func (api *API) validateShardOwnership(indexName string, shard uint64) error {
 	// Validate that this handler owns the shard.
 	if !api.cluster.ownsShard(api.Node().ID, indexName, shard) {
-		api.server.logger.Printf("node %s does not own shard %d of index %s", api.Node().ID, shard, indexName)
+		api.server.subsystemLogger(LogSubsystemImport).Printf("node %s does not own shard %d of index %s", api.Node().ID, shard, indexName)
 		return ErrClusterDoesNotOwnShard
 	}
 	return nil
 }
 
 func (api *API) indexField(indexName string, fieldName string, shard uint64) (*Index, *Field, error) {
-	api.server.logger.Debugf("importing: %v %v %v", indexName, fieldName, shard)
+	api.server.subsystemLogger(LogSubsystemImport).Debugf("importing: %v %v %v", indexName, fieldName, shard)
 
 	// Find the Index.
 	index := api.holder.Index(indexName)
 	if index == nil {
-		api.server.logger.Printf("fragment error: index=%s, field=%s, shard=%d, err=%s", indexName, fieldName, shard, ErrIndexNotFound.Error())
+		api.server.subsystemLogger(LogSubsystemImport).Printf("fragment error: index=%s, field=%s, shard=%d, err=%s", indexName, fieldName, shard, ErrIndexNotFound.Error())
 		return nil, nil, newNotFoundError(ErrIndexNotFound)
 	}
 
 	// Retrieve field.
 	field := index.Field(fieldName)
 	if field == nil {
-		api.server.logger.Printf("field error: index=%s, field=%s, shard=%d, err=%s", indexName, fieldName, shard, ErrFieldNotFound.Error())
+		api.server.subsystemLogger(LogSubsystemImport).Printf("field error: index=%s, field=%s, shard=%d, err=%s", indexName, fieldName, shard, ErrFieldNotFound.Error())
 		return nil, nil, ErrFieldNotFound
 	}
 	return index, field, nil
 }
 
-// SetCoordinator makes a new Node the cluster coordinator.
+// coordinatorPingTimeout is how long SetCoordinator waits for the new
+// coordinator to respond before treating it as unreachable.
+const coordinatorPingTimeout = 5 * time.Second
+
+// SetCoordinator makes a new Node the cluster coordinator. Returns
+// ErrCoordinatorUnreachable if the new node does not respond.
 func (api *API) SetCoordinator(ctx context.Context, id string) (oldNode, newNode *Node, err error) {
-	span, _ := tracing.StartSpanFromContext(ctx, "API.SetCoordinator")
+	span, ctx := tracing.StartSpanFromContext(ctx, "API.SetCoordinator")
 	defer span.Finish()
 
 	if err := api.validate(apiSetCoordinator); err != nil {
 		return nil, nil, errors.Wrap(err, "validating api method")
 	}
 
+	return api.setCoordinator(ctx, id, false)
+}
+
+// ForceCoordinator makes a new Node the cluster coordinator like
+// SetCoordinator, but if the new node does not respond this node makes the
+// change itself and notifies the other nodes. A forced coordinator learns of
+// its role when it rejoins.
+func (api *API) ForceCoordinator(ctx context.Context, id string) (oldNode, newNode *Node, err error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "API.ForceCoordinator")
+	defer span.Finish()
+
+	if err := api.validate(apiForceCoordinator); err != nil {
+		return nil, nil, errors.Wrap(err, "validating api method")
+	}
+
+	return api.setCoordinator(ctx, id, true)
+}
+
+func (api *API) setCoordinator(ctx context.Context, id string, force bool) (oldNode, newNode *Node, err error) {
 	oldNode = api.cluster.nodeByID(api.cluster.Coordinator)
 	newNode = api.cluster.nodeByID(id)
 	if newNode == nil {
@@ -1090,8 +4814,25 @@ func (api *API) SetCoordinator(ctx context.Context, id string) (oldNode, newNode
 		return oldNode, newNode, api.cluster.setCoordinator(newNode)
 	}
 
+	// Make sure the new node is reachable before handing over, unless the
+	// caller forces the change.
+	pingCtx, cancel := context.WithTimeout(ctx, coordinatorPingTimeout)
+	err = api.server.sendTo(pingCtx, newNode, &PingMessage{})
+	cancel()
+	if err != nil {
+		if !force {
+			return nil, nil, errors.Wrapf(ErrCoordinatorUnreachable, "pinging node %s: %v", newNode.ID, err)
+		}
+		api.server.subsystemLogger(LogSubsystemCluster).Printf("forcing unreachable node %s to become coordinator: %v", newNode.ID, err)
+		if err := api.cluster.forceCoordinator(newNode); err != nil {
+			return nil, nil, errors.Wrap(err, "forcing coordinator")
+		}
+		return oldNode, newNode, nil
+	}
+
 	// Send the set-coordinator message to new node.
-	err = api.server.SendTo(
+	err = api.server.sendTo(
+		ctx,
 		newNode,
 		&SetCoordinatorMessage{
 			New: newNode,
@@ -1102,6 +4843,31 @@ func (api *API) SetCoordinator(ctx context.Context, id string) (oldNode, newNode
 	return oldNode, newNode, nil
 }
 
+// StepDownCoordinator hands the coordinator role from this node to another
+// node which is ready, choosing the first in ID order. It returns once the new
+// coordinator has notified every node of the change. Returns
+// ErrNodeNotCoordinator if this node is not the coordinator.
+func (api *API) StepDownCoordinator(ctx context.Context) error {
+	span, ctx := tracing.StartSpanFromContext(ctx, "API.StepDownCoordinator")
+	defer span.Finish()
+
+	if err := api.validate(apiStepDownCoordinator); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+
+	if !api.cluster.isCoordinator() {
+		return newConflictError(ErrNodeNotCoordinator)
+	}
+	n := api.cluster.coordinatorCandidate()
+	if n == nil {
+		return newConflictError(ErrNoCoordinatorCandidate)
+	}
+
+	// The new coordinator broadcasts the change before responding.
+	_, _, err := api.setCoordinator(ctx, n.ID, false)
+	return errors.Wrap(err, "setting coordinator")
+}
+
 // RemoveNode puts the cluster into the "RESIZING" state and begins the job of
 // removing the given node.
 func (api *API) RemoveNode(id string) (*Node, error) {
@@ -1127,6 +4893,39 @@ func (api *API) RemoveNode(id string) (*Node, error) {
 	return removeNode, nil
 }
 
+// CanRemoveNode reports whether the node can be removed from the cluster
+// without leaving any shard under-replicated or without a live copy of its
+// data. If not, reason explains why.
+func (api *API) CanRemoveNode(ctx context.Context, id string) (ok bool, reason string, err error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.CanRemoveNode")
+	defer span.Finish()
+
+	if err := api.validate(apiCanRemoveNode); err != nil {
+		return false, "", errors.Wrap(err, "validating api method")
+	}
+
+	if !api.cluster.topologyContainsNode(id) {
+		return false, "", errors.Wrap(ErrNodeIDNotExists, "finding node to remove")
+	} else if reason := api.cluster.removeNodeBlocker(id); reason != "" {
+		return false, reason, nil
+	}
+
+	// Resizing copies each shard from a live owner, so shards whose owners
+	// are all down would be lost.
+	for _, index := range api.holder.Indexes() {
+		shards := index.AvailableShards()
+		if shards.Count() == 0 {
+			continue
+		}
+		for _, sr := range api.cluster.underReplicatedShards(index.Name(), shards.Max()) {
+			if len(sr.LiveNodes) == 0 {
+				return false, fmt.Sprintf("shard %d of index %s has no live replica", sr.Shard, index.Name()), nil
+			}
+		}
+	}
+	return true, "", nil
+}
+
 // ResizeAbort stops the current resize job.
 func (api *API) ResizeAbort() error {
 	if err := api.validate(apiResizeAbort); err != nil {
@@ -1172,6 +4971,31 @@ func (api *API) Info() serverInfo {
 	}
 }
 
+// NodeConfig returns the effective configuration of this node.
+func (api *API) NodeConfig(ctx context.Context) (NodeConfig, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.NodeConfig")
+	defer span.Finish()
+
+	if err := api.validate(apiNodeConfig); err != nil {
+		return NodeConfig{}, errors.Wrap(err, "validating api method")
+	}
+
+	return NodeConfig{
+		NodeID:              api.server.nodeID,
+		URI:                 api.server.uri.String(),
+		DataDir:             api.holder.Path,
+		ShardWidth:          ShardWidth,
+		ReplicaN:            api.cluster.ReplicaN,
+		LongQueryTime:       api.cluster.longQueryTime,
+		AntiEntropyInterval: api.server.antiEntropyInterval,
+		MaxWritesPerRequest: api.server.maxWritesPerRequest,
+		MaxCacheBytes:       api.holder.MaxTotalCacheBytes(),
+		OrderedImports:      api.server.orderedImports,
+		Prefetch:            api.holder.Prefetch(),
+		QueryLogSize:        api.server.queryLogSize,
+	}, nil
+}
+
 func (api *API) TranslateKeys(body io.Reader) ([]byte, error) {
 	reqBytes, err := ioutil.ReadAll(body)
 	if err != nil {
@@ -1202,51 +5026,345 @@ func (api *API) TranslateKeys(body io.Reader) ([]byte, error) {
 	return buf, nil
 }
 
+// IndexKeysOptions holds the options for the API.EnableIndexKeys method.
+type IndexKeysOptions struct {
+	// If true, each existing column is assigned its id as a decimal string
+	// key. Requires existence tracking.
+	Backfill bool
+}
+
+// IndexKeysOption is a functional option type for API.EnableIndexKeys.
+type IndexKeysOption func(*IndexKeysOptions) error
+
+func OptIndexKeysBackfill(b bool) IndexKeysOption {
+	return func(o *IndexKeysOptions) error {
+		o.Backfill = b
+		return nil
+	}
+}
+
+// backfillKeysBatchSize is the maximum number of column keys assigned by each
+// log entry when EnableIndexKeys backfills keys.
+const backfillKeysBatchSize = 1 << 16
+
+// EnableIndexKeys switches an existing index from integer column ids to
+// string keys across the cluster. Columns already in the index keep their
+// ids; new keys are assigned ids after the last shard with data, so they
+// never collide with existing columns. Must be called on the node whose
+// translate store is writable. Columns written by id while keys are being
+// enabled may not be backfilled.
+func (api *API) EnableIndexKeys(ctx context.Context, indexName string, opts ...IndexKeysOption) error {
+	span, ctx := tracing.StartSpanFromContext(ctx, "API.EnableIndexKeys")
+	defer span.Finish()
+
+	if err := api.validate(apiEnableIndexKeys); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+
+	options := IndexKeysOptions{}
+	for _, opt := range opts {
+		if err := opt(&options); err != nil {
+			return errors.Wrap(err, "applying option")
+		}
+	}
+
+	index := api.holder.Index(indexName)
+	if index == nil {
+		return newNotFoundError(ErrIndexNotFound)
+	} else if index.Keys() {
+		return newConflictError(ErrIndexKeysEnabled)
+	} else if api.holder.translateFile.isReadOnly() {
+		return newConflictError(ErrTranslateStoreReadOnly)
+	}
+
+	if options.Backfill {
+		if !index.Options().TrackExistence {
+			return NewBadRequestError(errors.Errorf("backfilling keys requires existence tracking: %s", indexName))
+		}
+		// Find existing columns one shard at a time and assign their keys
+		// in bounded batches so that no single query or log entry holds
+		// every column of the index.
+		for _, shard := range index.AvailableShards().Slice() {
+			resp, err := api.Query(ctx, &QueryRequest{Index: indexName, Query: "Not(Union())", Shards: []uint64{shard}})
+			if err != nil {
+				return errors.Wrapf(err, "finding existing columns in shard %d", shard)
+			}
+			cols := resp.Results[0].(*Row).Columns()
+			for len(cols) > 0 {
+				n := len(cols)
+				if n > backfillKeysBatchSize {
+					n = backfillKeysBatchSize
+				}
+				if err := api.holder.translateFile.backfillColumnKeys(indexName, cols[:n]); err != nil {
+					return errors.Wrap(err, "backfilling keys")
+				}
+				cols = cols[n:]
+			}
+		}
+	}
+
+	if shards := index.AvailableShards(); shards.Count() > 0 {
+		if err := api.holder.translateFile.reserveColumnIDsThrough(indexName, (shards.Max()+1)*ShardWidth-1); err != nil {
+			return errors.Wrap(err, "reserving existing column ids")
+		}
+	}
+
+	if err := index.EnableKeys(); err != nil {
+		return errors.Wrap(err, "enabling keys")
+	}
+
+	// Send the change to all nodes.
+	if err := api.server.SendSync(&EnableIndexKeysMessage{
+		Index: indexName,
+	}); err != nil {
+		return errors.Wrap(err, "sending EnableIndexKeys message")
+	}
+	return nil
+}
+
+// ReserveColumnIDs reserves n consecutive column ids in a keyed index and
+// returns the first of them. The translate store never assigns reserved ids
+// to keys, so callers can assign them to their own keys and import by id.
+// Reservations must be made on the node whose translate store is writable.
+func (api *API) ReserveColumnIDs(ctx context.Context, indexName string, n int) (start uint64, err error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.ReserveColumnIDs")
+	defer span.Finish()
+
+	if err := api.validate(apiReserveColumnIDs); err != nil {
+		return 0, errors.Wrap(err, "validating api method")
+	}
+
+	index := api.holder.Index(indexName)
+	if index == nil {
+		return 0, newNotFoundError(ErrIndexNotFound)
+	} else if !index.Keys() {
+		return 0, NewBadRequestError(errors.Errorf("index does not use keys: %s", indexName))
+	} else if n <= 0 {
+		return 0, NewBadRequestError(errors.New("number of ids must be positive"))
+	}
+
+	start, err = api.holder.translateFile.reserveColumnIDs(indexName, uint64(n))
+	if err == ErrTranslateStoreReadOnly {
+		return 0, newConflictError(err)
+	}
+	return start, errors.Wrap(err, "reserving column ids")
+}
+
+// TranslateRowKeys returns the row id for each key in a keyed field. Keys
+// without an id are created unless the local translate store is a read-only
+// replica, in which case a NotFoundError is returned.
+func (api *API) TranslateRowKeys(ctx context.Context, indexName, fieldName string, keys []string) ([]uint64, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.TranslateRowKeys")
+	defer span.Finish()
+
+	if err := api.validate(apiTranslateRowKeys); err != nil {
+		return nil, errors.Wrap(err, "validating api method")
+	}
+
+	if err := api.validateRowKeysField(indexName, fieldName); err != nil {
+		return nil, err
+	}
+
+	ids, err := api.holder.translateFile.TranslateRowsToUint64(indexName, fieldName, keys)
+	if err == ErrTranslateStoreReadOnly {
+		for i := range ids {
+			if ids[i] == 0 {
+				return nil, newNotFoundError(errors.Errorf("row key not found: %q", keys[i]))
+			}
+		}
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "translating row keys")
+	}
+	return ids, nil
+}
+
+// TranslateRowIDs returns the key for each row id in a keyed field. A
+// NotFoundError is returned if any id has no key.
+func (api *API) TranslateRowIDs(ctx context.Context, indexName, fieldName string, ids []uint64) ([]string, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.TranslateRowIDs")
+	defer span.Finish()
+
+	if err := api.validate(apiTranslateRowIDs); err != nil {
+		return nil, errors.Wrap(err, "validating api method")
+	}
+
+	if err := api.validateRowKeysField(indexName, fieldName); err != nil {
+		return nil, err
+	}
+
+	keys, err := api.holder.translateFile.TranslateRowsToStrings(indexName, fieldName, ids)
+	if err != nil {
+		return nil, errors.Wrap(err, "translating row ids")
+	}
+	for i := range keys {
+		if keys[i] == "" {
+			return nil, newNotFoundError(errors.Errorf("row id not found: %d", ids[i]))
+		}
+	}
+	return keys, nil
+}
+
+// validateRowKeysField returns an error if the field does not exist or does
+// not use keys for rows.
+func (api *API) validateRowKeysField(indexName, fieldName string) error {
+	field := api.holder.Field(indexName, fieldName)
+	if field == nil {
+		return newNotFoundError(ErrFieldNotFound)
+	} else if !field.keys() {
+		return NewBadRequestError(errors.Errorf("field does not use keys: %s", fieldName))
+	}
+	return nil
+}
+
 type serverInfo struct {
 	ShardWidth uint64 `json:"shardWidth"`
 }
 
+// NodeConfig is the effective configuration of a node, as returned by
+// API.NodeConfig.
+type NodeConfig struct {
+	NodeID              string        `json:"nodeID"`
+	URI                 string        `json:"uri"`
+	DataDir             string        `json:"dataDir"`
+	ShardWidth          uint64        `json:"shardWidth"`
+	ReplicaN            int           `json:"replicaN"`
+	LongQueryTime       time.Duration `json:"longQueryTime"`
+	AntiEntropyInterval time.Duration `json:"antiEntropyInterval"`
+	MaxWritesPerRequest int           `json:"maxWritesPerRequest"`
+	MaxCacheBytes       int64         `json:"maxCacheBytes"`
+	OrderedImports      bool          `json:"orderedImports"`
+	Prefetch            bool          `json:"prefetch"`
+	QueryLogSize        int           `json:"queryLogSize"`
+}
+
 type apiMethod int
 
 // API validation constants.
 const (
-	apiClusterMessage apiMethod = iota
+	apiAbortCreateIndex apiMethod = iota
+	apiAccountUsage
+	apiAggregateAcrossIndexes
+	apiBackupIndex
+	apiBackupNode
+	apiCacheStaleness
+	apiCancelImport
+	apiCanRemoveNode
+	apiChangedShards
+	apiClusterMessage
+	apiColumnAttrsFiltered
+	apiCommitCreateIndex
+	apiCompactTranslateStore
+	apiCoordinator
 	apiCreateField
 	apiCreateIndex
 	apiDeleteField
 	apiDeleteAvailableShard
 	apiDeleteIndex
 	apiDeleteView
+	apiDeleteViews
+	apiDeleteViewsMatching
+	apiEnableIndexKeys
+	apiEstimateRowCount
+	apiExplainAnalyze
+	apiExportAttrSchema
 	apiExportCSV
+	apiExportFieldMeta
+	apiExportNDJSON
+	apiExportParquet
+	apiForceCoordinator
+	apiForEachBit
 	apiFragmentBlockData
 	apiFragmentBlocks
+	apiFragmentContainerStats
 	apiFragmentData
+	apiFragmentModTime
 	apiField
 	apiFieldAttrDiff
+	apiFieldHistogram
+	apiFieldsEqual
+	apiGetBits
 	//apiHosts // not implemented
 	apiImport
+	apiImportAttrSchema
 	apiImportValue
+	apiImportBatch
+	apiImportFieldMeta
+	apiImportHistory
+	apiImportStream
+	apiIntersectRows
 	apiIndex
 	apiIndexAttrDiff
+	apiIndexBitCount
+	apiIndexMerkleRoot
+	apiManifest
+	apiMergeIndexes
 	//apiLocalID // not implemented
+	apiLogLevel
 	//apiLongQueryTime // not implemented
 	//apiMaxShards // not implemented
+	apiNodeConfig
+	apiOpenFileCount
+	apiOwnershipMap
+	apiPauseQueries
+	apiPingCluster
+	apiPrepareCreateIndex
+	apiPruneTimeViews
+	apiPurgeTrash
 	apiQuery
+	apiQueryFieldRefs
+	apiQueryShardCount
+	apiRebuildSchemaFromDisk
+	apiReloadIndex
 	apiRecalculateCaches
+	apiRecomputeMaxShard
+	apiRegisterImportTransform
+	apiRemapRows
+	apiRestoreField
+	apiRestoreIndex
+	apiRestoreNode
 	apiRemoveNode
+	apiReserveColumnIDs
+	apiResetAccountUsage
 	apiResizeAbort
+	apiResumeQueries
 	//apiSchema // not implemented
 	apiSetCoordinator
+	apiSetDefaultFieldOptions
+	apiSetFieldACL
+	apiSetIndexQueryRateLimit
+	apiSetLogLevel
+	apiSetSnapshotCompressionLevel
+	apiSetStatsSampleRate
 	apiShardNodes
+	apiSnapshotInfo
+	apiStepDownCoordinator
+	apiSubscribe
+	apiTopColumns
+	apiTranslateRowIDs
+	apiTranslateRowKeys
+	apiUnderReplicatedShards
 	//apiState // not implemented
 	//apiStatsWithTags // not implemented
+	apiValueHistogram
+	apiVerifySchemaConsistency
 	//apiVersion // not implemented
 	apiViews
+	apiWatchSchema
 )
 
 var methodsCommon = map[apiMethod]struct{}{
-	apiClusterMessage: {},
-	apiSetCoordinator: {},
+	apiClusterMessage:              {},
+	apiCoordinator:                 {},
+	apiForceCoordinator:            {},
+	apiLogLevel:                    {},
+	apiNodeConfig:                  {},
+	apiRegisterImportTransform:     {},
+	apiSetCoordinator:              {},
+	apiSetLogLevel:                 {},
+	apiSetSnapshotCompressionLevel: {},
+	apiSetStatsSampleRate:          {},
+	apiStepDownCoordinator:         {},
 }
 
 var methodsResizing = map[apiMethod]struct{}{
@@ -1255,24 +5373,92 @@ var methodsResizing = map[apiMethod]struct{}{
 }
 
 var methodsNormal = map[apiMethod]struct{}{
-	apiCreateField:          {},
-	apiCreateIndex:          {},
-	apiDeleteField:          {},
-	apiDeleteAvailableShard: {},
-	apiDeleteIndex:          {},
-	apiDeleteView:           {},
-	apiExportCSV:            {},
-	apiFragmentBlockData:    {},
-	apiFragmentBlocks:       {},
-	apiField:                {},
-	apiFieldAttrDiff:        {},
-	apiImport:               {},
-	apiImportValue:          {},
-	apiIndex:                {},
-	apiIndexAttrDiff:        {},
-	apiQuery:                {},
-	apiRecalculateCaches:    {},
-	apiRemoveNode:           {},
-	apiShardNodes:           {},
-	apiViews:                {},
+	apiAbortCreateIndex:        {},
+	apiAccountUsage:            {},
+	apiAggregateAcrossIndexes:  {},
+	apiBackupIndex:             {},
+	apiBackupNode:              {},
+	apiCacheStaleness:          {},
+	apiCancelImport:            {},
+	apiCanRemoveNode:           {},
+	apiChangedShards:           {},
+	apiColumnAttrsFiltered:     {},
+	apiCommitCreateIndex:       {},
+	apiCompactTranslateStore:   {},
+	apiCreateField:             {},
+	apiCreateIndex:             {},
+	apiDeleteField:             {},
+	apiDeleteAvailableShard:    {},
+	apiDeleteIndex:             {},
+	apiDeleteView:              {},
+	apiDeleteViews:             {},
+	apiDeleteViewsMatching:     {},
+	apiEnableIndexKeys:         {},
+	apiEstimateRowCount:        {},
+	apiExplainAnalyze:          {},
+	apiExportAttrSchema:        {},
+	apiExportCSV:               {},
+	apiExportFieldMeta:         {},
+	apiExportNDJSON:            {},
+	apiExportParquet:           {},
+	apiForEachBit:              {},
+	apiFragmentBlockData:       {},
+	apiFragmentBlocks:          {},
+	apiFragmentContainerStats:  {},
+	apiFragmentModTime:         {},
+	apiField:                   {},
+	apiFieldAttrDiff:           {},
+	apiFieldHistogram:          {},
+	apiFieldsEqual:             {},
+	apiGetBits:                 {},
+	apiImport:                  {},
+	apiImportAttrSchema:        {},
+	apiImportValue:             {},
+	apiImportBatch:             {},
+	apiImportFieldMeta:         {},
+	apiImportHistory:           {},
+	apiImportStream:            {},
+	apiIntersectRows:           {},
+	apiIndex:                   {},
+	apiIndexAttrDiff:           {},
+	apiIndexBitCount:           {},
+	apiIndexMerkleRoot:         {},
+	apiManifest:                {},
+	apiMergeIndexes:            {},
+	apiOpenFileCount:           {},
+	apiOwnershipMap:            {},
+	apiPauseQueries:            {},
+	apiPingCluster:             {},
+	apiPrepareCreateIndex:      {},
+	apiPruneTimeViews:          {},
+	apiPurgeTrash:              {},
+	apiQuery:                   {},
+	apiQueryFieldRefs:          {},
+	apiQueryShardCount:         {},
+	apiRebuildSchemaFromDisk:   {},
+	apiReloadIndex:             {},
+	apiRecalculateCaches:       {},
+	apiRecomputeMaxShard:       {},
+	apiRemapRows:               {},
+	apiRestoreField:            {},
+	apiRestoreIndex:            {},
+	apiRestoreNode:             {},
+	apiRemoveNode:              {},
+	apiReserveColumnIDs:        {},
+	apiResetAccountUsage:       {},
+	apiResumeQueries:           {},
+	apiSetDefaultFieldOptions:  {},
+	apiSetFieldACL:             {},
+	apiSetIndexQueryRateLimit:  {},
+	apiShardNodes:              {},
+	apiSnapshotInfo:            {},
+	apiSubscribe:               {},
+	apiTopColumns:              {},
+	apiTranslateRowIDs:         {},
+	apiTranslateRowKeys:        {},
+	apiUnderReplicatedShards:   {},
+	apiValueHistogram:          {},
+	apiVerifySchemaConsistency: {},
+	apiViews:                   {},
+	apiWatchSchema:             {},
 }
@@ -0,0 +1,127 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilosa
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// unreachableClient is an internal client which fails to send messages.
+type unreachableClient struct {
+	nopInternalClient
+}
+
+func (unreachableClient) SendMessage(ctx context.Context, uri *URI, msg []byte) error {
+	return errors.New("connection refused")
+}
+
+// emptySerializer marshals every message as an empty payload.
+type emptySerializer struct{}
+
+func (emptySerializer) Marshal(Message) ([]byte, error) { return nil, nil }
+func (emptySerializer) Unmarshal([]byte, Message) error { return nil }
+
+func TestAPI_SetCoordinatorUnreachable(t *testing.T) {
+	td, err := ioutil.TempDir(*TempDir, "")
+	if err != nil {
+		t.Fatalf("getting temp dir: %v", err)
+	}
+	defer os.RemoveAll(td)
+
+	s, err := NewServer(OptServerDataDir(td), OptServerNodeID("node0"), OptServerInternalClient(unreachableClient{}), OptServerSerializer(emptySerializer{}))
+	if err != nil {
+		t.Fatalf("making new server: %v", err)
+	}
+	api, err := NewAPI(OptAPIServer(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.cluster.state = ClusterStateNormal
+	s.cluster.addNodeBasicSorted(&Node{ID: "node1"})
+	ctx := context.Background()
+
+	if _, _, err := api.SetCoordinator(ctx, "node1"); errors.Cause(err) != ErrCoordinatorUnreachable {
+		t.Fatalf("expected unreachable error, got %v", err)
+	} else if s.cluster.Coordinator == "node1" {
+		t.Fatal("expected coordinator to be unchanged")
+	}
+
+	epoch := s.cluster.coordinatorEpoch
+	if _, newNode, err := api.ForceCoordinator(ctx, "node1"); err != nil {
+		t.Fatal(err)
+	} else if newNode.ID != "node1" || s.cluster.Coordinator != "node1" {
+		t.Fatalf("unexpected coordinator: %s", s.cluster.Coordinator)
+	} else if s.cluster.coordinatorEpoch != epoch+1 {
+		t.Fatalf("unexpected epoch: %d", s.cluster.coordinatorEpoch)
+	}
+}
+
+// Ensure only requests carrying the cluster's internal token are marked as
+// internal, and that nodes adopt the coordinator's token.
+func TestAPI_InternalContext(t *testing.T) {
+	c := newCluster()
+	api := &API{cluster: c}
+	ctx := context.Background()
+
+	if isInternalRequest(ctx) {
+		t.Fatal("expected plain context not to be internal")
+	} else if isInternalRequest(api.InternalContext(ctx, "")) {
+		t.Fatal("expected empty token to be rejected")
+	} else if isInternalRequest(api.InternalContext(ctx, "bogus")) {
+		t.Fatal("expected invalid token to be rejected")
+	} else if !isInternalRequest(api.InternalContext(ctx, c.internalRequestToken())) {
+		t.Fatal("expected cluster token to be accepted")
+	}
+
+	// A node which is not coordinator adopts the coordinator's token.
+	c.Node = &Node{ID: "node1"}
+	c.Coordinator = "node0"
+	c.Topology = newTopology()
+	c.holder = NewHolder()
+	if err := c.mergeClusterStatus(&ClusterStatus{State: ClusterStateNormal, InternalToken: "coordinator"}); err != nil {
+		t.Fatal(err)
+	} else if !isInternalRequest(api.InternalContext(ctx, "coordinator")) {
+		t.Fatal("expected coordinator token to be accepted")
+	}
+}
+
+func TestImportRegistry_Expire(t *testing.T) {
+	var r importRegistry
+	stale := r.register()
+	r.imports[stale].lastUsed = time.Now().Add(-importRegistrationTTL - time.Minute)
+	imp := r.imports[stale]
+
+	// Registering or binding another import expires stale ones.
+	id := r.register()
+	if _, ok := r.imports[stale]; ok {
+		t.Fatal("expected stale import to be removed")
+	} else if imp.ctx.Err() == nil {
+		t.Fatal("expected stale import to be canceled")
+	}
+	if _, _, release, err := r.bind(context.Background(), id); err != nil {
+		t.Fatal(err)
+	} else {
+		release()
+	}
+	if _, _, _, err := r.bind(context.Background(), stale); err != ErrImportNotFound {
+		t.Fatalf("expected import not found, got %v", err)
+	}
+}
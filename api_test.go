@@ -15,15 +15,30 @@
 package pilosa_test
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/pilosa/pilosa"
+	"github.com/pilosa/pilosa/boltdb"
+	"github.com/pilosa/pilosa/roaring"
 	"github.com/pilosa/pilosa/server"
+	"github.com/pilosa/pilosa/stats"
 	"github.com/pilosa/pilosa/test"
+	"github.com/pkg/errors"
 )
 
 func TestAPI_Import(t *testing.T) {
@@ -169,6 +184,129 @@ func TestAPI_Import(t *testing.T) {
 			t.Fatalf("unexpected column ids: %+v", columns)
 		}
 	})
+
+	t.Run("AllowForward", func(t *testing.T) {
+		ctx := context.Background()
+		index := "fwd"
+		field := "f"
+
+		if _, err := m0.API.CreateIndex(ctx, index, pilosa.IndexOptions{}); err != nil {
+			t.Fatalf("creating index: %v", err)
+		} else if _, err := m0.API.CreateField(ctx, index, field); err != nil {
+			t.Fatalf("creating field: %v", err)
+		}
+
+		// node0 does not own shard 0 (node1 does; because of offsetModHasher).
+		colIDs := []uint64{1, 2, 3}
+		req := &pilosa.ImportRequest{
+			Index:     index,
+			Field:     field,
+			Shard:     0,
+			RowIDs:    []uint64{1, 1, 1},
+			ColumnIDs: colIDs,
+		}
+		if err := m0.API.Import(ctx, req); errors.Cause(err) != pilosa.ErrClusterDoesNotOwnShard {
+			t.Fatalf("expected shard ownership error, got %v", err)
+		}
+
+		req.AllowForward = true
+		if err := m0.API.Import(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+
+		if res, err := m1.API.Query(ctx, &pilosa.QueryRequest{Index: index, Query: "Row(f=1)"}); err != nil {
+			t.Fatal(err)
+		} else if columns := res.Results[0].(*pilosa.Row).Columns(); !reflect.DeepEqual(columns, colIDs) {
+			t.Fatalf("unexpected column ids: %+v", columns)
+		}
+	})
+}
+
+func TestAPI_ImportWeights(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	for _, index := range []string{"i", "k"} {
+		if _, err := m0.API.CreateIndex(ctx, index, pilosa.IndexOptions{Keys: index == "k"}); err != nil {
+			t.Fatal(err)
+		} else if _, err := m0.API.CreateField(ctx, index, "f"); err != nil {
+			t.Fatal(err)
+		} else if _, err := m0.API.CreateField(ctx, index, "w", pilosa.OptFieldTypeInt(0, 100)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sum := func(t *testing.T, index string) pilosa.ValCount {
+		t.Helper()
+		resp := m0.MustQuery(t, &pilosa.QueryRequest{Index: index, Query: `Sum(Row(f=1), field=w)`})
+		return resp.Results[0].(pilosa.ValCount)
+	}
+
+	t.Run("IDs", func(t *testing.T) {
+		if err := m0.API.Import(ctx, &pilosa.ImportRequest{
+			Index:       "i",
+			Field:       "f",
+			RowIDs:      []uint64{1, 1},
+			ColumnIDs:   []uint64{1, 2},
+			WeightField: "w",
+			Weights:     []int64{5, 7},
+		}); err != nil {
+			t.Fatal(err)
+		} else if vc := sum(t, "i"); vc.Val != 12 || vc.Count != 2 {
+			t.Fatalf("unexpected sum: %+v", vc)
+		}
+	})
+
+	t.Run("Keys", func(t *testing.T) {
+		if err := m0.API.Import(ctx, &pilosa.ImportRequest{
+			Index:       "k",
+			Field:       "f",
+			RowIDs:      []uint64{1, 1},
+			ColumnKeys:  []string{"a", "b"},
+			Timestamps:  []int64{0, 0},
+			WeightField: "w",
+			Weights:     []int64{3, 4},
+		}); err != nil {
+			t.Fatal(err)
+		} else if vc := sum(t, "k"); vc.Val != 7 || vc.Count != 2 {
+			t.Fatalf("unexpected sum: %+v", vc)
+		}
+	})
+
+	t.Run("OutOfRange", func(t *testing.T) {
+		if err := m0.API.Import(ctx, &pilosa.ImportRequest{
+			Index:       "i",
+			Field:       "f",
+			RowIDs:      []uint64{1},
+			ColumnIDs:   []uint64{3},
+			WeightField: "w",
+			Weights:     []int64{200},
+		}); err == nil {
+			t.Fatal("expected error")
+		} else if vc := sum(t, "i"); vc.Val != 12 || vc.Count != 2 {
+			t.Fatalf("unexpected sum: %+v", vc)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, req := range []*pilosa.ImportRequest{
+			{Index: "i", Field: "f", RowIDs: []uint64{1}, ColumnIDs: []uint64{3}, WeightField: "w"},
+			{Index: "i", Field: "f", RowIDs: []uint64{1}, ColumnIDs: []uint64{3}, WeightField: "f", Weights: []int64{1}},
+		} {
+			if err := m0.API.Import(ctx, req); err == nil {
+				t.Fatal("expected error")
+			} else if _, ok := errors.Cause(err).(pilosa.BadRequestError); !ok {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		if err := m0.API.Import(ctx, &pilosa.ImportRequest{Index: "i", Field: "f", RowIDs: []uint64{1}, ColumnIDs: []uint64{3}, WeightField: "missing", Weights: []int64{1}}); err == nil {
+			t.Fatal("expected error")
+		} else if _, ok := errors.Cause(err).(pilosa.NotFoundError); !ok {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
 }
 
 func TestAPI_ImportValue(t *testing.T) {
@@ -247,3 +385,3944 @@ type offsetModHasher struct{}
 func (*offsetModHasher) Hash(key uint64, n int) int {
 	return int(key+1) % n
 }
+
+func TestAPI_CancelImport(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+
+	m0 := c[0]
+	ctx := context.Background()
+	index, field := "i", "f"
+
+	if _, err := m0.API.CreateIndex(ctx, index, pilosa.IndexOptions{}); err != nil {
+		t.Fatalf("creating index: %v", err)
+	}
+	if _, err := m0.API.CreateField(ctx, index, field, pilosa.OptFieldTypeSet(pilosa.DefaultCacheType, 100)); err != nil {
+		t.Fatalf("creating field: %v", err)
+	}
+
+	count := func() uint64 {
+		res, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: index, Query: fmt.Sprintf("Count(Row(%s=1))", field)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.Results[0].(uint64)
+	}
+
+	id, err := m0.API.BeginImport(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := &pilosa.ImportRequest{Index: index, Field: field, Shard: 0, RowIDs: []uint64{1, 1}, ColumnIDs: []uint64{1, 2}}
+	if err := m0.API.Import(ctx, req, pilosa.OptImportOptionsID(id)); err != nil {
+		t.Fatal(err)
+	} else if n := count(); n != 2 {
+		t.Fatalf("unexpected count: %d", n)
+	}
+
+	if err := m0.API.CancelImport(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+
+	// Batches sent after the import is canceled must not be written.
+	req = &pilosa.ImportRequest{Index: index, Field: field, Shard: 0, RowIDs: []uint64{1}, ColumnIDs: []uint64{3}}
+	if err := m0.API.Import(ctx, req, pilosa.OptImportOptionsID(id)); err == nil {
+		t.Fatal("expected error importing into canceled import")
+	} else if n := count(); n != 2 {
+		t.Fatalf("unexpected count after cancel: %d", n)
+	}
+
+	if err := m0.API.CancelImport(ctx, id); !strings.Contains(fmt.Sprint(err), pilosa.ErrImportNotFound.Error()) {
+		t.Fatalf("expected import not found error, got: %v", err)
+	}
+}
+
+func TestAPI_FieldHistogram(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+
+	m0 := c[0]
+	ctx := context.Background()
+	index, field := "i", "f"
+
+	if _, err := m0.API.CreateIndex(ctx, index, pilosa.IndexOptions{}); err != nil {
+		t.Fatalf("creating index: %v", err)
+	}
+	if _, err := m0.API.CreateField(ctx, index, field, pilosa.OptFieldTypeSet(pilosa.DefaultCacheType, 100)); err != nil {
+		t.Fatalf("creating field: %v", err)
+	}
+
+	// Row 1 has 3 bits, row 2 has 1 bit; column 1 has 2 bits, columns 2 and
+	// ShardWidth+1 have 1 bit.
+	req := &pilosa.ImportRequest{
+		Index:     index,
+		Field:     field,
+		RowIDs:    []uint64{1, 1, 1, 2},
+		ColumnIDs: []uint64{1, 2, pilosa.ShardWidth + 1, 1},
+	}
+	if err := m0.API.Import(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Row", func(t *testing.T) {
+		h, err := m0.API.FieldHistogram(ctx, index, field, pilosa.HistogramDimensionRow)
+		if err != nil {
+			t.Fatal(err)
+		} else if exp := map[uint64]uint64{3: 1, 1: 1}; !reflect.DeepEqual(h, exp) {
+			t.Fatalf("unexpected histogram: %v", h)
+		}
+	})
+
+	t.Run("Column", func(t *testing.T) {
+		h, err := m0.API.FieldHistogram(ctx, index, field, pilosa.HistogramDimensionColumn)
+		if err != nil {
+			t.Fatal(err)
+		} else if exp := map[uint64]uint64{2: 1, 1: 2}; !reflect.DeepEqual(h, exp) {
+			t.Fatalf("unexpected histogram: %v", h)
+		}
+	})
+
+	t.Run("ErrInvalidDimension", func(t *testing.T) {
+		if _, err := m0.API.FieldHistogram(ctx, index, field, "cell"); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+
+	t.Run("ErrFieldNotFound", func(t *testing.T) {
+		if _, err := m0.API.FieldHistogram(ctx, index, "nofield", pilosa.HistogramDimensionRow); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+}
+
+func TestAPI_ClusterMembershipView(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+
+	m0 := c[0]
+	view, err := m0.API.ClusterMembershipView(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nodeID := m0.API.Node().ID
+	if view.NodeID != nodeID {
+		t.Fatalf("unexpected node id: %s", view.NodeID)
+	} else if view.CoordinatorID != nodeID {
+		t.Fatalf("unexpected coordinator id: %s", view.CoordinatorID)
+	} else if view.State != pilosa.ClusterStateNormal {
+		t.Fatalf("unexpected state: %s", view.State)
+	} else if len(view.Nodes) != 1 || view.Nodes[0].ID != nodeID {
+		t.Fatalf("unexpected nodes: %+v", view.Nodes)
+	}
+}
+
+func TestAPI_ImportBatch(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+
+	m0 := c[0]
+	ctx := context.Background()
+	index := "i"
+
+	m0.MustCreateIndex(t, index, pilosa.IndexOptions{})
+	m0.MustCreateField(t, index, "s", pilosa.OptFieldTypeSet(pilosa.DefaultCacheType, 100))
+	m0.MustCreateField(t, index, "m", pilosa.OptFieldTypeMutex(pilosa.DefaultCacheType, 100))
+	m0.MustCreateField(t, index, "b", pilosa.OptFieldTypeBool())
+
+	query := func(pql string) []uint64 {
+		res, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: index, Query: pql})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.Results[0].(*pilosa.Row).Columns()
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		if err := m0.API.ImportBatch(ctx, index, []pilosa.FieldImport{
+			{Field: "s", RowIDs: []uint64{1, 1}, ColumnIDs: []uint64{1, pilosa.ShardWidth + 1}},
+			{Field: "m", RowIDs: []uint64{1, 2}, ColumnIDs: []uint64{1, pilosa.ShardWidth + 1}},
+			{Field: "b", RowIDs: []uint64{1}, ColumnIDs: []uint64{1}},
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		if columns := query("Row(s=1)"); !reflect.DeepEqual(columns, []uint64{1, pilosa.ShardWidth + 1}) {
+			t.Fatalf("unexpected columns: %v", columns)
+		} else if columns := query("Row(m=2)"); !reflect.DeepEqual(columns, []uint64{pilosa.ShardWidth + 1}) {
+			t.Fatalf("unexpected columns: %v", columns)
+		} else if columns := query("Row(b=true)"); !reflect.DeepEqual(columns, []uint64{1}) {
+			t.Fatalf("unexpected columns: %v", columns)
+		}
+	})
+
+	t.Run("Rollback", func(t *testing.T) {
+		// The bool field rejects row 2, so the set and mutex imports must be
+		// reverted.
+		if err := m0.API.ImportBatch(ctx, index, []pilosa.FieldImport{
+			{Field: "s", RowIDs: []uint64{2, 1}, ColumnIDs: []uint64{5, 1}},
+			{Field: "m", RowIDs: []uint64{2}, ColumnIDs: []uint64{1}},
+			{Field: "b", RowIDs: []uint64{2}, ColumnIDs: []uint64{5}},
+		}); err == nil {
+			t.Fatal("expected error")
+		}
+
+		if columns := query("Row(s=2)"); len(columns) != 0 {
+			t.Fatalf("unexpected columns: %v", columns)
+		} else if columns := query("Row(s=1)"); !reflect.DeepEqual(columns, []uint64{1, pilosa.ShardWidth + 1}) {
+			t.Fatalf("unexpected columns: %v", columns)
+		} else if columns := query("Row(m=1)"); !reflect.DeepEqual(columns, []uint64{1}) {
+			t.Fatalf("unexpected columns: %v", columns)
+		} else if columns := query("Row(m=2)"); !reflect.DeepEqual(columns, []uint64{pilosa.ShardWidth + 1}) {
+			t.Fatalf("unexpected columns: %v", columns)
+		}
+	})
+
+	t.Run("ErrFieldNotFound", func(t *testing.T) {
+		if err := m0.API.ImportBatch(ctx, index, []pilosa.FieldImport{
+			{Field: "s", RowIDs: []uint64{3}, ColumnIDs: []uint64{1}},
+			{Field: "nofield", RowIDs: []uint64{3}, ColumnIDs: []uint64{1}},
+		}); err == nil {
+			t.Fatal("expected error")
+		} else if columns := query("Row(s=3)"); len(columns) != 0 {
+			t.Fatalf("unexpected columns: %v", columns)
+		}
+	})
+}
+
+func TestAPI_RecentQueries(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+
+	m0 := c[0]
+	ctx := context.Background()
+	m0.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	m0.MustCreateField(t, "i", "f")
+
+	for _, pql := range []string{"Set(1, f=1)", "Count(Row(f=1))", "Row(nofield=1)"} {
+		_, _ = m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: pql})
+	}
+
+	entries, err := m0.API.RecentQueries(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(entries) != 2 {
+		t.Fatalf("unexpected entry count: %d", len(entries))
+	}
+
+	if entries[0].PQL != "Row(nofield=1)" || entries[0].Err == "" {
+		t.Fatalf("unexpected entry: %+v", entries[0])
+	} else if entries[1].PQL != "Count(Row(f=1))" || entries[1].Err != "" || entries[1].Index != "i" || entries[1].ShardN != 1 {
+		t.Fatalf("unexpected entry: %+v", entries[1])
+	}
+}
+
+func TestAPI_Query_SingleShard(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+
+	m0 := c[0]
+	ctx := context.Background()
+	index := "i"
+
+	m0.MustCreateIndex(t, index, pilosa.IndexOptions{})
+	m0.MustCreateField(t, index, "f")
+
+	if _, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: index, Query: fmt.Sprintf(`
+		Set(1, f=1)
+		Set(%d, f=1)
+		Set(%d, f=1)`, pilosa.ShardWidth+1, 2*pilosa.ShardWidth+1),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	shard := uint64(1)
+	res, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: index, Query: `Row(f=1)`, SingleShard: &shard})
+	if err != nil {
+		t.Fatal(err)
+	} else if columns := res.Results[0].(*pilosa.Row).Columns(); !reflect.DeepEqual(columns, []uint64{pilosa.ShardWidth + 1}) {
+		t.Fatalf("unexpected columns: %+v", columns)
+	}
+
+	_, err = m0.API.Query(ctx, &pilosa.QueryRequest{Index: index, Query: `Row(f=1)`, Shards: []uint64{0}, SingleShard: &shard})
+	if _, ok := errors.Cause(err).(pilosa.BadRequestError); !ok {
+		t.Fatalf("expected bad request error, got: %v", err)
+	}
+}
+
+func TestAPI_AttrSchema(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+
+	m0 := c[0]
+	ctx := context.Background()
+
+	m0.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	m0.MustCreateIndex(t, "j", pilosa.IndexOptions{})
+
+	if _, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: `
+		SetColumnAttrs(1, name="a", age=3)
+		SetColumnAttrs(2, active=true, score=1.5)`,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Export the schema inferred from the attributes in "i".
+	schema, err := m0.API.ExportAttrSchema(ctx, "i")
+	if err != nil {
+		t.Fatal(err)
+	} else if exp := (pilosa.AttrSchema{"name": "string", "age": "int", "active": "bool", "score": "float"}); !reflect.DeepEqual(schema, exp) {
+		t.Fatalf("unexpected schema: %v", schema)
+	}
+
+	// Register it on "j" before any attributes exist.
+	if err := m0.API.ImportAttrSchema(ctx, "j", schema); err != nil {
+		t.Fatal(err)
+	} else if other, err := m0.API.ExportAttrSchema(ctx, "j"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(other, schema) {
+		t.Fatalf("unexpected schema: %v", other)
+	}
+
+	t.Run("TypeMismatch", func(t *testing.T) {
+		if _, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "j", Query: `SetColumnAttrs(1, age="old")`}); errors.Cause(err) != pilosa.ErrAttrSchemaConflict {
+			t.Fatalf("unexpected error: %v", err)
+		} else if _, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "j", Query: `SetColumnAttrs(1, age=4, other="x")`}); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Conflict", func(t *testing.T) {
+		err := m0.API.ImportAttrSchema(ctx, "j", pilosa.AttrSchema{"age": "string"})
+		if _, ok := errors.Cause(err).(pilosa.ConflictError); !ok {
+			t.Fatalf("expected conflict error, got: %v", err)
+		}
+	})
+
+	t.Run("InvalidType", func(t *testing.T) {
+		err := m0.API.ImportAttrSchema(ctx, "j", pilosa.AttrSchema{"x": "date"})
+		if _, ok := errors.Cause(err).(pilosa.BadRequestError); !ok {
+			t.Fatalf("expected bad request error, got: %v", err)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		if _, err := m0.API.ExportAttrSchema(ctx, "k"); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+}
+
+func TestAPI_AggregateAcrossIndexes(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+
+	m0 := c[0]
+	ctx := context.Background()
+
+	for i, index := range []string{"a", "b", "c"} {
+		m0.MustCreateIndex(t, index, pilosa.IndexOptions{})
+		if index == "c" {
+			m0.MustCreateField(t, index, "other")
+			continue
+		}
+		m0.MustCreateField(t, index, "f")
+		for col := 0; col <= i; col++ {
+			if _, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: index, Query: fmt.Sprintf("Set(%d, f=1)", col)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	results, err := m0.API.AggregateAcrossIndexes(ctx, "f", "Count(Row(f=1))")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(results) != 2 {
+		t.Fatalf("unexpected results: %+v", results)
+	} else if n := results["a"].Results[0]; n != uint64(1) {
+		t.Fatalf("unexpected count for a: %v", n)
+	} else if n := results["b"].Results[0]; n != uint64(2) {
+		t.Fatalf("unexpected count for b: %v", n)
+	}
+
+	if _, err := m0.API.AggregateAcrossIndexes(ctx, "f", "Count("); err == nil {
+		t.Fatal("expected parse error")
+	}
+}
+
+func TestAPI_FragmentModTime(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+
+	m0 := c[0]
+	ctx := context.Background()
+
+	m0.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	m0.MustCreateField(t, "i", "f")
+	if _, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf("Set(1, f=1) Set(%d, f=1)", 2*pilosa.ShardWidth)}); err != nil {
+		t.Fatal(err)
+	}
+
+	modTimes, err := m0.API.FragmentModTimes(ctx, "i", "f")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(modTimes) != 2 || modTimes[0].IsZero() || modTimes[2].IsZero() {
+		t.Fatalf("unexpected mod times: %v", modTimes)
+	}
+
+	if modTime, err := m0.API.FragmentModTime(ctx, "i", "f", 2); err != nil {
+		t.Fatal(err)
+	} else if !modTime.Equal(modTimes[2]) {
+		t.Fatalf("unexpected mod time: %v, expected %v", modTime, modTimes[2])
+	}
+
+	if _, err := m0.API.FragmentModTime(ctx, "i", "f", 1); err == nil {
+		t.Fatal("expected error for missing fragment")
+	} else if _, ok := errors.Cause(err).(pilosa.NotFoundError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := m0.API.FragmentModTimes(ctx, "i", "g"); err == nil {
+		t.Fatal("expected error for missing field")
+	}
+}
+
+func TestAPI_TwoPhaseCreateIndex(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+
+	m0 := c[0]
+	ctx := context.Background()
+
+	t.Run("Commit", func(t *testing.T) {
+		if err := m0.API.PrepareCreateIndex(ctx, "i", pilosa.IndexOptions{Keys: true}); err != nil {
+			t.Fatal(err)
+		}
+
+		// The reserved name cannot be used by a regular create.
+		if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err == nil {
+			t.Fatal("expected conflict creating reserved index")
+		} else if _, ok := errors.Cause(err).(pilosa.ConflictError); !ok {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := m0.API.Index(ctx, "i"); err == nil {
+			t.Fatal("expected index not to exist before commit")
+		}
+
+		if _, err := m0.API.CommitCreateIndex(ctx, "i"); err != nil {
+			t.Fatal(err)
+		}
+		if idx, err := m0.API.Index(ctx, "i"); err != nil {
+			t.Fatal(err)
+		} else if !idx.Keys() {
+			t.Fatal("expected committed index to use keys")
+		}
+
+		// Committing again is a no-op.
+		if _, err := m0.API.CommitCreateIndex(ctx, "i"); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Abort", func(t *testing.T) {
+		if err := m0.API.PrepareCreateIndex(ctx, "j", pilosa.IndexOptions{}); err != nil {
+			t.Fatal(err)
+		} else if err := m0.API.AbortCreateIndex(ctx, "j"); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := m0.API.CommitCreateIndex(ctx, "j"); err == nil {
+			t.Fatal("expected error committing aborted index")
+		} else if _, ok := errors.Cause(err).(pilosa.NotFoundError); !ok {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := m0.API.CreateIndex(ctx, "j", pilosa.IndexOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		if err := m0.API.PrepareCreateIndex(ctx, "Bad Name", pilosa.IndexOptions{}); err == nil {
+			t.Fatal("expected error")
+		} else if _, ok := errors.Cause(err).(pilosa.BadRequestError); !ok {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := m0.API.PrepareCreateIndex(ctx, "i", pilosa.IndexOptions{}); err == nil {
+			t.Fatal("expected error preparing existing index")
+		}
+	})
+}
+
+func TestAPI_EstimateRowCount(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+
+	m0 := c[0]
+	ctx := context.Background()
+
+	m0.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	m0.MustCreateField(t, "i", "ranked")
+	m0.MustCreateField(t, "i", "uncached", pilosa.OptFieldTypeSet(pilosa.CacheTypeNone, 0))
+	for _, col := range []uint64{1, 2, pilosa.ShardWidth + 1, 3 * pilosa.ShardWidth} {
+		q := fmt.Sprintf("Set(%d, ranked=7) Set(%d, uncached=7)", col, col)
+		if _, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: q}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, field := range []string{"ranked", "uncached"} {
+		if n, err := m0.API.EstimateRowCount(ctx, "i", field, 7); err != nil {
+			t.Fatal(err)
+		} else if n != 4 {
+			t.Fatalf("unexpected count for %s: %d", field, n)
+		}
+		if n, err := m0.API.EstimateRowCount(ctx, "i", field, 8); err != nil {
+			t.Fatal(err)
+		} else if n != 0 {
+			t.Fatalf("unexpected count for empty row in %s: %d", field, n)
+		}
+	}
+
+	if _, err := m0.API.EstimateRowCount(ctx, "i", "missing", 7); err == nil {
+		t.Fatal("expected error for missing field")
+	}
+}
+
+func TestAPI_ImportValueNull(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+
+	m0 := c[0]
+	ctx := context.Background()
+
+	m0.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	m0.MustCreateField(t, "i", "f", pilosa.OptFieldTypeInt(-10, 100))
+
+	// Set a value on every column, then clear some of them with nulls.
+	if err := m0.API.ImportValue(ctx, &pilosa.ImportValueRequest{
+		Index:     "i",
+		Field:     "f",
+		ColumnIDs: []uint64{1, 2, 3, 4},
+		Values:    []int64{5, 5, 5, 5},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m0.API.ImportValue(ctx, &pilosa.ImportValueRequest{
+		Index:     "i",
+		Field:     "f",
+		ColumnIDs: []uint64{1, 2, 3, 5},
+		Values:    []int64{0, 500, 7, 0},
+		Null:      []bool{false, true, false, true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for pql, exp := range map[string][]uint64{
+		"Row(f >= -10)": {1, 3, 4},
+		"Row(f == 0)":   {1},
+		"Row(f == 7)":   {3},
+	} {
+		if res, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: pql}); err != nil {
+			t.Fatal(err)
+		} else if cols := res.Results[0].(*pilosa.Row).Columns(); !reflect.DeepEqual(cols, exp) {
+			t.Fatalf("%s: unexpected columns: %v", pql, cols)
+		}
+	}
+
+	if err := m0.API.ImportValue(ctx, &pilosa.ImportValueRequest{
+		Index:     "i",
+		Field:     "f",
+		ColumnIDs: []uint64{1, 2},
+		Values:    []int64{1, 2},
+		Null:      []bool{true},
+	}); err == nil {
+		t.Fatal("expected error for mismatched nulls")
+	}
+}
+
+// recordingStatsClient records the names and tags of counts.
+type recordingStatsClient struct {
+	stats.StatsClient
+	tags   []string
+	mu     *sync.Mutex
+	counts map[string][]string
+}
+
+func newRecordingStatsClient() *recordingStatsClient {
+	return &recordingStatsClient{
+		StatsClient: stats.NopStatsClient,
+		mu:          &sync.Mutex{},
+		counts:      make(map[string][]string),
+	}
+}
+
+func (c *recordingStatsClient) Tags() []string { return c.tags }
+
+func (c *recordingStatsClient) WithTags(tags ...string) stats.StatsClient {
+	other := *c
+	other.tags = append(append([]string{}, c.tags...), tags...)
+	return &other
+}
+
+func (c *recordingStatsClient) Count(name string, value int64, rate float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[name] = c.tags
+}
+
+func TestAPI_SetStatsClient(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+
+	m0 := c[0]
+	ctx := context.Background()
+
+	m0.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	m0.MustCreateField(t, "i", "f")
+	if _, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: "Set(1, f=1)"}); err != nil {
+		t.Fatal(err)
+	}
+
+	sc := newRecordingStatsClient()
+	if err := m0.API.SetStatsClient(sc); err != nil {
+		t.Fatal(err)
+	}
+
+	// Existing fragments report to the new client with their tags.
+	if _, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: "Set(2, f=1)"}); err != nil {
+		t.Fatal(err)
+	}
+	sc.mu.Lock()
+	tags := sc.counts["setBit"]
+	sc.mu.Unlock()
+	got := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		got[tag] = true
+	}
+	for _, tag := range []string{"NodeID:" + m0.API.Node().ID, "index:i", "field:f", "view:standard", "shard:0"} {
+		if !got[tag] {
+			t.Fatalf("missing tag %q: %v", tag, tags)
+		}
+	}
+
+	if err := m0.API.SetStatsClient(nil); err == nil {
+		t.Fatal("expected error for nil client")
+	}
+}
+
+func TestAPI_ExportCSVKeys(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+
+	m0 := c[0]
+	ctx := context.Background()
+
+	m0.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	m0.MustCreateField(t, "i", "f", pilosa.OptFieldKeys())
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `Set(1, f="a")`})
+
+	// Row 9 is written by ID, so it has no key.
+	if err := m0.API.Import(ctx, &pilosa.ImportRequest{
+		Index:     "i",
+		Field:     "f",
+		RowIDs:    []uint64{9},
+		ColumnIDs: []uint64{2},
+	}, pilosa.OptImportOptionsIgnoreKeyCheck(true)); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := m0.API.ExportCSV(ctx, "i", "f", 0, &buf); err != nil {
+		t.Fatal(err)
+	} else if buf.String() != "a,1\n9,2\n" {
+		t.Fatalf("unexpected export: %q", buf.String())
+	}
+}
+
+func TestAPI_ExportParquet(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+
+	m0 := c[0]
+	ctx := context.Background()
+
+	m0.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	m0.MustCreateField(t, "i", "f")
+	if _, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: "Set(3, f=1) Set(1, f=2)"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := m0.API.ExportParquet(ctx, "i", "f", 0, &buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if !bytes.HasPrefix(data, []byte("PAR1")) || !bytes.HasSuffix(data, []byte("PAR1")) {
+		t.Fatalf("unexpected file: %q", data)
+	}
+	// The single row group holds the row column followed by the column column.
+	if !bytes.Contains(data, []byte{1, 0, 0, 0, 0, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0}) ||
+		!bytes.Contains(data, []byte{3, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0}) {
+		t.Fatalf("row and column values not found: %v", data)
+	}
+
+	// A shard without data produces an empty file.
+	buf.Reset()
+	if err := m0.API.ExportParquet(ctx, "i", "f", 5, &buf); err != nil {
+		t.Fatal(err)
+	} else if !bytes.HasPrefix(buf.Bytes(), []byte("PAR1")) {
+		t.Fatalf("unexpected empty file: %q", buf.Bytes())
+	}
+
+	if err := m0.API.ExportParquet(ctx, "i", "missing", 0, &buf); err == nil {
+		t.Fatal("expected error for missing field")
+	}
+}
+
+func TestAPI_ExportNDJSON(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+
+	m0 := c[0]
+	ctx := context.Background()
+
+	m0.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	m0.MustCreateField(t, "i", "f")
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: "Set(3, f=1) Set(1, f=2) Set(4096, f=12)"})
+
+	var buf bytes.Buffer
+	if err := m0.API.ExportNDJSON(ctx, "i", "f", 0, &buf); err != nil {
+		t.Fatal(err)
+	} else if exp := "{\"row\":1,\"col\":3}\n{\"row\":2,\"col\":1}\n{\"row\":12,\"col\":4096}\n"; buf.String() != exp {
+		t.Fatalf("unexpected export: %q", buf.String())
+	}
+
+	// A shard without data writes nothing.
+	buf.Reset()
+	if err := m0.API.ExportNDJSON(ctx, "i", "f", 5, &buf); err != nil {
+		t.Fatal(err)
+	} else if buf.Len() != 0 {
+		t.Fatalf("unexpected export: %q", buf.String())
+	}
+
+	if err := m0.API.ExportNDJSON(ctx, "i", "missing", 0, &buf); err == nil {
+		t.Fatal("expected error for missing field")
+	}
+}
+
+func TestAPI_RecomputeMaxShard(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+
+	m0 := c[0]
+	ctx := context.Background()
+
+	m0.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	m0.MustCreateField(t, "i", "f")
+	q := fmt.Sprintf("Set(1, f=1) Set(%d, f=1)", 3*pilosa.ShardWidth+1)
+	if _, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: q}); err != nil {
+		t.Fatal(err)
+	}
+
+	if max, err := m0.API.RecomputeMaxShard(ctx, "i"); err != nil {
+		t.Fatal(err)
+	} else if max != 3 {
+		t.Fatalf("unexpected max shard: %d", max)
+	}
+
+	if _, err := m0.API.RecomputeMaxShard(ctx, "missing"); err == nil {
+		t.Fatal("expected error for missing index")
+	} else if _, ok := errors.Cause(err).(pilosa.NotFoundError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAPI_QueryAttrFilter(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+
+	m0 := c[0]
+	ctx := context.Background()
+
+	m0.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	m0.MustCreateField(t, "i", "f")
+	q := fmt.Sprintf(`Set(1, f=1) Set(2, f=1) Set(%d, f=1)
+		SetColumnAttrs(1, segment="a", score=5)
+		SetColumnAttrs(2, segment="b", score=5)
+		SetColumnAttrs(%d, segment="a", score=6)`, pilosa.ShardWidth+1, pilosa.ShardWidth+1)
+	if _, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: q}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		filter map[string]interface{}
+		exp    []uint64
+	}{
+		{filter: map[string]interface{}{"segment": "a"}, exp: []uint64{1, pilosa.ShardWidth + 1}},
+		{filter: map[string]interface{}{"segment": "a", "score": float64(5)}, exp: []uint64{1}},
+		{filter: map[string]interface{}{"score": int64(5)}, exp: []uint64{1, 2}},
+		{filter: map[string]interface{}{"segment": "c"}, exp: []uint64{}},
+	} {
+		resp, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: "Row(f=1)", ColumnAttrs: true, AttrFilter: tt.filter})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cols := resp.Results[0].(*pilosa.Row).Columns(); !reflect.DeepEqual(cols, tt.exp) {
+			t.Fatalf("filter %v: unexpected columns: %v", tt.filter, cols)
+		} else if len(resp.ColumnAttrSets) != len(tt.exp) {
+			t.Fatalf("filter %v: unexpected column attrs: %v", tt.filter, resp.ColumnAttrSets)
+		}
+	}
+
+	if _, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: "Row(f=1)", AttrFilter: map[string]interface{}{"x": []int{1}}}); err == nil {
+		t.Fatal("expected error for invalid filter value")
+	}
+}
+
+func TestAPI_TranslateRowKeys(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+
+	m0 := c[0]
+	ctx := context.Background()
+
+	m0.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	m0.MustCreateField(t, "i", "tags", pilosa.OptFieldKeys())
+	m0.MustCreateField(t, "i", "f")
+
+	ids, err := m0.API.TranslateRowKeys(ctx, "i", "tags", []string{"red", "blue", "red"})
+	if err != nil {
+		t.Fatal(err)
+	} else if ids[0] == 0 || ids[1] == 0 || ids[0] == ids[1] || ids[0] != ids[2] {
+		t.Fatalf("unexpected ids: %v", ids)
+	}
+
+	if keys, err := m0.API.TranslateRowIDs(ctx, "i", "tags", ids); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(keys, []string{"red", "blue", "red"}) {
+		t.Fatalf("unexpected keys: %v", keys)
+	}
+
+	if _, err := m0.API.TranslateRowIDs(ctx, "i", "tags", []uint64{1000}); err == nil {
+		t.Fatal("expected error for unknown id")
+	} else if _, ok := errors.Cause(err).(pilosa.NotFoundError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := m0.API.TranslateRowKeys(ctx, "i", "f", []string{"red"}); err == nil {
+		t.Fatal("expected error for field without keys")
+	}
+	if _, err := m0.API.TranslateRowKeys(ctx, "i", "missing", []string{"red"}); err == nil {
+		t.Fatal("expected error for missing field")
+	}
+}
+
+func TestAPI_OwnershipMap(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+
+	m0 := c[0]
+	ctx := context.Background()
+
+	m0.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	m0.MustCreateField(t, "i", "f")
+
+	if m, err := m0.API.OwnershipMap(ctx, "i"); err != nil {
+		t.Fatal(err)
+	} else if len(m) != 0 {
+		t.Fatalf("unexpected ownership for empty index: %v", m)
+	}
+
+	q := fmt.Sprintf("Set(1, f=1) Set(%d, f=1)", 2*pilosa.ShardWidth+1)
+	if _, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: q}); err != nil {
+		t.Fatal(err)
+	}
+
+	id := m0.API.Node().ID
+	if m, err := m0.API.OwnershipMap(ctx, "i"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(m, map[uint64][]string{0: {id}, 1: {id}, 2: {id}}) {
+		t.Fatalf("unexpected ownership: %v", m)
+	}
+
+	if _, err := m0.API.OwnershipMap(ctx, "missing"); err == nil {
+		t.Fatal("expected error for missing index")
+	}
+}
+
+func TestAPI_FieldMeta(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+
+	m0 := c[0]
+	ctx := context.Background()
+
+	m0.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	m0.MustCreateIndex(t, "j", pilosa.IndexOptions{})
+	m0.MustCreateField(t, "i", "t", pilosa.OptFieldTypeTime(pilosa.TimeQuantum("YM")), pilosa.OptFieldKeys())
+	if _, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: `Set(1, t="a", 2018-03-01T00:00)`}); err != nil {
+		t.Fatal(err)
+	}
+
+	blob, err := m0.API.ExportFieldMeta(ctx, "i", "t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := m0.API.ImportFieldMeta(ctx, "j", "t2", blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := m0.API.Field(ctx, "i", "t")
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(f.Options(), src.Options()) {
+		t.Fatalf("unexpected options: %+v", f.Options())
+	}
+
+	views := make(map[string][]string)
+	for _, ii := range m0.Server.Holder().Schema() {
+		for _, fi := range ii.Fields {
+			for _, vi := range fi.Views {
+				views[fi.Name] = append(views[fi.Name], vi.Name)
+			}
+		}
+	}
+	if len(views["t2"]) != 3 || !reflect.DeepEqual(views["t2"], views["t"]) {
+		t.Fatalf("unexpected views: %v", views)
+	}
+
+	if resp, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "j", Query: `Count(Row(t2="a"))`}); err != nil {
+		t.Fatal(err)
+	} else if n := resp.Results[0].(uint64); n != 0 {
+		t.Fatalf("expected no data to be copied, got %d", n)
+	}
+
+	if _, err := m0.API.ImportFieldMeta(ctx, "j", "t2", blob); err == nil {
+		t.Fatal("expected error for existing field")
+	} else if _, err := m0.API.ImportFieldMeta(ctx, "j", "t3", []byte("bad")); err == nil {
+		t.Fatal("expected error for invalid blob")
+	} else if _, err := m0.API.ExportFieldMeta(ctx, "i", "missing"); err == nil {
+		t.Fatal("expected error for missing field")
+	}
+}
+
+func TestAPI_QueryIncludeKeys(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+
+	m0 := c[0]
+	ctx := context.Background()
+
+	m0.MustCreateIndex(t, "i", pilosa.IndexOptions{Keys: true})
+	m0.MustCreateField(t, "i", "f", pilosa.OptFieldKeys())
+	if _, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: `Set("a", f="x") SetColumnAttrs("a", n=1)`}); err != nil {
+		t.Fatal(err)
+	}
+
+	q := `Row(f="x") TopN(f) Rows(field=f)`
+	resp, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: q, ColumnAttrs: true, IncludeKeys: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	row := resp.Results[0].(*pilosa.Row)
+	if len(row.Columns()) != 1 || !reflect.DeepEqual(row.Keys, []string{"a"}) {
+		t.Fatalf("unexpected row: columns=%v keys=%v", row.Columns(), row.Keys)
+	}
+	if pairs := resp.Results[1].([]pilosa.Pair); len(pairs) != 1 || pairs[0].ID == 0 || pairs[0].Key != "x" {
+		t.Fatalf("unexpected pairs: %v", pairs)
+	}
+	if ids := resp.Results[2].(pilosa.RowIdentifiers); len(ids.Rows) != 1 || !reflect.DeepEqual(ids.Keys, []string{"x"}) {
+		t.Fatalf("unexpected row identifiers: %v", ids)
+	}
+	if attrs := resp.ColumnAttrSets; len(attrs) != 1 || attrs[0].ID != row.Columns()[0] || attrs[0].Key != "a" {
+		t.Fatalf("unexpected column attrs: %v", attrs)
+	}
+
+	// Without IncludeKeys, ids are replaced by keys.
+	resp, err = m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: q})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row := resp.Results[0].(*pilosa.Row); len(row.Columns()) != 0 || !reflect.DeepEqual(row.Keys, []string{"a"}) {
+		t.Fatalf("unexpected row: columns=%v keys=%v", row.Columns(), row.Keys)
+	} else if pairs := resp.Results[1].([]pilosa.Pair); pairs[0].ID != 0 {
+		t.Fatalf("unexpected pairs: %v", pairs)
+	}
+}
+
+func TestAPI_BackupRestoreNode(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+
+	m0 := c[0]
+	ctx := context.Background()
+
+	m0.MustCreateIndex(t, "i", pilosa.IndexOptions{TrackExistence: true})
+	m0.MustCreateField(t, "i", "f")
+	m0.MustCreateField(t, "i", "n", pilosa.OptFieldTypeInt(0, 100))
+	m0.MustCreateIndex(t, "k", pilosa.IndexOptions{Keys: true})
+	m0.MustCreateField(t, "k", "t", pilosa.OptFieldKeys())
+
+	q := fmt.Sprintf(`Set(1, f=1) Set(%d, f=1) Set(2, n=42)
+		SetRowAttrs(f, 1, name="one") SetColumnAttrs(1, active=true)`, 3*pilosa.ShardWidth+2)
+	if _, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: q}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "k", Query: `Set("a", t="x") Set("b", t="y")`}); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := m0.API.BackupNode(ctx, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	c1 := test.MustRunCluster(t, 1)
+	defer c1.Close()
+	m1 := c1[0]
+	if err := m1.API.RestoreNode(ctx, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := m1.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: "Row(f=1) Sum(field=n) Count(Row(f=1))", ColumnAttrs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	row := resp.Results[0].(*pilosa.Row)
+	if !reflect.DeepEqual(row.Columns(), []uint64{1, 3*pilosa.ShardWidth + 2}) {
+		t.Fatalf("unexpected columns: %v", row.Columns())
+	} else if !reflect.DeepEqual(row.Attrs, map[string]interface{}{"name": "one"}) {
+		t.Fatalf("unexpected row attrs: %v", row.Attrs)
+	} else if len(resp.ColumnAttrSets) != 1 || resp.ColumnAttrSets[0].Attrs["active"] != true {
+		t.Fatalf("unexpected column attrs: %v", resp.ColumnAttrSets)
+	} else if vc := resp.Results[1].(pilosa.ValCount); vc.Val != 42 || vc.Count != 1 {
+		t.Fatalf("unexpected sum: %+v", vc)
+	}
+
+	if idx, err := m1.API.Index(ctx, "k"); err != nil {
+		t.Fatal(err)
+	} else if !idx.Keys() {
+		t.Fatal("expected index options to be restored")
+	}
+	resp, err = m1.API.Query(ctx, &pilosa.QueryRequest{Index: "k", Query: `Row(t="y")`})
+	if err != nil {
+		t.Fatal(err)
+	} else if keys := resp.Results[0].(*pilosa.Row).Keys; !reflect.DeepEqual(keys, []string{"b"}) {
+		t.Fatalf("unexpected keys: %v", keys)
+	}
+
+	if err := m1.API.RestoreNode(ctx, strings.NewReader("bad archive")); err == nil {
+		t.Fatal("expected error for invalid archive")
+	}
+}
+
+func TestAPI_BackupRestoreIndex(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+
+	m0 := c[0]
+	ctx := context.Background()
+
+	m0.MustCreateIndex(t, "i", pilosa.IndexOptions{TrackExistence: true})
+	m0.MustCreateField(t, "i", "f")
+	m0.MustCreateField(t, "i", "n", pilosa.OptFieldTypeInt(0, 100))
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf(`Set(1, f=1) Set(%d, f=1) Set(2, n=42)
+		SetRowAttrs(f, 1, name="one") SetColumnAttrs(1, active=true)`, 3*pilosa.ShardWidth+2)})
+
+	var buf bytes.Buffer
+	if n, err := m0.API.BackupIndex(ctx, "i", &buf); err != nil {
+		t.Fatal(err)
+	} else if n != int64(buf.Len()) {
+		t.Fatalf("unexpected size: %d, wrote %d", n, buf.Len())
+	}
+
+	// Restore the archive as a new index.
+	if err := m0.API.RestoreIndex(ctx, "j", &buf); err != nil {
+		t.Fatal(err)
+	}
+	if idx, err := m0.API.Index(ctx, "j"); err != nil {
+		t.Fatal(err)
+	} else if !idx.Options().TrackExistence {
+		t.Fatal("expected index options to be restored")
+	}
+	resp := m0.MustQuery(t, &pilosa.QueryRequest{Index: "j", Query: "Row(f=1) Sum(field=n)", ColumnAttrs: true})
+	row := resp.Results[0].(*pilosa.Row)
+	if !reflect.DeepEqual(row.Columns(), []uint64{1, 3*pilosa.ShardWidth + 2}) {
+		t.Fatalf("unexpected columns: %v", row.Columns())
+	} else if !reflect.DeepEqual(row.Attrs, map[string]interface{}{"name": "one"}) {
+		t.Fatalf("unexpected row attrs: %v", row.Attrs)
+	} else if len(resp.ColumnAttrSets) != 1 || resp.ColumnAttrSets[0].Attrs["active"] != true {
+		t.Fatalf("unexpected column attrs: %v", resp.ColumnAttrSets)
+	} else if vc := resp.Results[1].(pilosa.ValCount); vc.Val != 42 || vc.Count != 1 {
+		t.Fatalf("unexpected sum: %+v", vc)
+	}
+
+	// Column and row keys are restored with their ids.
+	m0.MustCreateIndex(t, "k", pilosa.IndexOptions{Keys: true})
+	m0.MustCreateField(t, "k", "f", pilosa.OptFieldKeys())
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "k", Query: `Set("a", f="x") Set("b", f="y") Set("c", f="x")`})
+	buf.Reset()
+	if _, err := m0.API.BackupIndex(ctx, "k", &buf); err != nil {
+		t.Fatal(err)
+	} else if err := m0.API.RestoreIndex(ctx, "l", &buf); err != nil {
+		t.Fatal(err)
+	}
+	resp = m0.MustQuery(t, &pilosa.QueryRequest{Index: "l", Query: `Row(f="x") Rows(f)`})
+	if keys := resp.Results[0].(*pilosa.Row).Keys; !reflect.DeepEqual(keys, []string{"a", "c"}) {
+		t.Fatalf("unexpected column keys: %v", keys)
+	} else if keys := resp.Results[1].(pilosa.RowIdentifiers).Keys; !reflect.DeepEqual(keys, []string{"x", "y"}) {
+		t.Fatalf("unexpected row keys: %v", keys)
+	}
+
+	if _, err := m0.API.BackupIndex(ctx, "x", &buf); err == nil {
+		t.Fatal("expected error")
+	} else if _, ok := errors.Cause(err).(pilosa.NotFoundError); !ok {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+	if err := m0.API.RestoreIndex(ctx, "j", strings.NewReader("bad archive")); err == nil {
+		t.Fatal("expected error for invalid archive")
+	}
+}
+
+func TestAPI_QueryFieldRefs(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	names, err := m0.API.QueryFieldRefs(ctx, `
+		Count(Intersect(Row(a=1), Row(b > 10)))
+		Sum(Row(c=2), field=d)
+		TopN(e, n=5)
+		GroupBy(Rows(field=f), filter=Row(g=3))
+		SetColumnAttrs(1, name="x")
+		SetRowAttrs(h, 1, name="y")
+		Store(Row(a=1), i=4)`)
+	if err != nil {
+		t.Fatal(err)
+	} else if exp := []string{"a", "b", "c", "d", "e", "f", "g", "h", "i"}; !reflect.DeepEqual(names, exp) {
+		t.Fatalf("unexpected fields: %v", names)
+	}
+
+	if _, err := m0.API.QueryFieldRefs(ctx, "Row(a=1"); err == nil {
+		t.Fatal("expected parse error")
+	} else if _, ok := errors.Cause(err).(pilosa.BadRequestError); !ok {
+		t.Fatalf("unexpected error type: %T", errors.Cause(err))
+	}
+}
+
+func TestAPI_ImportChecksum(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	}
+
+	req := &pilosa.ImportRequest{Index: "i", Field: "f", RowIDs: []uint64{1, 2}, ColumnIDs: []uint64{10, 20}}
+	req.PayloadChecksum = req.Checksum()
+	req.ColumnIDs[1] = 30
+	if err := m0.API.Import(ctx, req); errors.Cause(err) != pilosa.ErrImportChecksumMismatch {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+
+	req.PayloadChecksum = req.Checksum()
+	if err := m0.API.Import(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: "Row(f=2)"})
+	if err != nil {
+		t.Fatal(err)
+	} else if cols := resp.Results[0].(*pilosa.Row).Columns(); !reflect.DeepEqual(cols, []uint64{30}) {
+		t.Fatalf("unexpected columns: %v", cols)
+	}
+}
+
+func TestAPI_Coordinator(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	id, epoch, err := m0.API.Coordinator(ctx)
+	if err != nil {
+		t.Fatal(err)
+	} else if id != m0.API.Node().ID || epoch != 0 {
+		t.Fatalf("unexpected coordinator: id=%s, epoch=%d", id, epoch)
+	}
+
+	// Each election increases the epoch, even if the coordinator is unchanged.
+	for i := uint64(1); i <= 2; i++ {
+		if _, _, err := m0.API.SetCoordinator(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+		if _, epoch, err := m0.API.Coordinator(ctx); err != nil {
+			t.Fatal(err)
+		} else if epoch != i {
+			t.Fatalf("unexpected epoch: %d", epoch)
+		}
+	}
+}
+
+func TestAPI_SetFieldACL(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "secret"); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "public"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m0.API.SetFieldACL(ctx, "i", "secret", []string{"alice"}); err != nil {
+		t.Fatal(err)
+	} else if err := m0.API.SetFieldACL(ctx, "i", "missing", nil); err == nil {
+		t.Fatal("expected error for missing field")
+	} else if _, ok := errors.Cause(err).(pilosa.NotFoundError); !ok {
+		t.Fatalf("unexpected error type: %T", errors.Cause(err))
+	}
+
+	t.Run("Query", func(t *testing.T) {
+		query := "Count(Union(Row(public=1), Row(secret=1)))"
+		if _, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: query, Principal: "bob"}); errors.Cause(err) != pilosa.ErrForbidden {
+			t.Fatalf("expected forbidden, got %v", err)
+		} else if _, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: query, Principal: "alice"}); err != nil {
+			t.Fatal(err)
+		} else if _, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: "Row(public=1)", Principal: "bob"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Import", func(t *testing.T) {
+		req := &pilosa.ImportRequest{Index: "i", Field: "secret", RowIDs: []uint64{1}, ColumnIDs: []uint64{1}}
+		if err := m0.API.Import(ctx, req); errors.Cause(err) != pilosa.ErrForbidden {
+			t.Fatalf("expected forbidden, got %v", err)
+		}
+		req.Principal = "alice"
+		if err := m0.API.Import(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	// Arguments clients can set do not skip the check.
+	t.Run("ClientFlags", func(t *testing.T) {
+		if _, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: "Row(secret=1)", Principal: "bob", Remote: true}); errors.Cause(err) != pilosa.ErrForbidden {
+			t.Fatalf("expected forbidden for remote query, got %v", err)
+		}
+		req := &pilosa.ImportRequest{Index: "i", Field: "secret", RowIDs: []uint64{1}, ColumnIDs: []uint64{2}, Principal: "bob"}
+		if err := m0.API.Import(ctx, req, pilosa.OptImportOptionsIgnoreKeyCheck(true)); errors.Cause(err) != pilosa.ErrForbidden {
+			t.Fatalf("expected forbidden for import ignoring keys, got %v", err)
+		} else if _, err := m0.API.Query(m0.API.InternalContext(ctx, "bogus"), &pilosa.QueryRequest{Index: "i", Query: "Row(secret=1)", Principal: "bob"}); errors.Cause(err) != pilosa.ErrForbidden {
+			t.Fatalf("expected forbidden for invalid internal token, got %v", err)
+		}
+	})
+
+	t.Run("ImportValue", func(t *testing.T) {
+		if _, err := m0.API.CreateField(ctx, "i", "secretint", pilosa.OptFieldTypeInt(0, 100)); err != nil {
+			t.Fatal(err)
+		} else if err := m0.API.SetFieldACL(ctx, "i", "secretint", []string{"alice"}); err != nil {
+			t.Fatal(err)
+		}
+		req := &pilosa.ImportValueRequest{Index: "i", Field: "secretint", ColumnIDs: []uint64{1}, Values: []int64{10}, Principal: "bob"}
+		if err := m0.API.ImportValue(ctx, req); errors.Cause(err) != pilosa.ErrForbidden {
+			t.Fatalf("expected forbidden, got %v", err)
+		}
+		req.Principal = "alice"
+		if err := m0.API.ImportValue(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("ImportRoaring", func(t *testing.T) {
+		req := &pilosa.ImportRoaringRequest{Principal: "bob"}
+		if err := m0.API.ImportRoaring(ctx, "i", "secret", 0, false, req); errors.Cause(err) != pilosa.ErrForbidden {
+			t.Fatalf("expected forbidden, got %v", err)
+		}
+		req.Principal = "alice"
+		if err := m0.API.ImportRoaring(ctx, "i", "secret", 0, false, req); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("ImportBatch", func(t *testing.T) {
+		batches := []pilosa.FieldImport{{Field: "secret", RowIDs: []uint64{1}, ColumnIDs: []uint64{3}}}
+		if err := m0.API.ImportBatch(pilosa.WithPrincipal(ctx, "bob"), "i", batches); errors.Cause(err) != pilosa.ErrForbidden {
+			t.Fatalf("expected forbidden, got %v", err)
+		} else if err := m0.API.ImportBatch(pilosa.WithPrincipal(ctx, "alice"), "i", batches); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Export", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := m0.API.ExportCSV(pilosa.WithPrincipal(ctx, "bob"), "i", "secret", 0, &buf); errors.Cause(err) != pilosa.ErrForbidden {
+			t.Fatalf("expected forbidden for csv, got %v", err)
+		} else if err := m0.API.ExportNDJSON(pilosa.WithPrincipal(ctx, "bob"), "i", "secret", 0, &buf); errors.Cause(err) != pilosa.ErrForbidden {
+			t.Fatalf("expected forbidden for ndjson, got %v", err)
+		} else if err := m0.API.ExportParquet(pilosa.WithPrincipal(ctx, "bob"), "i", "secret", 0, &buf); errors.Cause(err) != pilosa.ErrForbidden {
+			t.Fatalf("expected forbidden for parquet, got %v", err)
+		} else if err := m0.API.ExportCSV(pilosa.WithPrincipal(ctx, "alice"), "i", "secret", 0, &buf); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	// The list persists across reopening, and clearing it allows all principals.
+	if err := m0.Reopen(); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: "Row(secret=1)", Principal: "bob"}); errors.Cause(err) != pilosa.ErrForbidden {
+		t.Fatalf("expected forbidden after reopen, got %v", err)
+	} else if err := m0.API.SetFieldACL(ctx, "i", "secret", nil); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: "Row(secret=1)", Principal: "bob"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAPI_Subscribe(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+
+	if _, err := m0.API.CreateIndex(context.Background(), "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(context.Background(), "i", "f"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := m0.API.Subscribe(ctx, &pilosa.QueryRequest{Index: "i", Query: "Count(Row(f=1)"}, time.Millisecond); err == nil {
+		t.Fatal("expected parse error")
+	} else if _, err := m0.API.Subscribe(ctx, &pilosa.QueryRequest{Index: "i", Query: "Count(Row(f=1))"}, 0); err == nil {
+		t.Fatal("expected interval error")
+	} else if _, err := m0.API.Subscribe(ctx, &pilosa.QueryRequest{Index: "i", Query: "Set(10, f=1)"}, time.Millisecond); err == nil {
+		t.Fatal("expected write error")
+	} else if _, err := m0.API.Subscribe(ctx, &pilosa.QueryRequest{Index: "i", Query: "Count(Row(f=1)) ClearRow(f=1)"}, time.Millisecond); err == nil {
+		t.Fatal("expected write error")
+	}
+
+	ch, err := m0.API.Subscribe(ctx, &pilosa.QueryRequest{Index: "i", Query: "Count(Row(f=1))"}, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp := <-ch; resp.Err != nil {
+		t.Fatal(resp.Err)
+	} else if n := resp.Results[0].(uint64); n != 0 {
+		t.Fatalf("unexpected count: %d", n)
+	}
+
+	// Later executions see new data.
+	if _, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: "Set(10, f=1)"}); err != nil {
+		t.Fatal(err)
+	}
+	for resp := range ch {
+		if resp.Err != nil {
+			t.Fatal(resp.Err)
+		} else if resp.Results[0].(uint64) == 1 {
+			break
+		}
+	}
+
+	// Canceling the context closes the channel.
+	cancel()
+	for range ch {
+	}
+}
+
+func TestAPI_ColumnAttrsFiltered(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: `
+		SetColumnAttrs(1, name="a", age=10, active=true)
+		SetColumnAttrs(2, age=20)
+		SetColumnAttrs(3, active=false)`}); err != nil {
+		t.Fatal(err)
+	}
+
+	sets, err := m0.API.ColumnAttrsFiltered(ctx, "i", []uint64{1, 2, 3, 4}, []string{"name", "age"})
+	if err != nil {
+		t.Fatal(err)
+	} else if exp := []*pilosa.ColumnAttrSet{
+		{ID: 1, Attrs: map[string]interface{}{"name": "a", "age": int64(10)}},
+		{ID: 2, Attrs: map[string]interface{}{"age": int64(20)}},
+	}; !reflect.DeepEqual(sets, exp) {
+		t.Fatalf("unexpected attrs: %#v", sets)
+	}
+
+	if sets, err := m0.API.ColumnAttrsFiltered(ctx, "i", []uint64{3}, nil); err != nil {
+		t.Fatal(err)
+	} else if len(sets) != 1 || sets[0].Attrs["active"] != false {
+		t.Fatalf("unexpected attrs: %#v", sets)
+	}
+
+	if _, err := m0.API.ColumnAttrsFiltered(ctx, "missing", []uint64{1}, nil); err == nil {
+		t.Fatal("expected error for missing index")
+	} else if _, ok := errors.Cause(err).(pilosa.NotFoundError); !ok {
+		t.Fatalf("unexpected error type: %T", errors.Cause(err))
+	}
+}
+
+func TestAPI_ImportReportDuplicates(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	}
+
+	req := &pilosa.ImportRequest{Index: "i", Field: "f", RowIDs: []uint64{1, 1}, ColumnIDs: []uint64{1, 2}, ReportDuplicates: true}
+	if resp, err := m0.API.ImportWithResponse(ctx, req); err != nil {
+		t.Fatal(err)
+	} else if len(resp.Duplicates) != 0 {
+		t.Fatalf("unexpected duplicates: %v", resp.Duplicates)
+	}
+
+	req = &pilosa.ImportRequest{Index: "i", Field: "f", RowIDs: []uint64{1, 2, 1}, ColumnIDs: []uint64{2, 2, 3}, ReportDuplicates: true}
+	if resp, err := m0.API.ImportWithResponse(ctx, req); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(resp.Duplicates, []pilosa.Bit{{RowID: 1, ColumnID: 2}}) {
+		t.Fatalf("unexpected duplicates: %v", resp.Duplicates)
+	}
+
+	// Duplicates are only reported when requested.
+	req = &pilosa.ImportRequest{Index: "i", Field: "f", RowIDs: []uint64{1}, ColumnIDs: []uint64{1}}
+	if resp, err := m0.API.ImportWithResponse(ctx, req); err != nil {
+		t.Fatal(err)
+	} else if resp.Duplicates != nil {
+		t.Fatalf("unexpected duplicates: %v", resp.Duplicates)
+	}
+
+	// Bits with timestamps are only duplicates if they are set in the time
+	// views as well as the standard view.
+	if _, err := m0.API.CreateField(ctx, "i", "t", pilosa.OptFieldTypeTime(pilosa.TimeQuantum("YMD"))); err != nil {
+		t.Fatal(err)
+	}
+	ts := time.Date(2018, 8, 1, 0, 0, 0, 0, time.UTC).UnixNano()
+	if err := m0.API.Import(ctx, &pilosa.ImportRequest{Index: "i", Field: "t", RowIDs: []uint64{1}, ColumnIDs: []uint64{1}}); err != nil {
+		t.Fatal(err)
+	}
+	req = &pilosa.ImportRequest{Index: "i", Field: "t", RowIDs: []uint64{1}, ColumnIDs: []uint64{1}, Timestamps: []int64{ts}, ReportDuplicates: true}
+	if resp, err := m0.API.ImportWithResponse(ctx, req); err != nil {
+		t.Fatal(err)
+	} else if len(resp.Duplicates) != 0 {
+		t.Fatalf("unexpected duplicates: %v", resp.Duplicates)
+	}
+	if resp, err := m0.API.ImportWithResponse(ctx, req); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(resp.Duplicates, []pilosa.Bit{{RowID: 1, ColumnID: 1}}) {
+		t.Fatalf("unexpected duplicates: %v", resp.Duplicates)
+	}
+}
+
+func TestAPI_ImportRejectOutOfRange(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	min := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	max := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "t", pilosa.OptFieldTypeTime("YMD"), pilosa.OptFieldTimeRange(min, max)); err != nil {
+		t.Fatal(err)
+	}
+
+	inRange := time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC).UnixNano()
+	outOfRange := time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC).UnixNano()
+	req := &pilosa.ImportRequest{Index: "i", Field: "t", RowIDs: []uint64{1, 1}, ColumnIDs: []uint64{1, 2}, Timestamps: []int64{inRange, outOfRange}}
+	if err := m0.API.Import(ctx, req, pilosa.OptImportOptionsRejectOutOfRange(true)); errors.Cause(err) != pilosa.ErrImportTimestampOutOfRange {
+		t.Fatalf("expected out of range error, got %v", err)
+	}
+
+	// Without the option, out of range timestamps are imported as before.
+	if err := m0.API.Import(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+
+	// The range persists across reopening.
+	if err := m0.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	f, err := m0.API.Field(ctx, "i", "t")
+	if err != nil {
+		t.Fatal(err)
+	} else if opt := f.Options(); !opt.TimeMin.Equal(min) || !opt.TimeMax.Equal(max) {
+		t.Fatalf("unexpected time range: %s - %s", opt.TimeMin, opt.TimeMax)
+	}
+}
+
+func TestAPI_ImportAllowedRows(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "f", pilosa.OptFieldAllowedRows(3, 1, 2)); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "n", pilosa.OptFieldTypeInt(0, 10), pilosa.OptFieldAllowedRows(1)); err == nil {
+		t.Fatal("expected error for int field")
+	}
+
+	req := &pilosa.ImportRequest{Index: "i", Field: "f", RowIDs: []uint64{1, 9, 2, 9, 4}, ColumnIDs: []uint64{1, 2, 3, 4, 5}}
+	if err := m0.API.Import(ctx, req); err == nil {
+		t.Fatal("expected error")
+	} else if _, ok := errors.Cause(err).(pilosa.BadRequestError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	} else if !strings.Contains(err.Error(), "[4 9]") {
+		t.Fatalf("expected offending rows in error: %v", err)
+	}
+	res := m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `Count(Row(f=1))`})
+	if n := res.Results[0].(uint64); n != 0 {
+		t.Fatalf("expected nothing imported, got %d bits", n)
+	}
+
+	req = &pilosa.ImportRequest{Index: "i", Field: "f", RowIDs: []uint64{1, 3}, ColumnIDs: []uint64{1, 2}}
+	if err := m0.API.Import(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+
+	// Roaring imports and Set() calls are restricted too.
+	var buf bytes.Buffer
+	if _, err := roaring.NewBitmap(1*pilosa.ShardWidth+7, 9*pilosa.ShardWidth+7).WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	} else if err := m0.API.ImportRoaring(ctx, "i", "f", 0, false, &pilosa.ImportRoaringRequest{Views: map[string][]byte{"": buf.Bytes()}}); err == nil {
+		t.Fatal("expected error for roaring import")
+	} else if !strings.Contains(err.Error(), "[9]") {
+		t.Fatalf("expected offending rows in error: %v", err)
+	}
+	if _, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: `Set(8, f=9)`}); err == nil {
+		t.Fatal("expected error for Set()")
+	} else if _, ok := errors.Cause(err).(pilosa.BadRequestError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res = m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `Count(Row(f=1)) Count(Row(f=9))`})
+	if n := res.Results[0].(uint64); n != 1 {
+		t.Fatalf("unexpected count for allowed row: %d", n)
+	} else if n := res.Results[1].(uint64); n != 0 {
+		t.Fatalf("expected nothing set in row 9, got %d bits", n)
+	}
+
+	// Keyed imports are checked before they are split by shard, and keys
+	// which are not already assigned are refused without being assigned.
+	if _, err := m0.API.CreateIndex(ctx, "k", pilosa.IndexOptions{Keys: true}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "k", "f", pilosa.OptFieldKeys(), pilosa.OptFieldAllowedRows(1)); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.TranslateRowKeys(ctx, "k", "f", []string{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, keys := range [][]string{{"a", "b"}, {"a", "new"}} {
+		req := &pilosa.ImportRequest{Index: "k", Field: "f", RowKeys: keys, ColumnKeys: []string{"x", "y"}}
+		if err := m0.API.Import(ctx, req); err == nil {
+			t.Fatalf("expected error for rows %v", keys)
+		} else if _, ok := errors.Cause(err).(pilosa.BadRequestError); !ok {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if ids, err := m0.API.TranslateRowKeys(ctx, "k", "f", []string{"c"}); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(ids, []uint64{3}) {
+		t.Fatalf("expected refused key not to be assigned an id: %v", ids)
+	}
+	res = m0.MustQuery(t, &pilosa.QueryRequest{Index: "k", Query: `Count(Row(f="a"))`})
+	if n := res.Results[0].(uint64); n != 0 {
+		t.Fatalf("expected nothing imported, got %d bits", n)
+	}
+
+	// The allowed rows persist across reopening.
+	if err := m0.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	f, err := m0.API.Field(ctx, "i", "f")
+	if err != nil {
+		t.Fatal(err)
+	} else if rows := f.Options().AllowedRows; !reflect.DeepEqual(rows, []uint64{1, 2, 3}) {
+		t.Fatalf("unexpected allowed rows: %v", rows)
+	}
+}
+
+func TestAPI_PruneTimeViews(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "t", pilosa.OptFieldTypeTime("YMD")); err != nil {
+		t.Fatal(err)
+	}
+	ts := []int64{
+		time.Date(2017, 12, 31, 0, 0, 0, 0, time.UTC).UnixNano(),
+		time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC).UnixNano(),
+	}
+	if err := m0.API.Import(ctx, &pilosa.ImportRequest{Index: "i", Field: "t", RowIDs: []uint64{1, 1}, ColumnIDs: []uint64{1, 2}, Timestamps: ts}); err != nil {
+		t.Fatal(err)
+	}
+
+	pruned, err := m0.API.PruneTimeViews(ctx, "i", "t", time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	} else if exp := []string{"standard_2017", "standard_201712", "standard_20171231"}; !reflect.DeepEqual(pruned, exp) {
+		t.Fatalf("unexpected pruned views: %v", pruned)
+	}
+
+	// Pruned views are gone, and the standard view is never pruned.
+	pruned, err = m0.API.PruneTimeViews(ctx, "i", "t", time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	} else if exp := []string{"standard_2018", "standard_201806", "standard_20180601"}; !reflect.DeepEqual(pruned, exp) {
+		t.Fatalf("unexpected pruned views: %v", pruned)
+	}
+
+	if _, err := m0.API.PruneTimeViews(ctx, "i", "x", time.Now()); err == nil {
+		t.Fatal("expected field not found")
+	} else if _, ok := errors.Cause(err).(pilosa.NotFoundError); !ok {
+		t.Fatalf("unexpected error type: %T", errors.Cause(err))
+	}
+}
+
+func TestAPI_ImportOrdered(t *testing.T) {
+	c := test.MustRunCluster(t, 1,
+		[]server.CommandOption{
+			server.OptCommandServerOptions(
+				pilosa.OptServerOrderedImports(true),
+			)},
+	)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The primary assigns consecutive sequence numbers.
+	resp0, err := m0.API.ImportWithResponse(ctx, &pilosa.ImportRequest{Index: "i", Field: "f", RowIDs: []uint64{1}, ColumnIDs: []uint64{1}})
+	if err != nil {
+		t.Fatal(err)
+	} else if resp0.Sequence == 0 {
+		t.Fatal("expected sequence")
+	}
+	resp1, err := m0.API.ImportWithResponse(ctx, &pilosa.ImportRequest{Index: "i", Field: "f", RowIDs: []uint64{1}, ColumnIDs: []uint64{2}})
+	if err != nil {
+		t.Fatal(err)
+	} else if resp1.Sequence != resp0.Sequence+1 {
+		t.Fatalf("unexpected sequence: %d, previous %d", resp1.Sequence, resp0.Sequence)
+	}
+
+	// Imports sequenced before one which was applied are left to the
+	// resync rather than applied out of order.
+	req := &pilosa.ImportRequest{Index: "i", Field: "f", RowIDs: []uint64{1}, ColumnIDs: []uint64{3}}
+	if err := m0.API.Import(ctx, req, pilosa.OptImportOptionsSequence(resp0.Sequence)); err != nil {
+		t.Fatal(err)
+	} else if cols := importOrderedColumns(t, m0); !reflect.DeepEqual(cols, []uint64{1, 2}) {
+		t.Fatalf("unexpected columns: %v", cols)
+	}
+
+	// The next sequence number is applied.
+	if resp, err := m0.API.ImportWithResponse(ctx, req, pilosa.OptImportOptionsSequence(resp1.Sequence+1)); err != nil {
+		t.Fatal(err)
+	} else if resp.Sequence != resp1.Sequence+1 {
+		t.Fatalf("unexpected sequence: %d", resp.Sequence)
+	} else if cols := importOrderedColumns(t, m0); !reflect.DeepEqual(cols, []uint64{1, 2, 3}) {
+		t.Fatalf("unexpected columns: %v", cols)
+	}
+}
+
+// importOrderedColumns returns the columns of row 1 of field f in index i.
+func importOrderedColumns(t *testing.T, m *test.Command) []uint64 {
+	t.Helper()
+	resp, err := m.API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: "Row(f=1)"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.Results[0].(*pilosa.Row).Columns()
+}
+
+func TestAPI_OpenFileCount(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	}
+	n0, err := m0.API.OpenFileCount(ctx, "i")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each new fragment opens a file.
+	for _, shard := range []uint64{0, 1} {
+		req := &pilosa.ImportRequest{Index: "i", Field: "f", Shard: shard, RowIDs: []uint64{1}, ColumnIDs: []uint64{shard * pilosa.ShardWidth}}
+		if err := m0.API.Import(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, err := m0.API.OpenFileCount(ctx, "i"); err != nil {
+		t.Fatal(err)
+	} else if n != n0+2 {
+		t.Fatalf("unexpected count: %d, before import %d", n, n0)
+	}
+
+	// The holder count includes every index.
+	if _, err := m0.API.CreateIndex(ctx, "j", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if n, err := m0.API.OpenFileCount(ctx, ""); err != nil {
+		t.Fatal(err)
+	} else if n <= n0+2 {
+		t.Fatalf("unexpected holder count: %d", n)
+	}
+
+	if _, err := m0.API.OpenFileCount(ctx, "x"); err == nil {
+		t.Fatal("expected error")
+	} else if _, ok := errors.Cause(err).(pilosa.NotFoundError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAPI_ImportTransform(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m0.API.RegisterImportTransform(ctx, "colShift", func(arg string, rowIDs, columnIDs []uint64) error {
+		for i := range columnIDs {
+			columnIDs[i] += pilosa.ShardWidth
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	} else if err := m0.API.RegisterImportTransform(ctx, "rowOffset", func(string, []uint64, []uint64) error { return nil }); err == nil {
+		t.Fatal("expected conflict for built in transform")
+	}
+
+	for _, transform := range []string{"rowOffset:10", "rowModulo:7"} {
+		req := &pilosa.ImportRequest{Index: "i", Field: "f", RowIDs: []uint64{1, 9}, ColumnIDs: []uint64{1, 2}, Transform: transform}
+		if err := m0.API.Import(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resp, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: "Row(f=11) Row(f=19) Row(f=2)"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, exp := range [][]uint64{{1}, {2}, {2}} {
+		if cols := resp.Results[i].(*pilosa.Row).Columns(); !reflect.DeepEqual(cols, exp) {
+			t.Fatalf("result %d: unexpected columns: %v", i, cols)
+		}
+	}
+
+	// Unknown and failing transforms are rejected.
+	for transform, exp := range map[string]error{
+		"x":           pilosa.ErrImportTransformNotFound,
+		"rowModulo:0": pilosa.ErrImportTransformFailed,
+		"colShift":    pilosa.ErrImportTransformFailed,
+	} {
+		req := &pilosa.ImportRequest{Index: "i", Field: "f", RowIDs: []uint64{1}, ColumnIDs: []uint64{1}, Transform: transform}
+		if err := m0.API.Import(ctx, req); errors.Cause(err) != exp {
+			t.Fatalf("%s: expected %v, got %v", transform, exp, err)
+		}
+	}
+
+	// Transforms apply to the IDs which keys are translated to.
+	if _, err := m0.API.CreateIndex(ctx, "k", pilosa.IndexOptions{Keys: true}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "k", "f", pilosa.OptFieldKeys()); err != nil {
+		t.Fatal(err)
+	}
+	var rows, cols []uint64
+	if err := m0.API.RegisterImportTransform(ctx, "record", func(arg string, rowIDs, columnIDs []uint64) error {
+		rows, cols = append(rows, rowIDs...), append(cols, columnIDs...)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	req := &pilosa.ImportRequest{Index: "k", Field: "f", RowKeys: []string{"x", "y"}, ColumnKeys: []string{"a", "b"}, Transform: "record"}
+	if err := m0.API.Import(ctx, req); err != nil {
+		t.Fatal(err)
+	} else if len(rows) != 2 || rows[0] == rows[1] {
+		t.Fatalf("unexpected rows: %v", rows)
+	} else if len(cols) != 2 || cols[0] == cols[1] {
+		t.Fatalf("unexpected columns: %v", cols)
+	}
+}
+
+func TestAPI_QueryShardCount(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	}
+
+	// An empty index is queried on shard zero.
+	if n, err := m0.API.QueryShardCount(ctx, "i", "Count(Row(f=1))"); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatalf("unexpected count: %d", n)
+	}
+
+	for _, shard := range []uint64{0, 2, 5} {
+		req := &pilosa.ImportRequest{Index: "i", Field: "f", Shard: shard, RowIDs: []uint64{1}, ColumnIDs: []uint64{shard * pilosa.ShardWidth}}
+		if err := m0.API.Import(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		query string
+		exp   int
+	}{
+		{query: "Count(Row(f=1))", exp: 3},
+		{query: "Options(Row(f=1), shards=[0, 7, 7])", exp: 2},
+		{query: "Options(Row(f=1), shards=[0]) Options(Count(Row(f=1)), shards=[9])", exp: 2},
+		{query: "Options(Row(f=1), shards=[9]) Count(Row(f=1))", exp: 4},
+		{query: "Options(Row(f=1), excludeColumns=true)", exp: 3},
+		{query: "Set(1, f=1) Clear(2, f=1)", exp: 0},
+		{query: "Set(1, f=1) TopN(f)", exp: 3},
+	} {
+		if n, err := m0.API.QueryShardCount(ctx, "i", tt.query); err != nil {
+			t.Fatal(err)
+		} else if n != tt.exp {
+			t.Fatalf("%s: unexpected count: %d, expected %d", tt.query, n, tt.exp)
+		}
+	}
+
+	if _, err := m0.API.QueryShardCount(ctx, "i", "Options(Row(f=1), shards=1)"); err == nil {
+		t.Fatal("expected shards error")
+	} else if _, ok := errors.Cause(err).(pilosa.BadRequestError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := m0.API.QueryShardCount(ctx, "i", "Row("); err == nil {
+		t.Fatal("expected parse error")
+	} else if _, ok := errors.Cause(err).(pilosa.BadRequestError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := m0.API.QueryShardCount(ctx, "x", "Row(f=1)"); err == nil {
+		t.Fatal("expected error")
+	} else if _, ok := errors.Cause(err).(pilosa.NotFoundError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAPI_QueryTreatMissingAsEmpty(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: "Set(1, f=1) Set(2, f=1)"}); err != nil {
+		t.Fatal(err)
+	}
+
+	query := "Union(Row(f=1), Row(g=1)) Count(Intersect(Row(f=1), Row(g=1))) Row(h > 10)"
+	if _, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: query}); errors.Cause(err) != pilosa.ErrFieldNotFound {
+		t.Fatalf("expected field not found, got %v", err)
+	}
+
+	resp, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: query, TreatMissingAsEmpty: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cols := resp.Results[0].(*pilosa.Row).Columns(); !reflect.DeepEqual(cols, []uint64{1, 2}) {
+		t.Fatalf("unexpected columns: %v", cols)
+	} else if n := resp.Results[1].(uint64); n != 0 {
+		t.Fatalf("unexpected count: %d", n)
+	} else if cols := resp.Results[2].(*pilosa.Row).Columns(); len(cols) != 0 {
+		t.Fatalf("unexpected columns: %v", cols)
+	}
+}
+
+func TestAPI_CompactTranslateStore(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{Keys: true}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "f", pilosa.OptFieldKeys()); err != nil {
+		t.Fatal(err)
+	}
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `Set("c1", f="r1") Set("c2", f="r2")`})
+
+	if err := m0.API.CompactTranslateStore(ctx, "i"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Keys resolve to the same IDs after compaction.
+	res := m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `Row(f="r2")`})
+	if keys := res.Results[0].(*pilosa.Row).Keys; !reflect.DeepEqual(keys, []string{"c2"}) {
+		t.Fatalf("unexpected keys: %v", keys)
+	}
+
+	if err := m0.API.CompactTranslateStore(ctx, "x"); err == nil {
+		t.Fatal("expected error")
+	} else if _, ok := errors.Cause(err).(pilosa.NotFoundError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAPI_QuerySortResults(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	}
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `Set(1, f=3) Set(1, f=1) Set(2, f=5) Set(3, f=5) Set(1, f=2)`})
+	if err := m0.API.RecalculateCaches(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	res := m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `TopN(f)`, SortResults: true})
+	if pairs := res.Results[0].([]pilosa.Pair); !reflect.DeepEqual(pairs, []pilosa.Pair{
+		{ID: 5, Count: 2},
+		{ID: 1, Count: 1},
+		{ID: 2, Count: 1},
+		{ID: 3, Count: 1},
+	}) {
+		t.Fatalf("unexpected pairs: %v", pairs)
+	}
+}
+
+func TestAPI_ReserveColumnIDs(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{Keys: true}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	}
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `Set("c1", f=1)`})
+
+	if start, err := m0.API.ReserveColumnIDs(ctx, "i", 10); err != nil {
+		t.Fatal(err)
+	} else if start != 2 {
+		t.Fatalf("unexpected start: %d", start)
+	}
+
+	// Reservations survive compaction and restarts.
+	if err := m0.API.CompactTranslateStore(ctx, "i"); err != nil {
+		t.Fatal(err)
+	} else if err := m0.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+
+	// New keys are assigned ids after the reserved block.
+	res := m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `Set("c2", f=1) Row(f=1)`, IncludeKeys: true})
+	if cols := res.Results[1].(*pilosa.Row).Columns(); !reflect.DeepEqual(cols, []uint64{1, 12}) {
+		t.Fatalf("unexpected columns: %v", cols)
+	}
+	if start, err := m0.API.ReserveColumnIDs(ctx, "i", 1); err != nil {
+		t.Fatal(err)
+	} else if start != 13 {
+		t.Fatalf("unexpected start: %d", start)
+	}
+
+	if _, err := m0.API.ReserveColumnIDs(ctx, "i", 0); err == nil {
+		t.Fatal("expected error")
+	} else if _, ok := errors.Cause(err).(pilosa.BadRequestError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := m0.API.ReserveColumnIDs(ctx, "x", 1); err == nil {
+		t.Fatal("expected error")
+	} else if _, ok := errors.Cause(err).(pilosa.NotFoundError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAPI_NodeConfig(t *testing.T) {
+	c := test.MustRunCluster(t, 1, []server.CommandOption{server.OptCommandServerOptions(
+		pilosa.OptServerLongQueryTime(3*time.Second),
+		pilosa.OptServerMaxWritesPerRequest(500),
+		pilosa.OptServerOrderedImports(true),
+	)})
+	defer c.Close()
+	m0 := c[0]
+
+	cfg, err := m0.API.NodeConfig(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.NodeID != m0.API.Node().ID {
+		t.Fatalf("unexpected node id: %s", cfg.NodeID)
+	} else if cfg.DataDir != m0.Config.DataDir {
+		t.Fatalf("unexpected data dir: %s", cfg.DataDir)
+	} else if cfg.ShardWidth != pilosa.ShardWidth || cfg.ReplicaN != 1 {
+		t.Fatalf("unexpected shard width or replica count: %+v", cfg)
+	} else if cfg.LongQueryTime != 3*time.Second || cfg.MaxWritesPerRequest != 500 || !cfg.OrderedImports {
+		t.Fatalf("unexpected options: %+v", cfg)
+	}
+}
+
+func TestAPI_WatchSchema(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := m0.API.WatchSchema(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	}
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `Set(1, f=1)`})
+	if err := m0.API.DeleteField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, exp := range []pilosa.SchemaEvent{
+		{Type: pilosa.SchemaEventCreateIndex, Index: "i"},
+		{Type: pilosa.SchemaEventCreateField, Index: "i", Field: "f"},
+		{Type: pilosa.SchemaEventCreateView, Index: "i", Field: "f", View: "standard"},
+		{Type: pilosa.SchemaEventDeleteField, Index: "i", Field: "f"},
+	} {
+		select {
+		case ev := <-ch:
+			if ev != exp {
+				t.Fatalf("unexpected event: got %+v, exp %+v", ev, exp)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %+v", exp)
+		}
+	}
+
+	// The channel closes when the context is canceled.
+	cancel()
+	select {
+	case ev, ok := <-ch:
+		if ok {
+			t.Fatalf("unexpected event: %+v", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("channel not closed")
+	}
+}
+
+func TestAPI_TopColumns(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	}
+	col := uint64(pilosa.ShardWidth + 5)
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf(`
+		Set(1, f=1) Set(1, f=2)
+		Set(2, f=1)
+		Set(3, f=1) Set(3, f=2)
+		Set(%d, f=1) Set(%d, f=2) Set(%d, f=3)`, col, col, col)})
+
+	counts, err := m0.API.TopColumns(ctx, "i", "f", 3)
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(counts, []pilosa.ColumnCount{
+		{ID: col, Count: 3},
+		{ID: 1, Count: 2},
+		{ID: 3, Count: 2},
+	}) {
+		t.Fatalf("unexpected counts: %v", counts)
+	}
+
+	if _, err := m0.API.TopColumns(ctx, "i", "f", 0); err == nil {
+		t.Fatal("expected error")
+	} else if _, ok := errors.Cause(err).(pilosa.BadRequestError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := m0.API.TopColumns(ctx, "i", "x", 1); err == nil {
+		t.Fatal("expected error")
+	} else if _, ok := errors.Cause(err).(pilosa.NotFoundError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAPI_ForEachBit(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	}
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `Set(3, f=1) Set(1, f=2) Set(2, f=1)`})
+
+	var bits [][2]uint64
+	if err := m0.API.ForEachBit(ctx, "i", "f", 0, func(rowID, columnID uint64) error {
+		bits = append(bits, [2]uint64{rowID, columnID})
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(bits, [][2]uint64{{1, 2}, {1, 3}, {2, 1}}) {
+		t.Fatalf("unexpected bits: %v", bits)
+	}
+
+	// Errors from the callback stop iteration and are returned.
+	errStop := errors.New("stop")
+	var n int
+	if err := m0.API.ForEachBit(ctx, "i", "f", 0, func(rowID, columnID uint64) error {
+		n++
+		return errStop
+	}); err != errStop {
+		t.Fatalf("unexpected error: %v", err)
+	} else if n != 1 {
+		t.Fatalf("unexpected calls: %d", n)
+	}
+
+	// Shards without data have no bits.
+	if err := m0.API.ForEachBit(ctx, "i", "f", 5, func(rowID, columnID uint64) error {
+		t.Fatal("unexpected bit")
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m0.API.ForEachBit(ctx, "i", "x", 0, func(rowID, columnID uint64) error { return nil }); err == nil {
+		t.Fatal("expected error")
+	} else if _, ok := errors.Cause(err).(pilosa.NotFoundError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAPI_CanRemoveNode(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if ok, reason, err := m0.API.CanRemoveNode(ctx, m0.API.Node().ID); err != nil {
+		t.Fatal(err)
+	} else if ok || reason == "" {
+		t.Fatalf("expected removal to be blocked: ok=%v, reason=%q", ok, reason)
+	}
+
+	if _, _, err := m0.API.CanRemoveNode(ctx, "x"); errors.Cause(err) != pilosa.ErrNodeIDNotExists {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAPI_FragmentContainerStats(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Row 1 holds a few bits and row 2 holds enough scattered bits to need
+	// a bitmap container.
+	req := &pilosa.ImportRequest{Index: "i", Field: "f", Shard: 0}
+	for _, col := range []uint64{1, 5, 9} {
+		req.RowIDs = append(req.RowIDs, 1)
+		req.ColumnIDs = append(req.ColumnIDs, col)
+	}
+	for col := uint64(0); col < 10000; col += 2 {
+		req.RowIDs = append(req.RowIDs, 2)
+		req.ColumnIDs = append(req.ColumnIDs, col)
+	}
+	if err := m0.API.Import(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := m0.API.FragmentContainerStats(ctx, "i", "f", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Array.Containers != 1 || stats.Array.Bits != 3 || stats.Array.Bytes == 0 {
+		t.Fatalf("unexpected array stats: %+v", stats.Array)
+	} else if stats.Bitmap.Containers != 1 || stats.Bitmap.Bits != 5000 || stats.Bitmap.Bytes != 8192 {
+		t.Fatalf("unexpected bitmap stats: %+v", stats.Bitmap)
+	} else if stats.Run.Containers != 0 {
+		t.Fatalf("unexpected run stats: %+v", stats.Run)
+	}
+
+	if _, err := m0.API.FragmentContainerStats(ctx, "i", "f", 5); err == nil {
+		t.Fatal("expected error for missing fragment")
+	} else if _, ok := errors.Cause(err).(pilosa.NotFoundError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	} else if _, err := m0.API.FragmentContainerStats(ctx, "i", "x", 0); err == nil {
+		t.Fatal("expected error for missing field")
+	} else if _, ok := errors.Cause(err).(pilosa.NotFoundError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAPI_EnableIndexKeys(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{TrackExistence: true}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	}
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf(`Set(1, f=1) Set(%d, f=1)`, pilosa.ShardWidth+2)})
+
+	if err := m0.API.EnableIndexKeys(ctx, "i", pilosa.OptIndexKeysBackfill(true)); err != nil {
+		t.Fatal(err)
+	} else if !m0.Server.Holder().Index("i").Keys() {
+		t.Fatal("expected index to use keys")
+	}
+
+	// Existing columns are keyed by their ids and new keys are assigned ids
+	// after the last shard.
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `Set("a", f=1)`})
+	res := m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `Row(f=1)`})
+	exp := []string{"1", fmt.Sprint(pilosa.ShardWidth + 2), "a"}
+	if keys := res.Results[0].(*pilosa.Row).Keys; !reflect.DeepEqual(keys, exp) {
+		t.Fatalf("unexpected keys: %v", keys)
+	}
+
+	if err := m0.API.EnableIndexKeys(ctx, "i"); err == nil {
+		t.Fatal("expected error for keyed index")
+	} else if _, ok := errors.Cause(err).(pilosa.ConflictError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Keys persist across restarts.
+	if err := m0.Reopen(); err != nil {
+		t.Fatal(err)
+	} else if !m0.Server.Holder().Index("i").Keys() {
+		t.Fatal("expected index to use keys after reopen")
+	}
+}
+
+func TestAPI_SnapshotInfo(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	}
+
+	if info, err := m0.API.SnapshotInfo(ctx, "i"); err != nil {
+		t.Fatal(err)
+	} else if info.Fragments != 0 || !info.Oldest.IsZero() {
+		t.Fatalf("unexpected info for empty index: %+v", info)
+	}
+
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf(`Set(1, f=1) Set(%d, f=1)`, pilosa.ShardWidth)})
+	info, err := m0.API.SnapshotInfo(ctx, "i")
+	if err != nil {
+		t.Fatal(err)
+	} else if info.Fragments != 2 || info.Bytes == 0 {
+		t.Fatalf("unexpected info: %+v", info)
+	} else if info.Oldest.IsZero() || info.Newest.Before(info.Oldest) {
+		t.Fatalf("unexpected times: %+v", info)
+	}
+
+	if _, err := m0.API.SnapshotInfo(ctx, "x"); err == nil {
+		t.Fatal("expected error for missing index")
+	} else if _, ok := errors.Cause(err).(pilosa.NotFoundError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAPI_QueryCacheResults(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	}
+
+	gen := m0.API.IndexGeneration(ctx, "i")
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `Set(1, f=1)`})
+	if g := m0.API.IndexGeneration(ctx, "i"); g <= gen {
+		t.Fatalf("expected generation to increase after write: %d <= %d", g, gen)
+	}
+
+	count := func() uint64 {
+		res := m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `Count(Row(f=1))`, CacheResults: true})
+		return res.Results[0].(uint64)
+	}
+	if n := count(); n != 1 {
+		t.Fatalf("unexpected count: %d", n)
+	}
+
+	// Writes which bypass the API don't change the generation, so the
+	// cached result is returned.
+	hldr := test.Holder{Holder: m0.Server.Holder()}
+	hldr.SetBit("i", "f", 1, 2)
+	if n := count(); n != 1 {
+		t.Fatalf("expected cached count, got %d", n)
+	}
+
+	// Imports move the index to a new generation.
+	gen = m0.API.IndexGeneration(ctx, "i")
+	if err := m0.API.Import(ctx, &pilosa.ImportRequest{Index: "i", Field: "f", RowIDs: []uint64{1}, ColumnIDs: []uint64{3}}); err != nil {
+		t.Fatal(err)
+	} else if g := m0.API.IndexGeneration(ctx, "i"); g <= gen {
+		t.Fatalf("expected generation to increase after import: %d <= %d", g, gen)
+	} else if n := count(); n != 3 {
+		t.Fatalf("unexpected count after import: %d", n)
+	}
+
+	// Each caller gets its own copy of a cached result.
+	row := func() *pilosa.Row {
+		res := m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `Row(f=1)`, CacheResults: true})
+		return res.Results[0].(*pilosa.Row)
+	}
+	row().SetBit(100)
+	if cols := row().Columns(); !reflect.DeepEqual(cols, []uint64{1, 2, 3}) {
+		t.Fatalf("unexpected columns: %v", cols)
+	}
+
+	if g := m0.API.IndexGeneration(ctx, "x"); g != 0 {
+		t.Fatalf("unexpected generation for missing index: %d", g)
+	}
+}
+
+func TestAPI_DeleteViews(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "t", pilosa.OptFieldTypeTime("Y")); err != nil {
+		t.Fatal(err)
+	}
+	ts := []int64{
+		time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC).UnixNano(),
+		time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC).UnixNano(),
+	}
+	if err := m0.API.Import(ctx, &pilosa.ImportRequest{Index: "i", Field: "t", RowIDs: []uint64{1, 1}, ColumnIDs: []uint64{1, 2}, Timestamps: ts}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Views which don't exist on this node are skipped.
+	if removed, err := m0.API.DeleteViews(ctx, "i", "t", []string{"standard_2017", "standard_1999", "standard_2018"}); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(removed, []string{"standard_2017", "standard_2018"}) {
+		t.Fatalf("unexpected views removed: %v", removed)
+	}
+	if views, err := m0.API.Views(ctx, "i", "t"); err != nil {
+		t.Fatal(err)
+	} else if len(views) != 1 {
+		t.Fatalf("unexpected views after delete: %d", len(views))
+	}
+
+	if _, err := m0.API.DeleteViews(ctx, "i", "x", []string{"standard_2017"}); err == nil {
+		t.Fatal("expected error for missing field")
+	} else if _, ok := errors.Cause(err).(pilosa.NotFoundError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAPI_DeleteViewsMatching(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	m0.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	m0.MustCreateField(t, "i", "t", pilosa.OptFieldTypeTime("YMD"))
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `
+		Set(1, t=1, 2018-12-31T00:00) Set(1, t=1, 2019-01-01T00:00) Set(1, t=1, 2019-01-02T00:00)`})
+
+	if removed, err := m0.API.DeleteViewsMatching(ctx, "i", "t", "standard_201901*"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(removed, []string{"standard_201901", "standard_20190101", "standard_20190102"}) {
+		t.Fatalf("unexpected views removed: %v", removed)
+	}
+	if views, err := m0.API.Views(ctx, "i", "t"); err != nil {
+		t.Fatal(err)
+	} else if len(views) != 5 {
+		t.Fatalf("unexpected views after delete: %d", len(views))
+	}
+
+	if removed, err := m0.API.DeleteViewsMatching(ctx, "i", "t", "standard_1999*"); err != nil {
+		t.Fatal(err)
+	} else if len(removed) != 0 {
+		t.Fatalf("unexpected views removed: %v", removed)
+	}
+	if _, err := m0.API.DeleteViewsMatching(ctx, "i", "t", "standard_[2019"); err == nil {
+		t.Fatal("expected error for invalid pattern")
+	} else if _, ok := errors.Cause(err).(pilosa.BadRequestError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := m0.API.DeleteViewsMatching(ctx, "i", "x", "*"); err == nil {
+		t.Fatal("expected error for missing field")
+	} else if _, ok := errors.Cause(err).(pilosa.NotFoundError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAPI_SetLogLevel(t *testing.T) {
+	l := &captureLogger{}
+	c := test.MustRunCluster(t, 1, []server.CommandOption{server.OptCommandServerOptions(pilosa.OptServerLogger(l))})
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	// Imports into a missing index are logged at the default level.
+	req := &pilosa.ImportRequest{Index: "x", Field: "f", RowIDs: []uint64{1}, ColumnIDs: []uint64{1}}
+	if err := m0.API.Import(ctx, req); err == nil {
+		t.Fatal("expected error for missing index")
+	} else if !l.contains("fragment error: index=x") {
+		t.Fatal("expected import error to be logged")
+	}
+
+	if err := m0.API.SetLogLevel(ctx, pilosa.LogSubsystemImport, pilosa.LogLevelNone); err != nil {
+		t.Fatal(err)
+	} else if lvl, err := m0.API.LogLevel(ctx, pilosa.LogSubsystemImport); err != nil {
+		t.Fatal(err)
+	} else if lvl != pilosa.LogLevelNone {
+		t.Fatalf("unexpected level: %s", lvl)
+	} else if lvl, err := m0.API.LogLevel(ctx, pilosa.LogSubsystemQuery); err != nil {
+		t.Fatal(err)
+	} else if lvl != pilosa.LogLevelInfo {
+		t.Fatalf("unexpected default level: %s", lvl)
+	}
+	req.Index = "y"
+	if err := m0.API.Import(ctx, req); err == nil {
+		t.Fatal("expected error for missing index")
+	} else if l.contains("fragment error: index=y") {
+		t.Fatal("expected import error not to be logged")
+	}
+
+	if err := m0.API.SetLogLevel(ctx, "bogus", pilosa.LogLevelInfo); err == nil {
+		t.Fatal("expected error for invalid subsystem")
+	} else if err := m0.API.SetLogLevel(ctx, pilosa.LogSubsystemImport, "loud"); err == nil {
+		t.Fatal("expected error for invalid level")
+	} else if _, ok := err.(pilosa.BadRequestError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAPI_PingCluster(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+
+	results, err := m0.API.PingCluster(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	} else if len(results) != 1 {
+		t.Fatalf("unexpected results: %v", results)
+	} else if err, ok := results[m0.API.Node().ID]; !ok || err != nil {
+		t.Fatalf("unexpected result for local node: %v", results)
+	}
+}
+
+func TestAPI_ImportReaderAuto(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "t", pilosa.OptFieldTypeTime("Y")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Bits from several shards, in no particular order.
+	data := fmt.Sprintf("1,%d\n1,3\n\n2,%d\n1,%d\n", 2*pilosa.ShardWidth+1, pilosa.ShardWidth, pilosa.ShardWidth+7)
+	if err := m0.API.ImportReaderAuto(ctx, "i", "f", strings.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	res := m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `Row(f=1) Row(f=2)`})
+	if cols := res.Results[0].(*pilosa.Row).Columns(); !reflect.DeepEqual(cols, []uint64{3, pilosa.ShardWidth + 7, 2*pilosa.ShardWidth + 1}) {
+		t.Fatalf("unexpected columns for row 1: %v", cols)
+	} else if cols := res.Results[1].(*pilosa.Row).Columns(); !reflect.DeepEqual(cols, []uint64{pilosa.ShardWidth}) {
+		t.Fatalf("unexpected columns for row 2: %v", cols)
+	}
+
+	// Timestamps are optional.
+	if err := m0.API.ImportReaderAuto(ctx, "i", "t", strings.NewReader("1,5,2018-01-02T00:00\n1,6\n")); err != nil {
+		t.Fatal(err)
+	}
+	res = m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `Row(t=1, from=2018-01-01T00:00, to=2019-01-01T00:00)`})
+	if cols := res.Results[0].(*pilosa.Row).Columns(); !reflect.DeepEqual(cols, []uint64{5}) {
+		t.Fatalf("unexpected columns in time range: %v", cols)
+	}
+
+	if err := m0.API.ImportReaderAuto(ctx, "i", "f", strings.NewReader("1,x\n")); err == nil {
+		t.Fatal("expected error for invalid column")
+	} else if _, ok := errors.Cause(err).(pilosa.BadRequestError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure each batch is imported on every replica of its shard, including
+// the replicas of shards which the receiving node owns.
+func TestAPI_ImportReaderAuto_Replicas(t *testing.T) {
+	c := test.MustNewCluster(t, 2)
+	for _, m := range c {
+		m.Config.Cluster.ReplicaN = 2
+	}
+	if err := c.Start(); err != nil {
+		t.Fatalf("starting cluster: %v", err)
+	}
+	defer c.Close()
+	ctx := context.Background()
+
+	if _, err := c[0].API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := c[0].API.CreateField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	}
+
+	data := fmt.Sprintf("1,3\n1,%d\n", pilosa.ShardWidth+7)
+	if err := c[0].API.ImportReaderAuto(ctx, "i", "f", strings.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range c {
+		hldr := test.Holder{Holder: m.Server.Holder()}
+		if cols := hldr.Row("i", "f", 1).Columns(); !reflect.DeepEqual(cols, []uint64{3, pilosa.ShardWidth + 7}) {
+			t.Fatalf("unexpected columns on node %d: %v", i, cols)
+		}
+	}
+}
+
+func TestAPI_FieldsEqual(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "a"); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "b"); err != nil {
+		t.Fatal(err)
+	}
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf(`Set(1, a=1) Set(%d, a=2) Set(1, b=1) Set(%d, b=2)`, pilosa.ShardWidth+1, pilosa.ShardWidth+1)})
+
+	if eq, err := m0.API.FieldsEqual(ctx, "i", "a", "b"); err != nil {
+		t.Fatal(err)
+	} else if !eq {
+		t.Fatal("expected fields to be equal")
+	}
+
+	// A bit in a shard which only one field has.
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf(`Set(%d, b=1)`, 2*pilosa.ShardWidth)})
+	if eq, err := m0.API.FieldsEqual(ctx, "i", "a", "b"); err != nil {
+		t.Fatal(err)
+	} else if eq {
+		t.Fatal("expected fields to differ")
+	}
+
+	if _, err := m0.API.FieldsEqual(ctx, "i", "a", "missing"); err == nil {
+		t.Fatal("expected error")
+	} else if _, ok := errors.Cause(err).(pilosa.NotFoundError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAPI_Query_AttrsBestEffort(t *testing.T) {
+	l := &captureLogger{}
+	c := test.MustRunCluster(t, 1,
+		[]server.CommandOption{
+			server.OptCommandServerOptions(
+				pilosa.OptServerLogger(l),
+				pilosa.OptServerAttrStoreFunc(func(path string) pilosa.AttrStore {
+					return &brokenAttrStore{boltdb.NewAttrStore(path)}
+				}),
+			)},
+	)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	}
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `Set(1, f=1) Set(2, f=1)`})
+
+	if _, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: `Row(f=1)`, ColumnAttrs: true}); err == nil {
+		t.Fatal("expected error")
+	}
+
+	resp, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: `Row(f=1)`, ColumnAttrs: true, AttrsBestEffort: true})
+	if err != nil {
+		t.Fatal(err)
+	} else if cols := resp.Results[0].(*pilosa.Row).Columns(); !reflect.DeepEqual(cols, []uint64{1, 2}) {
+		t.Fatalf("unexpected columns: %v", cols)
+	} else if len(resp.ColumnAttrSets) != 0 {
+		t.Fatalf("unexpected column attrs: %v", resp.ColumnAttrSets)
+	} else if !l.contains("reading column attrs") {
+		t.Fatal("expected attr error to be logged")
+	}
+
+	// Attr errors are logged by the query subsystem.
+	l.mu.Lock()
+	l.msgs = nil
+	l.mu.Unlock()
+	if err := m0.API.SetLogLevel(ctx, pilosa.LogSubsystemQuery, pilosa.LogLevelNone); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: `Row(f=1)`, ColumnAttrs: true, AttrsBestEffort: true}); err != nil {
+		t.Fatal(err)
+	} else if l.contains("reading column attrs") {
+		t.Fatal("expected attr error not to be logged")
+	}
+}
+
+func TestAPI_RemapRows(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "n", pilosa.OptFieldTypeInt(0, 100)); err != nil {
+		t.Fatal(err)
+	}
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf(`Set(1, f=1) Set(2, f=1) Set(3, f=2) Set(%d, f=5) Set(4, f=7)`, pilosa.ShardWidth+1)})
+
+	// Merge rows 1 and 5 into row 2, and swap rows 2 and 7.
+	if err := m0.API.RemapRows(ctx, "i", "f", map[uint64]uint64{1: 2, 5: 2, 2: 7, 7: 2}); err != nil {
+		t.Fatal(err)
+	}
+	res := m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `Row(f=1) Row(f=2) Row(f=5) Row(f=7) TopN(f)`})
+	for i, exp := range [][]uint64{nil, {1, 2, 4, pilosa.ShardWidth + 1}, nil, {3}} {
+		if cols := res.Results[i].(*pilosa.Row).Columns(); len(cols) != len(exp) || (len(exp) > 0 && !reflect.DeepEqual(cols, exp)) {
+			t.Fatalf("unexpected columns for result %d: %v", i, cols)
+		}
+	}
+	if pairs := res.Results[4].([]pilosa.Pair); len(pairs) == 0 || pairs[0] != (pilosa.Pair{ID: 2, Count: 4}) {
+		t.Fatalf("unexpected top rows: %v", pairs)
+	}
+
+	if err := m0.API.RemapRows(ctx, "i", "n", map[uint64]uint64{1: 2}); err == nil {
+		t.Fatal("expected error")
+	} else if _, ok := errors.Cause(err).(pilosa.BadRequestError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := m0.API.RemapRows(ctx, "i", "missing", map[uint64]uint64{1: 2}); err == nil {
+		t.Fatal("expected error")
+	} else if _, ok := errors.Cause(err).(pilosa.NotFoundError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAPI_ExplainAnalyze(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	}
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf(`Set(1, f=1) Set(%d, f=1) Set(2, f=2)`, pilosa.ShardWidth+1)})
+
+	resp, stats, err := m0.API.ExplainAnalyze(ctx, &pilosa.QueryRequest{Index: "i", Query: `Count(Row(f=1)) Row(f=2)`})
+	if err != nil {
+		t.Fatal(err)
+	} else if n := resp.Results[0].(uint64); n != 2 {
+		t.Fatalf("unexpected count: %d", n)
+	} else if cols := resp.Results[1].(*pilosa.Row).Columns(); !reflect.DeepEqual(cols, []uint64{2}) {
+		t.Fatalf("unexpected columns: %v", cols)
+	} else if len(stats.Calls) != 2 {
+		t.Fatalf("unexpected call stats: %+v", stats.Calls)
+	}
+
+	if cs := stats.Calls[0]; cs.Call != "Count(Row(f=1))" || cs.Shards != 2 || cs.Rows != 2 || cs.Containers != 2 || cs.Duration <= 0 {
+		t.Fatalf("unexpected stats for Count: %+v", cs)
+	} else if cs := stats.Calls[1]; cs.Call != "Row(f=2)" || cs.Shards != 2 || cs.Rows != 2 || cs.Containers != 1 {
+		t.Fatalf("unexpected stats for Row: %+v", cs)
+	}
+}
+
+func TestAPI_SetIndexQueryRateLimit(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	} else if err := m0.API.SetIndexQueryRateLimit(ctx, "i", 2); err != nil {
+		t.Fatal(err)
+	}
+
+	// The bucket starts full, so a burst of two queries is allowed.
+	req := &pilosa.QueryRequest{Index: "i", Query: `Count(Row(f=1))`}
+	for i := 0; i < 2; i++ {
+		if _, err := m0.API.Query(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := m0.API.Query(ctx, req); errors.Cause(err) != pilosa.ErrRateLimited {
+		t.Fatalf("expected rate limited error, got: %v", err)
+	}
+
+	// Removing the limit allows queries again.
+	if err := m0.API.SetIndexQueryRateLimit(ctx, "i", 0); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.Query(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m0.API.SetIndexQueryRateLimit(ctx, "i", -1); err == nil {
+		t.Fatal("expected error")
+	} else if _, ok := errors.Cause(err).(pilosa.BadRequestError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Limits are removed with their index.
+	if err := m0.API.SetIndexQueryRateLimit(ctx, "i", 1); err != nil {
+		t.Fatal(err)
+	} else if err := m0.API.DeleteIndex(ctx, "i"); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := m0.API.Query(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := m0.API.SetIndexQueryRateLimit(ctx, "missing", 1); err == nil {
+		t.Fatal("expected error")
+	} else if _, ok := errors.Cause(err).(pilosa.NotFoundError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAPI_Manifest(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	}
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf(`Set(%d, f=1) Set(1, f=1)`, pilosa.ShardWidth+1)})
+
+	m, err := m0.API.Manifest(ctx)
+	if err != nil {
+		t.Fatal(err)
+	} else if m.NodeID != m0.API.Node().ID {
+		t.Fatalf("unexpected node id: %s", m.NodeID)
+	} else if len(m.Schema) != 1 || m.Schema[0].Name != "i" {
+		t.Fatalf("unexpected schema: %v", m.Schema)
+	} else if len(m.Fragments) != 2 {
+		t.Fatalf("unexpected fragments: %+v", m.Fragments)
+	}
+	for i, fm := range m.Fragments {
+		if fm.Index != "i" || fm.Field != "f" || fm.View != "standard" || fm.Shard != uint64(i) {
+			t.Fatalf("unexpected fragment %d: %+v", i, fm)
+		} else if len(fm.Blocks) != 1 || fm.Bytes == 0 {
+			t.Fatalf("unexpected blocks or size for fragment %d: %+v", i, fm)
+		}
+	}
+
+	// Blocks change with the data.
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `Set(2, f=1)`})
+	if m2, err := m0.API.Manifest(ctx); err != nil {
+		t.Fatal(err)
+	} else if reflect.DeepEqual(m.Fragments[0].Blocks, m2.Fragments[0].Blocks) {
+		t.Fatal("expected shard 0 blocks to change")
+	} else if !reflect.DeepEqual(m.Fragments[1].Blocks, m2.Fragments[1].Blocks) {
+		t.Fatal("expected shard 1 blocks to be unchanged")
+	}
+}
+
+func TestAPI_StepDownCoordinator(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+
+	// A lone coordinator has no node to hand off to.
+	if err := m0.API.StepDownCoordinator(context.Background()); err == nil {
+		t.Fatal("expected error")
+	} else if cerr, ok := errors.Cause(err).(pilosa.ConflictError); !ok || !strings.Contains(cerr.Error(), pilosa.ErrNoCoordinatorCandidate.Error()) {
+		t.Fatalf("unexpected error: %v", err)
+	} else if !m0.API.Node().IsCoordinator {
+		t.Fatal("expected node to remain coordinator")
+	}
+}
+
+// Ensure the coordinator role is handed to another node in a cluster.
+func TestAPI_StepDownCoordinator_Handoff(t *testing.T) {
+	c := test.MustRunCluster(t, 2)
+	defer c.Close()
+	ctx := context.Background()
+
+	// Find the current coordinator and the node it hands off to.
+	prev, next := c[0], c[1]
+	if !prev.API.Node().IsCoordinator {
+		prev, next = next, prev
+	}
+	_, epoch, err := prev.API.Coordinator(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := prev.API.StepDownCoordinator(ctx); err != nil {
+		t.Fatal(err)
+	} else if prev.API.Node().IsCoordinator {
+		t.Fatal("expected previous coordinator to step down")
+	} else if !next.API.Node().IsCoordinator {
+		t.Fatal("expected other node to become coordinator")
+	}
+
+	// Every node agrees on the new coordinator, at a later epoch.
+	for _, m := range c {
+		if id, e, err := m.API.Coordinator(ctx); err != nil {
+			t.Fatal(err)
+		} else if id != next.API.Node().ID {
+			t.Fatalf("unexpected coordinator on node %s: %s", m.API.Node().ID, id)
+		} else if e <= epoch {
+			t.Fatalf("expected epoch to increase on node %s: %d <= %d", m.API.Node().ID, e, epoch)
+		}
+	}
+
+	// The new coordinator can step down again.
+	if err := next.API.StepDownCoordinator(ctx); err != nil {
+		t.Fatal(err)
+	} else if !prev.API.Node().IsCoordinator {
+		t.Fatal("expected coordinator role to be handed back")
+	}
+}
+
+func TestAPI_MergeIndexes(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "t", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "t", "f"); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateIndex(ctx, "s", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "s", "f"); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "s", "n", pilosa.OptFieldTypeInt(-10, 100)); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "s", "tm", pilosa.OptFieldTypeTime("YMD")); err != nil {
+		t.Fatal(err)
+	}
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "t", Query: `Set(1, f=1)`})
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "s", Query: fmt.Sprintf(`Set(1, f=1) Set(%d, f=3) Set(3, n=-5) Set(4, n=70) Set(5, tm=2, 2018-01-02T00:00)`, pilosa.ShardWidth+2)})
+
+	if err := m0.API.MergeIndexes(ctx, "t", "s", pilosa.ShardWidth-1, pilosa.OptMergeIndexesDeleteSource(true)); err != nil {
+		t.Fatal(err)
+	}
+	res := m0.MustQuery(t, &pilosa.QueryRequest{Index: "t", Query: `Row(f=1) Row(f=3) Row(n == -5) Row(n > 0) Row(tm=2, from=2018-01-02T00:00, to=2018-01-03T00:00) Row(tm=2, from=2018-01-03T00:00, to=2018-01-04T00:00)`})
+	for i, exp := range [][]uint64{
+		{1, pilosa.ShardWidth},
+		{2*pilosa.ShardWidth + 1},
+		{pilosa.ShardWidth + 2},
+		{pilosa.ShardWidth + 3},
+		{pilosa.ShardWidth + 4},
+		nil,
+	} {
+		if cols := res.Results[i].(*pilosa.Row).Columns(); len(cols) != len(exp) || (len(exp) > 0 && !reflect.DeepEqual(cols, exp)) {
+			t.Fatalf("unexpected columns for result %d: %v", i, cols)
+		}
+	}
+	if _, err := m0.API.Index(ctx, "s"); err == nil {
+		t.Fatal("expected source index to be deleted")
+	}
+
+	if err := m0.API.MergeIndexes(ctx, "t", "t", 0); err == nil {
+		t.Fatal("expected error")
+	} else if _, ok := errors.Cause(err).(pilosa.BadRequestError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := m0.API.MergeIndexes(ctx, "t", "missing", 0); err == nil {
+		t.Fatal("expected error")
+	} else if _, ok := errors.Cause(err).(pilosa.NotFoundError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Fields must have the same type in both indexes.
+	if _, err := m0.API.CreateIndex(ctx, "s2", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "s2", "f", pilosa.OptFieldTypeInt(0, 10)); err != nil {
+		t.Fatal(err)
+	}
+	if err := m0.API.MergeIndexes(ctx, "t", "s2", 0); err == nil {
+		t.Fatal("expected error")
+	} else if _, ok := errors.Cause(err).(pilosa.BadRequestError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAPI_ImportStream(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "f", pilosa.OptFieldAllowedRows(1, 2, 3)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The third batch uses a row which is not allowed, so it and the
+	// batches after it are not imported.
+	var body bytes.Buffer
+	for _, req := range []*pilosa.ImportRequest{
+		{RowIDs: []uint64{1, 1}, ColumnIDs: []uint64{1, 2}},
+		{RowIDs: []uint64{2}, ColumnIDs: []uint64{3}},
+		{RowIDs: []uint64{9}, ColumnIDs: []uint64{4}},
+		{RowIDs: []uint64{3}, ColumnIDs: []uint64{5}},
+	} {
+		buf, err := m0.API.Serializer.Marshal(req)
+		if err != nil {
+			t.Fatal(err)
+		} else if err := pilosa.WriteImportStreamFrame(&body, buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var acks bytes.Buffer
+	if err := m0.API.ImportStream(ctx, "i", "f", &body, &acks); err == nil {
+		t.Fatal("expected error")
+	}
+	dec := json.NewDecoder(&acks)
+	for i, exp := range []pilosa.ImportAck{{Batch: 0, Bits: 2}, {Batch: 1, Bits: 1}, {Batch: 2}} {
+		var ack pilosa.ImportAck
+		if err := dec.Decode(&ack); err != nil {
+			t.Fatal(err)
+		}
+		if i == 2 && ack.Error == "" {
+			t.Fatal("expected batch error")
+		}
+		ack.Error = ""
+		if ack != exp {
+			t.Fatalf("unexpected ack %d: %+v", i, ack)
+		}
+	}
+	if dec.More() {
+		t.Fatal("unexpected acks after failed batch")
+	}
+
+	res := m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `Row(f=1) Row(f=2) Row(f=3)`})
+	for i, exp := range [][]uint64{{1, 2}, {3}, nil} {
+		if cols := res.Results[i].(*pilosa.Row).Columns(); len(cols) != len(exp) || (len(exp) > 0 && !reflect.DeepEqual(cols, exp)) {
+			t.Fatalf("unexpected columns for result %d: %v", i, cols)
+		}
+	}
+
+	// Acks are read while the stream is still open over an upgraded
+	// connection.
+	t.Run("Upgrade", func(t *testing.T) {
+		conn, err := net.Dial("tcp", m0.API.Node().URI.HostPort())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		fmt.Fprintf(conn, "POST /index/i/field/f/import-stream HTTP/1.1\r\nHost: %s\r\nConnection: Upgrade\r\nUpgrade: pilosa-import-stream\r\n\r\n", m0.API.Node().URI.HostPort())
+		br := bufio.NewReader(conn)
+		resp, err := http.ReadResponse(br, nil)
+		if err != nil {
+			t.Fatal(err)
+		} else if resp.StatusCode != http.StatusSwitchingProtocols {
+			t.Fatalf("unexpected status: %d", resp.StatusCode)
+		}
+
+		for i := uint64(0); i < 2; i++ {
+			buf, err := m0.API.Serializer.Marshal(&pilosa.ImportRequest{RowIDs: []uint64{3}, ColumnIDs: []uint64{10 + i}})
+			if err != nil {
+				t.Fatal(err)
+			} else if err := pilosa.WriteImportStreamFrame(conn, buf); err != nil {
+				t.Fatal(err)
+			}
+
+			var ack pilosa.ImportAck
+			if line, err := br.ReadBytes('\n'); err != nil {
+				t.Fatal(err)
+			} else if err := json.Unmarshal(line, &ack); err != nil {
+				t.Fatal(err)
+			} else if ack != (pilosa.ImportAck{Batch: i, Bits: 1}) {
+				t.Fatalf("unexpected ack: %+v", ack)
+			}
+		}
+		conn.(*net.TCPConn).CloseWrite()
+		if _, err := br.ReadByte(); err != io.EOF {
+			t.Fatalf("expected stream to end: %v", err)
+		}
+
+		res := m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `Row(f=3)`})
+		if cols := res.Results[0].(*pilosa.Row).Columns(); !reflect.DeepEqual(cols, []uint64{10, 11}) {
+			t.Fatalf("unexpected columns: %v", cols)
+		}
+	})
+}
+
+func TestAPI_ValueHistogram(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "n", pilosa.OptFieldTypeInt(-100, 100)); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	}
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf(`Set(1, n=-50) Set(2, n=0) Set(3, n=9) Set(4, n=10) Set(5, n=19) Set(%d, n=39) Set(6, n=40)`, pilosa.ShardWidth+1)})
+
+	counts, err := m0.API.ValueHistogram(ctx, "i", "n", 0, 39, 4)
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(counts, []uint64{2, 2, 0, 1}) {
+		t.Fatalf("unexpected counts: %v", counts)
+	}
+
+	if _, err := m0.API.ValueHistogram(ctx, "i", "n", 0, 39, 0); err == nil {
+		t.Fatal("expected error")
+	} else if _, ok := errors.Cause(err).(pilosa.BadRequestError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := m0.API.ValueHistogram(ctx, "i", "f", 0, 39, 4); err == nil {
+		t.Fatal("expected error")
+	} else if _, ok := errors.Cause(err).(pilosa.BadRequestError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := m0.API.ValueHistogram(ctx, "i", "missing", 0, 39, 4); err == nil {
+		t.Fatal("expected error")
+	} else if _, ok := errors.Cause(err).(pilosa.NotFoundError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAPI_ChangedShards(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "n", pilosa.OptFieldTypeInt(0, 100)); err != nil {
+		t.Fatal(err)
+	}
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf(`Set(1, f=1) Set(%d, f=1) Set(%d, f=1)`, pilosa.ShardWidth+1, 2*pilosa.ShardWidth+1)})
+
+	since := time.Now()
+	time.Sleep(time.Millisecond)
+	if err := m0.API.Import(ctx, &pilosa.ImportRequest{Index: "i", Field: "f", Shard: 2, RowIDs: []uint64{2}, ColumnIDs: []uint64{2*pilosa.ShardWidth + 2}}); err != nil {
+		t.Fatal(err)
+	} else if err := m0.API.ImportValue(ctx, &pilosa.ImportValueRequest{Index: "i", Field: "n", Shard: 3, ColumnIDs: []uint64{3*pilosa.ShardWidth + 1}, Values: []int64{5}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if shards, err := m0.API.ChangedShards(ctx, "i", since); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(shards, []uint64{2, 3}) {
+		t.Fatalf("unexpected shards: %v", shards)
+	}
+	if shards, err := m0.API.ChangedShards(ctx, "i", time.Time{}); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(shards, []uint64{0, 1, 2, 3}) {
+		t.Fatalf("unexpected shards: %v", shards)
+	}
+	if shards, err := m0.API.ChangedShards(ctx, "i", time.Now()); err != nil {
+		t.Fatal(err)
+	} else if len(shards) != 0 {
+		t.Fatalf("unexpected shards: %v", shards)
+	}
+
+	if _, err := m0.API.ChangedShards(ctx, "missing", since); err == nil {
+		t.Fatal("expected error")
+	} else if _, ok := errors.Cause(err).(pilosa.NotFoundError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAPI_AccountUsage(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	}
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf(`Set(1, f=1) Set(%d, f=1)`, pilosa.ShardWidth+1)})
+
+	// Each call is mapped to both shards.
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `Count(Row(f=1)) Row(f=1)`, AccountID: "a"})
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `Row(f=1)`, AccountID: "a"})
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `Row(f=1)`, AccountID: "b"})
+
+	if u, err := m0.API.AccountUsage(ctx, "a"); err != nil {
+		t.Fatal(err)
+	} else if u.Queries != 2 || u.Shards != 6 || u.Duration <= 0 {
+		t.Fatalf("unexpected usage: %+v", u)
+	}
+	if u, err := m0.API.AccountUsage(ctx, "b"); err != nil {
+		t.Fatal(err)
+	} else if u.Queries != 1 || u.Shards != 2 {
+		t.Fatalf("unexpected usage: %+v", u)
+	}
+
+	if err := m0.API.ResetAccountUsage(ctx, "a"); err != nil {
+		t.Fatal(err)
+	} else if u, err := m0.API.AccountUsage(ctx, "a"); err != nil {
+		t.Fatal(err)
+	} else if u != (pilosa.Usage{}) {
+		t.Fatalf("unexpected usage after reset: %+v", u)
+	} else if u, err := m0.API.AccountUsage(ctx, "b"); err != nil {
+		t.Fatal(err)
+	} else if u.Queries != 1 {
+		t.Fatalf("unexpected usage of other account: %+v", u)
+	}
+
+	if _, err := m0.API.AccountUsage(ctx, ""); err == nil {
+		t.Fatal("expected error")
+	} else if _, ok := errors.Cause(err).(pilosa.BadRequestError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAPI_VerifySchemaConsistency(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	}
+
+	if missing, err := m0.API.VerifySchemaConsistency(ctx); err != nil {
+		t.Fatal(err)
+	} else if len(missing) != 0 {
+		t.Fatalf("unexpected discrepancies: %v", missing)
+	}
+}
+
+func TestAPI_PauseQueries(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m0.API.PauseQueries(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Queries block until resumed.
+	done := make(chan error, 1)
+	go func() {
+		_, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: `Set(1, f=1)`})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("query completed while paused: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Queries give up when their context is done.
+	tctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := m0.API.Query(tctx, &pilosa.QueryRequest{Index: "i", Query: `Count(Row(f=1))`}); errors.Cause(err) != context.DeadlineExceeded {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Imports are held too.
+	req := &pilosa.ImportRequest{Index: "i", Field: "f", RowIDs: []uint64{2}, ColumnIDs: []uint64{1}}
+	if err := m0.API.Import(tctx, req); errors.Cause(err) != context.DeadlineExceeded {
+		t.Fatalf("unexpected import error: %v", err)
+	} else if err := m0.API.ImportBatch(tctx, "i", []pilosa.FieldImport{{Field: "f", RowIDs: []uint64{2}, ColumnIDs: []uint64{1}}}); errors.Cause(err) != context.DeadlineExceeded {
+		t.Fatalf("unexpected batch import error: %v", err)
+	}
+
+	// Remote queries are not paused.
+	if _, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: `Count(Row(f=1))`, Remote: true, Shards: []uint64{0}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m0.API.ResumeQueries(ctx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("query did not resume")
+	}
+	if resp := m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `Count(Row(f=1))`}); resp.Results[0] != uint64(1) {
+		t.Fatalf("unexpected count: %v", resp.Results[0])
+	} else if err := m0.API.Import(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAPI_IncludeRowAttrs(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "k", pilosa.OptFieldKeys()); err != nil {
+		t.Fatal(err)
+	}
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `
+		Set(1, f=1) Set(2, f=1) Set(1, f=2)
+		SetRowAttrs(f, 1, label="one")
+		Set(1, k="a") SetRowAttrs(k, "a", label="A")`})
+	m0.MustRecalculateCaches(t)
+
+	resp := m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `TopN(f) TopN(k)`, IncludeRowAttrs: true, SortResults: true})
+	if pairs := resp.Results[0].([]pilosa.PairAttrs); !reflect.DeepEqual(pairs, []pilosa.PairAttrs{
+		{Pair: pilosa.Pair{ID: 1, Count: 2}, Attrs: map[string]interface{}{"label": "one"}},
+		{Pair: pilosa.Pair{ID: 2, Count: 1}, Attrs: map[string]interface{}{}},
+	}) {
+		t.Fatalf("unexpected pairs: %#v", pairs)
+	}
+	if pairs := resp.Results[1].([]pilosa.PairAttrs); !reflect.DeepEqual(pairs, []pilosa.PairAttrs{
+		{Pair: pilosa.Pair{Key: "a", Count: 1}, Attrs: map[string]interface{}{"label": "A"}},
+	}) {
+		t.Fatalf("unexpected pairs: %#v", pairs)
+	}
+
+	// Attributes are not included by default, or if row attributes are
+	// excluded.
+	for _, req := range []*pilosa.QueryRequest{
+		{Index: "i", Query: `TopN(f)`},
+		{Index: "i", Query: `TopN(f)`, IncludeRowAttrs: true, ExcludeRowAttrs: true},
+	} {
+		resp := m0.MustQuery(t, req)
+		if _, ok := resp.Results[0].([]pilosa.Pair); !ok {
+			t.Fatalf("unexpected result: %#v", resp.Results[0])
+		}
+	}
+}
+
+func TestAPI_CacheStaleness(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first write to a cache ranks it, while later writes wait for the
+	// next recalculation.
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf(`Set(1, f=1) Set(2, f=2) Set(%d, f=1)`, pilosa.ShardWidth+1)})
+	if stale, err := m0.API.CacheStaleness(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(stale, map[uint64]bool{0: true, 1: false}) {
+		t.Fatalf("unexpected staleness: %v", stale)
+	}
+
+	m0.MustRecalculateCaches(t)
+	if stale, err := m0.API.CacheStaleness(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(stale, map[uint64]bool{0: false, 1: false}) {
+		t.Fatalf("unexpected staleness: %v", stale)
+	}
+
+	if _, err := m0.API.CacheStaleness(ctx, "i", "missing"); err == nil {
+		t.Fatal("expected error")
+	} else if _, ok := errors.Cause(err).(pilosa.NotFoundError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAPI_SetDefaultFieldOptions(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "before"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m0.API.SetDefaultFieldOptions(ctx, "i", pilosa.FieldOptions{
+		Type:        pilosa.FieldTypeSet,
+		CacheType:   pilosa.CacheTypeRanked,
+		CacheSize:   500,
+		Compression: pilosa.CompressionGzip,
+		Keys:        true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Fields inherit unset options, and options of their type.
+	if f, err := m0.API.CreateField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	} else if o := f.Options(); o.Type != pilosa.FieldTypeSet || o.CacheType != pilosa.CacheTypeRanked || o.CacheSize != 500 || o.Compression != pilosa.CompressionGzip || !o.Keys {
+		t.Fatalf("unexpected options: %+v", o)
+	}
+
+	// Options set explicitly are kept, even if they are zero values.
+	if f, err := m0.API.CreateField(ctx, "i", "g", pilosa.OptFieldTypeSet(pilosa.CacheTypeLRU, 0), pilosa.OptFieldNoKeys()); err != nil {
+		t.Fatal(err)
+	} else if o := f.Options(); o.CacheType != pilosa.CacheTypeLRU || o.CacheSize != pilosa.DefaultCacheSize || o.Keys || o.Compression != pilosa.CompressionGzip {
+		t.Fatalf("unexpected options: %+v", o)
+	}
+	if f, err := m0.API.CreateField(ctx, "i", "n", pilosa.OptFieldTypeInt(0, 10), pilosa.OptFieldCompression(pilosa.CompressionNone)); err != nil {
+		t.Fatal(err)
+	} else if o := f.Options(); o.Type != pilosa.FieldTypeInt || o.CacheType != pilosa.CacheTypeNone || o.Compression != pilosa.CompressionNone {
+		t.Fatalf("unexpected options: %+v", o)
+	}
+	if _, err := m0.API.CreateIndex(ctx, "j", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if err := m0.API.SetDefaultFieldOptions(ctx, "j", pilosa.FieldOptions{Type: pilosa.FieldTypeInt, Min: -5, Max: 5}); err != nil {
+		t.Fatal(err)
+	} else if f, err := m0.API.CreateField(ctx, "j", "n", pilosa.OptFieldTypeInt(0, 0)); err != nil {
+		t.Fatal(err)
+	} else if o := f.Options(); o.Min != 0 || o.Max != 0 {
+		t.Fatalf("unexpected options: %+v", o)
+	}
+
+	// Existing fields are unchanged.
+	if f, err := m0.API.Field(ctx, "i", "before"); err != nil {
+		t.Fatal(err)
+	} else if o := f.Options(); o.CacheSize != pilosa.DefaultCacheSize || o.Compression != "" {
+		t.Fatalf("unexpected options: %+v", o)
+	}
+
+	// The defaults are kept in the index meta.
+	if err := m0.Reopen(); err != nil {
+		t.Fatal(err)
+	} else if o := m0.Server.Holder().Index("i").DefaultFieldOptions(); o.CacheSize != 500 || o.Compression != pilosa.CompressionGzip {
+		t.Fatalf("unexpected defaults after reopen: %+v", o)
+	}
+
+	if err := m0.API.SetDefaultFieldOptions(ctx, "i", pilosa.FieldOptions{CacheType: "bad"}); err == nil {
+		t.Fatal("expected error")
+	} else if _, ok := errors.Cause(err).(pilosa.BadRequestError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := m0.API.SetDefaultFieldOptions(ctx, "missing", pilosa.FieldOptions{}); err == nil {
+		t.Fatal("expected error")
+	} else if _, ok := errors.Cause(err).(pilosa.NotFoundError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAPI_IndexMerkleRoot(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	}
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf(`Set(1, f=1) Set(%d, f=2)`, pilosa.ShardWidth+1)})
+
+	root, err := m0.API.IndexMerkleRoot(ctx, "i")
+	if err != nil {
+		t.Fatal(err)
+	} else if again, err := m0.API.IndexMerkleRoot(ctx, "i"); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(root, again) {
+		t.Fatalf("root changed without writes: %x != %x", root, again)
+	}
+
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `Set(2, f=1)`})
+	if changed, err := m0.API.IndexMerkleRoot(ctx, "i"); err != nil {
+		t.Fatal(err)
+	} else if bytes.Equal(root, changed) {
+		t.Fatal("expected root to change after a write")
+	}
+
+	if _, err := m0.API.IndexMerkleRoot(ctx, "missing"); err == nil {
+		t.Fatal("expected error")
+	} else if _, ok := errors.Cause(err).(pilosa.NotFoundError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAPI_MaxParallelism(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	}
+	for shard := uint64(0); shard < 5; shard++ {
+		m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf(`Set(%d, f=1)`, shard*pilosa.ShardWidth+1)})
+	}
+
+	for _, n := range []int{0, 1, 2, 10} {
+		resp := m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `Count(Row(f=1))`, MaxParallelism: n})
+		if count := resp.Results[0].(uint64); count != 5 {
+			t.Fatalf("max parallelism %d: unexpected count: %d", n, count)
+		}
+	}
+
+	if _, err := m0.API.Query(ctx, &pilosa.QueryRequest{Index: "i", Query: `Count(Row(f=1))`, MaxParallelism: -1}); err == nil {
+		t.Fatal("expected error")
+	} else if _, ok := errors.Cause(err).(pilosa.BadRequestError); !ok {
+		t.Fatalf("expected bad request error, got %v", err)
+	}
+}
+
+func TestAPI_RebuildSchemaFromDisk(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	if _, err := m0.API.CreateIndex(ctx, "i", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "s", pilosa.OptFieldTypeSet(pilosa.CacheTypeLRU, 100)); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "n", pilosa.OptFieldTypeInt(0, 1000)); err != nil {
+		t.Fatal(err)
+	} else if _, err := m0.API.CreateField(ctx, "i", "t", pilosa.OptFieldTypeTime("YMD")); err != nil {
+		t.Fatal(err)
+	}
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `
+		Set(1, s=1) Set(2, n=700) Set(3, t=1, 2019-01-02T00:00)`})
+
+	// Lose the field meta files, and add a field directory while the
+	// index is open.
+	path := m0.Server.Holder().Index("i").Path()
+	for _, name := range []string{"s", "n", "t"} {
+		if err := os.Remove(filepath.Join(path, name, ".meta")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m0.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(path, "x", "views", "standard"), 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m0.API.RebuildSchemaFromDisk(ctx, "j"); err == nil {
+		t.Fatal("expected error")
+	} else if _, ok := errors.Cause(err).(pilosa.NotFoundError); !ok {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+
+	info, err := m0.API.RebuildSchemaFromDisk(ctx, "i")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := make(map[string]*pilosa.FieldInfo)
+	for _, fi := range info.Fields {
+		fields[fi.Name] = fi
+	}
+	if fi := fields["s"]; fi == nil || fi.Options.Type != pilosa.FieldTypeSet {
+		t.Fatalf("unexpected field s: %#v", fi)
+	} else if fi := fields["x"]; fi == nil || fi.Options.Type != pilosa.FieldTypeSet {
+		t.Fatalf("unexpected field x: %#v", fi)
+	} else if fi := fields["n"]; fi == nil || fi.Options.Type != pilosa.FieldTypeInt || fi.Options.Min != 0 || fi.Options.Max != 1023 {
+		t.Fatalf("unexpected field n: %#v", fi)
+	} else if fi := fields["t"]; fi == nil || fi.Options.Type != pilosa.FieldTypeTime || fi.Options.TimeQuantum != "YMD" || fi.Options.NoStandardView {
+		t.Fatalf("unexpected field t: %#v", fi)
+	} else if len(fi.Views) != 4 {
+		t.Fatalf("unexpected views: %#v", fi.Views)
+	}
+
+	// The recovered schema is usable, and survives a restart.
+	if err := m0.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	resp := m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `
+		Count(Row(n == 700)) Count(Row(t=1, from=2019-01-01T00:00, to=2019-02-01T00:00))`})
+	if !reflect.DeepEqual(resp.Results, []interface{}{uint64(1), uint64(1)}) {
+		t.Fatalf("unexpected results: %#v", resp.Results)
+	}
+	if opt := m0.Server.Holder().Index("i").Field("t").Options(); opt.TimeQuantum != "YMD" {
+		t.Fatalf("unexpected time quantum after reopen: %q", opt.TimeQuantum)
+	}
+}
+
+func TestAPI_ImportHistory(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	m0.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	m0.MustCreateField(t, "i", "f")
+	m0.MustCreateField(t, "i", "n", pilosa.OptFieldTypeInt(0, 100))
+
+	if err := m0.API.Import(ctx, &pilosa.ImportRequest{
+		Index:     "i",
+		Field:     "f",
+		RowIDs:    []uint64{1, 1, 2},
+		ColumnIDs: []uint64{1, pilosa.ShardWidth + 1, pilosa.ShardWidth + 2},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m0.API.Import(ctx, &pilosa.ImportRequest{
+		Index:     "i",
+		Field:     "f",
+		RowIDs:    []uint64{1},
+		ColumnIDs: []uint64{1},
+	}, pilosa.OptImportOptionsClear(true)); err != nil {
+		t.Fatal(err)
+	}
+	if err := m0.API.ImportValue(ctx, &pilosa.ImportValueRequest{
+		Index:     "i",
+		Field:     "n",
+		ColumnIDs: []uint64{1, 2},
+		Values:    []int64{5, 6},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := m0.API.ImportHistory(ctx, "i", "f", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range records {
+		if records[i].Time.IsZero() {
+			t.Fatalf("record %d has no time", i)
+		}
+		records[i].Time = time.Time{}
+	}
+	if !reflect.DeepEqual(records, []pilosa.ImportRecord{
+		{Shard: 0, Columns: 1, Clear: true},
+		{Shard: 1, Columns: 2},
+		{Shard: 0, Columns: 1},
+	}) {
+		t.Fatalf("unexpected records: %#v", records)
+	}
+
+	if records, err := m0.API.ImportHistory(ctx, "i", "f", 1); err != nil {
+		t.Fatal(err)
+	} else if len(records) != 1 || !records[0].Clear {
+		t.Fatalf("unexpected records: %#v", records)
+	}
+	if records, err := m0.API.ImportHistory(ctx, "i", "n", 0); err != nil {
+		t.Fatal(err)
+	} else if len(records) != 1 || records[0].Columns != 2 {
+		t.Fatalf("unexpected records: %#v", records)
+	}
+
+	if _, err := m0.API.ImportHistory(ctx, "i", "x", 0); err == nil {
+		t.Fatal("expected error")
+	} else if _, ok := errors.Cause(err).(pilosa.NotFoundError); !ok {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
+
+func TestAPI_IndexBitCount(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	m0.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	m0.MustCreateField(t, "i", "f")
+	m0.MustCreateField(t, "i", "n", pilosa.OptFieldTypeInt(0, 100))
+
+	if n, err := m0.API.IndexBitCount(ctx, "i"); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatalf("unexpected count for empty index: %d", n)
+	}
+
+	// The value 5 sets two bits of its encoding, plus its not-null bit.
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf(`
+		Set(1, f=1) Set(2, f=1) Set(%d, f=2) Set(1, n=5)`, pilosa.ShardWidth+1)})
+	if n, err := m0.API.IndexBitCount(ctx, "i"); err != nil {
+		t.Fatal(err)
+	} else if n != 6 {
+		t.Fatalf("unexpected count: %d", n)
+	}
+
+	if _, err := m0.API.IndexBitCount(ctx, "x"); err == nil {
+		t.Fatal("expected error")
+	} else if _, ok := errors.Cause(err).(pilosa.NotFoundError); !ok {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
+
+func TestAPI_DeleteFieldKeys(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	m0.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	m0.MustCreateField(t, "i", "f", pilosa.OptFieldKeys())
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `Set(1, f="a") Set(2, f="b")`})
+
+	if err := m0.API.DeleteField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new field of the same name starts without the old field's keys.
+	m0.MustCreateField(t, "i", "f", pilosa.OptFieldKeys())
+	if ids, err := m0.API.TranslateRowKeys(ctx, "i", "f", []string{"b"}); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(ids, []uint64{1}) {
+		t.Fatalf("unexpected ids: %v", ids)
+	}
+
+	if err := m0.API.DeleteField(ctx, "i", "x"); err == nil {
+		t.Fatal("expected error")
+	} else if _, ok := errors.Cause(err).(pilosa.NotFoundError); !ok {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
+
+func TestAPI_GetBits(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	m0.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	m0.MustCreateField(t, "i", "f")
+	m0.MustCreateField(t, "i", "n", pilosa.OptFieldTypeInt(0, 100))
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf(`
+		Set(1, f=1) Set(2, f=3) Set(%d, f=1)`, pilosa.ShardWidth+1)})
+
+	rows := []uint64{1, 1, 3, 3, 1, 2, 1}
+	cols := []uint64{1, 2, 2, 1, pilosa.ShardWidth + 1, pilosa.ShardWidth + 1, 3 * pilosa.ShardWidth}
+	bits, err := m0.API.GetBits(ctx, "i", "f", rows, cols)
+	if err != nil {
+		t.Fatal(err)
+	} else if exp := []bool{true, false, true, false, true, false, false}; !reflect.DeepEqual(bits, exp) {
+		t.Fatalf("unexpected bits: %v", bits)
+	}
+
+	if _, err := m0.API.GetBits(ctx, "i", "f", []uint64{1}, nil); err == nil {
+		t.Fatal("expected error for mismatched lengths")
+	} else if _, ok := errors.Cause(err).(pilosa.BadRequestError); !ok {
+		t.Fatalf("expected bad request error, got %v", err)
+	}
+	if _, err := m0.API.GetBits(ctx, "i", "n", []uint64{1}, []uint64{1}); err == nil {
+		t.Fatal("expected error for int field")
+	}
+	if _, err := m0.API.GetBits(ctx, "i", "x", []uint64{1}, []uint64{1}); err == nil {
+		t.Fatal("expected error")
+	} else if _, ok := errors.Cause(err).(pilosa.NotFoundError); !ok {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
+
+func TestAPI_TrashDeletedFields(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	m0.Server.Holder().SetTrashDeletedFields(true)
+	m0.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	m0.MustCreateField(t, "i", "f", pilosa.OptFieldKeys())
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `Set(1, f="a") Set(2, f="a")`})
+
+	// A deleted field can be restored with its data and keys.
+	if err := m0.API.DeleteField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	} else if err := m0.API.RestoreField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	}
+	resp := m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `Row(f="a")`})
+	if cols := resp.Results[0].(*pilosa.Row).Columns(); !reflect.DeepEqual(cols, []uint64{1, 2}) {
+		t.Fatalf("unexpected columns: %v", cols)
+	}
+	if err := m0.API.RestoreField(ctx, "i", "f"); err == nil {
+		t.Fatal("expected error restoring existing field")
+	} else if _, ok := errors.Cause(err).(pilosa.ConflictError); !ok {
+		t.Fatalf("expected conflict error, got %v", err)
+	}
+
+	// Fields are kept until the grace period passes.
+	if err := m0.API.DeleteField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	} else if err := m0.API.PurgeTrash(ctx, time.Hour); err != nil {
+		t.Fatal(err)
+	} else if err := m0.API.RestoreField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m0.API.DeleteField(ctx, "i", "f"); err != nil {
+		t.Fatal(err)
+	} else if err := m0.API.PurgeTrash(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := m0.API.RestoreField(ctx, "i", "f"); err == nil {
+		t.Fatal("expected error restoring purged field")
+	} else if _, ok := errors.Cause(err).(pilosa.NotFoundError); !ok {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+	if err := m0.API.PurgeTrash(ctx, -time.Hour); err == nil {
+		t.Fatal("expected error for negative grace period")
+	}
+}
+
+func TestAPI_IntersectRows(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	m0.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	m0.MustCreateField(t, "i", "f")
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf(`
+		Set(1, f=1) Set(2, f=1) Set(%[1]d, f=1) Set(%[2]d, f=1)
+		Set(2, f=2) Set(3, f=2) Set(%[1]d, f=2) Set(%[2]d, f=2)
+		Set(2, f=3) Set(%[1]d, f=3)`, pilosa.ShardWidth+1, 2*pilosa.ShardWidth)})
+
+	if row, err := m0.API.IntersectRows(ctx, "i", "f", []uint64{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	} else if cols := row.Columns(); !reflect.DeepEqual(cols, []uint64{2, pilosa.ShardWidth + 1}) {
+		t.Fatalf("unexpected columns: %v", cols)
+	}
+	if row, err := m0.API.IntersectRows(ctx, "i", "f", []uint64{1, 4}); err != nil {
+		t.Fatal(err)
+	} else if row.Count() != 0 {
+		t.Fatalf("unexpected columns: %v", row.Columns())
+	}
+
+	if _, err := m0.API.IntersectRows(ctx, "i", "f", nil); err == nil {
+		t.Fatal("expected error for no rows")
+	} else if _, ok := errors.Cause(err).(pilosa.BadRequestError); !ok {
+		t.Fatalf("expected bad request error, got %v", err)
+	}
+	if _, err := m0.API.IntersectRows(ctx, "i", "x", []uint64{1}); err == nil {
+		t.Fatal("expected error")
+	} else if _, ok := errors.Cause(err).(pilosa.NotFoundError); !ok {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
+
+func TestAPI_ReloadIndex(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	m0.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	m0.MustCreateField(t, "i", "f")
+	m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `Set(1, f=1) Set(2, f=1)`})
+
+	// Restore a copy of field f as field g while the index is open.
+	path := m0.Server.Holder().Index("i").Path()
+	for _, name := range []string{".meta", "views/standard/fragments/0"} {
+		buf, err := ioutil.ReadFile(filepath.Join(path, "f", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		dst := filepath.Join(path, "g", name)
+		if err := os.MkdirAll(filepath.Dir(dst), 0777); err != nil {
+			t.Fatal(err)
+		} else if err := ioutil.WriteFile(dst, buf, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := m0.API.ReloadIndex(ctx, "i"); err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"f", "g"} {
+		resp := m0.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf("Count(Row(%s=1))", field)})
+		if n := resp.Results[0].(uint64); n != 2 {
+			t.Fatalf("unexpected count for field %s: %d", field, n)
+		}
+	}
+
+	if err := m0.API.ReloadIndex(ctx, "x"); err == nil {
+		t.Fatal("expected error")
+	} else if _, ok := errors.Cause(err).(pilosa.NotFoundError); !ok {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
+
+func TestAPI_ImportKeys(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m0 := c[0]
+	ctx := context.Background()
+
+	m0.MustCreateIndex(t, "k", pilosa.IndexOptions{Keys: true})
+	m0.MustCreateField(t, "k", "f", pilosa.OptFieldKeys())
+	m0.MustCreateField(t, "k", "g")
+
+	if err := m0.API.Import(ctx, &pilosa.ImportRequest{
+		Index:      "k",
+		Field:      "f",
+		RowKeys:    []string{"a", "a", "b"},
+		ColumnKeys: []string{"x", "y", "x"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	resp := m0.MustQuery(t, &pilosa.QueryRequest{Index: "k", Query: `Row(f="a")`})
+	if keys := resp.Results[0].(*pilosa.Row).Keys; !reflect.DeepEqual(keys, []string{"x", "y"}) {
+		t.Fatalf("unexpected keys: %v", keys)
+	}
+
+	// IDs can't be used where keys are, nor keys where they aren't, and each
+	// row needs a column.
+	for _, req := range []*pilosa.ImportRequest{
+		{Index: "k", Field: "f", RowIDs: []uint64{1}, ColumnKeys: []string{"x"}},
+		{Index: "k", Field: "f", RowKeys: []string{"a"}, ColumnIDs: []uint64{1}},
+		{Index: "k", Field: "g", RowKeys: []string{"a"}, ColumnKeys: []string{"x"}},
+		{Index: "k", Field: "f", RowKeys: []string{"a", "b"}, ColumnKeys: []string{"x"}},
+	} {
+		if err := m0.API.Import(ctx, req); err == nil {
+			t.Fatalf("expected error for %+v", req)
+		} else if _, ok := errors.Cause(err).(pilosa.BadRequestError); !ok {
+			t.Fatalf("expected bad request error, got %v", err)
+		}
+	}
+}
+
+// brokenAttrStore is an attribute store which fails all reads.
+type brokenAttrStore struct {
+	pilosa.AttrStore
+}
+
+func (s *brokenAttrStore) Attrs(id uint64) (map[string]interface{}, error) {
+	return nil, errors.New("attr store unavailable")
+}
+
+// captureLogger is a logger which records formatted messages.
+type captureLogger struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+func (l *captureLogger) Printf(format string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.msgs = append(l.msgs, fmt.Sprintf(format, v...))
+}
+
+func (l *captureLogger) Debugf(format string, v ...interface{}) {}
+
+// contains returns true if any recorded message contains s.
+func (l *captureLogger) contains(s string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, msg := range l.msgs {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
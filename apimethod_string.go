@@ -4,9 +4,9 @@ package pilosa
 
 import "strconv"
 
-const _apiMethod_name = "apiClusterMessageapiCreateFieldapiCreateIndexapiDeleteFieldapiDeleteAvailableShardapiDeleteIndexapiDeleteViewapiExportCSVapiFragmentBlockDataapiFragmentBlocksapiFieldapiFieldAttrDiffapiImportapiImportValueapiIndexapiIndexAttrDiffapiQueryapiRecalculateCachesapiRemoveNodeapiResizeAbortapiSetCoordinatorapiShardNodesapiViews"
+const _apiMethod_name = "apiAbortCreateIndexapiAccountUsageapiAggregateAcrossIndexesapiBackupIndexapiBackupNodeapiCacheStalenessapiCancelImportapiCanRemoveNodeapiChangedShardsapiClusterMessageapiColumnAttrsFilteredapiCommitCreateIndexapiCompactTranslateStoreapiCoordinatorapiCreateFieldapiCreateIndexapiDeleteFieldapiDeleteAvailableShardapiDeleteIndexapiDeleteViewapiDeleteViewsapiDeleteViewsMatchingapiEnableIndexKeysapiEstimateRowCountapiExplainAnalyzeapiExportAttrSchemaapiExportCSVapiExportFieldMetaapiExportNDJSONapiExportParquetapiForceCoordinatorapiForEachBitapiFragmentBlockDataapiFragmentBlocksapiFragmentContainerStatsapiFragmentDataapiFragmentModTimeapiFieldapiFieldAttrDiffapiFieldHistogramapiFieldsEqualapiGetBitsapiImportapiImportAttrSchemaapiImportValueapiImportBatchapiImportFieldMetaapiImportHistoryapiImportStreamapiIntersectRowsapiIndexapiIndexAttrDiffapiIndexBitCountapiIndexMerkleRootapiManifestapiMergeIndexesapiLogLevelapiNodeConfigapiOpenFileCountapiOwnershipMapapiPauseQueriesapiPingClusterapiPrepareCreateIndexapiPruneTimeViewsapiPurgeTrashapiQueryapiQueryFieldRefsapiQueryShardCountapiRebuildSchemaFromDiskapiReloadIndexapiRecalculateCachesapiRecomputeMaxShardapiRegisterImportTransformapiRemapRowsapiRestoreFieldapiRestoreIndexapiRestoreNodeapiRemoveNodeapiReserveColumnIDsapiResetAccountUsageapiResizeAbortapiResumeQueriesapiSetCoordinatorapiSetDefaultFieldOptionsapiSetFieldACLapiSetIndexQueryRateLimitapiSetLogLevelapiSetSnapshotCompressionLevelapiSetStatsSampleRateapiShardNodesapiSnapshotInfoapiStepDownCoordinatorapiSubscribeapiTopColumnsapiTranslateRowIDsapiTranslateRowKeysapiUnderReplicatedShardsapiValueHistogramapiVerifySchemaConsistencyapiViewsapiWatchSchema"
 
-var _apiMethod_index = [...]uint16{0, 17, 31, 45, 59, 82, 96, 109, 121, 141, 158, 166, 182, 191, 205, 213, 229, 237, 257, 270, 284, 301, 314, 322}
+var _apiMethod_index = [...]uint16{0, 19, 34, 59, 73, 86, 103, 118, 134, 150, 167, 189, 209, 233, 247, 261, 275, 289, 312, 326, 339, 353, 375, 393, 412, 429, 448, 460, 478, 493, 509, 528, 541, 561, 578, 603, 618, 636, 644, 660, 677, 691, 701, 710, 729, 743, 757, 775, 791, 806, 822, 830, 846, 862, 880, 891, 906, 917, 930, 946, 961, 976, 990, 1011, 1028, 1041, 1049, 1066, 1084, 1108, 1122, 1142, 1162, 1188, 1200, 1215, 1230, 1244, 1257, 1276, 1296, 1310, 1326, 1343, 1368, 1382, 1407, 1421, 1451, 1472, 1485, 1500, 1522, 1534, 1547, 1565, 1584, 1608, 1625, 1651, 1659, 1673}
 
 func (i apiMethod) String() string {
 	if i < 0 || i >= apiMethod(len(_apiMethod_index)-1) {
@@ -17,9 +17,11 @@ package pilosa
 import (
 	"bytes"
 	"sort"
+	"sync"
 
 	"github.com/gogo/protobuf/proto"
 	"github.com/pilosa/pilosa/internal"
+	"github.com/pkg/errors"
 )
 
 // Attribute data type enum.
@@ -30,6 +32,95 @@ const (
 	attrTypeFloat  = 4
 )
 
+// Attribute type names used in an AttrSchema.
+const (
+	AttrSchemaTypeString = "string"
+	AttrSchemaTypeInt    = "int"
+	AttrSchemaTypeBool   = "bool"
+	AttrSchemaTypeFloat  = "float"
+)
+
+// attrSchemaTypes maps attribute data types to their schema type names.
+var attrSchemaTypes = map[uint64]string{
+	attrTypeString: AttrSchemaTypeString,
+	attrTypeInt:    AttrSchemaTypeInt,
+	attrTypeBool:   AttrSchemaTypeBool,
+	attrTypeFloat:  AttrSchemaTypeFloat,
+}
+
+// AttrSchema maps attribute keys to the type name of their values.
+type AttrSchema map[string]string
+
+// attrSchemaType returns the schema type name of an attribute value. Returns
+// an empty string if the value's type cannot be stored as an attribute.
+func attrSchemaType(v interface{}) string {
+	return attrSchemaTypes[encodeAttr("", v).Type]
+}
+
+// isValidAttrSchemaType returns true if v is a valid attribute type name.
+func isValidAttrSchemaType(v string) bool {
+	switch v {
+	case AttrSchemaTypeString, AttrSchemaTypeInt, AttrSchemaTypeBool, AttrSchemaTypeFloat:
+		return true
+	default:
+		return false
+	}
+}
+
+// validate returns an error if any value in m has a different type than
+// the one registered for its key. Keys not in the schema and nil values,
+// which delete the attribute, are always allowed.
+func (s AttrSchema) validate(m map[string]interface{}) error {
+	for k, v := range m {
+		if v == nil {
+			continue
+		}
+		typ, ok := s[k]
+		if !ok {
+			continue
+		}
+		if vtyp := attrSchemaType(v); vtyp != typ {
+			return errors.Wrapf(ErrAttrSchemaConflict, "attr %q is %s, not %s", k, vtyp, typ)
+		}
+	}
+	return nil
+}
+
+// inferAttrSchema returns the key types of all attributes in store. When
+// a key has values of different types, the type of the value on the lowest
+// id is used.
+func inferAttrSchema(store AttrStore) (AttrSchema, error) {
+	blks, err := store.Blocks()
+	if err != nil {
+		return nil, errors.Wrap(err, "getting blocks")
+	}
+
+	schema := make(AttrSchema)
+	for _, blk := range blks {
+		m, err := store.BlockData(blk.ID)
+		if err != nil {
+			return nil, errors.Wrap(err, "getting block data")
+		}
+
+		ids := make([]uint64, 0, len(m))
+		for id := range m {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+		for _, id := range ids {
+			for k, v := range m[id] {
+				if _, ok := schema[k]; ok {
+					continue
+				} else if typ := attrSchemaType(v); typ != "" {
+					schema[k] = typ
+				}
+			}
+		}
+	}
+	return schema, nil
+}
+
 // AttrStore represents an interface for handling row/column attributes.
 type AttrStore interface {
 	Path() string
@@ -49,6 +140,38 @@ var nopStore AttrStore = nopAttrStore{}
 // object to avoid unnecessary allocations.
 func newNopAttrStore(string) AttrStore { return nopStore }
 
+// attrStores holds the AttrStore constructors which an index can select by
+// name in its options.
+var attrStores = struct {
+	mu sync.RWMutex
+	m  map[string]func(string) AttrStore
+}{m: map[string]func(string) AttrStore{"nop": newNopAttrStore}}
+
+// RegisterAttrStore makes an AttrStore constructor available under name, so
+// that indexes can select it with the AttrStore option. It panics if name is
+// already registered or fn is nil.
+func RegisterAttrStore(name string, fn func(string) AttrStore) {
+	attrStores.mu.Lock()
+	defer attrStores.mu.Unlock()
+	if fn == nil {
+		panic("pilosa: RegisterAttrStore constructor is nil")
+	} else if _, ok := attrStores.m[name]; ok {
+		panic("pilosa: RegisterAttrStore called twice for attr store " + name)
+	}
+	attrStores.m[name] = fn
+}
+
+// attrStoreByName returns the AttrStore constructor registered under name.
+func attrStoreByName(name string) (func(string) AttrStore, error) {
+	attrStores.mu.RLock()
+	defer attrStores.mu.RUnlock()
+	fn, ok := attrStores.m[name]
+	if !ok {
+		return nil, errors.Errorf("unknown attr store: %s", name)
+	}
+	return fn, nil
+}
+
 // nopAttrStore represents a no-op implementation of the AttrStore interface.
 type nopAttrStore struct{}
 
@@ -76,6 +199,21 @@ func (s nopAttrStore) Blocks() ([]AttrBlock, error) { return nil, nil }
 // BlockData is a no-op implementation of AttrStore BlockData method.
 func (s nopAttrStore) BlockData(i uint64) (map[uint64]map[string]interface{}, error) { return nil, nil }
 
+// openFileCounter is implemented by attribute stores which report the number
+// of files they have open.
+type openFileCounter interface {
+	OpenFileCount() int
+}
+
+// attrStoreOpenFileCount returns the number of files store has open, or zero
+// if the store does not report it.
+func attrStoreOpenFileCount(store AttrStore) int {
+	if c, ok := store.(openFileCounter); ok {
+		return c.OpenFileCount()
+	}
+	return 0
+}
+
 // AttrBlock represents a checksummed block of the attribute store.
 type AttrBlock struct {
 	ID       uint64 `json:"id"`
@@ -181,6 +319,38 @@ func decodeAttr(attr *internal.Attr) (key string, value interface{}) {
 	}
 }
 
+// encodeAttrSchema converts s into its internal representation. Only the key
+// and type of each attribute are set.
+func encodeAttrSchema(s AttrSchema) []*internal.Attr {
+	keys := make([]string, 0, len(s))
+	for k := range s {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	a := make([]*internal.Attr, 0, len(keys))
+	for _, k := range keys {
+		for typ, name := range attrSchemaTypes {
+			if name == s[k] {
+				a = append(a, &internal.Attr{Key: k, Type: typ})
+				break
+			}
+		}
+	}
+	return a
+}
+
+// decodeAttrSchema converts an internal representation into an AttrSchema.
+func decodeAttrSchema(pb []*internal.Attr) AttrSchema {
+	s := make(AttrSchema, len(pb))
+	for _, attr := range pb {
+		if typ, ok := attrSchemaTypes[attr.Type]; ok {
+			s[attr.Key] = typ
+		}
+	}
+	return s
+}
+
 // cloneAttrs returns a shallow clone of m.
 func cloneAttrs(m map[string]interface{}) map[string]interface{} {
 	other := make(map[string]interface{}, len(m))
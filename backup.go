@@ -0,0 +1,626 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilosa
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Names of the files in a holder backup archive. Attribute stores and
+// fragments are stored under their index, field and view names:
+//
+//	schema
+//	<index>/attrs
+//	<index>/<field>/attrs
+//	<index>/<field>/<view>/<shard>/data
+//	<index>/<field>/<view>/<shard>/cache
+//	translate
+const (
+	backupSchemaName    = "schema"
+	backupAttrsName     = "attrs"
+	backupTranslateName = "translate"
+)
+
+// backup writes a tar archive of the holder's schema, attributes, fragments
+// and key translation data to w. Each fragment is copied consistently but
+// writes which happen during the backup may be included for some fragments
+// and not others.
+func (h *Holder) backup(ctx context.Context, w io.Writer) error {
+	tw := tar.NewWriter(w)
+
+	// Write schema, including index options which Schema() omits.
+	schema := h.Schema()
+	for _, ii := range schema {
+		if index := h.Index(ii.Name); index != nil {
+			ii.Options = index.Options()
+		}
+	}
+	buf, err := json.Marshal(schema)
+	if err != nil {
+		return errors.Wrap(err, "marshaling schema")
+	} else if err := writeBackupFile(tw, backupSchemaName, buf); err != nil {
+		return err
+	}
+
+	for _, index := range h.Indexes() {
+		if err := writeBackupIndex(ctx, tw, index); err != nil {
+			return err
+		}
+	}
+
+	if err := h.backupTranslateFile(ctx, tw); err != nil {
+		return errors.Wrap(err, "backing up translate data")
+	}
+
+	return errors.Wrap(tw.Close(), "closing archive")
+}
+
+// writeBackupIndex writes the attributes and fragments of index to tw, named
+// under the index name.
+func writeBackupIndex(ctx context.Context, tw *tar.Writer, index *Index) error {
+	if err := writeBackupAttrs(tw, path.Join(index.Name(), backupAttrsName), index.ColumnAttrStore()); err != nil {
+		return errors.Wrapf(err, "backing up column attrs: index=%s", index.Name())
+	}
+
+	for _, field := range index.Fields() {
+		if err := writeBackupAttrs(tw, path.Join(index.Name(), field.Name(), backupAttrsName), field.RowAttrStore()); err != nil {
+			return errors.Wrapf(err, "backing up row attrs: index=%s, field=%s", index.Name(), field.Name())
+		}
+
+		for _, view := range field.views() {
+			for _, frag := range view.allFragments() {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+
+				name := path.Join(index.Name(), field.Name(), view.name, strconv.FormatUint(frag.shard, 10))
+				if err := frag.FlushCache(); err != nil {
+					return errors.Wrapf(err, "flushing cache: %s", name)
+				} else if err := frag.writeStorageToArchive(tw, path.Join(name, "data")); err != nil {
+					return errors.Wrapf(err, "backing up fragment: %s", name)
+				} else if err := frag.writeCacheToArchive(tw, path.Join(name, "cache")); err != nil {
+					return errors.Wrapf(err, "backing up cache: %s", name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// backupTranslateFile writes the translate log, up to its current size, to tw.
+func (h *Holder) backupTranslateFile(ctx context.Context, tw *tar.Writer) error {
+	sz := h.translateFile.size()
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    backupTranslateName,
+		Mode:    0600,
+		Size:    sz,
+		ModTime: time.Now(),
+	}); err != nil {
+		return errors.Wrap(err, "writing header")
+	} else if sz == 0 {
+		return nil
+	}
+
+	rc, err := h.translateFile.Reader(ctx, 0)
+	if err != nil {
+		return errors.Wrap(err, "opening reader")
+	}
+	defer rc.Close()
+
+	_, err = io.CopyN(tw, rc, sz)
+	return errors.Wrap(err, "copying")
+}
+
+// restore reads an archive written by backup and loads it into the holder.
+// Indexes, fields and views are created if they do not exist, and fragments
+// in the archive replace existing ones. Translate data is skipped if this
+// node's translate store is replicated from another node.
+func (h *Holder) restore(ctx context.Context, r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return errors.Wrap(err, "reading archive")
+		} else if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := h.restoreFile(hdr.Name, tr); err != nil {
+			return errors.Wrapf(err, "restoring %s", hdr.Name)
+		}
+	}
+}
+
+// restoreFile restores a single file from a backup archive.
+func (h *Holder) restoreFile(name string, r io.Reader) error {
+	parts := strings.Split(name, "/")
+	switch len(parts) {
+	case 1:
+		switch name {
+		case backupSchemaName:
+			var schema []*IndexInfo
+			if err := json.NewDecoder(r).Decode(&schema); err != nil {
+				return errors.Wrap(err, "decoding schema")
+			}
+			return h.applySchema(&Schema{Indexes: schema})
+		case backupTranslateName:
+			if h.translateFile.isReadOnly() {
+				return nil
+			}
+			return h.translateFile.restore(r)
+		}
+
+	case 2:
+		if index := h.Index(parts[0]); index != nil && parts[1] == backupAttrsName {
+			return readBackupAttrs(r, index.ColumnAttrStore())
+		}
+		return ErrIndexNotFound
+
+	case 3:
+		if field := h.Field(parts[0], parts[1]); field != nil && parts[2] == backupAttrsName {
+			return readBackupAttrs(r, field.RowAttrStore())
+		}
+		return ErrFieldNotFound
+
+	case 5:
+		field := h.Field(parts[0], parts[1])
+		if field == nil {
+			return ErrFieldNotFound
+		}
+		shard, err := strconv.ParseUint(parts[3], 10, 64)
+		if err != nil {
+			return errors.Wrap(err, "parsing shard")
+		}
+		if kind := parts[4]; kind == "data" || kind == "cache" {
+			return restoreBackupFragment(field, parts[2], shard, kind, r)
+		}
+	}
+	return fmt.Errorf("invalid backup archive file: %s", name)
+}
+
+// restoreBackupFragment reads the "data" or "cache" file of a fragment from a
+// backup archive, creating the view and fragment if they do not exist.
+func restoreBackupFragment(field *Field, viewName string, shard uint64, kind string, r io.Reader) error {
+	view, err := field.createViewIfNotExists(viewName)
+	if err != nil {
+		return errors.Wrap(err, "creating view")
+	}
+	frag, err := view.CreateFragmentIfNotExists(shard)
+	if err != nil {
+		return errors.Wrap(err, "creating fragment")
+	}
+
+	frag.mu.Lock()
+	defer frag.mu.Unlock()
+	if kind == "data" {
+		return frag.readStorageFromArchive(r)
+	}
+	return frag.readCacheFromArchive(r)
+}
+
+// Types of the records in an index backup stream.
+const (
+	backupRecordSchema uint8 = iota + 1
+	backupRecordColumnAttrs
+	backupRecordColumnKeys
+	backupRecordRowAttrs
+	backupRecordRowKeys
+	backupRecordFragment
+)
+
+// backupRecord is a record of an index backup stream. A fragment record holds
+// the field, view and shard of a marshaled fragment. Row attribute and key
+// records name their field, and the other records hold data of the whole
+// index.
+//
+// Each record is written as its type byte, the uvarint length and bytes of
+// the field and view names, the shard as a uvarint, and the uvarint length
+// and bytes of its data.
+type backupRecord struct {
+	typ   uint8
+	field string
+	view  string
+	shard uint64
+	data  []byte
+}
+
+// String returns a description of the record for errors.
+func (rec *backupRecord) String() string {
+	switch rec.typ {
+	case backupRecordSchema:
+		return "schema"
+	case backupRecordColumnAttrs:
+		return "column attrs"
+	case backupRecordColumnKeys:
+		return "column keys"
+	case backupRecordRowAttrs:
+		return fmt.Sprintf("row attrs: field=%s", rec.field)
+	case backupRecordRowKeys:
+		return fmt.Sprintf("row keys: field=%s", rec.field)
+	case backupRecordFragment:
+		return fmt.Sprintf("fragment: field=%s, view=%s, shard=%d", rec.field, rec.view, rec.shard)
+	}
+	return fmt.Sprintf("record type %d", rec.typ)
+}
+
+// WriteTo writes the record to w.
+func (rec *backupRecord) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	var tmp [binary.MaxVarintLen64]byte
+	buf.WriteByte(rec.typ)
+	buf.Write(tmp[:binary.PutUvarint(tmp[:], uint64(len(rec.field)))])
+	buf.WriteString(rec.field)
+	buf.Write(tmp[:binary.PutUvarint(tmp[:], uint64(len(rec.view)))])
+	buf.WriteString(rec.view)
+	buf.Write(tmp[:binary.PutUvarint(tmp[:], rec.shard)])
+	buf.Write(tmp[:binary.PutUvarint(tmp[:], uint64(len(rec.data)))])
+
+	n, err := w.Write(buf.Bytes())
+	if err != nil {
+		return int64(n), err
+	}
+	m, err := w.Write(rec.data)
+	return int64(n + m), err
+}
+
+// readBackupRecord reads the next record of an index backup stream. Returns
+// io.EOF if the stream ends before the record.
+func readBackupRecord(r *bufio.Reader) (*backupRecord, error) {
+	typ, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	rec := &backupRecord{typ: typ}
+	if rec.field, err = readBackupString(r); err != nil {
+		return nil, errors.Wrap(err, "reading field")
+	} else if rec.view, err = readBackupString(r); err != nil {
+		return nil, errors.Wrap(err, "reading view")
+	} else if rec.shard, err = binary.ReadUvarint(r); err != nil {
+		return nil, errors.Wrap(eofUnexpected(err), "reading shard")
+	} else if rec.data, err = readBackupBytes(r); err != nil {
+		return nil, errors.Wrap(err, "reading data")
+	}
+	return rec, nil
+}
+
+func readBackupString(r *bufio.Reader) (string, error) {
+	buf, err := readBackupBytes(r)
+	return string(buf), err
+}
+
+// readBackupBytes reads a uvarint length and that many bytes from r.
+func readBackupBytes(r *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, eofUnexpected(err)
+	}
+	buf, err := ioutil.ReadAll(io.LimitReader(r, int64(n)))
+	if err != nil {
+		return nil, err
+	} else if uint64(len(buf)) != n {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return buf, nil
+}
+
+// eofUnexpected returns io.ErrUnexpectedEOF if err is io.EOF, as a stream
+// which ends within a record is truncated.
+func eofUnexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// backupIndex writes an index backup stream to w: the index schema, column
+// attributes and keys, and the row attributes, row keys and fragments of each
+// field. Fragments of shards for which own returns false are skipped. Returns
+// the number of bytes written. Like backup, writes which happen during the
+// backup may be included for some fragments and not others.
+func (h *Holder) backupIndex(ctx context.Context, name string, w io.Writer, own func(shard uint64) bool) (int64, error) {
+	index := h.Index(name)
+	if index == nil {
+		return 0, newNotFoundError(ErrIndexNotFound)
+	}
+	cw := &countingWriter{w: w}
+	write := func(rec *backupRecord) error {
+		_, err := rec.WriteTo(cw)
+		return errors.Wrapf(err, "writing %s", rec)
+	}
+
+	var info *IndexInfo
+	for _, ii := range h.Schema() {
+		if ii.Name == name {
+			info = ii
+		}
+	}
+	if info == nil {
+		info = &IndexInfo{Name: name}
+	}
+	info.Options = index.Options()
+	buf, err := json.Marshal(info)
+	if err != nil {
+		return cw.n, errors.Wrap(err, "marshaling schema")
+	} else if err := write(&backupRecord{typ: backupRecordSchema, data: buf}); err != nil {
+		return cw.n, err
+	}
+
+	if buf, err := encodeBackupAttrs(index.ColumnAttrStore()); err != nil {
+		return cw.n, errors.Wrapf(err, "backing up column attrs: index=%s", name)
+	} else if err := write(&backupRecord{typ: backupRecordColumnAttrs, data: buf}); err != nil {
+		return cw.n, err
+	}
+	if buf, err := encodeLogEntries(h.translateFile.columnKeyEntries(name)); err != nil {
+		return cw.n, errors.Wrapf(err, "backing up column keys: index=%s", name)
+	} else if err := write(&backupRecord{typ: backupRecordColumnKeys, data: buf}); err != nil {
+		return cw.n, err
+	}
+
+	for _, field := range index.Fields() {
+		if buf, err := encodeBackupAttrs(field.RowAttrStore()); err != nil {
+			return cw.n, errors.Wrapf(err, "backing up row attrs: index=%s, field=%s", name, field.Name())
+		} else if err := write(&backupRecord{typ: backupRecordRowAttrs, field: field.Name(), data: buf}); err != nil {
+			return cw.n, err
+		}
+		if buf, err := encodeLogEntries(h.translateFile.rowKeyEntries(name, field.Name())); err != nil {
+			return cw.n, errors.Wrapf(err, "backing up row keys: index=%s, field=%s", name, field.Name())
+		} else if err := write(&backupRecord{typ: backupRecordRowKeys, field: field.Name(), data: buf}); err != nil {
+			return cw.n, err
+		}
+
+		for _, view := range field.views() {
+			for _, frag := range view.allFragments() {
+				if err := ctx.Err(); err != nil {
+					return cw.n, err
+				} else if !own(frag.shard) {
+					continue
+				}
+
+				rec := &backupRecord{typ: backupRecordFragment, field: field.Name(), view: view.name, shard: frag.shard}
+				var buf bytes.Buffer
+				if _, err := frag.WriteTo(&buf); err != nil {
+					return cw.n, errors.Wrapf(err, "backing up %s", rec)
+				}
+				rec.data = buf.Bytes()
+				if err := write(rec); err != nil {
+					return cw.n, err
+				}
+			}
+		}
+	}
+	return cw.n, nil
+}
+
+// restoreIndex reads a stream written by backupIndex and loads it into the
+// named index, which need not be the index the stream was written from. The
+// index, its fields and views are created if they do not exist, and fragments
+// in the stream replace existing ones. Fragments of shards for which own
+// returns false are skipped. Keys are skipped if this node's translate store
+// is replicated from another node.
+func (h *Holder) restoreIndex(ctx context.Context, name string, r io.Reader, own func(shard uint64) bool) error {
+	br := bufio.NewReader(r)
+	for {
+		rec, err := readBackupRecord(br)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return errors.Wrap(err, "reading backup record")
+		} else if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := h.restoreIndexRecord(name, rec, own); err != nil {
+			return errors.Wrapf(err, "restoring %s", rec)
+		}
+	}
+}
+
+// restoreIndexRecord restores a single record of an index backup stream.
+func (h *Holder) restoreIndexRecord(indexName string, rec *backupRecord, own func(shard uint64) bool) error {
+	if rec.typ == backupRecordSchema {
+		var info IndexInfo
+		if err := json.Unmarshal(rec.data, &info); err != nil {
+			return errors.Wrap(err, "decoding schema")
+		}
+		info.Name = indexName
+		return h.applySchema(&Schema{Indexes: []*IndexInfo{&info}})
+	}
+
+	index := h.Index(indexName)
+	if index == nil {
+		return ErrIndexNotFound
+	}
+	switch rec.typ {
+	case backupRecordColumnAttrs:
+		return readBackupAttrs(bytes.NewReader(rec.data), index.ColumnAttrStore())
+	case backupRecordColumnKeys:
+		return h.restoreKeys(indexName, rec.data)
+	}
+
+	field := index.Field(rec.field)
+	if field == nil {
+		return ErrFieldNotFound
+	}
+	switch rec.typ {
+	case backupRecordRowAttrs:
+		return readBackupAttrs(bytes.NewReader(rec.data), field.RowAttrStore())
+	case backupRecordRowKeys:
+		return h.restoreKeys(indexName, rec.data)
+	case backupRecordFragment:
+		if !own(rec.shard) {
+			return nil
+		}
+		view, err := field.createViewIfNotExists(rec.view)
+		if err != nil {
+			return errors.Wrap(err, "creating view")
+		}
+		frag, err := view.CreateFragmentIfNotExists(rec.shard)
+		if err != nil {
+			return errors.Wrap(err, "creating fragment")
+		}
+		_, err = frag.ReadFrom(bytes.NewReader(rec.data))
+		return err
+	}
+	return fmt.Errorf("invalid backup record type: %d", rec.typ)
+}
+
+// restoreKeys restores translate log entries written by encodeLogEntries into
+// the named index, keeping their ids. Skipped if the translate store is
+// replicated from another node, which restores them itself.
+func (h *Holder) restoreKeys(indexName string, data []byte) error {
+	if h.translateFile.isReadOnly() {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	br := bufio.NewReader(bytes.NewReader(data))
+	for {
+		var entry LogEntry
+		if _, err := entry.ReadFrom(br); err == io.EOF {
+			break
+		} else if err != nil {
+			return errors.Wrap(err, "reading entry")
+		}
+		entry.Index = []byte(indexName)
+		if _, err := entry.WriteTo(&buf); err != nil {
+			return errors.Wrap(err, "encoding entry")
+		}
+	}
+	return h.translateFile.restore(&buf)
+}
+
+// encodeLogEntries returns the encoding of a list of translate log entries.
+func encodeLogEntries(entries []*LogEntry) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		if _, err := entry.WriteTo(&buf); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// writeBackupFile writes buf to tw as the named file.
+func writeBackupFile(tw *tar.Writer, name string, buf []byte) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    int64(len(buf)),
+		ModTime: time.Now(),
+	}); err != nil {
+		return errors.Wrap(err, "writing header")
+	} else if _, err := tw.Write(buf); err != nil {
+		return errors.Wrap(err, "writing")
+	}
+	return nil
+}
+
+// writeBackupAttrs writes the contents of store to tw as the named file.
+func writeBackupAttrs(tw *tar.Writer, name string, store AttrStore) error {
+	buf, err := encodeBackupAttrs(store)
+	if err != nil {
+		return err
+	}
+	return writeBackupFile(tw, name, buf)
+}
+
+// encodeBackupAttrs returns the contents of store. Each id is written as a
+// uvarint, followed by the uvarint length and protobuf encoding of its
+// attributes.
+func encodeBackupAttrs(store AttrStore) ([]byte, error) {
+	blocks, err := store.Blocks()
+	if err != nil {
+		return nil, errors.Wrap(err, "getting blocks")
+	}
+
+	var buf bytes.Buffer
+	var tmp [binary.MaxVarintLen64]byte
+	for _, block := range blocks {
+		m, err := store.BlockData(block.ID)
+		if err != nil {
+			return nil, errors.Wrap(err, "getting block data")
+		}
+		for id, attrs := range m {
+			data, err := EncodeAttrs(attrs)
+			if err != nil {
+				return nil, errors.Wrap(err, "encoding attrs")
+			}
+			buf.Write(tmp[:binary.PutUvarint(tmp[:], id)])
+			buf.Write(tmp[:binary.PutUvarint(tmp[:], uint64(len(data)))])
+			buf.Write(data)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// readBackupAttrs reads attributes written by writeBackupAttrs into store.
+func readBackupAttrs(r io.Reader, store AttrStore) error {
+	br := bufio.NewReader(r)
+	m := make(map[uint64]map[string]interface{})
+	for {
+		id, err := binary.ReadUvarint(br)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return errors.Wrap(err, "reading id")
+		}
+		n, err := binary.ReadUvarint(br)
+		if err != nil {
+			return errors.Wrap(err, "reading length")
+		}
+		data, err := ioutil.ReadAll(io.LimitReader(br, int64(n)))
+		if err != nil {
+			return errors.Wrap(err, "reading attrs")
+		} else if uint64(len(data)) != n {
+			return io.ErrUnexpectedEOF
+		}
+		if m[id], err = DecodeAttrs(data); err != nil {
+			return errors.Wrap(err, "decoding attrs")
+		}
+	}
+
+	if len(m) == 0 {
+		return nil
+	}
+	return store.SetBulkAttrs(m)
+}
@@ -68,6 +68,7 @@ type attrStore struct {
 	mu        sync.RWMutex
 	path      string
 	db        *bolt.DB
+	open      bool
 	attrCache *attrCache
 }
 
@@ -78,6 +79,10 @@ func newAttrCache() *attrCache {
 	}
 }
 
+func init() {
+	pilosa.RegisterAttrStore("bolt", NewAttrStore)
+}
+
 // NewAttrStore returns a new instance of AttrStore.
 func NewAttrStore(path string) pilosa.AttrStore {
 	return &attrStore{
@@ -96,7 +101,9 @@ func (s *attrStore) Open() error {
 	if err != nil {
 		return errors.Wrap(err, "opening storage")
 	}
-	s.db = db
+	s.mu.Lock()
+	s.db, s.open = db, true
+	s.mu.Unlock()
 
 	// Initialize database.
 	if err := s.db.Update(func(tx *bolt.Tx) error {
@@ -111,12 +118,25 @@ func (s *attrStore) Open() error {
 
 // Close closes the store.
 func (s *attrStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	if s.db != nil {
 		s.db.Close()
+		s.open = false
 	}
 	return nil
 }
 
+// OpenFileCount returns the number of files the store has open.
+func (s *attrStore) OpenFileCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if !s.open {
+		return 0
+	}
+	return 1
+}
+
 // Attrs returns a set of attributes by ID.
 func (s *attrStore) Attrs(id uint64) (m map[string]interface{}, err error) {
 	s.mu.RLock()
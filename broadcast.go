@@ -69,6 +69,17 @@ const (
 	messageTypeRecalculateCaches
 	messageTypeNodeEvent
 	messageTypeNodeStatus
+	messageTypeSetAttrSchema
+	messageTypePrepareCreateIndex
+	messageTypeCommitCreateIndex
+	messageTypeAbortCreateIndex
+	messageTypeSetFieldACL
+	messageTypeEnableIndexKeys
+	messageTypeDeleteViews
+	messageTypePing
+	messageTypeRemapRows
+	messageTypePauseQueries
+	messageTypeSetDefaultFieldOptions
 )
 
 // MarshalInternalMessage serializes the pilosa message and adds pilosa internal
@@ -116,6 +127,28 @@ func getMessage(typ byte) Message {
 		return &NodeEvent{}
 	case messageTypeNodeStatus:
 		return &NodeStatus{}
+	case messageTypeSetAttrSchema:
+		return &SetAttrSchemaMessage{}
+	case messageTypePrepareCreateIndex:
+		return &PrepareCreateIndexMessage{}
+	case messageTypeCommitCreateIndex:
+		return &CommitCreateIndexMessage{}
+	case messageTypeAbortCreateIndex:
+		return &AbortCreateIndexMessage{}
+	case messageTypeSetFieldACL:
+		return &SetFieldACLMessage{}
+	case messageTypeEnableIndexKeys:
+		return &EnableIndexKeysMessage{}
+	case messageTypeDeleteViews:
+		return &DeleteViewsMessage{}
+	case messageTypePing:
+		return &PingMessage{}
+	case messageTypeRemapRows:
+		return &RemapRowsMessage{}
+	case messageTypePauseQueries:
+		return &PauseQueriesMessage{}
+	case messageTypeSetDefaultFieldOptions:
+		return &SetDefaultFieldOptionsMessage{}
 	default:
 		panic(fmt.Sprintf("unknown message type %d", typ))
 	}
@@ -155,6 +188,28 @@ func getMessageType(m Message) byte {
 		return messageTypeNodeEvent
 	case *NodeStatus:
 		return messageTypeNodeStatus
+	case *SetAttrSchemaMessage:
+		return messageTypeSetAttrSchema
+	case *PrepareCreateIndexMessage:
+		return messageTypePrepareCreateIndex
+	case *CommitCreateIndexMessage:
+		return messageTypeCommitCreateIndex
+	case *AbortCreateIndexMessage:
+		return messageTypeAbortCreateIndex
+	case *SetFieldACLMessage:
+		return messageTypeSetFieldACL
+	case *EnableIndexKeysMessage:
+		return messageTypeEnableIndexKeys
+	case *DeleteViewsMessage:
+		return messageTypeDeleteViews
+	case *PingMessage:
+		return messageTypePing
+	case *RemapRowsMessage:
+		return messageTypeRemapRows
+	case *PauseQueriesMessage:
+		return messageTypePauseQueries
+	case *SetDefaultFieldOptionsMessage:
+		return messageTypeSetDefaultFieldOptions
 	default:
 		panic(fmt.Sprintf("don't have type for message %#v", m))
 	}
@@ -29,6 +29,10 @@ import (
 const (
 	// thresholdFactor is used to calculate the threshold for new items entering the cache
 	thresholdFactor = 1.1
+
+	// cacheEntrySize is the approximate number of heap bytes used by each
+	// entry in a ranked or LRU cache.
+	cacheEntrySize = 64
 )
 
 // cache represents a cache of counts.
@@ -151,6 +155,9 @@ type rankCache struct {
 	// thresholdValue is the value of the last item in the cache
 	thresholdValue uint64
 
+	// dirty is set when entries change after rankings were calculated.
+	dirty bool
+
 	stats stats.StatsClient
 }
 
@@ -176,6 +183,7 @@ func (c *rankCache) Add(id uint64, n uint64) {
 	}
 
 	c.entries[id] = n
+	c.dirty = true
 
 	c.invalidate()
 }
@@ -189,6 +197,7 @@ func (c *rankCache) BulkAdd(id uint64, n uint64) {
 	}
 
 	c.entries[id] = n
+	c.dirty = true
 }
 
 // Get returns a count for a given id.
@@ -242,6 +251,14 @@ func (c *rankCache) invalidate() {
 	c.recalculate()
 }
 
+// stale returns true if entries have changed since the rankings returned by
+// Top were calculated.
+func (c *rankCache) stale() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.dirty
+}
+
 func (c *rankCache) recalculate() {
 	// Convert cache to a sorted list.
 	rankings := make([]bitmapPair, 0, len(c.entries))
@@ -269,6 +286,7 @@ func (c *rankCache) recalculate() {
 
 	// Reset counters.
 	c.updateTime, c.updateN = time.Now(), 0
+	c.dirty = false
 
 	// If size is larger than the threshold then trim it.
 	if len(c.entries) > c.thresholdBuffer {
@@ -327,6 +345,13 @@ func (p Pairs) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
 func (p Pairs) Len() int           { return len(p) }
 func (p Pairs) Less(i, j int) bool { return p[i].Count > p[j].Count }
 
+// PairAttrs holds a pair and the attributes of its row. TopN calls return
+// a list of PairAttrs instead of pairs if QueryRequest.IncludeRowAttrs is set.
+type PairAttrs struct {
+	Pair
+	Attrs map[string]interface{} `json:"attrs"`
+}
+
 // pairHeap is a heap implementation over a group of Pairs.
 type pairHeap struct {
 	Pairs
@@ -480,3 +505,34 @@ func (c nopCache) SetStats(stats.StatsClient) {}
 func (c nopCache) Top() []bitmapPair {
 	return []bitmapPair{}
 }
+
+// evictedCache stands in for a fragment cache which has been flushed to disk
+// and released to free memory. The first call which needs the cache contents
+// reloads it from disk and replaces itself on the fragment. The fragment's
+// lock must be held when calling its methods.
+type evictedCache struct {
+	f *fragment
+}
+
+// load reopens the fragment's cache and returns it.
+func (c *evictedCache) load() cache {
+	if c.f.cache != c {
+		return c.f.cache
+	}
+	if err := c.f.openCache(); err != nil {
+		c.f.Logger.Printf("error reloading evicted cache, skipping: path=%s, err=%s", c.f.cachePath(), err)
+		c.f.cache = globalNopCache
+	}
+	return c.f.cache
+}
+
+func (c *evictedCache) Add(id, n uint64)     { c.load().Add(id, n) }
+func (c *evictedCache) BulkAdd(id, n uint64) { c.load().BulkAdd(id, n) }
+func (c *evictedCache) Get(id uint64) uint64 { return c.load().Get(id) }
+func (c *evictedCache) IDs() []uint64        { return c.load().IDs() }
+
+func (c *evictedCache) Invalidate()                  { c.load().Invalidate() }
+func (c *evictedCache) Len() int                     { return 0 }
+func (c *evictedCache) Recalculate()                 { c.load().Recalculate() }
+func (c *evictedCache) SetStats(s stats.StatsClient) { c.load().SetStats(s) }
+func (c *evictedCache) Top() []bitmapPair            { return c.load().Top() }
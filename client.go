@@ -21,6 +21,9 @@ type FieldValue struct {
 	ColumnID  uint64
 	ColumnKey string
 	Value     int64
+
+	// Null clears the column's value instead of setting Value.
+	Null bool
 }
 
 // InternalClient should be implemented by any struct that enables any transport between nodes
@@ -32,6 +35,7 @@ type FieldValue struct {
 type InternalClient interface {
 	MaxShardByIndex(ctx context.Context) (map[string]uint64, error)
 	Schema(ctx context.Context) ([]*IndexInfo, error)
+	SchemaNode(ctx context.Context, uri *URI) ([]*IndexInfo, error)
 	CreateIndex(ctx context.Context, index string, opt IndexOptions) error
 	FragmentNodes(ctx context.Context, index string, shard uint64) ([]*Node, error)
 	Nodes(ctx context.Context) ([]*Node, error)
@@ -88,6 +92,9 @@ func (n nopInternalClient) MaxShardByIndex(context.Context) (map[string]uint64,
 	return nil, nil
 }
 func (n nopInternalClient) Schema(ctx context.Context) ([]*IndexInfo, error) { return nil, nil }
+func (n nopInternalClient) SchemaNode(ctx context.Context, uri *URI) ([]*IndexInfo, error) {
+	return nil, nil
+}
 func (n nopInternalClient) CreateIndex(ctx context.Context, index string, opt IndexOptions) error {
 	return nil
 }
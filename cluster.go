@@ -200,6 +200,18 @@ type cluster struct { // nolint: maligned
 	holder      *Holder
 	broadcaster broadcaster
 
+	// coordinatorEpoch is incremented each time a node becomes coordinator
+	// and is propagated to the other nodes with the coordinator change and
+	// with the cluster status. It is saved with the topology so that it
+	// survives restarts.
+	coordinatorEpoch uint64
+
+	// internalToken is sent with every request made by one node to
+	// another, so that the receiving node can tell them apart from client
+	// requests. Each node starts with its own and adopts the coordinator's
+	// with the cluster status. It is never sent to clients.
+	internalToken string
+
 	joiningLeavingNodes chan nodeAction
 
 	// joining is held open until this node
@@ -236,6 +248,8 @@ func newCluster() *cluster {
 
 		InternalClient: newNopInternalClient(),
 
+		internalToken: uuid.NewV4().String(),
+
 		logger: logger.NopLogger,
 	}
 }
@@ -303,12 +317,17 @@ func (c *cluster) setCoordinator(n *Node) error {
 	}
 
 	// Update IsCoordinator on all nodes (locally).
-	_ = c.unprotectedUpdateCoordinator(n)
+	epoch := c.coordinatorEpoch + 1
+	if _, err := c.unprotectedAdvanceCoordinator(n, epoch); err != nil {
+		c.mu.Unlock()
+		return errors.Wrap(err, "updating coordinator")
+	}
 	c.mu.Unlock()
 	// Send the update coordinator message to all nodes.
 	err := c.broadcaster.SendSync(
 		&UpdateCoordinatorMessage{
-			New: n,
+			New:   n,
+			Epoch: epoch,
 		})
 	if err != nil {
 		return fmt.Errorf("problem sending UpdateCoordinator message: %v", err)
@@ -318,16 +337,80 @@ func (c *cluster) setCoordinator(n *Node) error {
 	return c.broadcaster.SendSync(c.status())
 }
 
+// forceCoordinator makes n the coordinator without its participation, for
+// when it cannot be reached. The other nodes are notified directly, and
+// any which cannot be reached are logged and skipped.
+func (c *cluster) forceCoordinator(n *Node) error {
+	c.mu.Lock()
+	epoch := c.coordinatorEpoch + 1
+	if _, err := c.unprotectedAdvanceCoordinator(n, epoch); err != nil {
+		c.mu.Unlock()
+		return errors.Wrap(err, "updating coordinator")
+	}
+	nodes := append([]*Node(nil), c.nodes...)
+	c.mu.Unlock()
+
+	msg := &UpdateCoordinatorMessage{New: n, Epoch: epoch}
+	for _, node := range nodes {
+		if node.ID == c.Node.ID || node.ID == n.ID {
+			continue
+		}
+		if err := c.broadcaster.SendTo(node, msg); err != nil {
+			c.logger.Printf("notifying node %s of forced coordinator %s: %v", node.ID, n.ID, err)
+		}
+	}
+	return nil
+}
+
+// coordinatorCandidate returns the first node, in ID order, other than this
+// one which is ready to become coordinator, or nil if there is none.
+func (c *cluster) coordinatorCandidate() *Node {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, n := range c.nodes {
+		if n.ID == c.Node.ID {
+			continue
+		} else if c.Static || c.Topology.nodeStates[n.ID] == nodeStateReady {
+			return n
+		}
+	}
+	return nil
+}
+
 // updateCoordinator updates this nodes Coordinator value as well as
 // changing the corresponding node's IsCoordinator value
-// to true, and sets all other nodes to false. Returns true if the value
-// changed.
+// to true, and sets all other nodes to false. Returns true if the value changed.
 func (c *cluster) updateCoordinator(n *Node) bool { // nolint: unparam
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	return c.unprotectedUpdateCoordinator(n)
 }
 
+// advanceCoordinator makes n the coordinator if epoch is newer than the
+// current coordinator epoch, and saves the new epoch. Updates with an older
+// or equal epoch are stale and ignored. Returns true if the coordinator
+// changed.
+func (c *cluster) advanceCoordinator(n *Node, epoch uint64) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.unprotectedAdvanceCoordinator(n, epoch)
+}
+
+func (c *cluster) unprotectedAdvanceCoordinator(n *Node, epoch uint64) (bool, error) {
+	if epoch <= c.coordinatorEpoch {
+		c.logger.Printf("ignoring stale coordinator update: node=%s, epoch=%d, current=%d", n.ID, epoch, c.coordinatorEpoch)
+		return false, nil
+	}
+
+	prev := c.coordinatorEpoch
+	c.coordinatorEpoch = epoch
+	if err := c.saveTopology(); err != nil {
+		c.coordinatorEpoch = prev
+		return false, errors.Wrap(err, "saving topology")
+	}
+	return c.unprotectedUpdateCoordinator(n), nil
+}
+
 func (c *cluster) unprotectedUpdateCoordinator(n *Node) bool {
 	var changed bool
 	if c.Coordinator != n.ID {
@@ -541,12 +624,30 @@ func (c *cluster) status() *ClusterStatus {
 // unprotectedStatus returns the the cluster's status including what nodes it contains, its ID, and current state.
 func (c *cluster) unprotectedStatus() *ClusterStatus {
 	return &ClusterStatus{
-		ClusterID: c.id,
-		State:     c.state,
-		Nodes:     c.nodes,
+		ClusterID:        c.id,
+		State:            c.state,
+		Nodes:            c.nodes,
+		CoordinatorEpoch: c.coordinatorEpoch,
+		InternalToken:    c.internalToken,
 	}
 }
 
+// internalRequestToken returns the token which marks requests between the
+// nodes of the cluster.
+func (c *cluster) internalRequestToken() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.internalToken
+}
+
+// coordinatorWithEpoch returns the ID of the coordinator and the epoch in
+// which it became coordinator, read under the same lock.
+func (c *cluster) coordinatorWithEpoch() (string, uint64) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Coordinator, c.coordinatorEpoch
+}
+
 func (c *cluster) nodeByID(id string) *Node {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -606,6 +707,33 @@ func (c *cluster) addNodeBasicSorted(node *Node) bool {
 	return true
 }
 
+// ClusterView is a single node's view of the cluster membership.
+type ClusterView struct {
+	NodeID        string  `json:"nodeID"`
+	CoordinatorID string  `json:"coordinatorID"`
+	State         string  `json:"state"`
+	Nodes         []*Node `json:"nodes"`
+}
+
+// membershipView returns this node's view of the cluster membership. All
+// values are read under the same lock so that they are consistent with each
+// other.
+func (c *cluster) membershipView() ClusterView {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	nodes := make([]*Node, len(c.nodes))
+	for i, node := range c.nodes {
+		n := *node
+		nodes[i] = &n
+	}
+	return ClusterView{
+		NodeID:        c.Node.ID,
+		CoordinatorID: c.Coordinator,
+		State:         c.state,
+		Nodes:         nodes,
+	}
+}
+
 // Nodes returns a copy of the slice of nodes in the cluster. Safe for
 // concurrent use, result may be modified.
 func (c *cluster) Nodes() []*Node {
@@ -848,6 +976,65 @@ func (c *cluster) shardNodes(index string, shard uint64) []*Node {
 	return c.partitionNodes(c.partition(index, shard))
 }
 
+// ShardReplication describes the replicas of a shard which are currently
+// available compared to the number the cluster is configured to keep.
+type ShardReplication struct {
+	Shard     uint64   `json:"shard"`
+	ReplicaN  int      `json:"replicaN"`
+	LiveNodes []string `json:"liveNodes"`
+	DownNodes []string `json:"downNodes"`
+}
+
+// underReplicatedShards returns the shards of index, up to and including
+// maxShard, which have fewer live owners than the replica count. Ownership
+// is computed against the full topology so that nodes which have left the
+// cluster are still counted as owners.
+func (c *cluster) underReplicatedShards(index string, maxShard uint64) []ShardReplication {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	c.Topology.mu.RLock()
+	defer c.Topology.mu.RUnlock()
+
+	ids := c.Topology.nodeIDs
+	if c.Static || len(ids) == 0 {
+		ids = c.nodeIDs()
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	// A node is live if it is still a member and has not been marked down.
+	live := make(map[string]bool, len(c.nodes))
+	for _, n := range c.nodes {
+		live[n.ID] = c.Static || c.Topology.nodeStates[n.ID] != nodeStateDown
+	}
+
+	replicaN := c.ReplicaN
+	if replicaN > len(ids) {
+		replicaN = len(ids)
+	} else if replicaN == 0 {
+		replicaN = 1
+	}
+
+	var a []ShardReplication
+	for shard := uint64(0); shard <= maxShard; shard++ {
+		nodeIndex := c.Hasher.Hash(uint64(c.partition(index, shard)), len(ids))
+		sr := ShardReplication{Shard: shard, ReplicaN: replicaN}
+		for i := 0; i < replicaN; i++ {
+			id := ids[(nodeIndex+i)%len(ids)]
+			if live[id] {
+				sr.LiveNodes = append(sr.LiveNodes, id)
+			} else {
+				sr.DownNodes = append(sr.DownNodes, id)
+			}
+		}
+		if len(sr.LiveNodes) < replicaN {
+			a = append(a, sr)
+		}
+	}
+	return a
+}
+
 // ownsShard returns true if a host owns a fragment.
 func (c *cluster) ownsShard(nodeID string, index string, shard uint64) bool {
 	return Nodes(c.shardNodes(index, shard)).ContainsID(nodeID)
@@ -912,6 +1099,42 @@ func (h *jmphasher) Hash(key uint64, n int) int {
 	return int(b)
 }
 
+// DefaultHasher is the name of the Hasher which assigns partitions to nodes
+// unless another is selected.
+const DefaultHasher = "jump"
+
+// hashers holds the Hashers which can be selected by name.
+var hashers = struct {
+	mu sync.RWMutex
+	m  map[string]Hasher
+}{m: map[string]Hasher{DefaultHasher: &jmphasher{}}}
+
+// RegisterHasher makes a Hasher available under name, so that it can be
+// selected at startup with the cluster.hasher option. Every node in a
+// cluster must use the same Hasher. It panics if name is already
+// registered or h is nil.
+func RegisterHasher(name string, h Hasher) {
+	hashers.mu.Lock()
+	defer hashers.mu.Unlock()
+	if h == nil {
+		panic("pilosa: RegisterHasher hasher is nil")
+	} else if _, ok := hashers.m[name]; ok {
+		panic("pilosa: RegisterHasher called twice for hasher " + name)
+	}
+	hashers.m[name] = h
+}
+
+// HasherByName returns the Hasher registered under name.
+func HasherByName(name string) (Hasher, error) {
+	hashers.mu.RLock()
+	defer hashers.mu.RUnlock()
+	h, ok := hashers.m[name]
+	if !ok {
+		return nil, errors.Errorf("unknown hasher: %s", name)
+	}
+	return h, nil
+}
+
 func (c *cluster) setup() error {
 	// Cluster always comes up in state STARTING until cluster membership is determined.
 	c.state = ClusterStateStarting
@@ -1626,6 +1849,7 @@ func (c *cluster) loadTopology() error {
 		return errors.Wrap(err, "decoding")
 	}
 	c.Topology = top
+	c.coordinatorEpoch = pb.CoordinatorEpoch
 
 	return nil
 }
@@ -1637,7 +1861,12 @@ func (c *cluster) saveTopology() error {
 		return errors.Wrap(err, "creating directory")
 	}
 
-	if buf, err := proto.Marshal(encodeTopology(c.Topology)); err != nil {
+	pb := encodeTopology(c.Topology)
+	if pb == nil {
+		pb = &internal.Topology{}
+	}
+	pb.CoordinatorEpoch = c.coordinatorEpoch
+	if buf, err := proto.Marshal(pb); err != nil {
 		return errors.Wrap(err, "marshalling")
 	} else if err := ioutil.WriteFile(filepath.Join(c.Path, ".topology"), buf, 0666); err != nil {
 		return errors.Wrap(err, "writing file")
@@ -1835,6 +2064,38 @@ func (c *cluster) nodeLeave(nodeID string) error {
 	return nil
 }
 
+// removeNodeBlocker returns the reason that removing the node would fail or
+// leave shards under-replicated, or an empty string if there is none. The
+// node must be in the topology.
+func (c *cluster) removeNodeBlocker(nodeID string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	c.Topology.mu.RLock()
+	defer c.Topology.mu.RUnlock()
+
+	if nodeID == c.Coordinator {
+		return "coordinator cannot be removed; first, make a different node the new coordinator"
+	}
+
+	ids := c.Topology.nodeIDs
+	if c.Static || len(ids) == 0 {
+		ids = c.nodeIDs()
+	}
+	if len(ids) <= 1 {
+		return "cannot remove the only node in the cluster"
+	} else if remaining := len(ids) - 1; c.ReplicaN > remaining {
+		return fmt.Sprintf("%d nodes would remain for a replica count of %d", remaining, c.ReplicaN)
+	}
+
+	// Data is resized onto the remaining nodes, so they must all be up.
+	for _, id := range ids {
+		if id != nodeID && (c.unprotectedNodeByID(id) == nil || (!c.Static && c.Topology.nodeStates[id] == nodeStateDown)) {
+			return fmt.Sprintf("node %s is down", id)
+		}
+	}
+	return ""
+}
+
 func (c *cluster) nodeStatus() *NodeStatus {
 	ns := &NodeStatus{
 		Node:   c.Node,
@@ -1871,6 +2132,17 @@ func (c *cluster) mergeClusterStatus(cs *ClusterStatus) error {
 	// Set ClusterID.
 	c.unprotectedSetID(cs.ClusterID)
 
+	if cs.InternalToken != "" {
+		c.internalToken = cs.InternalToken
+	}
+
+	if cs.CoordinatorEpoch > c.coordinatorEpoch {
+		c.coordinatorEpoch = cs.CoordinatorEpoch
+		if err := c.saveTopology(); err != nil {
+			return errors.Wrap(err, "saving topology")
+		}
+	}
+
 	officialNodes := cs.Nodes
 
 	// Add all nodes from the coordinator.
@@ -1950,9 +2222,11 @@ func (c *cluster) setStatic(hosts []string) error {
 }
 
 type ClusterStatus struct {
-	ClusterID string
-	State     string
-	Nodes     []*Node
+	ClusterID        string
+	State            string
+	Nodes            []*Node
+	CoordinatorEpoch uint64
+	InternalToken    string
 }
 
 type ResizeInstruction struct {
@@ -2018,6 +2292,19 @@ type DeleteIndexMessage struct {
 	Index string
 }
 
+type PrepareCreateIndexMessage struct {
+	Index string
+	Meta  *IndexOptions
+}
+
+type CommitCreateIndexMessage struct {
+	Index string
+}
+
+type AbortCreateIndexMessage struct {
+	Index string
+}
+
 type CreateFieldMessage struct {
 	Index string
 	Field string
@@ -2046,6 +2333,22 @@ type DeleteViewMessage struct {
 	View  string
 }
 
+// DeleteViewsMessage is an internal message indicating views were deleted
+// from a field.
+type DeleteViewsMessage struct {
+	Index string
+	Field string
+	Views []string
+}
+
+// RemapRowsMessage is an internal message indicating the bits of a field's
+// rows should be moved to other rows.
+type RemapRowsMessage struct {
+	Index   string
+	Field   string
+	Mapping map[uint64]uint64
+}
+
 type ResizeInstructionComplete struct {
 	JobID int64
 	Node  *Node
@@ -2057,7 +2360,8 @@ type SetCoordinatorMessage struct {
 }
 
 type UpdateCoordinatorMessage struct {
-	New *Node
+	New   *Node
+	Epoch uint64
 }
 
 type NodeStateMessage struct {
@@ -2082,3 +2386,41 @@ type FieldStatus struct {
 }
 
 type RecalculateCaches struct{}
+
+// PingMessage is an internal message which does nothing. It is sent by
+// API.PingCluster to check that nodes can receive messages.
+type PingMessage struct{}
+
+// SetAttrSchemaMessage is an internal message indicating column attribute
+// types should be registered on an index.
+type SetAttrSchemaMessage struct {
+	Index  string
+	Schema AttrSchema
+}
+
+// PauseQueriesMessage is an internal message indicating queries should be
+// paused or resumed.
+type PauseQueriesMessage struct {
+	Paused bool
+}
+
+// SetDefaultFieldOptionsMessage is an internal message indicating the options
+// inherited by new fields of an index have changed.
+type SetDefaultFieldOptionsMessage struct {
+	Index   string
+	Options *FieldOptions
+}
+
+// EnableIndexKeysMessage is an internal message indicating an index has
+// switched to string keys.
+type EnableIndexKeysMessage struct {
+	Index string
+}
+
+// SetFieldACLMessage is an internal message indicating the principals
+// allowed to access a field have changed.
+type SetFieldACLMessage struct {
+	Index   string
+	Field   string
+	Allowed []string
+}
@@ -336,6 +336,86 @@ func TestCluster_Owners(t *testing.T) {
 	}
 }
 
+// Ensure shards owned by a node which has left the cluster are reported as under-replicated.
+func TestCluster_UnderReplicatedShards(t *testing.T) {
+	c := NewTestCluster(3)
+	c.ReplicaN = 2
+	for _, n := range c.nodes {
+		c.Topology.addID(n.ID)
+	}
+
+	if a := c.underReplicatedShards("i", 10); len(a) != 0 {
+		t.Fatalf("unexpected under-replicated shards: %s", spew.Sdump(a))
+	}
+
+	// Determine which shards node2 owns before it goes down.
+	var exp []uint64
+	for shard := uint64(0); shard <= 10; shard++ {
+		if Nodes(c.shardNodes("i", shard)).ContainsID("node2") {
+			exp = append(exp, shard)
+		}
+	}
+
+	c.removeNodeBasicSorted("node2")
+	c.Topology.nodeStates["node2"] = nodeStateDown
+
+	a := c.underReplicatedShards("i", 10)
+	if len(a) != len(exp) {
+		t.Fatalf("unexpected under-replicated shards: %s", spew.Sdump(a))
+	}
+	for i, sr := range a {
+		if sr.Shard != exp[i] {
+			t.Fatalf("unexpected shard: %d != %d", sr.Shard, exp[i])
+		} else if sr.ReplicaN != 2 || len(sr.LiveNodes) != 1 || !reflect.DeepEqual(sr.DownNodes, []string{"node2"}) {
+			t.Fatalf("unexpected replication: %s", spew.Sdump(sr))
+		}
+	}
+}
+
+// Ensure node removal is blocked when it would fail or under-replicate shards.
+func TestCluster_RemoveNodeBlocker(t *testing.T) {
+	c := NewTestCluster(3)
+	c.ReplicaN = 2
+	for _, n := range c.nodes {
+		c.Topology.addID(n.ID)
+	}
+
+	if reason := c.removeNodeBlocker("node1"); reason != "" {
+		t.Fatalf("unexpected reason: %s", reason)
+	} else if reason := c.removeNodeBlocker("node0"); reason == "" {
+		t.Fatal("expected coordinator to be blocked")
+	}
+
+	// Removing a node while another is down would fail to resize.
+	c.Topology.nodeStates["node2"] = nodeStateDown
+	if reason := c.removeNodeBlocker("node1"); reason != "node node2 is down" {
+		t.Fatalf("unexpected reason: %s", reason)
+	} else if reason := c.removeNodeBlocker("node2"); reason != "" {
+		t.Fatalf("unexpected reason: %s", reason)
+	}
+
+	// Two nodes can't hold three replicas.
+	c.ReplicaN = 3
+	if reason := c.removeNodeBlocker("node2"); reason != "2 nodes would remain for a replica count of 3" {
+		t.Fatalf("unexpected reason: %s", reason)
+	}
+}
+
+func TestCluster_CoordinatorCandidate(t *testing.T) {
+	c := NewTestCluster(3)
+	c.Topology.nodeStates["node1"] = nodeStateDown
+	c.Topology.nodeStates["node2"] = nodeStateReady
+
+	if n := c.coordinatorCandidate(); n == nil || n.ID != "node2" {
+		t.Fatalf("unexpected candidate: %v", n)
+	}
+
+	c.Topology.nodeStates["node2"] = nodeStateDown
+	if n := c.coordinatorCandidate(); n != nil {
+		t.Fatalf("unexpected candidate: %v", n)
+	}
+}
+
 // Ensure the partitioner can assign a fragment to a partition.
 func TestCluster_Partition(t *testing.T) {
 	if err := quick.Check(func(index string, shard uint64, partitionN int) bool {
@@ -380,6 +460,38 @@ func TestHasher(t *testing.T) {
 	}
 }
 
+// Ensure hashers can be registered and selected by name.
+func TestHasherByName(t *testing.T) {
+	if h, err := HasherByName(DefaultHasher); err != nil {
+		t.Fatal(err)
+	} else if _, ok := h.(*jmphasher); !ok {
+		t.Fatalf("unexpected default hasher: %T", h)
+	}
+	if _, err := HasherByName("test-unknown"); err == nil {
+		t.Fatal("expected error for unknown hasher")
+	}
+
+	if _, err := HasherByName("test-mod"); err != nil {
+		RegisterHasher("test-mod", NewTestModHasher())
+	}
+	h, err := HasherByName("test-mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewTestCluster(3)
+	c.Hasher = h
+	if nodes := c.partitionNodes(4); len(nodes) != 1 || nodes[0].ID != "node1" {
+		t.Fatalf("unexpected nodes: %v", Nodes(nodes).IDs())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic registering a name twice")
+		}
+	}()
+	RegisterHasher("test-mod", NewTestModHasher())
+}
+
 // Ensure ContainsShards can find the actual shard list for node and index.
 func TestCluster_ContainsShards(t *testing.T) {
 	c := NewTestCluster(5)
@@ -884,4 +996,63 @@ func TestCluster_UpdateCoordinator(t *testing.T) {
 			t.Errorf("expected coordinator: %s, but got: %s", c.Coordinator, newNode.URI)
 		}
 	})
+
+	t.Run("Epoch", func(t *testing.T) {
+		c := NewTestCluster(2)
+
+		if changed, err := c.advanceCoordinator(c.nodes[1], 3); err != nil {
+			t.Fatal(err)
+		} else if !changed {
+			t.Fatal("expected coordinator to change")
+		} else if id, epoch := c.coordinatorWithEpoch(); id != c.nodes[1].ID || epoch != 3 {
+			t.Fatalf("unexpected coordinator: id=%s, epoch=%d", id, epoch)
+		}
+
+		// Updates with a stale epoch are ignored.
+		for _, epoch := range []uint64{2, 3} {
+			if changed, err := c.advanceCoordinator(c.nodes[0], epoch); err != nil {
+				t.Fatal(err)
+			} else if changed {
+				t.Fatalf("expected stale update with epoch %d to be ignored", epoch)
+			} else if id, cur := c.coordinatorWithEpoch(); id != c.nodes[1].ID || cur != 3 {
+				t.Fatalf("unexpected coordinator: id=%s, epoch=%d", id, cur)
+			}
+		}
+
+		// The epoch is restored with the topology.
+		other := NewTestCluster(2)
+		other.Path = c.Path
+		if err := other.loadTopology(); err != nil {
+			t.Fatal(err)
+		} else if _, epoch := other.coordinatorWithEpoch(); epoch != 3 {
+			t.Fatalf("unexpected loaded epoch: %d", epoch)
+		}
+	})
+}
+
+// Ensure the indexes and fields missing from some nodes' schemas are reported.
+func TestSchemaDiscrepancies(t *testing.T) {
+	full := []*IndexInfo{
+		{Name: "i", Fields: []*FieldInfo{{Name: "f"}, {Name: "g"}}},
+		{Name: "j"},
+	}
+	schemas := map[string][]*IndexInfo{
+		"node0": full,
+		"node1": {{Name: "i", Fields: []*FieldInfo{{Name: "f"}}}},
+		"node2": full,
+		"node3": nil,
+	}
+	exp := map[string][]string{
+		"i":   {"node3"},
+		"i/f": {"node3"},
+		"i/g": {"node1", "node3"},
+		"j":   {"node1", "node3"},
+	}
+	if got := schemaDiscrepancies(schemas); !reflect.DeepEqual(got, exp) {
+		t.Fatalf("unexpected discrepancies: %v", got)
+	}
+
+	if got := schemaDiscrepancies(map[string][]*IndexInfo{"node0": full, "node1": full}); len(got) != 0 {
+		t.Fatalf("unexpected discrepancies: %v", got)
+	}
 }
@@ -27,6 +27,12 @@ func BuildServerFlags(cmd *cobra.Command, srv *server.Command) {
 	flags.StringVarP(&srv.Config.DataDir, "data-dir", "d", srv.Config.DataDir, "Directory to store pilosa data files.")
 	flags.StringVarP(&srv.Config.Bind, "bind", "b", srv.Config.Bind, "Default URI on which pilosa should listen.")
 	flags.IntVarP(&srv.Config.MaxWritesPerRequest, "max-writes-per-request", "", srv.Config.MaxWritesPerRequest, "Number of write commands per request.")
+	flags.IntVarP(&srv.Config.QueryLogSize, "query-log-size", "", srv.Config.QueryLogSize, "Number of recent queries retained for debugging.")
+	flags.Int64VarP(&srv.Config.MaxCacheBytes, "max-cache-bytes", "", srv.Config.MaxCacheBytes, "Approximate memory limit for TopN caches (0 for no limit).")
+	flags.BoolVarP(&srv.Config.Prefetch, "prefetch", "", srv.Config.Prefetch, "Read the next fragment ahead during exports.")
+	flags.IntVarP(&srv.Config.SnapshotCompressionLevel, "snapshot-compression-level", "", srv.Config.SnapshotCompressionLevel, "Gzip level (1-9) of fragment snapshots for compressed fields (0 for the default).")
+	flags.BoolVarP(&srv.Config.OrderedImports, "ordered-imports", "", srv.Config.OrderedImports, "Apply imports to each shard in the same order on all replicas.")
+	flags.BoolVarP(&srv.Config.TrashDeletedFields, "trash-deleted-fields", "", srv.Config.TrashDeletedFields, "Move deleted fields to the trash rather than removing them.")
 	flags.StringVar(&srv.Config.LogPath, "log-path", srv.Config.LogPath, "Log path")
 	flags.BoolVar(&srv.Config.Verbose, "verbose", srv.Config.Verbose, "Enable verbose logging")
 
@@ -42,6 +48,7 @@ func BuildServerFlags(cmd *cobra.Command, srv *server.Command) {
 	flags.IntVarP(&srv.Config.Cluster.ReplicaN, "cluster.replicas", "", 1, "Number of hosts each piece of data should be stored on.")
 	flags.StringSliceVarP(&srv.Config.Cluster.Hosts, "cluster.hosts", "", []string{}, "Comma separated list of hosts in cluster. Only used for testing.")
 	flags.DurationVarP((*time.Duration)(&srv.Config.Cluster.LongQueryTime), "cluster.long-query-time", "", time.Minute, "Duration that will trigger log and stat messages for slow queries.")
+	flags.StringVarP(&srv.Config.Cluster.Hasher, "cluster.hasher", "", srv.Config.Cluster.Hasher, "Name of the hasher which assigns shards to nodes. Every node in the cluster must use the same hasher.")
 
 	// Translation
 	flags.StringVarP(&srv.Config.Translation.PrimaryURL, "translation.primary-url", "", srv.Config.Translation.PrimaryURL, "DEPRECATED: URL for primary translation node for replication.")
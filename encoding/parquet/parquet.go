@@ -0,0 +1,321 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package parquet writes Apache Parquet files made up of required INT64
+// columns. Values are PLAIN encoded and uncompressed, with one data page per
+// column chunk.
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultRowGroupSize is the default number of rows buffered in memory before
+// a row group is written.
+const DefaultRowGroupSize = 1 << 20
+
+var magic = []byte("PAR1")
+
+// Parquet enum values used by this package.
+const (
+	typeInt64 = 2
+
+	repetitionRequired = 0
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	codecUncompressed = 0
+
+	pageTypeData = 0
+)
+
+// Writer writes rows of int64 values to a Parquet file. Close must be called
+// to write the file footer.
+type Writer struct {
+	w       io.Writer
+	offset  int64
+	columns []string
+	values  [][]int64
+
+	numRows   int64
+	rowGroups []rowGroup
+
+	// RowGroupSize is the number of rows in each row group.
+	RowGroupSize int
+}
+
+type rowGroup struct {
+	numRows int64
+	chunks  []columnChunk
+}
+
+type columnChunk struct {
+	offset int64
+	size   int64
+}
+
+// NewWriter returns a new Writer which writes a file with the named columns
+// to w.
+func NewWriter(w io.Writer, columns ...string) *Writer {
+	return &Writer{
+		w:            w,
+		columns:      columns,
+		values:       make([][]int64, len(columns)),
+		RowGroupSize: DefaultRowGroupSize,
+	}
+}
+
+// Write adds a row to the file. There must be one value per column.
+func (w *Writer) Write(row ...int64) error {
+	if len(row) != len(w.columns) {
+		return errors.Errorf("expected %d values, got %d", len(w.columns), len(row))
+	}
+	for i, v := range row {
+		w.values[i] = append(w.values[i], v)
+	}
+	if len(w.values[0]) >= w.RowGroupSize {
+		return w.flush()
+	}
+	return nil
+}
+
+// Close writes any buffered rows and the file footer. It does not close the
+// underlying writer.
+func (w *Writer) Close() error {
+	if len(w.values) > 0 && len(w.values[0]) > 0 {
+		if err := w.flush(); err != nil {
+			return err
+		}
+	} else if err := w.writeMagic(); err != nil {
+		return err
+	}
+
+	footer := w.footer()
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(len(footer)))
+	for _, buf := range [][]byte{footer, size[:], magic} {
+		if err := w.write(buf); err != nil {
+			return errors.Wrap(err, "writing footer")
+		}
+	}
+	return nil
+}
+
+// flush writes the buffered rows as a row group.
+func (w *Writer) flush() error {
+	if err := w.writeMagic(); err != nil {
+		return err
+	}
+
+	n := len(w.values[0])
+	rg := rowGroup{numRows: int64(n)}
+	for i, values := range w.values {
+		data := make([]byte, 8*n)
+		for j, v := range values {
+			binary.LittleEndian.PutUint64(data[8*j:], uint64(v))
+		}
+
+		var t thriftWriter
+		t.i32(1, pageTypeData)
+		t.i32(2, int32(len(data)))
+		t.i32(3, int32(len(data)))
+		t.beginStruct(5)
+		t.i32(1, int32(n))
+		t.i32(2, encodingPlain)
+		t.i32(3, encodingRLE)
+		t.i32(4, encodingRLE)
+		t.endStruct()
+		t.stop()
+
+		chunk := columnChunk{offset: w.offset, size: int64(t.buf.Len() + len(data))}
+		if err := w.write(t.buf.Bytes()); err != nil {
+			return errors.Wrap(err, "writing page header")
+		} else if err := w.write(data); err != nil {
+			return errors.Wrap(err, "writing page")
+		}
+		rg.chunks = append(rg.chunks, chunk)
+		w.values[i] = values[:0]
+	}
+	w.rowGroups = append(w.rowGroups, rg)
+	w.numRows += int64(n)
+	return nil
+}
+
+// writeMagic writes the leading magic bytes if nothing has been written yet.
+func (w *Writer) writeMagic() error {
+	if w.offset > 0 {
+		return nil
+	}
+	return errors.Wrap(w.write(magic), "writing magic")
+}
+
+func (w *Writer) write(buf []byte) error {
+	n, err := w.w.Write(buf)
+	w.offset += int64(n)
+	return err
+}
+
+// footer returns the encoded FileMetaData.
+func (w *Writer) footer() []byte {
+	var t thriftWriter
+	t.i32(1, 1)
+
+	// Schema: a root element followed by one element per column.
+	t.beginList(2, thriftStruct, len(w.columns)+1)
+	t.beginElem()
+	t.string(4, "schema")
+	t.i32(5, int32(len(w.columns)))
+	t.endStruct()
+	for _, name := range w.columns {
+		t.beginElem()
+		t.i32(1, typeInt64)
+		t.i32(3, repetitionRequired)
+		t.string(4, name)
+		t.endStruct()
+	}
+
+	t.i64(3, w.numRows)
+
+	t.beginList(4, thriftStruct, len(w.rowGroups))
+	for _, rg := range w.rowGroups {
+		var total int64
+		for _, c := range rg.chunks {
+			total += c.size
+		}
+
+		t.beginElem()
+		t.beginList(1, thriftStruct, len(rg.chunks))
+		for i, c := range rg.chunks {
+			t.beginElem()
+			t.i64(2, c.offset)
+			t.beginStruct(3)
+			t.i32(1, typeInt64)
+			t.beginList(2, thriftI32, 1)
+			t.listI32(encodingPlain)
+			t.beginList(3, thriftBinary, 1)
+			t.listString(w.columns[i])
+			t.i32(4, codecUncompressed)
+			t.i64(5, rg.numRows)
+			t.i64(6, c.size)
+			t.i64(7, c.size)
+			t.i64(9, c.offset)
+			t.endStruct()
+			t.endStruct()
+		}
+		t.i64(2, total)
+		t.i64(3, rg.numRows)
+		t.endStruct()
+	}
+
+	t.string(6, "pilosa")
+	t.stop()
+	return t.buf.Bytes()
+}
+
+// Thrift compact protocol type identifiers.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes structs using the Thrift compact protocol, which is
+// used for Parquet metadata.
+type thriftWriter struct {
+	buf  bytes.Buffer
+	last []int16 // last field id written, per nested struct
+	id   int16
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	if delta := id - t.id; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.varint(zigzag(int64(id)))
+	}
+	t.id = id
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.varint(zigzag(int64(v)))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(zigzag(v))
+}
+
+func (t *thriftWriter) string(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.listString(s)
+}
+
+func (t *thriftWriter) beginStruct(id int16) {
+	t.field(id, thriftStruct)
+	t.beginElem()
+}
+
+// beginElem starts a struct which is an element of a list.
+func (t *thriftWriter) beginElem() {
+	t.last = append(t.last, t.id)
+	t.id = 0
+}
+
+func (t *thriftWriter) endStruct() {
+	t.stop()
+	t.id = t.last[len(t.last)-1]
+	t.last = t.last[:len(t.last)-1]
+}
+
+func (t *thriftWriter) stop() {
+	t.buf.WriteByte(0)
+}
+
+func (t *thriftWriter) beginList(id int16, elemType byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.buf.WriteByte(byte(n)<<4 | elemType)
+	} else {
+		t.buf.WriteByte(0xf0 | elemType)
+		t.varint(uint64(n))
+	}
+}
+
+func (t *thriftWriter) listI32(v int32) {
+	t.varint(zigzag(int64(v)))
+}
+
+func (t *thriftWriter) listString(s string) {
+	t.varint(uint64(len(s)))
+	t.buf.WriteString(s)
+}
+
+func (t *thriftWriter) varint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	t.buf.Write(buf[:n])
+}
+
+func zigzag(v int64) uint64 {
+	return uint64((v << 1) ^ (v >> 63))
+}
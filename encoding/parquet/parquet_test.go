@@ -0,0 +1,150 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parquet_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"github.com/pilosa/pilosa/encoding/parquet"
+)
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := parquet.NewWriter(&buf, "row", "column")
+	w.RowGroupSize = 2
+	rows := [][]int64{{1, 10}, {1, 20}, {2, -5}}
+	for _, row := range rows {
+		if err := w.Write(row...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Write(1); err == nil {
+		t.Fatal("expected error for short row")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()
+	if !bytes.HasPrefix(data, []byte("PAR1")) || !bytes.HasSuffix(data, []byte("PAR1")) {
+		t.Fatal("missing magic bytes")
+	}
+	n := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	meta := readStruct(t, bytes.NewReader(data[len(data)-8-n:len(data)-8]))
+
+	if meta[3] != int64(3) {
+		t.Fatalf("unexpected num_rows: %v", meta[3])
+	}
+	var names []string
+	for _, elem := range meta[2].([]interface{}) {
+		names = append(names, string(elem.(map[int16]interface{})[4].([]byte)))
+	}
+	if !reflect.DeepEqual(names, []string{"schema", "row", "column"}) {
+		t.Fatalf("unexpected schema: %v", names)
+	}
+
+	// Read the values back from each column chunk.
+	got := make([][]int64, 2)
+	rowGroups := meta[4].([]interface{})
+	if len(rowGroups) != 2 {
+		t.Fatalf("unexpected row groups: %d", len(rowGroups))
+	}
+	for _, rg := range rowGroups {
+		for i, c := range rg.(map[int16]interface{})[1].([]interface{}) {
+			md := c.(map[int16]interface{})[3].(map[int16]interface{})
+			r := bytes.NewReader(data[md[9].(int64):])
+			header := readStruct(t, r)
+			values := make([]int64, header[5].(map[int16]interface{})[1].(int64))
+			if err := binary.Read(r, binary.LittleEndian, values); err != nil {
+				t.Fatal(err)
+			}
+			got[i] = append(got[i], values...)
+		}
+	}
+	if exp := [][]int64{{1, 1, 2}, {10, 20, -5}}; !reflect.DeepEqual(got, exp) {
+		t.Fatalf("unexpected values: %v", got)
+	}
+}
+
+// readStruct decodes a Thrift compact protocol struct into a map of field id
+// to value. Integers are returned as int64, binary as []byte, lists as
+// []interface{} and structs as map[int16]interface{}.
+func readStruct(t *testing.T, r *bytes.Reader) map[int16]interface{} {
+	m := make(map[int16]interface{})
+	var id int16
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			t.Fatal(err)
+		} else if b == 0 {
+			return m
+		}
+		if delta := int16(b >> 4); delta != 0 {
+			id += delta
+		} else {
+			id = int16(readInt(t, r))
+		}
+		m[id] = readValue(t, r, b&0x0f)
+	}
+}
+
+func readValue(t *testing.T, r *bytes.Reader, typ byte) interface{} {
+	switch typ {
+	case 5, 6:
+		return readInt(t, r)
+	case 8:
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, n)
+		if _, err := r.Read(buf); err != nil && n > 0 {
+			t.Fatal(err)
+		}
+		return buf
+	case 9:
+		b, err := r.ReadByte()
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := uint64(b >> 4)
+		if n == 15 {
+			if n, err = binary.ReadUvarint(r); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var list []interface{}
+		for i := uint64(0); i < n; i++ {
+			list = append(list, readValue(t, r, b&0x0f))
+		}
+		return list
+	case 12:
+		return readStruct(t, r)
+	default:
+		t.Fatalf("unexpected thrift type: %d", typ)
+		return nil
+	}
+}
+
+func readInt(t *testing.T, r *bytes.Reader) int64 {
+	v, err := binary.ReadVarint(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
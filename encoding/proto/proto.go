@@ -17,6 +17,7 @@ package proto
 import (
 	"fmt"
 	"sort"
+	"time"
 
 	"github.com/gogo/protobuf/proto"
 	"github.com/pilosa/pilosa"
@@ -65,6 +66,30 @@ func (Serializer) Unmarshal(buf []byte, m pilosa.Message) error {
 		}
 		decodeDeleteIndexMessage(msg, mt)
 		return nil
+	case *pilosa.PrepareCreateIndexMessage:
+		msg := &internal.PrepareCreateIndexMessage{}
+		err := proto.Unmarshal(buf, msg)
+		if err != nil {
+			return errors.Wrap(err, "unmarshaling PrepareCreateIndexMessage")
+		}
+		decodePrepareCreateIndexMessage(msg, mt)
+		return nil
+	case *pilosa.CommitCreateIndexMessage:
+		msg := &internal.CommitCreateIndexMessage{}
+		err := proto.Unmarshal(buf, msg)
+		if err != nil {
+			return errors.Wrap(err, "unmarshaling CommitCreateIndexMessage")
+		}
+		decodeCommitCreateIndexMessage(msg, mt)
+		return nil
+	case *pilosa.AbortCreateIndexMessage:
+		msg := &internal.AbortCreateIndexMessage{}
+		err := proto.Unmarshal(buf, msg)
+		if err != nil {
+			return errors.Wrap(err, "unmarshaling AbortCreateIndexMessage")
+		}
+		decodeAbortCreateIndexMessage(msg, mt)
+		return nil
 	case *pilosa.CreateFieldMessage:
 		msg := &internal.CreateFieldMessage{}
 		err := proto.Unmarshal(buf, msg)
@@ -105,6 +130,14 @@ func (Serializer) Unmarshal(buf []byte, m pilosa.Message) error {
 		}
 		decodeDeleteViewMessage(msg, mt)
 		return nil
+	case *pilosa.DeleteViewsMessage:
+		msg := &internal.DeleteViewsMessage{}
+		err := proto.Unmarshal(buf, msg)
+		if err != nil {
+			return errors.Wrap(err, "unmarshaling DeleteViewsMessage")
+		}
+		decodeDeleteViewsMessage(msg, mt)
+		return nil
 	case *pilosa.ClusterStatus:
 		msg := &internal.ClusterStatus{}
 		err := proto.Unmarshal(buf, msg)
@@ -161,6 +194,13 @@ func (Serializer) Unmarshal(buf []byte, m pilosa.Message) error {
 		}
 		decodeRecalculateCaches(msg, mt)
 		return nil
+	case *pilosa.PingMessage:
+		msg := &internal.PingMessage{}
+		err := proto.Unmarshal(buf, msg)
+		if err != nil {
+			return errors.Wrap(err, "unmarshaling PingMessage")
+		}
+		return nil
 	case *pilosa.NodeEvent:
 		msg := &internal.NodeEventMessage{}
 		err := proto.Unmarshal(buf, msg)
@@ -177,6 +217,54 @@ func (Serializer) Unmarshal(buf []byte, m pilosa.Message) error {
 		}
 		decodeNodeStatus(msg, mt)
 		return nil
+	case *pilosa.SetAttrSchemaMessage:
+		msg := &internal.SetAttrSchemaMessage{}
+		err := proto.Unmarshal(buf, msg)
+		if err != nil {
+			return errors.Wrap(err, "unmarshaling SetAttrSchemaMessage")
+		}
+		decodeSetAttrSchemaMessage(msg, mt)
+		return nil
+	case *pilosa.SetFieldACLMessage:
+		msg := &internal.SetFieldACLMessage{}
+		err := proto.Unmarshal(buf, msg)
+		if err != nil {
+			return errors.Wrap(err, "unmarshaling SetFieldACLMessage")
+		}
+		decodeSetFieldACLMessage(msg, mt)
+		return nil
+	case *pilosa.EnableIndexKeysMessage:
+		msg := &internal.EnableIndexKeysMessage{}
+		err := proto.Unmarshal(buf, msg)
+		if err != nil {
+			return errors.Wrap(err, "unmarshaling EnableIndexKeysMessage")
+		}
+		decodeEnableIndexKeysMessage(msg, mt)
+		return nil
+	case *pilosa.SetDefaultFieldOptionsMessage:
+		msg := &internal.SetDefaultFieldOptionsMessage{}
+		err := proto.Unmarshal(buf, msg)
+		if err != nil {
+			return errors.Wrap(err, "unmarshaling SetDefaultFieldOptionsMessage")
+		}
+		decodeSetDefaultFieldOptionsMessage(msg, mt)
+		return nil
+	case *pilosa.PauseQueriesMessage:
+		msg := &internal.PauseQueriesMessage{}
+		err := proto.Unmarshal(buf, msg)
+		if err != nil {
+			return errors.Wrap(err, "unmarshaling PauseQueriesMessage")
+		}
+		decodePauseQueriesMessage(msg, mt)
+		return nil
+	case *pilosa.RemapRowsMessage:
+		msg := &internal.RemapRowsMessage{}
+		err := proto.Unmarshal(buf, msg)
+		if err != nil {
+			return errors.Wrap(err, "unmarshaling RemapRowsMessage")
+		}
+		decodeRemapRowsMessage(msg, mt)
+		return nil
 	case *pilosa.Node:
 		msg := &internal.Node{}
 		err := proto.Unmarshal(buf, msg)
@@ -278,6 +366,12 @@ func encodeToProto(m pilosa.Message) proto.Message {
 		return encodeCreateIndexMessage(mt)
 	case *pilosa.DeleteIndexMessage:
 		return encodeDeleteIndexMessage(mt)
+	case *pilosa.PrepareCreateIndexMessage:
+		return encodePrepareCreateIndexMessage(mt)
+	case *pilosa.CommitCreateIndexMessage:
+		return encodeCommitCreateIndexMessage(mt)
+	case *pilosa.AbortCreateIndexMessage:
+		return encodeAbortCreateIndexMessage(mt)
 	case *pilosa.CreateFieldMessage:
 		return encodeCreateFieldMessage(mt)
 	case *pilosa.DeleteFieldMessage:
@@ -288,6 +382,8 @@ func encodeToProto(m pilosa.Message) proto.Message {
 		return encodeCreateViewMessage(mt)
 	case *pilosa.DeleteViewMessage:
 		return encodeDeleteViewMessage(mt)
+	case *pilosa.DeleteViewsMessage:
+		return encodeDeleteViewsMessage(mt)
 	case *pilosa.ClusterStatus:
 		return encodeClusterStatus(mt)
 	case *pilosa.ResizeInstruction:
@@ -302,10 +398,24 @@ func encodeToProto(m pilosa.Message) proto.Message {
 		return encodeNodeStateMessage(mt)
 	case *pilosa.RecalculateCaches:
 		return encodeRecalculateCaches(mt)
+	case *pilosa.PingMessage:
+		return &internal.PingMessage{}
 	case *pilosa.NodeEvent:
 		return encodeNodeEventMessage(mt)
 	case *pilosa.NodeStatus:
 		return encodeNodeStatus(mt)
+	case *pilosa.SetAttrSchemaMessage:
+		return encodeSetAttrSchemaMessage(mt)
+	case *pilosa.SetFieldACLMessage:
+		return encodeSetFieldACLMessage(mt)
+	case *pilosa.EnableIndexKeysMessage:
+		return encodeEnableIndexKeysMessage(mt)
+	case *pilosa.RemapRowsMessage:
+		return encodeRemapRowsMessage(mt)
+	case *pilosa.PauseQueriesMessage:
+		return encodePauseQueriesMessage(mt)
+	case *pilosa.SetDefaultFieldOptionsMessage:
+		return encodeSetDefaultFieldOptionsMessage(mt)
 	case *pilosa.Node:
 		return encodeNode(mt)
 	case *pilosa.QueryRequest:
@@ -349,21 +459,34 @@ func encodeBlockDataResponse(m *pilosa.BlockDataResponse) *internal.BlockDataRes
 }
 
 func encodeImportResponse(m *pilosa.ImportResponse) *internal.ImportResponse {
-	return &internal.ImportResponse{
-		Err: m.Err,
+	pb := &internal.ImportResponse{
+		Err:      m.Err,
+		Sequence: m.Sequence,
+	}
+	for _, b := range m.Duplicates {
+		pb.DuplicateRowIDs = append(pb.DuplicateRowIDs, b.RowID)
+		pb.DuplicateColumnIDs = append(pb.DuplicateColumnIDs, b.ColumnID)
 	}
+	return pb
 }
 
 func encodeImportRequest(m *pilosa.ImportRequest) *internal.ImportRequest {
 	return &internal.ImportRequest{
-		Index:      m.Index,
-		Field:      m.Field,
-		Shard:      m.Shard,
-		RowIDs:     m.RowIDs,
-		ColumnIDs:  m.ColumnIDs,
-		RowKeys:    m.RowKeys,
-		ColumnKeys: m.ColumnKeys,
-		Timestamps: m.Timestamps,
+		Index:            m.Index,
+		Field:            m.Field,
+		Shard:            m.Shard,
+		RowIDs:           m.RowIDs,
+		ColumnIDs:        m.ColumnIDs,
+		RowKeys:          m.RowKeys,
+		ColumnKeys:       m.ColumnKeys,
+		Timestamps:       m.Timestamps,
+		AllowForward:     m.AllowForward,
+		PayloadChecksum:  m.PayloadChecksum,
+		Principal:        m.Principal,
+		ReportDuplicates: m.ReportDuplicates,
+		Transform:        m.Transform,
+		WeightField:      m.WeightField,
+		Weights:          m.Weights,
 	}
 }
 
@@ -375,6 +498,8 @@ func encodeImportValueRequest(m *pilosa.ImportValueRequest) *internal.ImportValu
 		ColumnIDs:  m.ColumnIDs,
 		ColumnKeys: m.ColumnKeys,
 		Values:     m.Values,
+		Null:       m.Null,
+		Principal:  m.Principal,
 	}
 }
 
@@ -396,12 +521,24 @@ func encodeImportRoaringRequest(m *pilosa.ImportRoaringRequest) *internal.Import
 
 func encodeQueryRequest(m *pilosa.QueryRequest) *internal.QueryRequest {
 	return &internal.QueryRequest{
-		Query:           m.Query,
-		Shards:          m.Shards,
-		ColumnAttrs:     m.ColumnAttrs,
-		Remote:          m.Remote,
-		ExcludeRowAttrs: m.ExcludeRowAttrs,
-		ExcludeColumns:  m.ExcludeColumns,
+		Query:               m.Query,
+		Shards:              m.Shards,
+		ColumnAttrs:         m.ColumnAttrs,
+		Remote:              m.Remote,
+		ExcludeRowAttrs:     m.ExcludeRowAttrs,
+		ExcludeColumns:      m.ExcludeColumns,
+		View:                m.View,
+		AttrFilter:          encodeAttrs(m.AttrFilter),
+		IncludeKeys:         m.IncludeKeys,
+		Principal:           m.Principal,
+		TreatMissingAsEmpty: m.TreatMissingAsEmpty,
+		SortResults:         m.SortResults,
+		CacheResults:        m.CacheResults,
+		AttrsBestEffort:     m.AttrsBestEffort,
+		AccountID:           m.AccountID,
+		IncludeRowAttrs:     m.IncludeRowAttrs,
+		MaxParallelism:      uint32(m.MaxParallelism),
+		CompressResults:     m.CompressResults,
 	}
 }
 
@@ -421,6 +558,9 @@ func encodeQueryResponse(m *pilosa.QueryResponse) *internal.QueryResponse {
 		case []pilosa.Pair:
 			pb.Results[i].Type = queryResultTypePairs
 			pb.Results[i].Pairs = encodePairs(result)
+		case []pilosa.PairAttrs:
+			pb.Results[i].Type = queryResultTypePairAttrs
+			pb.Results[i].Pairs = encodePairAttrs(result)
 		case pilosa.ValCount:
 			pb.Results[i].Type = queryResultTypeValCount
 			pb.Results[i].ValCount = encodeValCount(result)
@@ -534,7 +674,21 @@ func encodeFieldOptions(o *pilosa.FieldOptions) *internal.FieldOptions {
 		Max:         o.Max,
 		TimeQuantum: string(o.TimeQuantum),
 		Keys:        o.Keys,
+		Compression: o.Compression,
+		ACL:         o.ACL,
+		TimeMin:     encodeTimeBound(o.TimeMin),
+		TimeMax:     encodeTimeBound(o.TimeMax),
+		AllowedRows: o.AllowedRows,
+	}
+}
+
+// encodeTimeBound returns t as nanoseconds since the epoch, or zero if t is
+// the zero time.
+func encodeTimeBound(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
 	}
+	return t.UnixNano()
 }
 
 // encodeNodes converts a slice of Nodes into its internal representation.
@@ -566,9 +720,11 @@ func encodeURI(u pilosa.URI) *internal.URI {
 
 func encodeClusterStatus(m *pilosa.ClusterStatus) *internal.ClusterStatus {
 	return &internal.ClusterStatus{
-		State:     m.State,
-		ClusterID: m.ClusterID,
-		Nodes:     encodeNodes(m.Nodes),
+		State:            m.State,
+		ClusterID:        m.ClusterID,
+		Nodes:            encodeNodes(m.Nodes),
+		CoordinatorEpoch: m.CoordinatorEpoch,
+		InternalToken:    m.InternalToken,
 	}
 }
 
@@ -591,6 +747,7 @@ func encodeIndexMeta(m *pilosa.IndexOptions) *internal.IndexMeta {
 	return &internal.IndexMeta{
 		Keys:           m.Keys,
 		TrackExistence: m.TrackExistence,
+		AttrStore:      m.AttrStore,
 	}
 }
 
@@ -600,6 +757,25 @@ func encodeDeleteIndexMessage(m *pilosa.DeleteIndexMessage) *internal.DeleteInde
 	}
 }
 
+func encodePrepareCreateIndexMessage(m *pilosa.PrepareCreateIndexMessage) *internal.PrepareCreateIndexMessage {
+	return &internal.PrepareCreateIndexMessage{
+		Index: m.Index,
+		Meta:  encodeIndexMeta(m.Meta),
+	}
+}
+
+func encodeCommitCreateIndexMessage(m *pilosa.CommitCreateIndexMessage) *internal.CommitCreateIndexMessage {
+	return &internal.CommitCreateIndexMessage{
+		Index: m.Index,
+	}
+}
+
+func encodeAbortCreateIndexMessage(m *pilosa.AbortCreateIndexMessage) *internal.AbortCreateIndexMessage {
+	return &internal.AbortCreateIndexMessage{
+		Index: m.Index,
+	}
+}
+
 func encodeCreateFieldMessage(m *pilosa.CreateFieldMessage) *internal.CreateFieldMessage {
 	return &internal.CreateFieldMessage{
 		Index: m.Index,
@@ -639,6 +815,14 @@ func encodeDeleteViewMessage(m *pilosa.DeleteViewMessage) *internal.DeleteViewMe
 	}
 }
 
+func encodeDeleteViewsMessage(m *pilosa.DeleteViewsMessage) *internal.DeleteViewsMessage {
+	return &internal.DeleteViewsMessage{
+		Index: m.Index,
+		Field: m.Field,
+		Views: m.Views,
+	}
+}
+
 func encodeResizeInstructionComplete(m *pilosa.ResizeInstructionComplete) *internal.ResizeInstructionComplete {
 	return &internal.ResizeInstructionComplete{
 		JobID: m.JobID,
@@ -655,7 +839,8 @@ func encodeSetCoordinatorMessage(m *pilosa.SetCoordinatorMessage) *internal.SetC
 
 func encodeUpdateCoordinatorMessage(m *pilosa.UpdateCoordinatorMessage) *internal.UpdateCoordinatorMessage {
 	return &internal.UpdateCoordinatorMessage{
-		New: encodeNode(m.New),
+		New:   encodeNode(m.New),
+		Epoch: m.Epoch,
 	}
 }
 
@@ -715,6 +900,68 @@ func encodeRecalculateCaches(*pilosa.RecalculateCaches) *internal.RecalculateCac
 	return &internal.RecalculateCaches{}
 }
 
+func encodeSetAttrSchemaMessage(m *pilosa.SetAttrSchemaMessage) *internal.SetAttrSchemaMessage {
+	return &internal.SetAttrSchemaMessage{
+		Index: m.Index,
+		Attrs: encodeAttrSchema(m.Schema),
+	}
+}
+
+func encodeSetFieldACLMessage(m *pilosa.SetFieldACLMessage) *internal.SetFieldACLMessage {
+	return &internal.SetFieldACLMessage{
+		Index:   m.Index,
+		Field:   m.Field,
+		Allowed: m.Allowed,
+	}
+}
+
+func encodeEnableIndexKeysMessage(m *pilosa.EnableIndexKeysMessage) *internal.EnableIndexKeysMessage {
+	return &internal.EnableIndexKeysMessage{
+		Index: m.Index,
+	}
+}
+
+func encodeSetDefaultFieldOptionsMessage(m *pilosa.SetDefaultFieldOptionsMessage) *internal.SetDefaultFieldOptionsMessage {
+	return &internal.SetDefaultFieldOptionsMessage{
+		Index:   m.Index,
+		Options: encodeFieldOptions(m.Options),
+	}
+}
+
+func encodePauseQueriesMessage(m *pilosa.PauseQueriesMessage) *internal.PauseQueriesMessage {
+	return &internal.PauseQueriesMessage{
+		Paused: m.Paused,
+	}
+}
+
+func encodeRemapRowsMessage(m *pilosa.RemapRowsMessage) *internal.RemapRowsMessage {
+	pb := &internal.RemapRowsMessage{
+		Index:   m.Index,
+		Field:   m.Field,
+		Sources: make([]uint64, 0, len(m.Mapping)),
+		Targets: make([]uint64, 0, len(m.Mapping)),
+	}
+	for src, dst := range m.Mapping {
+		pb.Sources = append(pb.Sources, src)
+		pb.Targets = append(pb.Targets, dst)
+	}
+	return pb
+}
+
+func encodeAttrSchema(s pilosa.AttrSchema) []*internal.Attr {
+	keys := make([]string, 0, len(s))
+	for k := range s {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	a := make([]*internal.Attr, len(keys))
+	for i, k := range keys {
+		a[i] = &internal.Attr{Key: k, Type: attrSchemaTypes[s[k]]}
+	}
+	return a
+}
+
 func encodeTranslateKeysResponse(response *pilosa.TranslateKeysResponse) *internal.TranslateKeysResponse {
 	return &internal.TranslateKeysResponse{
 		IDs: response.IDs,
@@ -802,6 +1049,15 @@ func decodeFieldOptions(options *internal.FieldOptions, m *pilosa.FieldOptions)
 	m.Max = options.Max
 	m.TimeQuantum = pilosa.TimeQuantum(options.TimeQuantum)
 	m.Keys = options.Keys
+	m.Compression = options.Compression
+	m.ACL = options.ACL
+	m.AllowedRows = options.AllowedRows
+	if options.TimeMin != 0 {
+		m.TimeMin = time.Unix(0, options.TimeMin).UTC()
+	}
+	if options.TimeMax != 0 {
+		m.TimeMax = time.Unix(0, options.TimeMax).UTC()
+	}
 }
 
 func decodeNodes(a []*internal.Node, m []*pilosa.Node) {
@@ -816,6 +1072,8 @@ func decodeClusterStatus(cs *internal.ClusterStatus, m *pilosa.ClusterStatus) {
 	m.ClusterID = cs.ClusterID
 	m.Nodes = make([]*pilosa.Node, len(cs.Nodes))
 	decodeNodes(cs.Nodes, m.Nodes)
+	m.CoordinatorEpoch = cs.CoordinatorEpoch
+	m.InternalToken = cs.InternalToken
 }
 
 func decodeNode(node *internal.Node, m *pilosa.Node) {
@@ -846,12 +1104,27 @@ func decodeCreateIndexMessage(pb *internal.CreateIndexMessage, m *pilosa.CreateI
 func decodeIndexMeta(pb *internal.IndexMeta, m *pilosa.IndexOptions) {
 	m.Keys = pb.Keys
 	m.TrackExistence = pb.TrackExistence
+	m.AttrStore = pb.AttrStore
 }
 
 func decodeDeleteIndexMessage(pb *internal.DeleteIndexMessage, m *pilosa.DeleteIndexMessage) {
 	m.Index = pb.Index
 }
 
+func decodePrepareCreateIndexMessage(pb *internal.PrepareCreateIndexMessage, m *pilosa.PrepareCreateIndexMessage) {
+	m.Index = pb.Index
+	m.Meta = &pilosa.IndexOptions{}
+	decodeIndexMeta(pb.Meta, m.Meta)
+}
+
+func decodeCommitCreateIndexMessage(pb *internal.CommitCreateIndexMessage, m *pilosa.CommitCreateIndexMessage) {
+	m.Index = pb.Index
+}
+
+func decodeAbortCreateIndexMessage(pb *internal.AbortCreateIndexMessage, m *pilosa.AbortCreateIndexMessage) {
+	m.Index = pb.Index
+}
+
 func decodeCreateFieldMessage(pb *internal.CreateFieldMessage, m *pilosa.CreateFieldMessage) {
 	m.Index = pb.Index
 	m.Field = pb.Field
@@ -882,6 +1155,12 @@ func decodeDeleteViewMessage(pb *internal.DeleteViewMessage, m *pilosa.DeleteVie
 	m.View = pb.View
 }
 
+func decodeDeleteViewsMessage(pb *internal.DeleteViewsMessage, m *pilosa.DeleteViewsMessage) {
+	m.Index = pb.Index
+	m.Field = pb.Field
+	m.Views = pb.Views
+}
+
 func decodeResizeInstructionComplete(pb *internal.ResizeInstructionComplete, m *pilosa.ResizeInstructionComplete) {
 	m.JobID = pb.JobID
 	m.Node = &pilosa.Node{}
@@ -897,6 +1176,7 @@ func decodeSetCoordinatorMessage(pb *internal.SetCoordinatorMessage, m *pilosa.S
 func decodeUpdateCoordinatorMessage(pb *internal.UpdateCoordinatorMessage, m *pilosa.UpdateCoordinatorMessage) {
 	m.New = &pilosa.Node{}
 	decodeNode(pb.New, m.New)
+	m.Epoch = pb.Epoch
 }
 
 func decodeNodeStateMessage(pb *internal.NodeStateMessage, m *pilosa.NodeStateMessage) {
@@ -947,6 +1227,57 @@ func decodeFieldStatus(pb *internal.FieldStatus, m *pilosa.FieldStatus) {
 
 func decodeRecalculateCaches(pb *internal.RecalculateCaches, m *pilosa.RecalculateCaches) {}
 
+func decodeSetAttrSchemaMessage(pb *internal.SetAttrSchemaMessage, m *pilosa.SetAttrSchemaMessage) {
+	m.Index = pb.Index
+	m.Schema = decodeAttrSchema(pb.Attrs)
+}
+
+func decodeSetFieldACLMessage(pb *internal.SetFieldACLMessage, m *pilosa.SetFieldACLMessage) {
+	m.Index = pb.Index
+	m.Field = pb.Field
+	m.Allowed = pb.Allowed
+}
+
+func decodeEnableIndexKeysMessage(pb *internal.EnableIndexKeysMessage, m *pilosa.EnableIndexKeysMessage) {
+	m.Index = pb.Index
+}
+
+func decodeSetDefaultFieldOptionsMessage(pb *internal.SetDefaultFieldOptionsMessage, m *pilosa.SetDefaultFieldOptionsMessage) {
+	m.Index = pb.Index
+	m.Options = &pilosa.FieldOptions{}
+	if pb.Options != nil {
+		decodeFieldOptions(pb.Options, m.Options)
+	}
+}
+
+func decodePauseQueriesMessage(pb *internal.PauseQueriesMessage, m *pilosa.PauseQueriesMessage) {
+	m.Paused = pb.Paused
+}
+
+func decodeRemapRowsMessage(pb *internal.RemapRowsMessage, m *pilosa.RemapRowsMessage) {
+	m.Index = pb.Index
+	m.Field = pb.Field
+	m.Mapping = make(map[uint64]uint64, len(pb.Sources))
+	for i, src := range pb.Sources {
+		if i < len(pb.Targets) {
+			m.Mapping[src] = pb.Targets[i]
+		}
+	}
+}
+
+func decodeAttrSchema(pb []*internal.Attr) pilosa.AttrSchema {
+	s := make(pilosa.AttrSchema, len(pb))
+	for _, attr := range pb {
+		for name, typ := range attrSchemaTypes {
+			if typ == attr.Type {
+				s[attr.Key] = name
+				break
+			}
+		}
+	}
+	return s
+}
+
 func decodeQueryRequest(pb *internal.QueryRequest, m *pilosa.QueryRequest) {
 	m.Query = pb.Query
 	m.Shards = pb.Shards
@@ -954,6 +1285,20 @@ func decodeQueryRequest(pb *internal.QueryRequest, m *pilosa.QueryRequest) {
 	m.Remote = pb.Remote
 	m.ExcludeRowAttrs = pb.ExcludeRowAttrs
 	m.ExcludeColumns = pb.ExcludeColumns
+	m.View = pb.View
+	m.IncludeKeys = pb.IncludeKeys
+	m.Principal = pb.Principal
+	m.TreatMissingAsEmpty = pb.TreatMissingAsEmpty
+	m.SortResults = pb.SortResults
+	m.CacheResults = pb.CacheResults
+	m.AttrsBestEffort = pb.AttrsBestEffort
+	m.AccountID = pb.AccountID
+	m.IncludeRowAttrs = pb.IncludeRowAttrs
+	m.MaxParallelism = int(pb.MaxParallelism)
+	m.CompressResults = pb.CompressResults
+	if len(pb.AttrFilter) > 0 {
+		m.AttrFilter = decodeAttrs(pb.AttrFilter)
+	}
 }
 
 func decodeImportRequest(pb *internal.ImportRequest, m *pilosa.ImportRequest) {
@@ -965,6 +1310,13 @@ func decodeImportRequest(pb *internal.ImportRequest, m *pilosa.ImportRequest) {
 	m.RowKeys = pb.RowKeys
 	m.ColumnKeys = pb.ColumnKeys
 	m.Timestamps = pb.Timestamps
+	m.AllowForward = pb.AllowForward
+	m.PayloadChecksum = pb.PayloadChecksum
+	m.Principal = pb.Principal
+	m.ReportDuplicates = pb.ReportDuplicates
+	m.Transform = pb.Transform
+	m.WeightField = pb.WeightField
+	m.Weights = pb.Weights
 }
 
 func decodeImportValueRequest(pb *internal.ImportValueRequest, m *pilosa.ImportValueRequest) {
@@ -974,6 +1326,8 @@ func decodeImportValueRequest(pb *internal.ImportValueRequest, m *pilosa.ImportV
 	m.ColumnIDs = pb.ColumnIDs
 	m.ColumnKeys = pb.ColumnKeys
 	m.Values = pb.Values
+	m.Null = pb.Null
+	m.Principal = pb.Principal
 }
 
 func decodeImportRoaringRequest(pb *internal.ImportRoaringRequest, m *pilosa.ImportRoaringRequest) {
@@ -987,6 +1341,10 @@ func decodeImportRoaringRequest(pb *internal.ImportRoaringRequest, m *pilosa.Imp
 
 func decodeImportResponse(pb *internal.ImportResponse, m *pilosa.ImportResponse) {
 	m.Err = pb.Err
+	m.Sequence = pb.Sequence
+	for i := 0; i < len(pb.DuplicateRowIDs) && i < len(pb.DuplicateColumnIDs); i++ {
+		m.Duplicates = append(m.Duplicates, pilosa.Bit{RowID: pb.DuplicateRowIDs[i], ColumnID: pb.DuplicateColumnIDs[i]})
+	}
 }
 
 func decodeBlockDataRequest(pb *internal.BlockDataRequest, m *pilosa.BlockDataRequest) {
@@ -1054,6 +1412,7 @@ const (
 	queryResultTypeRowIDs
 	queryResultTypeGroupCounts
 	queryResultTypeRowIdentifiers
+	queryResultTypePairAttrs
 )
 
 func decodeQueryResult(pb *internal.QueryResult) interface{} {
@@ -1076,6 +1435,8 @@ func decodeQueryResult(pb *internal.QueryResult) interface{} {
 		return decodeRowIdentifiers(pb.RowIdentifiers)
 	case queryResultTypeGroupCounts:
 		return decodeGroupCounts(pb.GroupCounts)
+	case queryResultTypePairAttrs:
+		return decodePairAttrs(pb.Pairs)
 	}
 	panic(fmt.Sprintf("unknown type: %d", pb.Type))
 }
@@ -1111,6 +1472,14 @@ const (
 	attrTypeFloat  = 4
 )
 
+// attrSchemaTypes maps attribute schema type names to attribute data types.
+var attrSchemaTypes = map[string]uint64{
+	pilosa.AttrSchemaTypeString: attrTypeString,
+	pilosa.AttrSchemaTypeInt:    attrTypeInt,
+	pilosa.AttrSchemaTypeBool:   attrTypeBool,
+	pilosa.AttrSchemaTypeFloat:  attrTypeFloat,
+}
+
 func decodeAttr(attr *internal.Attr) (key string, value interface{}) {
 	switch attr.Type {
 	case attrTypeString:
@@ -1174,6 +1543,17 @@ func decodePair(pb *internal.Pair) pilosa.Pair {
 	}
 }
 
+func decodePairAttrs(a []*internal.Pair) []pilosa.PairAttrs {
+	other := make([]pilosa.PairAttrs, len(a))
+	for i := range a {
+		other[i] = pilosa.PairAttrs{
+			Pair:  decodePair(a[i]),
+			Attrs: decodeAttrs(a[i].Attrs),
+		}
+	}
+	return other
+}
+
 func decodeValCount(pb *internal.ValCount) pilosa.ValCount {
 	return pilosa.ValCount{
 		Val:   pb.Val,
@@ -1263,6 +1643,15 @@ func encodePair(p pilosa.Pair) *internal.Pair {
 	}
 }
 
+func encodePairAttrs(a []pilosa.PairAttrs) []*internal.Pair {
+	other := make([]*internal.Pair, len(a))
+	for i := range a {
+		other[i] = encodePair(a[i].Pair)
+		other[i].Attrs = encodeAttrs(a[i].Attrs)
+	}
+	return other
+}
+
 func encodeValCount(vc pilosa.ValCount) *internal.ValCount {
 	return &internal.ValCount{
 		Val:   vc.Val,
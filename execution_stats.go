@@ -0,0 +1,85 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilosa
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// ExecutionStats describes what happened while executing a query, as returned
+// by API.ExplainAnalyze.
+type ExecutionStats struct {
+	// Actuals for each top-level call, in query order.
+	Calls []CallStats `json:"calls"`
+}
+
+// CallStats holds the actuals for a single top-level call of a query. Rows and
+// Containers only count work done on the node which received the query.
+type CallStats struct {
+	// The call, in PQL.
+	Call string `json:"call"`
+
+	// Time taken to execute the call, including any remote execution.
+	Duration time.Duration `json:"duration"`
+
+	// Number of shards the call was mapped to, locally and remotely. Calls
+	// which make several passes over the data count each shard once per pass.
+	Shards uint64 `json:"shards"`
+
+	// Number of rows read from local fragments.
+	Rows uint64 `json:"rows"`
+
+	// Number of roaring containers in the rows read from local fragments.
+	Containers uint64 `json:"containers"`
+}
+
+// callStats counts the work done by a call. It is safe for concurrent use by
+// the goroutines which map the call over shards.
+type callStats struct {
+	shards     uint64
+	rows       uint64
+	containers uint64
+}
+
+// addShards records that the call was mapped to n shards.
+func (s *callStats) addShards(n int) {
+	if s == nil {
+		return
+	}
+	atomic.AddUint64(&s.shards, uint64(n))
+}
+
+// addRow records that row was read from a fragment.
+func (s *callStats) addRow(row *Row) {
+	if s == nil {
+		return
+	}
+	atomic.AddUint64(&s.rows, 1)
+	for _, seg := range row.segments {
+		atomic.AddUint64(&s.containers, uint64(seg.data.Containers.Size()))
+	}
+}
+
+// stats returns the counts recorded for call.
+func (s *callStats) stats(call string, d time.Duration) CallStats {
+	return CallStats{
+		Call:       call,
+		Duration:   d,
+		Shards:     atomic.LoadUint64(&s.shards),
+		Rows:       atomic.LoadUint64(&s.rows),
+		Containers: atomic.LoadUint64(&s.containers),
+	}
+}
@@ -19,8 +19,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/pilosa/pilosa/logger"
 	"github.com/pilosa/pilosa/pql"
 	"github.com/pilosa/pilosa/tracing"
 	"github.com/pkg/errors"
@@ -36,6 +39,14 @@ const (
 
 	columnLabel = "col"
 	rowLabel    = "row"
+
+	// defaultQueryLogSize is the default number of recent queries retained
+	// by the executor.
+	defaultQueryLogSize = 100
+
+	// maxQueryLogPQLLen is the length at which the PQL of a logged query is
+	// truncated, so that large write queries don't bloat the query log.
+	maxQueryLogPQLLen = 1024
 )
 
 // executor recursively executes calls in a PQL query across all shards.
@@ -54,6 +65,13 @@ type executor struct {
 
 	// Stores key/id translation data.
 	TranslateStore TranslateStore
+
+	// Recently executed queries.
+	queryLog *queryLog
+
+	// Logs errors which don't fail the query, such as attribute read errors
+	// with AttrsBestEffort.
+	logger logger.Logger
 }
 
 // executorOption is a functional option type for pilosa.Executor
@@ -69,7 +87,9 @@ func optExecutorInternalQueryClient(c InternalQueryClient) executorOption {
 // newExecutor returns a new instance of Executor.
 func newExecutor(opts ...executorOption) *executor {
 	e := &executor{
-		client: newNopInternalQueryClient(),
+		client:   newNopInternalQueryClient(),
+		queryLog: newQueryLog(defaultQueryLogSize),
+		logger:   logger.NopLogger,
 	}
 	for _, opt := range opts {
 		err := opt(e)
@@ -122,15 +142,39 @@ func (e *executor) Execute(ctx context.Context, index string, q *pql.Query, shar
 		}
 	}
 
+	start := time.Now()
 	results, err := e.execute(ctx, index, q, shards, opt)
+	e.logQuery(idx, q, shards, opt, start, err)
 	if err != nil {
 		return resp, err
 	} else if err := validateQueryContext(ctx); err != nil {
 		return resp, err
 	}
 
+	// Remove columns which do not match the attribute filter. Remote calls
+	// return partial results so the filter is applied by the originating node.
+	if len(opt.AttrFilter) > 0 && !opt.Remote {
+		if err := e.filterResultsByColumnAttrs(idx, results, opt.AttrFilter); err != nil {
+			return resp, errors.Wrap(err, "filtering by column attrs")
+		}
+	}
+
+	// Sort results before translation so that ties are broken by id.
+	// Remote results are merged and sorted by the originating node.
+	if opt.SortResults && !opt.Remote {
+		sortResults(results)
+	}
+
 	resp.Results = results
 
+	// Fill row attributes of TopN pairs if requested. Remote results are
+	// filled by the originating node.
+	if opt.IncludeRowAttrs && !opt.ExcludeRowAttrs && !opt.Remote {
+		if err := e.fillPairRowAttrs(idx, q.Calls, results, opt.AttrsBestEffort); err != nil {
+			return resp, errors.Wrap(err, "reading row attrs")
+		}
+	}
+
 	// Fill column attributes if requested.
 	if opt.ColumnAttrs {
 		// Consolidate all column ids across all calls.
@@ -145,7 +189,10 @@ func (e *executor) Execute(ctx context.Context, index string, q *pql.Query, shar
 
 		// Retrieve column attributes across all calls.
 		columnAttrSets, err := e.readColumnAttrSets(e.Holder.Index(index), columnIDs)
-		if err != nil {
+		if err != nil && opt.AttrsBestEffort {
+			e.logger.Printf("reading column attrs, returning results without them: index=%s, err=%s", index, err)
+			columnAttrSets = nil
+		} else if err != nil {
 			return resp, errors.Wrap(err, "reading column attrs")
 		}
 
@@ -156,7 +203,10 @@ func (e *executor) Execute(ctx context.Context, index string, q *pql.Query, shar
 				if err != nil {
 					return resp, err
 				}
-				col.Key, col.ID = v, 0
+				col.Key = v
+				if !opt.IncludeKeys {
+					col.ID = 0
+				}
 			}
 		}
 
@@ -166,7 +216,7 @@ func (e *executor) Execute(ctx context.Context, index string, q *pql.Query, shar
 	// Translate response objects from ids to keys, if necessary.
 	// No need to translate a remote call.
 	if !opt.Remote {
-		if err := e.translateResults(ctx, index, idx, q.Calls, results); err != nil {
+		if err := e.translateResults(ctx, index, idx, q.Calls, results, opt.IncludeKeys); err != nil {
 			return resp, err
 		} else if err := validateQueryContext(ctx); err != nil {
 			return resp, err
@@ -176,6 +226,56 @@ func (e *executor) Execute(ctx context.Context, index string, q *pql.Query, shar
 	return resp, nil
 }
 
+// sortResults sorts TopN pairs in results by descending count and then by
+// ascending id. Rows and GroupBy results are already sorted by id.
+func sortResults(results []interface{}) {
+	for _, result := range results {
+		if pairs, ok := result.([]Pair); ok {
+			sort.Slice(pairs, func(i, j int) bool {
+				if pairs[i].Count != pairs[j].Count {
+					return pairs[i].Count > pairs[j].Count
+				}
+				return pairs[i].ID < pairs[j].ID
+			})
+		}
+	}
+}
+
+// fillPairRowAttrs replaces each list of pairs in results with a list of
+// PairAttrs holding the attributes of each pair's row. If bestEffort is true,
+// errors reading the attributes of a field are logged and its pairs are left
+// without them.
+func (e *executor) fillPairRowAttrs(idx *Index, calls []*pql.Call, results []interface{}, bestEffort bool) error {
+	for i, result := range results {
+		pairs, ok := result.([]Pair)
+		if !ok {
+			continue
+		}
+		fieldName := callArgString(calls[i], "_field")
+		field := idx.Field(fieldName)
+		if field == nil {
+			continue
+		}
+
+		other := make([]PairAttrs, len(pairs))
+		for j := range pairs {
+			attrs, err := field.RowAttrStore().Attrs(pairs[j].ID)
+			if err != nil && bestEffort {
+				e.logger.Printf("getting row attrs, returning pairs without them: index=%s, field=%s, err=%s", idx.Name(), fieldName, err)
+				other = nil
+				break
+			} else if err != nil {
+				return errors.Wrapf(err, "getting row attrs: field=%s", fieldName)
+			}
+			other[j] = PairAttrs{Pair: pairs[j], Attrs: attrs}
+		}
+		if other != nil {
+			results[i] = other
+		}
+	}
+	return nil
+}
+
 // readColumnAttrSets returns a list of column attribute objects by id.
 func (e *executor) readColumnAttrSets(index *Index, ids []uint64) ([]*ColumnAttrSet, error) {
 	if index == nil {
@@ -199,6 +299,70 @@ func (e *executor) readColumnAttrSets(index *Index, ids []uint64) ([]*ColumnAttr
 	return ax, nil
 }
 
+// filterResultsByColumnAttrs replaces each row in results with a row
+// containing only the columns whose attributes match every key/value pair
+// in filter. The attributes of each column are read at most once.
+func (e *executor) filterResultsByColumnAttrs(index *Index, results []interface{}, filter map[string]interface{}) error {
+	matches := make(map[uint64]bool)
+	for i, result := range results {
+		row, ok := result.(*Row)
+		if !ok {
+			continue
+		}
+
+		var columns []uint64
+		for _, id := range row.Columns() {
+			match, ok := matches[id]
+			if !ok {
+				attrs, err := index.ColumnAttrStore().Attrs(id)
+				if err != nil {
+					return errors.Wrap(err, "getting attrs")
+				}
+				match = attrsMatch(attrs, filter)
+				matches[id] = match
+			}
+			if match {
+				columns = append(columns, id)
+			}
+		}
+
+		other := NewRow(columns...)
+		other.Attrs = row.Attrs
+		results[i] = other
+	}
+	return nil
+}
+
+// attrsMatch returns true if attrs contains every key in filter with an equal
+// value. Numeric values are compared by value regardless of type.
+func attrsMatch(attrs, filter map[string]interface{}) bool {
+	for k, want := range filter {
+		got, ok := attrs[k]
+		if !ok {
+			return false
+		}
+		if gotNum, ok := attrNumber(got); ok {
+			if wantNum, ok := attrNumber(want); !ok || gotNum != wantNum {
+				return false
+			}
+		} else if got != want {
+			return false
+		}
+	}
+	return true
+}
+
+// attrNumber returns v as a float64 if it is a numeric attribute value.
+func attrNumber(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
 func (e *executor) execute(ctx context.Context, index string, q *pql.Query, shards []uint64, opt *execOptions) ([]interface{}, error) {
 	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.execute")
 	defer span.Finish()
@@ -232,11 +396,21 @@ func (e *executor) execute(ctx context.Context, index string, q *pql.Query, shar
 			return nil, err
 		}
 
+		// Record actuals for each call if requested.
+		if opt.Stats != nil {
+			opt.callStats = &callStats{}
+		}
+		start := time.Now()
+
 		v, err := e.executeCall(ctx, index, call, shards, opt)
 		if err != nil {
 			return nil, err
 		}
 		results = append(results, v)
+
+		if opt.Stats != nil {
+			opt.Stats.Calls = append(opt.Stats.Calls, opt.callStats.stats(call.String(), time.Since(start)))
+		}
 	}
 	return results, nil
 }
@@ -255,13 +429,13 @@ func (e *executor) executeCall(ctx context.Context, index string, c *pql.Call, s
 	// Special handling for mutation and top-n calls.
 	switch c.Name {
 	case "Sum":
-		e.Holder.Stats.CountWithCustomTags(c.Name, 1, 1.0, []string{indexTag})
+		e.Holder.statsClient().CountWithCustomTags(c.Name, 1, 1.0, []string{indexTag})
 		return e.executeSum(ctx, index, c, shards, opt)
 	case "Min":
-		e.Holder.Stats.CountWithCustomTags(c.Name, 1, 1.0, []string{indexTag})
+		e.Holder.statsClient().CountWithCustomTags(c.Name, 1, 1.0, []string{indexTag})
 		return e.executeMin(ctx, index, c, shards, opt)
 	case "Max":
-		e.Holder.Stats.CountWithCustomTags(c.Name, 1, 1.0, []string{indexTag})
+		e.Holder.statsClient().CountWithCustomTags(c.Name, 1, 1.0, []string{indexTag})
 		return e.executeMax(ctx, index, c, shards, opt)
 	case "Clear":
 		return e.executeClearBit(ctx, index, c, opt)
@@ -270,7 +444,7 @@ func (e *executor) executeCall(ctx context.Context, index string, c *pql.Call, s
 	case "Store":
 		return e.executeSetRow(ctx, index, c, shards, opt)
 	case "Count":
-		e.Holder.Stats.CountWithCustomTags(c.Name, 1, 1.0, []string{indexTag})
+		e.Holder.statsClient().CountWithCustomTags(c.Name, 1, 1.0, []string{indexTag})
 		return e.executeCount(ctx, index, c, shards, opt)
 	case "Set":
 		return e.executeSet(ctx, index, c, opt)
@@ -279,18 +453,18 @@ func (e *executor) executeCall(ctx context.Context, index string, c *pql.Call, s
 	case "SetColumnAttrs":
 		return nil, e.executeSetColumnAttrs(ctx, index, c, opt)
 	case "TopN":
-		e.Holder.Stats.CountWithCustomTags(c.Name, 1, 1.0, []string{indexTag})
+		e.Holder.statsClient().CountWithCustomTags(c.Name, 1, 1.0, []string{indexTag})
 		return e.executeTopN(ctx, index, c, shards, opt)
 	case "Rows":
-		e.Holder.Stats.CountWithCustomTags(c.Name, 1, 1.0, []string{indexTag})
+		e.Holder.statsClient().CountWithCustomTags(c.Name, 1, 1.0, []string{indexTag})
 		return e.executeRows(ctx, index, c, shards, opt)
 	case "GroupBy":
-		e.Holder.Stats.CountWithCustomTags(c.Name, 1, 1.0, []string{indexTag})
+		e.Holder.statsClient().CountWithCustomTags(c.Name, 1, 1.0, []string{indexTag})
 		return e.executeGroupBy(ctx, index, c, shards, opt)
 	case "Options":
 		return e.executeOptionsCall(ctx, index, c, shards, opt)
 	default:
-		e.Holder.Stats.CountWithCustomTags(c.Name, 1, 1.0, []string{indexTag})
+		e.Holder.statsClient().CountWithCustomTags(c.Name, 1, 1.0, []string{indexTag})
 		return e.executeBitmapCall(ctx, index, c, shards, opt)
 	}
 }
@@ -374,7 +548,7 @@ func (e *executor) executeSum(ctx context.Context, index string, c *pql.Call, sh
 
 	// Execute calls in bulk on each remote node and merge.
 	mapFn := func(shard uint64) (interface{}, error) {
-		return e.executeSumCountShard(ctx, index, c, shard)
+		return e.executeSumCountShard(ctx, index, c, shard, opt)
 	}
 
 	// Merge returned results at coordinating node.
@@ -410,7 +584,7 @@ func (e *executor) executeMin(ctx context.Context, index string, c *pql.Call, sh
 
 	// Execute calls in bulk on each remote node and merge.
 	mapFn := func(shard uint64) (interface{}, error) {
-		return e.executeMinShard(ctx, index, c, shard)
+		return e.executeMinShard(ctx, index, c, shard, opt)
 	}
 
 	// Merge returned results at coordinating node.
@@ -446,7 +620,7 @@ func (e *executor) executeMax(ctx context.Context, index string, c *pql.Call, sh
 
 	// Execute calls in bulk on each remote node and merge.
 	mapFn := func(shard uint64) (interface{}, error) {
-		return e.executeMaxShard(ctx, index, c, shard)
+		return e.executeMaxShard(ctx, index, c, shard, opt)
 	}
 
 	// Merge returned results at coordinating node.
@@ -474,7 +648,7 @@ func (e *executor) executeBitmapCall(ctx context.Context, index string, c *pql.C
 
 	// Execute calls in bulk on each remote node and merge.
 	mapFn := func(shard uint64) (interface{}, error) {
-		return e.executeBitmapCallShard(ctx, index, c, shard)
+		return e.executeBitmapCallShard(ctx, index, c, shard, opt)
 	}
 
 	// Merge returned results at coordinating node.
@@ -504,7 +678,9 @@ func (e *executor) executeBitmapCall(ctx context.Context, index string, c *pql.C
 			if idx != nil {
 				if columnID, ok, err := c.UintArg("_" + columnLabel); ok && err == nil {
 					attrs, err := idx.ColumnAttrStore().Attrs(columnID)
-					if err != nil {
+					if err != nil && opt.AttrsBestEffort {
+						e.logger.Printf("getting column attrs, returning row without them: index=%s, err=%s", index, err)
+					} else if err != nil {
 						return nil, errors.Wrap(err, "getting column attrs")
 					}
 					row.Attrs = attrs
@@ -519,7 +695,9 @@ func (e *executor) executeBitmapCall(ctx context.Context, index string, c *pql.C
 							return nil, errors.Wrap(err, "getting row")
 						}
 						attrs, err := fr.RowAttrStore().Attrs(rowID)
-						if err != nil {
+						if err != nil && opt.AttrsBestEffort {
+							e.logger.Printf("getting row attrs, returning row without them: index=%s, field=%s, err=%s", index, fieldName, err)
+						} else if err != nil {
 							return nil, errors.Wrap(err, "getting row attrs")
 						}
 						row.Attrs = attrs
@@ -537,7 +715,7 @@ func (e *executor) executeBitmapCall(ctx context.Context, index string, c *pql.C
 }
 
 // executeBitmapCallShard executes a bitmap call for a single shard.
-func (e *executor) executeBitmapCallShard(ctx context.Context, index string, c *pql.Call, shard uint64) (*Row, error) {
+func (e *executor) executeBitmapCallShard(ctx context.Context, index string, c *pql.Call, shard uint64, opt *execOptions) (*Row, error) {
 	if err := validateQueryContext(ctx); err != nil {
 		return nil, err
 	}
@@ -547,30 +725,30 @@ func (e *executor) executeBitmapCallShard(ctx context.Context, index string, c *
 
 	switch c.Name {
 	case "Row", "Range":
-		return e.executeRowShard(ctx, index, c, shard)
+		return e.executeRowShard(ctx, index, c, shard, opt)
 	case "Difference":
-		return e.executeDifferenceShard(ctx, index, c, shard)
+		return e.executeDifferenceShard(ctx, index, c, shard, opt)
 	case "Intersect":
-		return e.executeIntersectShard(ctx, index, c, shard)
+		return e.executeIntersectShard(ctx, index, c, shard, opt)
 	case "Union":
-		return e.executeUnionShard(ctx, index, c, shard)
+		return e.executeUnionShard(ctx, index, c, shard, opt)
 	case "Xor":
-		return e.executeXorShard(ctx, index, c, shard)
+		return e.executeXorShard(ctx, index, c, shard, opt)
 	case "Not":
-		return e.executeNotShard(ctx, index, c, shard)
+		return e.executeNotShard(ctx, index, c, shard, opt)
 	default:
 		return nil, fmt.Errorf("unknown call: %s", c.Name)
 	}
 }
 
 // executeSumCountShard calculates the sum and count for bsiGroups on a shard.
-func (e *executor) executeSumCountShard(ctx context.Context, index string, c *pql.Call, shard uint64) (ValCount, error) {
+func (e *executor) executeSumCountShard(ctx context.Context, index string, c *pql.Call, shard uint64, opt *execOptions) (ValCount, error) {
 	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeSumCountShard")
 	defer span.Finish()
 
 	var filter *Row
 	if len(c.Children) == 1 {
-		row, err := e.executeBitmapCallShard(ctx, index, c.Children[0], shard)
+		row, err := e.executeBitmapCallShard(ctx, index, c.Children[0], shard, opt)
 		if err != nil {
 			return ValCount{}, errors.Wrap(err, "executing bitmap call")
 		}
@@ -605,13 +783,13 @@ func (e *executor) executeSumCountShard(ctx context.Context, index string, c *pq
 }
 
 // executeMinShard calculates the min for bsiGroups on a shard.
-func (e *executor) executeMinShard(ctx context.Context, index string, c *pql.Call, shard uint64) (ValCount, error) {
+func (e *executor) executeMinShard(ctx context.Context, index string, c *pql.Call, shard uint64, opt *execOptions) (ValCount, error) {
 	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeMinShard")
 	defer span.Finish()
 
 	var filter *Row
 	if len(c.Children) == 1 {
-		row, err := e.executeBitmapCallShard(ctx, index, c.Children[0], shard)
+		row, err := e.executeBitmapCallShard(ctx, index, c.Children[0], shard, opt)
 		if err != nil {
 			return ValCount{}, err
 		}
@@ -646,13 +824,13 @@ func (e *executor) executeMinShard(ctx context.Context, index string, c *pql.Cal
 }
 
 // executeMaxShard calculates the max for bsiGroups on a shard.
-func (e *executor) executeMaxShard(ctx context.Context, index string, c *pql.Call, shard uint64) (ValCount, error) {
+func (e *executor) executeMaxShard(ctx context.Context, index string, c *pql.Call, shard uint64, opt *execOptions) (ValCount, error) {
 	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeMaxShard")
 	defer span.Finish()
 
 	var filter *Row
 	if len(c.Children) == 1 {
-		row, err := e.executeBitmapCallShard(ctx, index, c.Children[0], shard)
+		row, err := e.executeBitmapCallShard(ctx, index, c.Children[0], shard, opt)
 		if err != nil {
 			return ValCount{}, err
 		}
@@ -737,7 +915,7 @@ func (e *executor) executeTopNShards(ctx context.Context, index string, c *pql.C
 
 	// Execute calls in bulk on each remote node and merge.
 	mapFn := func(shard uint64) (interface{}, error) {
-		return e.executeTopNShard(ctx, index, c, shard)
+		return e.executeTopNShard(ctx, index, c, shard, opt)
 	}
 
 	// Merge returned results at coordinating node.
@@ -759,7 +937,7 @@ func (e *executor) executeTopNShards(ctx context.Context, index string, c *pql.C
 }
 
 // executeTopNShard executes a TopN call for a single shard.
-func (e *executor) executeTopNShard(ctx context.Context, index string, c *pql.Call, shard uint64) ([]Pair, error) {
+func (e *executor) executeTopNShard(ctx context.Context, index string, c *pql.Call, shard uint64, opt *execOptions) ([]Pair, error) {
 	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeTopNShard")
 	defer span.Finish()
 
@@ -786,7 +964,7 @@ func (e *executor) executeTopNShard(ctx context.Context, index string, c *pql.Ca
 	// Retrieve bitmap used to intersect.
 	var src *Row
 	if len(c.Children) == 1 {
-		row, err := e.executeBitmapCallShard(ctx, index, c.Children[0], shard)
+		row, err := e.executeBitmapCallShard(ctx, index, c.Children[0], shard, opt)
 		if err != nil {
 			return nil, err
 		}
@@ -824,7 +1002,7 @@ func (e *executor) executeTopNShard(ctx context.Context, index string, c *pql.Ca
 }
 
 // executeDifferenceShard executes a difference() call for a local shard.
-func (e *executor) executeDifferenceShard(ctx context.Context, index string, c *pql.Call, shard uint64) (*Row, error) {
+func (e *executor) executeDifferenceShard(ctx context.Context, index string, c *pql.Call, shard uint64, opt *execOptions) (*Row, error) {
 	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeDifferenceShard")
 	defer span.Finish()
 
@@ -833,7 +1011,7 @@ func (e *executor) executeDifferenceShard(ctx context.Context, index string, c *
 		return nil, fmt.Errorf("empty Difference query is currently not supported")
 	}
 	for i, input := range c.Children {
-		row, err := e.executeBitmapCallShard(ctx, index, input, shard)
+		row, err := e.executeBitmapCallShard(ctx, index, input, shard, opt)
 		if err != nil {
 			return nil, err
 		}
@@ -944,7 +1122,7 @@ func (e *executor) executeGroupBy(ctx context.Context, index string, c *pql.Call
 
 	// Execute calls in bulk on each remote node and merge.
 	mapFn := func(shard uint64) (interface{}, error) {
-		return e.executeGroupByShard(ctx, index, c, filter, shard, childRows)
+		return e.executeGroupByShard(ctx, index, c, filter, shard, childRows, opt)
 	}
 	// Merge returned results at coordinating node.
 	reduceFn := func(prev, v interface{}) interface{} {
@@ -1057,10 +1235,10 @@ func (g GroupCount) Compare(o GroupCount) int {
 	return 0
 }
 
-func (e *executor) executeGroupByShard(ctx context.Context, index string, c *pql.Call, filter *pql.Call, shard uint64, childRows []RowIDs) (_ []GroupCount, err error) {
+func (e *executor) executeGroupByShard(ctx context.Context, index string, c *pql.Call, filter *pql.Call, shard uint64, childRows []RowIDs, opt *execOptions) (_ []GroupCount, err error) {
 	var filterRow *Row
 	if filter != nil {
-		if filterRow, err = e.executeBitmapCallShard(ctx, index, filter, shard); err != nil {
+		if filterRow, err = e.executeBitmapCallShard(ctx, index, filter, shard, opt); err != nil {
 			return nil, errors.Wrapf(err, "executing group by filter for shard %d", shard)
 		}
 	}
@@ -1188,7 +1366,7 @@ func (e *executor) executeRowsShard(_ context.Context, index string, fieldName s
 	return frag.rows(start, filters...), nil
 }
 
-func (e *executor) executeRowShard(ctx context.Context, index string, c *pql.Call, shard uint64) (*Row, error) {
+func (e *executor) executeRowShard(ctx context.Context, index string, c *pql.Call, shard uint64, opt *execOptions) (*Row, error) {
 	span, _ := tracing.StartSpanFromContext(ctx, "Executor.executeRowShard")
 	defer span.Finish()
 
@@ -1196,6 +1374,13 @@ func (e *executor) executeRowShard(ctx context.Context, index string, c *pql.Cal
 		e.Holder.Logger.Printf("DEPRECATED: Range() is deprecated, please use Row() instead.")
 	}
 
+	// Treat references to missing fields as empty rows, if requested.
+	if opt.TreatMissingAsEmpty {
+		if fieldName, err := c.FieldArg(); err == nil && e.Holder.Field(index, fieldName) == nil {
+			return NewRow(), nil
+		}
+	}
+
 	// Handle bsiGroup ranges differently.
 	if c.HasConditionArg() {
 		return e.executeRowBSIGroupShard(ctx, index, c, shard)
@@ -1254,13 +1439,29 @@ func (e *executor) executeRowShard(ctx context.Context, index string, c *pql.Cal
 		}
 	}
 
+	// Read from the requested view, if set, regardless of time arguments.
+	if opt.View != "" {
+		if f.view(opt.View) == nil {
+			return nil, ErrViewNotFound
+		}
+		frag := e.Holder.fragment(index, fieldName, opt.View, shard)
+		if frag == nil {
+			return NewRow(), nil
+		}
+		row := frag.row(rowID)
+		opt.callStats.addRow(row)
+		return row, nil
+	}
+
 	// Simply return row if times are not set.
 	if c.Name == "Row" && fromTime.IsZero() && toTime.IsZero() {
 		frag := e.Holder.fragment(index, fieldName, viewStandard, shard)
 		if frag == nil {
 			return NewRow(), nil
 		}
-		return frag.row(rowID), nil
+		row := frag.row(rowID)
+		opt.callStats.addRow(row)
+		return row, nil
 	}
 
 	// If no quantum exists then return an empty bitmap.
@@ -1283,9 +1484,11 @@ func (e *executor) executeRowShard(ctx context.Context, index string, c *pql.Cal
 		if f == nil {
 			continue
 		}
-		row = row.Union(f.row(rowID))
+		viewRow := f.row(rowID)
+		opt.callStats.addRow(viewRow)
+		row = row.Union(viewRow)
 	}
-	f.Stats.Count("range", 1, 1.0)
+	f.statsClient().Count("range", 1, 1.0)
 	return row, nil
 
 }
@@ -1418,13 +1621,13 @@ func (e *executor) executeRowBSIGroupShard(ctx context.Context, index string, c
 			return frag.notNull(bsig.BitDepth())
 		}
 
-		f.Stats.Count("range:bsigroup", 1, 1.0)
+		f.statsClient().Count("range:bsigroup", 1, 1.0)
 		return frag.rangeOp(cond.Op, bsig.BitDepth(), baseValue)
 	}
 }
 
 // executeIntersectShard executes a intersect() call for a local shard.
-func (e *executor) executeIntersectShard(ctx context.Context, index string, c *pql.Call, shard uint64) (*Row, error) {
+func (e *executor) executeIntersectShard(ctx context.Context, index string, c *pql.Call, shard uint64, opt *execOptions) (*Row, error) {
 	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeIntersectShard")
 	defer span.Finish()
 
@@ -1433,7 +1636,7 @@ func (e *executor) executeIntersectShard(ctx context.Context, index string, c *p
 		return nil, fmt.Errorf("empty Intersect query is currently not supported")
 	}
 	for i, input := range c.Children {
-		row, err := e.executeBitmapCallShard(ctx, index, input, shard)
+		row, err := e.executeBitmapCallShard(ctx, index, input, shard, opt)
 		if err != nil {
 			return nil, err
 		}
@@ -1449,13 +1652,13 @@ func (e *executor) executeIntersectShard(ctx context.Context, index string, c *p
 }
 
 // executeUnionShard executes a union() call for a local shard.
-func (e *executor) executeUnionShard(ctx context.Context, index string, c *pql.Call, shard uint64) (*Row, error) {
+func (e *executor) executeUnionShard(ctx context.Context, index string, c *pql.Call, shard uint64, opt *execOptions) (*Row, error) {
 	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeUnionShard")
 	defer span.Finish()
 
 	other := NewRow()
 	for i, input := range c.Children {
-		row, err := e.executeBitmapCallShard(ctx, index, input, shard)
+		row, err := e.executeBitmapCallShard(ctx, index, input, shard, opt)
 		if err != nil {
 			return nil, err
 		}
@@ -1471,13 +1674,13 @@ func (e *executor) executeUnionShard(ctx context.Context, index string, c *pql.C
 }
 
 // executeXorShard executes a xor() call for a local shard.
-func (e *executor) executeXorShard(ctx context.Context, index string, c *pql.Call, shard uint64) (*Row, error) {
+func (e *executor) executeXorShard(ctx context.Context, index string, c *pql.Call, shard uint64, opt *execOptions) (*Row, error) {
 	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeXorShard")
 	defer span.Finish()
 
 	other := NewRow()
 	for i, input := range c.Children {
-		row, err := e.executeBitmapCallShard(ctx, index, input, shard)
+		row, err := e.executeBitmapCallShard(ctx, index, input, shard, opt)
 		if err != nil {
 			return nil, err
 		}
@@ -1493,7 +1696,7 @@ func (e *executor) executeXorShard(ctx context.Context, index string, c *pql.Cal
 }
 
 // executeNotShard executes a not() call for a local shard.
-func (e *executor) executeNotShard(ctx context.Context, index string, c *pql.Call, shard uint64) (*Row, error) {
+func (e *executor) executeNotShard(ctx context.Context, index string, c *pql.Call, shard uint64, opt *execOptions) (*Row, error) {
 	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeNotShard")
 	defer span.Finish()
 
@@ -1519,7 +1722,7 @@ func (e *executor) executeNotShard(ctx context.Context, index string, c *pql.Cal
 		existenceRow = existenceFrag.row(0)
 	}
 
-	row, err := e.executeBitmapCallShard(ctx, index, c.Children[0], shard)
+	row, err := e.executeBitmapCallShard(ctx, index, c.Children[0], shard, opt)
 	if err != nil {
 		return nil, err
 	}
@@ -1540,7 +1743,7 @@ func (e *executor) executeCount(ctx context.Context, index string, c *pql.Call,
 
 	// Execute calls in bulk on each remote node and merge.
 	mapFn := func(shard uint64) (interface{}, error) {
-		row, err := e.executeBitmapCallShard(ctx, index, c.Children[0], shard)
+		row, err := e.executeBitmapCallShard(ctx, index, c.Children[0], shard, opt)
 		if err != nil {
 			return 0, err
 		}
@@ -1624,7 +1827,7 @@ func (e *executor) executeClearBitField(ctx context.Context, index string, c *pq
 		}
 
 		// Forward call to remote node otherwise.
-		if res, err := e.remoteExec(ctx, node, index, &pql.Query{Calls: []*pql.Call{c}}, nil); err != nil {
+		if res, err := e.remoteExec(ctx, node, index, &pql.Query{Calls: []*pql.Call{c}}, nil, opt); err != nil {
 			return false, err
 		} else {
 			ret = res[0].(bool)
@@ -1730,7 +1933,7 @@ func (e *executor) executeSetRow(ctx context.Context, index string, c *pql.Call,
 
 	// Execute calls in bulk on each remote node and merge.
 	mapFn := func(shard uint64) (interface{}, error) {
-		return e.executeSetRowShard(ctx, index, c, shard)
+		return e.executeSetRowShard(ctx, index, c, shard, opt)
 	}
 
 	// Merge returned results at coordinating node.
@@ -1747,7 +1950,7 @@ func (e *executor) executeSetRow(ctx context.Context, index string, c *pql.Call,
 }
 
 // executeSetRowShard executes a SetRow() call for a single shard.
-func (e *executor) executeSetRowShard(ctx context.Context, index string, c *pql.Call, shard uint64) (bool, error) {
+func (e *executor) executeSetRowShard(ctx context.Context, index string, c *pql.Call, shard uint64, opt *execOptions) (bool, error) {
 	fieldName, err := c.FieldArg()
 	if err != nil {
 		return false, errors.New("Store() argument required: field")
@@ -1769,7 +1972,7 @@ func (e *executor) executeSetRowShard(ctx context.Context, index string, c *pql.
 	// Retrieve source row.
 	var src *Row
 	if len(c.Children) == 1 {
-		row, err := e.executeBitmapCallShard(ctx, index, c.Children[0], shard)
+		row, err := e.executeBitmapCallShard(ctx, index, c.Children[0], shard, opt)
 		if err != nil {
 			return false, errors.Wrap(err, "getting source row")
 		}
@@ -1830,6 +2033,14 @@ func (e *executor) executeSet(ctx context.Context, index string, c *pql.Call, op
 		return false, ErrFieldNotFound
 	}
 
+	// Reject rows which the field does not allow before anything is set.
+	if rowID, ok, err := c.UintArg(fieldName); err == nil && ok {
+		fieldOptions := f.Options()
+		if rows := fieldOptions.rowsNotAllowed([]uint64{rowID}); len(rows) > 0 {
+			return false, NewBadRequestError(errors.Errorf("rows not allowed in field %s: %v", fieldName, rows))
+		}
+	}
+
 	// Set column on existence field.
 	if ef := idx.existenceField(); ef != nil {
 		if _, err := ef.SetBit(0, colID, nil); err != nil {
@@ -1897,7 +2108,7 @@ func (e *executor) executeSetBitField(ctx context.Context, index string, c *pql.
 		}
 
 		// Forward call to remote node otherwise.
-		if res, err := e.remoteExec(ctx, node, index, &pql.Query{Calls: []*pql.Call{c}}, nil); err != nil {
+		if res, err := e.remoteExec(ctx, node, index, &pql.Query{Calls: []*pql.Call{c}}, nil, opt); err != nil {
 			return false, err
 		} else {
 			ret = res[0].(bool)
@@ -1932,7 +2143,7 @@ func (e *executor) executeSetValueField(ctx context.Context, index string, c *pq
 		}
 
 		// Forward call to remote node otherwise.
-		if res, err := e.remoteExec(ctx, node, index, &pql.Query{Calls: []*pql.Call{c}}, nil); err != nil {
+		if res, err := e.remoteExec(ctx, node, index, &pql.Query{Calls: []*pql.Call{c}}, nil, opt); err != nil {
 			return false, err
 		} else {
 			ret = res[0].(bool)
@@ -1974,7 +2185,7 @@ func (e *executor) executeSetRowAttrs(ctx context.Context, index string, c *pql.
 	if err := field.RowAttrStore().SetAttrs(rowID, attrs); err != nil {
 		return err
 	}
-	field.Stats.Count("SetRowAttrs", 1, 1.0)
+	field.statsClient().Count("SetRowAttrs", 1, 1.0)
 
 	// Do not forward call if this is already being forwarded.
 	if opt.Remote {
@@ -1986,7 +2197,7 @@ func (e *executor) executeSetRowAttrs(ctx context.Context, index string, c *pql.
 	resp := make(chan error, len(nodes))
 	for _, node := range nodes {
 		go func(node *Node) {
-			_, err := e.remoteExec(ctx, node, index, &pql.Query{Calls: []*pql.Call{c}}, nil)
+			_, err := e.remoteExec(ctx, node, index, &pql.Query{Calls: []*pql.Call{c}}, nil, opt)
 			resp <- err
 		}(node)
 	}
@@ -2068,7 +2279,7 @@ func (e *executor) executeBulkSetRowAttrs(ctx context.Context, index string, cal
 		if err := field.RowAttrStore().SetBulkAttrs(fieldMap); err != nil {
 			return nil, err
 		}
-		field.Stats.Count("SetRowAttrs", 1, 1.0)
+		field.statsClient().Count("SetRowAttrs", 1, 1.0)
 	}
 
 	// Do not forward call if this is already being forwarded.
@@ -2081,7 +2292,7 @@ func (e *executor) executeBulkSetRowAttrs(ctx context.Context, index string, cal
 	resp := make(chan error, len(nodes))
 	for _, node := range nodes {
 		go func(node *Node) {
-			_, err := e.remoteExec(ctx, node, index, &pql.Query{Calls: calls}, nil)
+			_, err := e.remoteExec(ctx, node, index, &pql.Query{Calls: calls}, nil, opt)
 			resp <- err
 		}(node)
 	}
@@ -2118,11 +2329,16 @@ func (e *executor) executeSetColumnAttrs(ctx context.Context, index string, c *p
 	delete(attrs, "_"+columnLabel)
 	delete(attrs, "field")
 
+	// Ensure values match the registered attribute types.
+	if err := idx.AttrSchema().validate(attrs); err != nil {
+		return err
+	}
+
 	// Set attributes.
 	if err := idx.ColumnAttrStore().SetAttrs(col, attrs); err != nil {
 		return err
 	}
-	idx.Stats.Count("SetProfileAttrs", 1, 1.0)
+	idx.statsClient().Count("SetProfileAttrs", 1, 1.0)
 	// Do not forward call if this is already being forwarded.
 	if opt.Remote {
 		return nil
@@ -2133,7 +2349,7 @@ func (e *executor) executeSetColumnAttrs(ctx context.Context, index string, c *p
 	resp := make(chan error, len(nodes))
 	for _, node := range nodes {
 		go func(node *Node) {
-			_, err := e.remoteExec(ctx, node, index, &pql.Query{Calls: []*pql.Call{c}}, nil)
+			_, err := e.remoteExec(ctx, node, index, &pql.Query{Calls: []*pql.Call{c}}, nil, opt)
 			resp <- err
 		}(node)
 	}
@@ -2149,15 +2365,19 @@ func (e *executor) executeSetColumnAttrs(ctx context.Context, index string, c *p
 }
 
 // remoteExec executes a PQL query remotely for a set of shards on a node.
-func (e *executor) remoteExec(ctx context.Context, node *Node, index string, q *pql.Query, shards []uint64) (results []interface{}, err error) { // nolint: interfacer
+func (e *executor) remoteExec(ctx context.Context, node *Node, index string, q *pql.Query, shards []uint64, opt *execOptions) (results []interface{}, err error) { // nolint: interfacer
 	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeExec")
 	defer span.Finish()
 
 	// Encode request object.
 	pbreq := &QueryRequest{
-		Query:  q.String(),
-		Shards: shards,
-		Remote: true,
+		Query:               q.String(),
+		Shards:              shards,
+		Remote:              true,
+		View:                opt.View,
+		TreatMissingAsEmpty: opt.TreatMissingAsEmpty,
+		AttrsBestEffort:     opt.AttrsBestEffort,
+		MaxParallelism:      opt.MaxParallelism,
 	}
 
 	pb, err := e.client.QueryNode(ctx, &node.URI, index, pbreq)
@@ -2264,14 +2484,18 @@ func (e *executor) mapper(ctx context.Context, ch chan mapResponse, nodes []*Nod
 
 	// Execute each node in a separate goroutine.
 	for n, nodeShards := range m {
+		opt.callStats.addShards(len(nodeShards))
+		if opt.shardsScanned != nil {
+			atomic.AddUint64(opt.shardsScanned, uint64(len(nodeShards)))
+		}
 		go func(n *Node, nodeShards []uint64) {
 			resp := mapResponse{node: n, shards: nodeShards}
 
 			// Send local shards to mapper, otherwise remote exec.
 			if n.ID == e.Node.ID {
-				resp.result, resp.err = e.mapperLocal(ctx, nodeShards, mapFn, reduceFn)
+				resp.result, resp.err = e.mapperLocal(ctx, nodeShards, opt.MaxParallelism, mapFn, reduceFn)
 			} else if !opt.Remote {
-				results, err := e.remoteExec(ctx, n, index, &pql.Query{Calls: []*pql.Call{c}}, nodeShards)
+				results, err := e.remoteExec(ctx, n, index, &pql.Query{Calls: []*pql.Call{c}}, nodeShards, opt)
 				if len(results) > 0 {
 					resp.result = results[0]
 				}
@@ -2289,23 +2513,45 @@ func (e *executor) mapper(ctx context.Context, ch chan mapResponse, nodes []*Nod
 	return nil
 }
 
-// mapperLocal performs map & reduce entirely on the local node.
-func (e *executor) mapperLocal(ctx context.Context, shards []uint64, mapFn mapFunc, reduceFn reduceFunc) (interface{}, error) {
+// mapperLocal performs map & reduce entirely on the local node. If
+// maxParallelism is positive, a pool of at most that many workers maps the
+// shards, otherwise every shard is mapped concurrently.
+func (e *executor) mapperLocal(ctx context.Context, shards []uint64, maxParallelism int, mapFn mapFunc, reduceFn reduceFunc) (interface{}, error) {
 	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.mapperLocal")
 	defer span.Finish()
 
 	ch := make(chan mapResponse, len(shards))
 
-	for _, shard := range shards {
-		go func(shard uint64) {
-			result, err := mapFn(shard)
+	if maxParallelism > 0 && maxParallelism < len(shards) {
+		work := make(chan uint64, len(shards))
+		for _, shard := range shards {
+			work <- shard
+		}
+		close(work)
 
-			// Return response to the channel.
-			select {
-			case <-ctx.Done():
-			case ch <- mapResponse{result: result, err: err}:
-			}
-		}(shard)
+		for i := 0; i < maxParallelism; i++ {
+			go func() {
+				for shard := range work {
+					if ctx.Err() != nil {
+						return
+					}
+					result, err := mapFn(shard)
+					ch <- mapResponse{result: result, err: err}
+				}
+			}()
+		}
+	} else {
+		for _, shard := range shards {
+			go func(shard uint64) {
+				result, err := mapFn(shard)
+
+				// Return response to the channel.
+				select {
+				case <-ctx.Done():
+				case ch <- mapResponse{result: result, err: err}:
+				}
+			}(shard)
+		}
 	}
 
 	// Reduce results
@@ -2490,12 +2736,14 @@ func (e *executor) translateGroupByCall(index string, idx *Index, c *pql.Call) e
 	return nil
 }
 
-func (e *executor) translateResults(ctx context.Context, index string, idx *Index, calls []*pql.Call, results []interface{}) (err error) {
+// translateResults replaces ids in results with keys for keyed indexes and
+// fields. If includeKeys is true, the ids are kept alongside the keys.
+func (e *executor) translateResults(ctx context.Context, index string, idx *Index, calls []*pql.Call, results []interface{}, includeKeys bool) (err error) {
 	span, _ := tracing.StartSpanFromContext(ctx, "Executor.translateResults")
 	defer span.Finish()
 
 	for i := range results {
-		results[i], err = e.translateResult(index, idx, calls[i], results[i])
+		results[i], err = e.translateResult(index, idx, calls[i], results[i], includeKeys)
 		if err != nil {
 			return err
 		}
@@ -2503,11 +2751,14 @@ func (e *executor) translateResults(ctx context.Context, index string, idx *Inde
 	return nil
 }
 
-func (e *executor) translateResult(index string, idx *Index, call *pql.Call, result interface{}) (interface{}, error) {
+func (e *executor) translateResult(index string, idx *Index, call *pql.Call, result interface{}, includeKeys bool) (interface{}, error) {
 	switch result := result.(type) {
 	case *Row:
 		if idx.Keys() {
 			other := &Row{Attrs: result.Attrs}
+			if includeKeys {
+				other.segments = result.segments
+			}
 			for _, segment := range result.Segments() {
 				for _, col := range segment.Columns() {
 					key, err := e.TranslateStore.TranslateColumnToString(index, col)
@@ -2534,11 +2785,29 @@ func (e *executor) translateResult(index string, idx *Index, call *pql.Call, res
 						return nil, err
 					}
 					other[i] = Pair{Key: key, Count: result[i].Count}
+					if includeKeys {
+						other[i].ID = result[i].ID
+					}
 				}
 				return other, nil
 			}
 		}
 
+	case []PairAttrs:
+		pairs := make([]Pair, len(result))
+		for i := range result {
+			pairs[i] = result[i].Pair
+		}
+		translated, err := e.translateResult(index, idx, call, pairs, includeKeys)
+		if err != nil {
+			return nil, err
+		}
+		other := make([]PairAttrs, len(result))
+		for i, p := range translated.([]Pair) {
+			other[i] = PairAttrs{Pair: p, Attrs: result[i].Attrs}
+		}
+		return other, nil
+
 	case []GroupCount:
 		other := make([]GroupCount, 0)
 		for _, gl := range result {
@@ -2587,6 +2856,9 @@ func (e *executor) translateResult(index string, idx *Index, call *pql.Call, res
 				}
 				other.Keys[i] = key
 			}
+			if includeKeys {
+				other.Rows = result
+			}
 		} else {
 			other.Rows = result
 		}
@@ -2635,6 +2907,36 @@ type execOptions struct {
 	ExcludeRowAttrs bool
 	ExcludeColumns  bool
 	ColumnAttrs     bool
+	View            string
+
+	// Only columns with attributes matching every pair are returned.
+	AttrFilter map[string]interface{}
+
+	// Keep ids in results when translating them to keys.
+	IncludeKeys bool
+
+	// Return empty rows for Row calls on missing fields.
+	TreatMissingAsEmpty bool
+
+	// Sort results into a deterministic order.
+	SortResults bool
+
+	// Log attribute read errors and omit the attributes instead of failing.
+	AttrsBestEffort bool
+
+	// Fill the row attributes of TopN pairs.
+	IncludeRowAttrs bool
+
+	// Maximum number of local shards computed concurrently, if positive.
+	MaxParallelism int
+
+	// If set, actuals for each top-level call are appended to Stats.
+	Stats     *ExecutionStats
+	callStats *callStats
+
+	// If set, the number of shards each call is mapped to is added to
+	// shardsScanned. Accessed atomically.
+	shardsScanned *uint64
 }
 
 // hasOnlySetRowAttrs returns true if calls only contains SetRowAttrs() calls.
@@ -2904,3 +3206,89 @@ func (gbi *groupByIterator) Next() (ret GroupCount, done bool) {
 
 	return ret, false
 }
+
+// logQuery records an executed query in the executor's query log.
+func (e *executor) logQuery(idx *Index, q *pql.Query, shards []uint64, opt *execOptions, start time.Time, err error) {
+	if e.queryLog == nil {
+		return
+	}
+
+	// An empty shard list means the query ran against all available shards.
+	shardN := len(shards)
+	if shardN == 0 && needsShards(q.Calls) {
+		shardN = int(idx.AvailableShards().Count())
+	}
+
+	pql := q.String()
+	if len(pql) > maxQueryLogPQLLen {
+		pql = pql[:maxQueryLogPQLLen] + "..."
+	}
+
+	entry := QueryLogEntry{
+		Index:    idx.Name(),
+		PQL:      pql,
+		Start:    start,
+		Duration: time.Since(start),
+		ShardN:   shardN,
+		Remote:   opt.Remote,
+	}
+	if err != nil {
+		entry.Err = err.Error()
+	}
+	e.queryLog.add(entry)
+}
+
+// QueryLogEntry describes a query executed by a node.
+type QueryLogEntry struct {
+	Index    string        `json:"index"`
+	PQL      string        `json:"pql"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	ShardN   int           `json:"shardN"`
+	Remote   bool          `json:"remote"`
+	Err      string        `json:"error,omitempty"`
+}
+
+// queryLog is a fixed size ring buffer of recently executed queries.
+type queryLog struct {
+	mu      sync.Mutex
+	entries []QueryLogEntry
+	next    int // position of the next entry to write
+	n       int // number of entries written, up to len(entries)
+}
+
+// newQueryLog returns a query log which retains up to size entries.
+func newQueryLog(size int) *queryLog {
+	if size < 0 {
+		size = 0
+	}
+	return &queryLog{entries: make([]QueryLogEntry, size)}
+}
+
+// add appends an entry to the log, overwriting the oldest entry if full.
+func (l *queryLog) add(entry QueryLogEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.entries) == 0 {
+		return
+	}
+	l.entries[l.next] = entry
+	l.next = (l.next + 1) % len(l.entries)
+	if l.n < len(l.entries) {
+		l.n++
+	}
+}
+
+// recent returns up to n of the most recent entries, newest first.
+func (l *queryLog) recent(n int) []QueryLogEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if n > l.n {
+		n = l.n
+	}
+	entries := make([]QueryLogEntry, n)
+	for i := range entries {
+		entries[i] = l.entries[(l.next-1-i+len(l.entries))%len(l.entries)]
+	}
+	return entries
+}
@@ -222,3 +222,35 @@ func TestFieldRowMarshalJSON(t *testing.T) {
 		t.Fatalf("unexpected json: %s", b)
 	}
 }
+
+func TestQueryLog(t *testing.T) {
+	l := newQueryLog(3)
+	if entries := l.recent(10); len(entries) != 0 {
+		t.Fatalf("unexpected entries: %v", entries)
+	}
+
+	for i := 0; i < 5; i++ {
+		l.add(QueryLogEntry{PQL: fmt.Sprintf("Row(f=%d)", i)})
+	}
+
+	entries := l.recent(10)
+	if len(entries) != 3 {
+		t.Fatalf("unexpected entry count: %d", len(entries))
+	}
+	for i, exp := range []string{"Row(f=4)", "Row(f=3)", "Row(f=2)"} {
+		if entries[i].PQL != exp {
+			t.Fatalf("unexpected entry %d: %s", i, entries[i].PQL)
+		}
+	}
+
+	if entries := l.recent(1); len(entries) != 1 || entries[0].PQL != "Row(f=4)" {
+		t.Fatalf("unexpected entries: %v", entries)
+	}
+
+	// A zero-sized log retains nothing.
+	l = newQueryLog(0)
+	l.add(QueryLogEntry{PQL: "Row(f=1)"})
+	if entries := l.recent(1); len(entries) != 0 {
+		t.Fatalf("unexpected entries: %v", entries)
+	}
+}
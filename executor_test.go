@@ -1686,6 +1686,46 @@ func TestExecutor_Execute_Row_Range(t *testing.T) {
 	})
 }
 
+// Ensure a row query can be restricted to a single view.
+func TestExecutor_Execute_Row_View(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	cmd := c[0]
+
+	cmd.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	cmd.MustCreateField(t, "i", "f", pilosa.OptFieldTypeTime(pilosa.TimeQuantum("Y")))
+
+	if _, err := cmd.API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `
+		Set(1, f=1, 2000-01-01T00:00)
+		Set(2, f=1, 2001-01-01T00:00)
+		Set(3, f=1, 2001-06-01T00:00)`,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Row", func(t *testing.T) {
+		if res, err := cmd.API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `Row(f=1)`, View: "standard_2001"}); err != nil {
+			t.Fatal(err)
+		} else if columns := res.Results[0].(*pilosa.Row).Columns(); !reflect.DeepEqual(columns, []uint64{2, 3}) {
+			t.Fatalf("unexpected columns: %+v", columns)
+		}
+	})
+
+	t.Run("Count", func(t *testing.T) {
+		if res, err := cmd.API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `Count(Row(f=1))`, View: "standard_2000"}); err != nil {
+			t.Fatal(err)
+		} else if res.Results[0] != uint64(1) {
+			t.Fatalf("unexpected n: %d", res.Results[0])
+		}
+	})
+
+	t.Run("ErrViewNotFound", func(t *testing.T) {
+		if _, err := cmd.API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `Row(f=1)`, View: "standard_1999"}); errors.Cause(err) != pilosa.ErrViewNotFound {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
+
 // Ensure a range query can be executed.
 func TestExecutor_Execute_Range_Deprecated(t *testing.T) {
 	t.Run("RowIDColumnID", func(t *testing.T) {
@@ -2456,6 +2496,19 @@ func TestExecutor_ExecuteOptions(t *testing.T) {
 		}
 	})
 
+	t.Run("excludeRowAttrsPerCall", func(t *testing.T) {
+		writeQuery := `
+			Set(100, f=10)
+			SetRowAttrs(f, 10, foo="bar")`
+		readQueries := []string{`Row(f=10) Options(Row(f=10), excludeRowAttrs=true)`}
+		responses := runCallTest(t, writeQuery, readQueries, nil)
+		if attrs := responses[0].Results[0].(*pilosa.Row).Attrs; !reflect.DeepEqual(attrs, map[string]interface{}{"foo": "bar"}) {
+			t.Fatalf("unexpected attrs: %s", spew.Sdump(attrs))
+		} else if attrs := responses[0].Results[1].(*pilosa.Row).Attrs; !reflect.DeepEqual(attrs, map[string]interface{}{}) {
+			t.Fatalf("unexpected attrs: %s", spew.Sdump(attrs))
+		}
+	})
+
 	t.Run("excludeColumns", func(t *testing.T) {
 		writeQuery := `
 			Set(100, f=10)
@@ -81,6 +81,12 @@ type Field struct {
 	// Shards with data on any node in the cluster, according to this node.
 	remoteAvailableShards *roaring.Bitmap
 
+	// Returns the gzip level of fragment snapshots. Set by the index.
+	snapshotCompressionLevel func() int
+
+	// Recent imports into the field.
+	importHistory importHistory
+
 	logger logger.Logger
 }
 
@@ -90,6 +96,40 @@ type FieldOption func(fo *FieldOptions) error
 func OptFieldKeys() FieldOption {
 	return func(fo *FieldOptions) error {
 		fo.Keys = true
+		fo.explicit |= fieldOptionKeys
+		return nil
+	}
+}
+
+// optFieldOptions sets all of the field's options to o, so none are taken
+// from the index's default field options.
+func optFieldOptions(o FieldOptions) FieldOption {
+	return func(fo *FieldOptions) error {
+		*fo = o
+		fo.explicit = ^fieldOptionMask(0)
+		return nil
+	}
+}
+
+// OptFieldNoKeys disables keys on the field, even if the index's default
+// field options enable them.
+func OptFieldNoKeys() FieldOption {
+	return func(fo *FieldOptions) error {
+		fo.Keys = false
+		fo.explicit |= fieldOptionKeys
+		return nil
+	}
+}
+
+// OptFieldCompression sets the algorithm used to compress the field's
+// fragment snapshots on disk.
+func OptFieldCompression(compression string) FieldOption {
+	return func(fo *FieldOptions) error {
+		if !isValidCompression(compression) {
+			return ErrInvalidCompression
+		}
+		fo.Compression = compression
+		fo.explicit |= fieldOptionCompression
 		return nil
 	}
 }
@@ -102,6 +142,7 @@ func OptFieldTypeDefault() FieldOption {
 		fo.Type = FieldTypeSet
 		fo.CacheType = DefaultCacheType
 		fo.CacheSize = DefaultCacheSize
+		fo.explicit |= fieldOptionType | fieldOptionCache
 		return nil
 	}
 }
@@ -114,6 +155,7 @@ func OptFieldTypeSet(cacheType string, cacheSize uint32) FieldOption {
 		fo.Type = FieldTypeSet
 		fo.CacheType = cacheType
 		fo.CacheSize = cacheSize
+		fo.explicit |= fieldOptionType | fieldOptionCache
 		return nil
 	}
 }
@@ -129,6 +171,7 @@ func OptFieldTypeInt(min, max int64) FieldOption {
 		fo.Type = FieldTypeInt
 		fo.Min = min
 		fo.Max = max
+		fo.explicit |= fieldOptionType | fieldOptionRange
 		return nil
 	}
 }
@@ -146,6 +189,36 @@ func OptFieldTypeTime(timeQuantum TimeQuantum, opt ...bool) FieldOption {
 		fo.Type = FieldTypeTime
 		fo.TimeQuantum = timeQuantum
 		fo.NoStandardView = len(opt) >= 1 && opt[0]
+		fo.explicit |= fieldOptionType | fieldOptionTimeQuantum
+		return nil
+	}
+}
+
+// OptFieldTimeRange sets the range of timestamps expected by a time field.
+// Either bound may be zero to leave that side open. Imports may reject
+// timestamps outside the range with OptImportOptionsRejectOutOfRange.
+func OptFieldTimeRange(min, max time.Time) FieldOption {
+	return func(fo *FieldOptions) error {
+		if !min.IsZero() && !max.IsZero() && max.Before(min) {
+			return errors.New("time range max is before min")
+		}
+		fo.TimeMin = min
+		fo.TimeMax = max
+		fo.explicit |= fieldOptionTimeRange
+		return nil
+	}
+}
+
+// OptFieldAllowedRows restricts imports and Set() calls into a set, time or
+// mutex field to the given row IDs. Those referencing other rows are
+// rejected.
+func OptFieldAllowedRows(rowIDs ...uint64) FieldOption {
+	return func(fo *FieldOptions) error {
+		a := make([]uint64, len(rowIDs))
+		copy(a, rowIDs)
+		sort.Sort(uint64Slice(a))
+		fo.AllowedRows = a
+		fo.explicit |= fieldOptionAllowedRows
 		return nil
 	}
 }
@@ -158,6 +231,7 @@ func OptFieldTypeMutex(cacheType string, cacheSize uint32) FieldOption {
 		fo.Type = FieldTypeMutex
 		fo.CacheType = cacheType
 		fo.CacheSize = cacheSize
+		fo.explicit |= fieldOptionType | fieldOptionCache
 		return nil
 	}
 }
@@ -168,6 +242,7 @@ func OptFieldTypeBool() FieldOption {
 			return errors.Errorf("field type is already set to: %s", fo.Type)
 		}
 		fo.Type = FieldTypeBool
+		fo.explicit |= fieldOptionType
 		return nil
 	}
 }
@@ -350,6 +425,37 @@ func (f *Field) CacheSize() uint32 {
 	return v
 }
 
+// SetACL sets the principals allowed to query and import into the field. An
+// empty list allows all principals. Persists to meta file on update.
+func (f *Field) SetACL(allowed []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(allowed) == 0 {
+		allowed = nil
+	}
+	f.options.ACL = allowed
+	if err := f.saveMeta(); err != nil {
+		return errors.Wrap(err, "saving")
+	}
+	return nil
+}
+
+// allows returns true if principal may query and import into the field.
+func (f *Field) allows(principal string) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if len(f.options.ACL) == 0 {
+		return true
+	}
+	for _, p := range f.options.ACL {
+		if p == principal {
+			return true
+		}
+	}
+	return false
+}
+
 // Options returns all options for this field.
 func (f *Field) Options() FieldOptions {
 	f.mu.RLock()
@@ -444,14 +550,7 @@ func (f *Field) loadMeta() error {
 	}
 
 	// Copy metadata fields.
-	f.options.Type = pb.Type
-	f.options.CacheType = pb.CacheType
-	f.options.CacheSize = pb.CacheSize
-	f.options.Min = pb.Min
-	f.options.Max = pb.Max
-	f.options.TimeQuantum = TimeQuantum(pb.TimeQuantum)
-	f.options.Keys = pb.Keys
-	f.options.NoStandardView = pb.NoStandardView
+	f.options = decodeFieldOptions(&pb)
 
 	return nil
 }
@@ -473,8 +572,122 @@ func (f *Field) saveMeta() error {
 	return nil
 }
 
+// inferOptions returns best-effort options for a field whose meta file has
+// been lost, based on its views. A field with a BSI view is an int field
+// whose range is assumed to start at zero and to span the bit depth of the
+// stored values; a field with time views is a time field; any other field
+// is a set field.
+func (f *Field) inferOptions() FieldOptions {
+	var names []string
+	var bitDepth uint64
+	for _, v := range f.views() {
+		names = append(names, v.name)
+		if v.name != viewBSIGroupPrefix+f.name {
+			continue
+		}
+		// The not-null row of a BSI fragment is stored above its bits.
+		for _, frag := range v.allFragments() {
+			frag.mu.RLock()
+			if frag.maxRowID > bitDepth {
+				bitDepth = frag.maxRowID
+			}
+			frag.mu.RUnlock()
+		}
+	}
+	return inferFieldOptions(f.name, names, bitDepth)
+}
+
+// inferFieldOptions returns the options implied by a field's view names and,
+// for int fields, the bit depth of its BSI view.
+func inferFieldOptions(name string, views []string, bitDepth uint64) FieldOptions {
+	var standard bool
+	var units []rune
+	for _, v := range views {
+		if v == viewBSIGroupPrefix+name {
+			return FieldOptions{Type: FieldTypeInt, Min: 0, Max: 1<<bitDepth - 1}
+		} else if v == viewStandard {
+			standard = true
+		} else if strings.HasPrefix(v, viewStandard+"_") {
+			switch len(v) - len(viewStandard) - 1 {
+			case 4:
+				units = append(units, 'Y')
+			case 6:
+				units = append(units, 'M')
+			case 8:
+				units = append(units, 'D')
+			case 10:
+				units = append(units, 'H')
+			}
+		}
+	}
+	if len(units) == 0 {
+		return FieldOptions{Type: FieldTypeSet, CacheType: DefaultCacheType, CacheSize: DefaultCacheSize}
+	}
+
+	// Time quanta are contiguous, so span every unit between the coarsest
+	// and finest views found.
+	const all = "YMDH"
+	lo, hi := len(all), 0
+	for _, u := range units {
+		if i := strings.IndexRune(all, u); i < lo {
+			lo = i
+		}
+		if i := strings.IndexRune(all, u); i+1 > hi {
+			hi = i + 1
+		}
+	}
+	return FieldOptions{
+		Type:           FieldTypeTime,
+		TimeQuantum:    TimeQuantum(all[lo:hi]),
+		NoStandardView: !standard,
+	}
+}
+
+// marshalDefinition returns the field's options and view names encoded as
+// protobuf. Data is not included.
+func (f *Field) marshalDefinition() ([]byte, error) {
+	f.mu.RLock()
+	pb := &internal.FieldMeta{Options: f.options.encode()}
+	for name := range f.viewMap {
+		pb.Views = append(pb.Views, name)
+	}
+	f.mu.RUnlock()
+	sort.Strings(pb.Views)
+
+	buf, err := proto.Marshal(pb)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshaling")
+	}
+	return buf, nil
+}
+
+// unmarshalFieldDefinition decodes field options and view names encoded by
+// marshalDefinition.
+func unmarshalFieldDefinition(buf []byte) (FieldOptions, []string, error) {
+	var pb internal.FieldMeta
+	if err := proto.Unmarshal(buf, &pb); err != nil {
+		return FieldOptions{}, nil, errors.Wrap(err, "unmarshaling")
+	} else if pb.Options == nil {
+		return FieldOptions{}, nil, errors.New("field options required")
+	}
+	return decodeFieldOptions(pb.Options), pb.Views, nil
+}
+
 // applyOptions configures the field based on opt.
 func (f *Field) applyOptions(opt FieldOptions) error {
+	if !isValidCompression(opt.Compression) {
+		return ErrInvalidCompression
+	}
+	f.options.Compression = opt.Compression
+	f.options.ACL = opt.ACL
+
+	switch opt.Type {
+	case FieldTypeInt, FieldTypeBool:
+		if len(opt.AllowedRows) > 0 {
+			return errors.Errorf("allowed rows do not apply to field type %s", opt.Type)
+		}
+	}
+
 	switch opt.Type {
 	case FieldTypeSet, "":
 		f.options.Type = FieldTypeSet
@@ -488,6 +701,7 @@ func (f *Field) applyOptions(opt FieldOptions) error {
 		f.options.Max = 0
 		f.options.TimeQuantum = ""
 		f.options.Keys = opt.Keys
+		f.options.AllowedRows = opt.AllowedRows
 	case FieldTypeInt:
 		f.options.Type = opt.Type
 		f.options.CacheType = CacheTypeNone
@@ -519,6 +733,9 @@ func (f *Field) applyOptions(opt FieldOptions) error {
 		f.options.Max = 0
 		f.options.Keys = opt.Keys
 		f.options.NoStandardView = opt.NoStandardView
+		f.options.TimeMin = opt.TimeMin
+		f.options.TimeMax = opt.TimeMax
+		f.options.AllowedRows = opt.AllowedRows
 		// Set the time quantum.
 		if err := f.setTimeQuantum(opt.TimeQuantum); err != nil {
 			f.Close()
@@ -536,6 +753,7 @@ func (f *Field) applyOptions(opt FieldOptions) error {
 		f.options.Max = 0
 		f.options.TimeQuantum = ""
 		f.options.Keys = opt.Keys
+		f.options.AllowedRows = opt.AllowedRows
 	case FieldTypeBool:
 		f.options.Type = FieldTypeBool
 		f.options.CacheType = CacheTypeNone
@@ -701,6 +919,16 @@ func (f *Field) views() []*view {
 	return other
 }
 
+// openFileCount returns the number of files the field's attribute store and
+// fragments have open.
+func (f *Field) openFileCount() int {
+	n := attrStoreOpenFileCount(f.RowAttrStore())
+	for _, view := range f.views() {
+		n += view.openFileCount()
+	}
+	return n
+}
+
 // recalculateCaches recalculates caches on every view in the field.
 func (f *Field) recalculateCaches() {
 	for _, view := range f.views() {
@@ -708,6 +936,25 @@ func (f *Field) recalculateCaches() {
 	}
 }
 
+// setStats replaces the stats client of the field and its views.
+func (f *Field) setStats(sc stats.StatsClient) {
+	f.mu.Lock()
+	f.Stats = sc
+	f.mu.Unlock()
+
+	for _, view := range f.views() {
+		view.setStats(sc.WithTags(fmt.Sprintf("view:%s", view.name)))
+	}
+}
+
+// statsClient returns the stats client used by the field. It is safe to call
+// while setStats replaces the client.
+func (f *Field) statsClient() stats.StatsClient {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.Stats
+}
+
 // createViewIfNotExists returns the named view, creating it if necessary.
 // Additionally, a CreateViewMessage is sent to the cluster.
 func (f *Field) createViewIfNotExists(name string) (*view, error) {
@@ -758,6 +1005,7 @@ func (f *Field) newView(path, name string) *view {
 	view.rowAttrStore = f.rowAttrStore
 	view.stats = f.Stats.WithTags(fmt.Sprintf("view:%s", name))
 	view.broadcaster = f.broadcaster
+	view.snapshotCompressionLevel = f.snapshotCompressionLevel
 	return view
 }
 
@@ -783,6 +1031,22 @@ func (f *Field) deleteView(name string) error {
 	return nil
 }
 
+// remapRows moves the bits of each source row in mapping to its target row in
+// every view, for the fragments whose shard owns returns true for.
+func (f *Field) remapRows(mapping map[uint64]uint64, owns func(shard uint64) bool) error {
+	for _, view := range f.views() {
+		for _, frag := range view.allFragments() {
+			if !owns(frag.shard) {
+				continue
+			}
+			if err := frag.remapRows(mapping); err != nil {
+				return errors.Wrapf(err, "remapping rows: view=%s, shard=%d", view.name, frag.shard)
+			}
+		}
+	}
+	return nil
+}
+
 // Row returns a row of the standard view.
 // It seems this method is only being used by the test
 // package, and the fact that it's only allowed on
@@ -1054,6 +1318,21 @@ func (f *Field) Range(name string, op pql.Token, predicate int64) (*Row, error)
 	return view.rangeOp(op, bsig.BitDepth(), baseValue)
 }
 
+// importViews returns the names of the views which a bit imported with
+// timestamp is written to, given the field's time quantum q.
+func (f *Field) importViews(timestamp *time.Time, q TimeQuantum) []string {
+	if timestamp == nil {
+		return []string{viewStandard}
+	}
+	views := viewsByTime(viewStandard, *timestamp, q)
+	if !f.options.NoStandardView {
+		// In order to match the logic of `SetBit()`, we want bits
+		// with timestamps to write to both time and standard views.
+		views = append(views, viewStandard)
+	}
+	return views
+}
+
 // Import bulk imports data.
 func (f *Field) Import(rowIDs, columnIDs []uint64, timestamps []*time.Time, opts ...ImportOption) error {
 
@@ -1080,8 +1359,10 @@ func (f *Field) Import(rowIDs, columnIDs []uint64, timestamps []*time.Time, opts
 
 	// Split import data by fragment.
 	dataByFragment := make(map[importKey]importData)
+	columnsByShard := make(map[uint64]uint64)
 	for i := range rowIDs {
 		rowID, columnID := rowIDs[i], columnIDs[i]
+		columnsByShard[columnID/ShardWidth]++
 
 		// Bool-specific data validation.
 		if fieldType == FieldTypeBool && rowID > 1 {
@@ -1093,20 +1374,8 @@ func (f *Field) Import(rowIDs, columnIDs []uint64, timestamps []*time.Time, opts
 			timestamp = timestamps[i]
 		}
 
-		var standard []string
-		if timestamp == nil {
-			standard = []string{viewStandard}
-		} else {
-			standard = viewsByTime(viewStandard, *timestamp, q)
-			if !f.options.NoStandardView {
-				// In order to match the logic of `SetBit()`, we want bits
-				// with timestamps to write to both time and standard views.
-				standard = append(standard, viewStandard)
-			}
-		}
-
 		// Attach bit to each standard view.
-		for _, name := range standard {
+		for _, name := range f.importViews(timestamp, q) {
 			key := importKey{View: name, Shard: columnID / ShardWidth}
 			data := dataByFragment[key]
 			data.RowIDs = append(data.RowIDs, rowID)
@@ -1132,11 +1401,99 @@ func (f *Field) Import(rowIDs, columnIDs []uint64, timestamps []*time.Time, opts
 		}
 	}
 
+	shards := make([]uint64, 0, len(columnsByShard))
+	for shard := range columnsByShard {
+		shards = append(shards, shard)
+	}
+	sort.Slice(shards, func(i, j int) bool { return shards[i] < shards[j] })
+	for _, shard := range shards {
+		f.importHistory.add(shard, columnsByShard[shard], options.Clear)
+	}
+
+	return nil
+}
+
+// importUndo holds the changes required to revert an import into the
+// standard view of a field.
+type importUndo struct {
+	field    *Field
+	clears   []Bit
+	restores []Bit
+}
+
+// importUndo returns the changes required to revert importing the given bits
+// into the standard view of the field.
+func (f *Field) importUndo(rowIDs, columnIDs []uint64) (*importUndo, error) {
+	if len(rowIDs) != len(columnIDs) {
+		return nil, fmt.Errorf("mismatch of row/column len: %d != %d", len(rowIDs), len(columnIDs))
+	}
+
+	// Split bits by shard.
+	byShard := make(map[uint64]importData)
+	for i := range rowIDs {
+		shard := columnIDs[i] / ShardWidth
+		data := byShard[shard]
+		data.RowIDs = append(data.RowIDs, rowIDs[i])
+		data.ColumnIDs = append(data.ColumnIDs, columnIDs[i])
+		byShard[shard] = data
+	}
+
+	undo := &importUndo{field: f}
+	view := f.view(viewStandard)
+	for shard, data := range byShard {
+		var frag *fragment
+		if view != nil {
+			frag = view.Fragment(shard)
+		}
+
+		// Every bit is new if the fragment doesn't exist yet.
+		if frag == nil {
+			for i := range data.RowIDs {
+				undo.clears = append(undo.clears, Bit{RowID: data.RowIDs[i], ColumnID: data.ColumnIDs[i]})
+			}
+			continue
+		}
+
+		clears, restores, err := frag.importUndo(data.RowIDs, data.ColumnIDs)
+		if err != nil {
+			return nil, errors.Wrap(err, "determining fragment undo")
+		}
+		undo.clears = append(undo.clears, clears...)
+		undo.restores = append(undo.restores, restores...)
+	}
+	return undo, nil
+}
+
+// rollback reverts the import by clearing the newly set bits and restoring
+// any mutex bits which were cleared by the import.
+func (u *importUndo) rollback() error {
+	if len(u.clears) > 0 {
+		rowIDs, columnIDs := bitIDs(u.clears)
+		if err := u.field.Import(rowIDs, columnIDs, nil, OptImportOptionsClear(true)); err != nil {
+			return errors.Wrap(err, "clearing imported bits")
+		}
+	}
+	if len(u.restores) > 0 {
+		rowIDs, columnIDs := bitIDs(u.restores)
+		if err := u.field.Import(rowIDs, columnIDs, nil); err != nil {
+			return errors.Wrap(err, "restoring mutex bits")
+		}
+	}
 	return nil
 }
 
+// bitIDs returns the row and column IDs of bits as separate slices.
+func bitIDs(bits []Bit) (rowIDs, columnIDs []uint64) {
+	rowIDs = make([]uint64, len(bits))
+	columnIDs = make([]uint64, len(bits))
+	for i, bit := range bits {
+		rowIDs[i], columnIDs[i] = bit.RowID, bit.ColumnID
+	}
+	return rowIDs, columnIDs
+}
+
 // importValue bulk imports range-encoded value data.
-func (f *Field) importValue(columnIDs []uint64, values []int64, options *ImportOptions) error {
+func (f *Field) importValue(columnIDs []uint64, values []int64, nulls []bool, options *ImportOptions) error {
 	viewName := viewBSIGroupPrefix + f.name
 	// Get the bsiGroup so we know bitDepth.
 	bsig := f.bsiGroup(f.name)
@@ -1148,7 +1505,10 @@ func (f *Field) importValue(columnIDs []uint64, values []int64, options *ImportO
 	dataByFragment := make(map[importKey]importValueData)
 	for i := range columnIDs {
 		columnID, value := columnIDs[i], values[i]
-		if value > bsig.Max {
+		null := len(nulls) != 0 && nulls[i]
+		if null {
+			value = bsig.Min
+		} else if value > bsig.Max {
 			return fmt.Errorf("%v, columnID=%v, value=%v", ErrBSIGroupValueTooHigh, columnID, value)
 		} else if value < bsig.Min {
 			return fmt.Errorf("%v, columnID=%v, value=%v", ErrBSIGroupValueTooLow, columnID, value)
@@ -1160,6 +1520,9 @@ func (f *Field) importValue(columnIDs []uint64, values []int64, options *ImportO
 			data := dataByFragment[key]
 			data.ColumnIDs = append(data.ColumnIDs, columnID)
 			data.Values = append(data.Values, value)
+			if len(nulls) != 0 {
+				data.Null = append(data.Null, null)
+			}
 			dataByFragment[key] = data
 		}
 	}
@@ -1184,9 +1547,10 @@ func (f *Field) importValue(columnIDs []uint64, values []int64, options *ImportO
 			baseValues[i] = uint64(value - bsig.Min)
 		}
 
-		if err := frag.importValue(data.ColumnIDs, baseValues, bsig.BitDepth(), options.Clear); err != nil {
+		if err := frag.importValue(data.ColumnIDs, baseValues, data.Null, bsig.BitDepth(), options.Clear); err != nil {
 			return err
 		}
+		f.importHistory.add(key.Shard, uint64(len(data.ColumnIDs)), options.Clear)
 	}
 
 	return nil
@@ -1210,6 +1574,12 @@ func (f *Field) importRoaring(data []byte, shard uint64, viewName string, clear
 		return err
 	}
 
+	// The data was validated by the import, so only its count is needed.
+	bm := roaring.NewBitmap()
+	if err := bm.UnmarshalBinary(data); err == nil {
+		f.importHistory.add(shard, bm.Count(), clear)
+	}
+
 	return nil
 }
 
@@ -1238,10 +1608,122 @@ type FieldOptions struct {
 	Max            int64       `json:"max,omitempty"`
 	Keys           bool        `json:"keys"`
 	NoStandardView bool        `json:"noStandardView,omitempty"`
+	Compression    string      `json:"compression,omitempty"`
 	CacheSize      uint32      `json:"cacheSize,omitempty"`
 	CacheType      string      `json:"cacheType,omitempty"`
 	Type           string      `json:"type,omitempty"`
 	TimeQuantum    TimeQuantum `json:"timeQuantum,omitempty"`
+
+	// ACL lists the principals allowed to query and import into the field.
+	// If empty, all principals are allowed.
+	ACL []string `json:"acl,omitempty"`
+
+	// TimeMin and TimeMax bound the timestamps expected by a time field.
+	// A zero value leaves that side of the range open.
+	TimeMin time.Time `json:"timeMin,omitempty"`
+	TimeMax time.Time `json:"timeMax,omitempty"`
+
+	// AllowedRows lists, in ascending order, the only row IDs which imports
+	// may reference. If empty, any row may be imported.
+	AllowedRows []uint64 `json:"allowedRows,omitempty"`
+
+	// Options set by FieldOption funcs, which withDefaults keeps even if
+	// they hold zero values.
+	explicit fieldOptionMask
+}
+
+// fieldOptionMask is a set of field options, grouped by the FieldOption
+// funcs which set them.
+type fieldOptionMask uint16
+
+const (
+	fieldOptionType        fieldOptionMask = 1 << iota // Type
+	fieldOptionCache                                   // CacheType, CacheSize
+	fieldOptionRange                                   // Min, Max
+	fieldOptionTimeQuantum                             // TimeQuantum, NoStandardView
+	fieldOptionTimeRange                               // TimeMin, TimeMax
+	fieldOptionKeys                                    // Keys
+	fieldOptionCompression                             // Compression
+	fieldOptionAllowedRows                             // AllowedRows
+)
+
+// withDefaults returns o with the options not set by a FieldOption func
+// taken from d. Options which depend on the field type, such as the cache
+// and the range of int fields, are only taken from d if o has the same type
+// or no type.
+func (o FieldOptions) withDefaults(d FieldOptions) FieldOptions {
+	unset := func(m fieldOptionMask) bool { return o.explicit&m == 0 }
+
+	if unset(fieldOptionCompression) {
+		o.Compression = d.Compression
+	}
+	if len(o.ACL) == 0 {
+		o.ACL = d.ACL
+	}
+
+	if unset(fieldOptionType) {
+		o.Type = d.Type
+	}
+	if o.Type != d.Type && !(o.Type == FieldTypeSet && d.Type == "") {
+		return o
+	}
+	if unset(fieldOptionRange) {
+		o.Min, o.Max = d.Min, d.Max
+	}
+	if unset(fieldOptionKeys) {
+		o.Keys = d.Keys
+	}
+	if unset(fieldOptionCache) {
+		o.CacheType, o.CacheSize = d.CacheType, d.CacheSize
+	}
+	if unset(fieldOptionTimeQuantum) {
+		o.TimeQuantum, o.NoStandardView = d.TimeQuantum, d.NoStandardView
+	}
+	if unset(fieldOptionTimeRange) {
+		o.TimeMin, o.TimeMax = d.TimeMin, d.TimeMax
+	}
+	if unset(fieldOptionAllowedRows) {
+		o.AllowedRows = d.AllowedRows
+	}
+	return o
+}
+
+// rowsNotAllowed returns the distinct row IDs in rowIDs which are not in
+// AllowedRows, in ascending order. Returns nil if AllowedRows is empty.
+func (o *FieldOptions) rowsNotAllowed(rowIDs []uint64) []uint64 {
+	if len(o.AllowedRows) == 0 {
+		return nil
+	}
+	var a []uint64
+	for _, id := range rowIDs {
+		i := sort.Search(len(o.AllowedRows), func(i int) bool { return o.AllowedRows[i] >= id })
+		if i == len(o.AllowedRows) || o.AllowedRows[i] != id {
+			a = append(a, id)
+		}
+	}
+	if len(a) == 0 {
+		return nil
+	}
+	sort.Sort(uint64Slice(a))
+
+	// Remove duplicates.
+	n := 1
+	for _, id := range a[1:] {
+		if id != a[n-1] {
+			a[n] = id
+			n++
+		}
+	}
+	return a[:n]
+}
+
+// timeInRange returns true if t is within the bounds set by TimeMin and
+// TimeMax.
+func (o *FieldOptions) timeInRange(t time.Time) bool {
+	if !o.TimeMin.IsZero() && t.Before(o.TimeMin) {
+		return false
+	}
+	return o.TimeMax.IsZero() || !t.After(o.TimeMax)
 }
 
 // applyDefaultOptions returns a new FieldOptions object
@@ -1249,9 +1731,10 @@ type FieldOptions struct {
 func applyDefaultOptions(o FieldOptions) FieldOptions {
 	if o.Type == "" {
 		return FieldOptions{
-			Type:      DefaultFieldType,
-			CacheType: DefaultCacheType,
-			CacheSize: DefaultCacheSize,
+			Type:        DefaultFieldType,
+			CacheType:   DefaultCacheType,
+			CacheSize:   DefaultCacheSize,
+			Compression: o.Compression,
 		}
 	}
 	return o
@@ -1275,6 +1758,46 @@ func encodeFieldOptions(o *FieldOptions) *internal.FieldOptions {
 		TimeQuantum:    string(o.TimeQuantum),
 		Keys:           o.Keys,
 		NoStandardView: o.NoStandardView,
+		Compression:    o.Compression,
+		ACL:            o.ACL,
+		TimeMin:        encodeTimeBound(o.TimeMin),
+		TimeMax:        encodeTimeBound(o.TimeMax),
+		AllowedRows:    o.AllowedRows,
+	}
+}
+
+// encodeTimeBound returns t as nanoseconds since the epoch, or zero if t is
+// the zero time.
+func encodeTimeBound(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// decodeTimeBound is the inverse of encodeTimeBound.
+func decodeTimeBound(ns int64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns).UTC()
+}
+
+func decodeFieldOptions(pb *internal.FieldOptions) FieldOptions {
+	return FieldOptions{
+		Type:           pb.Type,
+		CacheType:      pb.CacheType,
+		CacheSize:      pb.CacheSize,
+		Min:            pb.Min,
+		Max:            pb.Max,
+		TimeQuantum:    TimeQuantum(pb.TimeQuantum),
+		Keys:           pb.Keys,
+		NoStandardView: pb.NoStandardView,
+		Compression:    pb.Compression,
+		ACL:            pb.ACL,
+		TimeMin:        decodeTimeBound(pb.TimeMin),
+		TimeMax:        decodeTimeBound(pb.TimeMax),
+		AllowedRows:    pb.AllowedRows,
 	}
 }
 
@@ -1282,57 +1805,84 @@ func (o *FieldOptions) MarshalJSON() ([]byte, error) {
 	switch o.Type {
 	case FieldTypeSet:
 		return json.Marshal(struct {
-			Type      string `json:"type"`
-			CacheType string `json:"cacheType"`
-			CacheSize uint32 `json:"cacheSize"`
-			Keys      bool   `json:"keys"`
+			Type        string   `json:"type"`
+			CacheType   string   `json:"cacheType"`
+			CacheSize   uint32   `json:"cacheSize"`
+			Keys        bool     `json:"keys"`
+			Compression string   `json:"compression,omitempty"`
+			AllowedRows []uint64 `json:"allowedRows,omitempty"`
 		}{
 			o.Type,
 			o.CacheType,
 			o.CacheSize,
 			o.Keys,
+			o.Compression,
+			o.AllowedRows,
 		})
 	case FieldTypeInt:
 		return json.Marshal(struct {
-			Type string `json:"type"`
-			Min  int64  `json:"min"`
-			Max  int64  `json:"max"`
-			Keys bool   `json:"keys"`
+			Type        string `json:"type"`
+			Min         int64  `json:"min"`
+			Max         int64  `json:"max"`
+			Keys        bool   `json:"keys"`
+			Compression string `json:"compression,omitempty"`
 		}{
 			o.Type,
 			o.Min,
 			o.Max,
 			o.Keys,
+			o.Compression,
 		})
 	case FieldTypeTime:
+		var timeMin, timeMax *time.Time
+		if !o.TimeMin.IsZero() {
+			timeMin = &o.TimeMin
+		}
+		if !o.TimeMax.IsZero() {
+			timeMax = &o.TimeMax
+		}
 		return json.Marshal(struct {
 			Type           string      `json:"type"`
 			TimeQuantum    TimeQuantum `json:"timeQuantum"`
 			Keys           bool        `json:"keys"`
 			NoStandardView bool        `json:"noStandardView"`
+			Compression    string      `json:"compression,omitempty"`
+			TimeMin        *time.Time  `json:"timeMin,omitempty"`
+			TimeMax        *time.Time  `json:"timeMax,omitempty"`
+			AllowedRows    []uint64    `json:"allowedRows,omitempty"`
 		}{
 			o.Type,
 			o.TimeQuantum,
 			o.Keys,
 			o.NoStandardView,
+			o.Compression,
+			timeMin,
+			timeMax,
+			o.AllowedRows,
 		})
 	case FieldTypeMutex:
 		return json.Marshal(struct {
-			Type      string `json:"type"`
-			CacheType string `json:"cacheType"`
-			CacheSize uint32 `json:"cacheSize"`
-			Keys      bool   `json:"keys"`
+			Type        string   `json:"type"`
+			CacheType   string   `json:"cacheType"`
+			CacheSize   uint32   `json:"cacheSize"`
+			Keys        bool     `json:"keys"`
+			Compression string   `json:"compression,omitempty"`
+			AllowedRows []uint64 `json:"allowedRows,omitempty"`
 		}{
 			o.Type,
 			o.CacheType,
 			o.CacheSize,
 			o.Keys,
+			o.Compression,
+			o.AllowedRows,
 		})
 	case FieldTypeBool:
 		return json.Marshal(struct {
-			Type string `json:"type"`
+			Type        string `json:"type"`
+			Compression string `json:"compression,omitempty"`
 		}{
 			o.Type,
+			o.Compression,
 		})
 	}
 	return nil, errors.New("invalid field type")
@@ -1442,6 +1992,23 @@ const (
 	CacheTypeNone   = "none"
 )
 
+// Compression algorithms for fragment snapshots.
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+)
+
+// isValidCompression returns true if v is a supported compression algorithm.
+// An empty value is treated as CompressionNone.
+func isValidCompression(v string) bool {
+	switch v {
+	case "", CompressionNone, CompressionGzip:
+		return true
+	default:
+		return false
+	}
+}
+
 // isValidCacheType returns true if v is a valid cache type.
 func isValidCacheType(v string) bool {
 	switch v {
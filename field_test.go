@@ -22,6 +22,7 @@ import (
 	"github.com/pilosa/pilosa"
 	"github.com/pilosa/pilosa/roaring"
 	"github.com/pilosa/pilosa/test"
+	"github.com/pkg/errors"
 )
 
 // Ensure a field can set & read a bsiGroup value.
@@ -223,3 +224,21 @@ func TestField_AvailableShards(t *testing.T) {
 		t.Fatal(diff)
 	}
 }
+
+// Ensure a field persists its compression setting and rejects unknown algorithms.
+func TestField_Compression(t *testing.T) {
+	idx := test.MustOpenIndex()
+	defer idx.Close()
+
+	if _, err := idx.CreateField("z", pilosa.OptFieldTypeDefault(), pilosa.OptFieldCompression("zstd")); errors.Cause(err) != pilosa.ErrInvalidCompression {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := idx.CreateField("f", pilosa.OptFieldTypeDefault(), pilosa.OptFieldCompression(pilosa.CompressionGzip)); err != nil {
+		t.Fatal(err)
+	} else if err := idx.Reopen(); err != nil {
+		t.Fatal(err)
+	} else if c := idx.Field("f").Options().Compression; c != pilosa.CompressionGzip {
+		t.Fatalf("unexpected compression: %q", c)
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"archive/tar"
 	"bufio"
 	"bytes"
+	"compress/gzip"
 	"container/heap"
 	"context"
 	"encoding/binary"
@@ -30,6 +31,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 	"unsafe"
@@ -105,6 +107,20 @@ type fragment struct {
 	cache     cache
 	CacheSize uint32
 
+	// Unix time in nanoseconds when the cache was last read. Accessed atomically.
+	cacheUsed int64
+
+	// Unix time in nanoseconds when the data last changed, starting from the
+	// data file's modification time. Accessed atomically.
+	modTime int64
+
+	// Algorithm used to compress snapshots. Passed in by field.
+	compression string
+
+	// Returns the gzip level of snapshots. Zero, or a nil function, means
+	// the default level. Passed in by view.
+	compressionLevel func() int
+
 	// Stats reporting.
 	maxRowID uint64
 
@@ -219,6 +235,7 @@ func (f *fragment) openStorage() error {
 			return errors.Wrap(err, "statting file after")
 		}
 	}
+	atomic.CompareAndSwapInt64(&f.modTime, 0, fi.ModTime().UnixNano())
 
 	// Mmap the underlying file so it can be zero copied.
 	storageData, err := syscall.Mmap(int(f.file.Fd()), 0, int(fi.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
@@ -232,8 +249,14 @@ func (f *fragment) openStorage() error {
 		return fmt.Errorf("madvise: %s", err)
 	}
 
-	// Attach the mmap file to the bitmap.
+	// Attach the mmap file to the bitmap. Compressed snapshots are inflated
+	// onto the heap along with any ops appended after them.
 	data := f.storageData
+	if isGzipData(data) {
+		if data, err = decompressStorageData(data); err != nil {
+			return fmt.Errorf("decompress storage: file=%s, err=%s", f.file.Name(), err)
+		}
+	}
 	if err := f.storage.UnmarshalBinary(data); err != nil {
 		return fmt.Errorf("unmarshal storage: file=%s, err=%s", f.file.Name(), err)
 	}
@@ -338,11 +361,22 @@ func (f *fragment) closeStorage() error {
 		if err := f.file.Close(); err != nil {
 			return fmt.Errorf("close file: %s", err)
 		}
+		f.file = nil
 	}
 
 	return nil
 }
 
+// openFileCount returns the number of files the fragment has open.
+func (f *fragment) openFileCount() int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.file == nil {
+		return 0
+	}
+	return 1
+}
+
 // row returns a row by ID.
 func (f *fragment) row(rowID uint64) *Row {
 	f.mu.Lock()
@@ -593,6 +627,21 @@ func (f *fragment) bit(rowID, columnID uint64) (bool, error) {
 	return f.storage.Contains(pos), nil
 }
 
+// bits returns whether each bit, given by its row and column, is set.
+func (f *fragment) bits(rowIDs, columnIDs []uint64) ([]bool, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	a := make([]bool, len(rowIDs))
+	for i := range rowIDs {
+		v, err := f.bit(rowIDs[i], columnIDs[i])
+		if err != nil {
+			return nil, err
+		}
+		a[i] = v
+	}
+	return a, nil
+}
+
 // value uses a column of bits to read a multi-bit value.
 func (f *fragment) value(columnID uint64, bitDepth uint) (value uint64, exists bool, err error) {
 	f.mu.Lock()
@@ -617,6 +666,25 @@ func (f *fragment) value(columnID uint64, bitDepth uint) (value uint64, exists b
 	return value, true, nil
 }
 
+// forEachValue calls fn with each column which has a multi-bit value and its
+// value, in column order. Values are read in a single pass over the fragment.
+func (f *fragment) forEachValue(bitDepth uint, fn func(columnID, value uint64)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Bits are visited by row, so every value bit of a column is seen before
+	// its existence bit.
+	values := make(map[uint64]uint64)
+	f.storage.ForEach(func(i uint64) {
+		rowID, columnID := i/ShardWidth, (f.shard*ShardWidth)+(i%ShardWidth)
+		if rowID < uint64(bitDepth) {
+			values[columnID] |= 1 << rowID
+		} else if rowID == uint64(bitDepth) {
+			fn(columnID, values[columnID])
+		}
+	})
+}
+
 // clearValue uses a column of bits to clear a multi-bit value.
 func (f *fragment) clearValue(columnID uint64, bitDepth uint, value uint64) (changed bool, err error) {
 	return f.setValueBase(columnID, bitDepth, value, true)
@@ -1150,6 +1218,8 @@ func (f *fragment) top(opt topOptions) ([]Pair, error) {
 }
 
 func (f *fragment) topBitmapPairs(rowIDs []uint64) []bitmapPair {
+	atomic.StoreInt64(&f.cacheUsed, time.Now().UnixNano())
+
 	// Don't retrieve from storage if CacheTypeNone.
 	if f.CacheType == CacheTypeNone {
 		return f.cache.Top()
@@ -1162,11 +1232,20 @@ func (f *fragment) topBitmapPairs(rowIDs []uint64) []bitmapPair {
 		return f.cache.Top()
 	}
 
+	// Reload the cache if it was evicted to free memory. The loaded cache is
+	// kept so that a concurrent eviction cannot swap it out from under us.
+	f.mu.Lock()
+	c := f.cache
+	if ec, ok := c.(*evictedCache); ok {
+		c = ec.load()
+	}
+	f.mu.Unlock()
+
 	// Otherwise retrieve specific rows.
 	pairs := make([]bitmapPair, 0, len(rowIDs))
 	for _, rowID := range rowIDs {
 		// Look up cache first, if available.
-		if n := f.cache.Get(rowID); n > 0 {
+		if n := c.Get(rowID); n > 0 {
 			pairs = append(pairs, bitmapPair{
 				ID:    rowID,
 				Count: n,
@@ -1454,6 +1533,35 @@ func (f *fragment) bulkImport(rowIDs, columnIDs []uint64, options *ImportOptions
 	return f.bulkImportStandard(rowIDs, columnIDs, options)
 }
 
+// importUndo returns the changes required to revert a bulk import of the
+// given bits: the bits which are not currently set and must be cleared, and,
+// for mutex fragments, the existing bits which the import would clear and
+// must be set again.
+func (f *fragment) importUndo(rowIDs, columnIDs []uint64) (clears, restores []Bit, err error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	for i := range rowIDs {
+		rowID, columnID := rowIDs[i], columnIDs[i]
+		if set, err := f.bit(rowID, columnID); err != nil {
+			return nil, nil, errors.Wrap(err, "getting bit")
+		} else if set {
+			continue
+		}
+		clears = append(clears, Bit{RowID: rowID, ColumnID: columnID})
+
+		if f.mutexVector == nil {
+			continue
+		}
+		if existingRowID, found, err := f.mutexVector.Get(columnID); err != nil {
+			return nil, nil, errors.Wrap(err, "getting mutex vector data")
+		} else if found {
+			restores = append(restores, Bit{RowID: existingRowID, ColumnID: columnID})
+		}
+	}
+	return clears, restores, nil
+}
+
 // bulkImportStandard performs a bulk import on a standard fragment.
 func (f *fragment) bulkImportStandard(rowIDs, columnIDs []uint64, options *ImportOptions) error {
 	// Create a temporary bitmap which will be populated by rowIDs and columnIDs
@@ -1621,12 +1729,14 @@ func (f *fragment) bulkImportMutex(rowIDs, columnIDs []uint64) error {
 }
 
 // importValue bulk imports a set of range-encoded values.
-func (f *fragment) importValue(columnIDs, values []uint64, bitDepth uint, clear bool) error {
+func (f *fragment) importValue(columnIDs, values []uint64, nulls []bool, bitDepth uint, clear bool) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	// Verify that there are an equal number of column ids and values.
 	if len(columnIDs) != len(values) {
 		return fmt.Errorf("mismatch of column/value len: %d != %d", len(columnIDs), len(values))
+	} else if len(nulls) != 0 && len(nulls) != len(values) {
+		return fmt.Errorf("mismatch of null/value len: %d != %d", len(nulls), len(values))
 	}
 
 	f.storage.OpWriter = nil
@@ -1636,6 +1746,14 @@ func (f *fragment) importValue(columnIDs, values []uint64, bitDepth uint, clear
 		for i := range columnIDs {
 			columnID, value := columnIDs[i], values[i]
 
+			// Null values clear every bit, including not-null.
+			if len(nulls) != 0 && nulls[i] {
+				if _, err := f.importSetValue(columnID, bitDepth, 0, true); err != nil {
+					return errors.Wrap(err, "clearing")
+				}
+				continue
+			}
+
 			_, err := f.importSetValue(columnID, bitDepth, value, clear)
 			if err != nil {
 				return errors.Wrap(err, "setting")
@@ -1702,9 +1820,91 @@ func (f *fragment) importRoaring(data []byte, clear bool) error {
 	return err
 }
 
+// remapRows moves the bits of each source row in mapping to its target row,
+// ORing them into the target and clearing the source. All rows are moved at
+// once, so a source may also be the target of another row.
+func (f *fragment) remapRows(mapping map[uint64]uint64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	cleared, moved := roaring.NewBitmap(), roaring.NewBitmap()
+	for src, dst := range mapping {
+		if src == dst {
+			continue
+		}
+		cleared.UnionInPlace(f.storage.OffsetRange(src*ShardWidth, src*ShardWidth, (src+1)*ShardWidth))
+		moved.UnionInPlace(f.storage.OffsetRange(dst*ShardWidth, src*ShardWidth, (src+1)*ShardWidth))
+	}
+	if cleared.Count() == 0 {
+		return nil
+	}
+	bm := f.storage.Difference(cleared).Union(moved)
+
+	for src, dst := range mapping {
+		for _, rowID := range []uint64{src, dst} {
+			n := bm.CountRange(rowID*ShardWidth, (rowID+1)*ShardWidth)
+			f.cache.BulkAdd(rowID, n)
+			delete(f.checksums, int(rowID/HashBlockSize))
+			if n > 0 && rowID > f.maxRowID {
+				f.maxRowID = rowID
+			}
+		}
+	}
+	f.cache.Recalculate()
+
+	return unprotectedWriteToFragment(f, bm)
+}
+
+// storageCopy returns a copy of the fragment's data.
+func (f *fragment) storageCopy() *roaring.Bitmap {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.storage.Clone()
+}
+
+// revertColumns restores the bits of the given columns to their state in
+// prev, a copy of the fragment's data taken by storageCopy. Bits of other
+// columns are left as they are.
+func (f *fragment) revertColumns(prev *roaring.Bitmap, columnIDs []uint64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	columns := make(map[uint64]struct{}, len(columnIDs))
+	for _, columnID := range columnIDs {
+		columns[columnID%ShardWidth] = struct{}{}
+	}
+
+	bm := f.storage.Clone()
+	rowSet := make(map[uint64]struct{})
+	f.storage.Xor(prev).ForEach(func(pos uint64) {
+		if _, ok := columns[pos%ShardWidth]; !ok {
+			return
+		}
+		if prev.Contains(pos) {
+			bm.DirectAdd(pos)
+		} else {
+			// The copy has no op writer, so removing cannot fail.
+			_, _ = bm.Remove(pos)
+		}
+		rowSet[pos/ShardWidth] = struct{}{}
+	})
+	if len(rowSet) == 0 {
+		return nil
+	}
+
+	for rowID := range rowSet {
+		delete(f.checksums, int(rowID/HashBlockSize))
+		f.cache.BulkAdd(rowID, bm.CountRange(rowID*ShardWidth, (rowID+1)*ShardWidth))
+	}
+	f.cache.Recalculate()
+
+	return unprotectedWriteToFragment(f, bm)
+}
+
 // incrementOpN increase the operation count by one.
 // If the count exceeds the maximum allowed then a snapshot is performed.
 func (f *fragment) incrementOpN() error {
+	f.touch()
 	f.opN++
 	if f.opN <= f.MaxOpN {
 		return nil
@@ -1716,6 +1916,16 @@ func (f *fragment) incrementOpN() error {
 	return nil
 }
 
+// touch records that the fragment's data changed now.
+func (f *fragment) touch() {
+	atomic.StoreInt64(&f.modTime, time.Now().UnixNano())
+}
+
+// lastModified returns the time the fragment's data last changed.
+func (f *fragment) lastModified() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&f.modTime))
+}
+
 // Snapshot writes the storage bitmap to disk and reopens it.
 func (f *fragment) Snapshot() error {
 	f.mu.Lock()
@@ -1735,7 +1945,7 @@ func (f *fragment) snapshot() error {
 // unprotectedWriteToFragment writes the fragment f with bm as the data. It is unprotected, and
 // f.mu must be locked when calling it.
 func unprotectedWriteToFragment(f *fragment, bm *roaring.Bitmap) error { // nolint: interfacer
-
+	f.touch()
 	completeMessage := fmt.Sprintf("fragment: snapshot complete %s/%s/%s/%d", f.index, f.field, f.view, f.shard)
 	start := time.Now()
 	defer track(start, completeMessage, f.stats, f.Logger)
@@ -1750,7 +1960,18 @@ func unprotectedWriteToFragment(f *fragment, bm *roaring.Bitmap) error { // noli
 
 	// Write storage to snapshot.
 	bw := bufio.NewWriter(file)
-	if _, err := bm.WriteTo(bw); err != nil {
+	if f.compression == CompressionGzip {
+		gw, err := gzip.NewWriterLevel(bw, f.gzipLevel())
+		if err != nil {
+			return fmt.Errorf("snapshot compress: %s", err)
+		}
+		if _, err := bm.WriteTo(gw); err != nil {
+			return fmt.Errorf("snapshot write to: %s", err)
+		}
+		if err := gw.Close(); err != nil {
+			return fmt.Errorf("snapshot compress: %s", err)
+		}
+	} else if _, err := bm.WriteTo(bw); err != nil {
 		return fmt.Errorf("snapshot write to: %s", err)
 	}
 
@@ -1779,6 +2000,43 @@ func unprotectedWriteToFragment(f *fragment, bm *roaring.Bitmap) error { // noli
 	return nil
 }
 
+// gzipLevel returns the gzip level used to compress snapshots.
+func (f *fragment) gzipLevel() int {
+	if f.compressionLevel != nil {
+		if level := f.compressionLevel(); level != 0 {
+			return level
+		}
+	}
+	return gzip.DefaultCompression
+}
+
+// isGzipData returns true if data begins with a gzip header.
+func isGzipData(data []byte) bool {
+	return len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b
+}
+
+// decompressStorageData inflates the gzipped snapshot at the start of data
+// and returns it followed by the uncompressed op log written after it.
+func decompressStorageData(data []byte) ([]byte, error) {
+	br := bytes.NewReader(data)
+	gr, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating gzip reader")
+	}
+	gr.Multistream(false)
+
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, gr); err != nil {
+		return nil, errors.Wrap(err, "reading snapshot")
+	} else if err := gr.Close(); err != nil {
+		return nil, errors.Wrap(err, "closing gzip reader")
+	}
+
+	// Append the remaining op log entries.
+	buf.Write(data[len(data)-br.Len():])
+	return buf.Bytes(), nil
+}
+
 // RecalculateCache rebuilds the cache regardless of invalidate time delay.
 func (f *fragment) RecalculateCache() {
 	f.mu.Lock()
@@ -1786,6 +2044,41 @@ func (f *fragment) RecalculateCache() {
 	f.mu.Unlock()
 }
 
+// cacheStale returns true if the fragment's TopN cache ranks rows by counts
+// which have since changed. Only ranked caches can be stale.
+func (f *fragment) cacheStale() bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	c, ok := f.cache.(*rankCache)
+	return ok && c.stale()
+}
+
+// containerStats returns the number of containers of each type in the
+// fragment's storage, along with the bits they hold and the bytes they use.
+func (f *fragment) containerStats() ContainerStats {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	var stats ContainerStats
+	for _, ci := range f.storage.Info().Containers {
+		var s *ContainerTypeStats
+		switch ci.Type {
+		case "array":
+			s = &stats.Array
+		case "bitmap":
+			s = &stats.Bitmap
+		case "run":
+			s = &stats.Run
+		default:
+			continue
+		}
+		s.Containers++
+		s.Bits += int64(ci.N)
+		s.Bytes += int64(ci.Alloc)
+	}
+	return stats
+}
+
 // FlushCache writes the cache data to disk.
 func (f *fragment) FlushCache() error {
 	f.mu.Lock()
@@ -1796,6 +2089,9 @@ func (f *fragment) FlushCache() error {
 func (f *fragment) flushCache() error {
 	if f.cache == nil {
 		return nil
+	} else if _, ok := f.cache.(*evictedCache); ok {
+		// Already flushed when evicted.
+		return nil
 	}
 
 	if f.CacheType == CacheTypeNone {
@@ -1819,6 +2115,74 @@ func (f *fragment) flushCache() error {
 	return nil
 }
 
+// rowCountEstimate returns the number of bits set in a row. The cached count
+// is used when available, otherwise the count is read from the containers
+// without materializing the row.
+func (f *fragment) rowCountEstimate(rowID uint64) uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if n := f.cache.Get(rowID); n > 0 {
+		return n
+	}
+	return f.storage.CountRange(rowID*ShardWidth, (rowID+1)*ShardWidth)
+}
+
+// bitCount returns the number of bits set in the fragment.
+func (f *fragment) bitCount() uint64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.storage.Count()
+}
+
+// setStats replaces the stats client of the fragment.
+func (f *fragment) setStats(sc stats.StatsClient) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stats = sc
+}
+
+// cacheBytes returns the approximate heap size of the fragment's cache.
+func (f *fragment) cacheBytes() int64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.cache == nil {
+		return 0
+	}
+	return int64(f.cache.Len()) * cacheEntrySize
+}
+
+// evictCache flushes the cache to disk and releases it. The cache is
+// reloaded from disk the next time it is used.
+func (f *fragment) evictCache() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.cache == nil || f.CacheType == CacheTypeNone {
+		return nil
+	} else if _, ok := f.cache.(*evictedCache); ok {
+		return nil
+	}
+
+	if err := f.flushCache(); err != nil {
+		return errors.Wrap(err, "flushing cache")
+	}
+	f.cache = &evictedCache{f: f}
+	return nil
+}
+
+// prefetch reads the fragment's data file sequentially so that its pages are
+// in the operating system's page cache before they are accessed randomly. It
+// returns the number of bytes read.
+func (f *fragment) prefetch() (int64, error) {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return 0, errors.Wrap(err, "opening file")
+	}
+	defer file.Close()
+
+	n, err := io.Copy(ioutil.Discard, file)
+	return n, errors.Wrap(err, "reading file")
+}
+
 // WriteTo writes the fragment's data to w.
 func (f *fragment) WriteTo(w io.Writer) (n int64, err error) {
 	// Force cache flush.
@@ -1828,16 +2192,17 @@ func (f *fragment) WriteTo(w io.Writer) (n int64, err error) {
 
 	// Write out data and cache to a tar archive.
 	tw := tar.NewWriter(w)
-	if err := f.writeStorageToArchive(tw); err != nil {
+	if err := f.writeStorageToArchive(tw, "data"); err != nil {
 		return 0, fmt.Errorf("write storage: %s", err)
 	}
-	if err := f.writeCacheToArchive(tw); err != nil {
+	if err := f.writeCacheToArchive(tw, "cache"); err != nil {
 		return 0, fmt.Errorf("write cache: %s", err)
 	}
 	return 0, nil
 }
 
-func (f *fragment) writeStorageToArchive(tw *tar.Writer) error {
+// writeStorageToArchive writes the fragment's data file to tw as the named file.
+func (f *fragment) writeStorageToArchive(tw *tar.Writer, name string) error {
 	// Open separate file descriptor to read from.
 	file, err := os.Open(f.path)
 	if err != nil {
@@ -1865,7 +2230,7 @@ func (f *fragment) writeStorageToArchive(tw *tar.Writer) error {
 
 	// Write archive header.
 	if err := tw.WriteHeader(&tar.Header{
-		Name:    "data",
+		Name:    name,
 		Mode:    0600,
 		Size:    sz,
 		ModTime: time.Now(),
@@ -1881,7 +2246,9 @@ func (f *fragment) writeStorageToArchive(tw *tar.Writer) error {
 	return nil
 }
 
-func (f *fragment) writeCacheToArchive(tw *tar.Writer) error {
+// writeCacheToArchive writes the fragment's cache file, if any, to tw as the
+// named file.
+func (f *fragment) writeCacheToArchive(tw *tar.Writer, name string) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
@@ -1895,7 +2262,7 @@ func (f *fragment) writeCacheToArchive(tw *tar.Writer) error {
 
 	// Write archive header.
 	if err := tw.WriteHeader(&tar.Header{
-		Name:    "cache",
+		Name:    name,
 		Mode:    0600,
 		Size:    int64(len(buf)),
 		ModTime: time.Now(),
@@ -1992,6 +2359,44 @@ func (f *fragment) readCacheFromArchive(r io.Reader) error {
 	return nil
 }
 
+// cloneStorage returns a copy of the fragment's bitmap.
+func (f *fragment) cloneStorage() *roaring.Bitmap {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.storage.Clone()
+}
+
+// readArchiveStorage returns the bitmap stored in a fragment archive written
+// by fragment.WriteTo, without creating a fragment.
+func readArchiveStorage(r io.Reader) (*roaring.Bitmap, error) {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, errors.New("fragment archive has no data file")
+		} else if err != nil {
+			return nil, errors.Wrap(err, "opening")
+		} else if hdr.Name != "data" {
+			continue
+		}
+
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading storage")
+		}
+		if isGzipData(data) {
+			if data, err = decompressStorageData(data); err != nil {
+				return nil, errors.Wrap(err, "decompressing storage")
+			}
+		}
+		bm := roaring.NewBitmap()
+		if err := bm.UnmarshalBinary(data); err != nil {
+			return nil, errors.Wrap(err, "unmarshaling storage")
+		}
+		return bm, nil
+	}
+}
+
 // rowFilter is a function signature for controlling iteration over containers
 // in a fragment. It will be invoked on each container found and returns two
 // booleans. The first is whether the row this container is in should be
@@ -689,7 +689,7 @@ func benchmarkImportValues(b *testing.B, bitDepth uint, f *fragment, cfunc func(
 		column = cfunc(column)
 	}
 	b.StartTimer()
-	err := f.importValue(columns, values, bitDepth, false)
+	err := f.importValue(columns, values, nil, bitDepth, false)
 	if err != nil {
 		b.Fatalf("error importing values: %s", err)
 	}
@@ -742,6 +742,52 @@ func TestFragment_Snapshot(t *testing.T) {
 	}
 }
 
+// Ensure a fragment can write a compressed snapshot and replay ops appended after it.
+func TestFragment_Snapshot_Gzip(t *testing.T) {
+	f := mustOpenFragment("i", "f", viewStandard, 0, "")
+	defer f.Clean(t)
+	f.compression = CompressionGzip
+
+	if _, err := f.setBit(1000, 1); err != nil {
+		t.Fatal(err)
+	} else if _, err := f.setBit(1000, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	// Snapshot bitmap and verify the file is compressed.
+	if err := f.Snapshot(); err != nil {
+		t.Fatal(err)
+	} else if buf, err := ioutil.ReadFile(f.path); err != nil {
+		t.Fatal(err)
+	} else if !isGzipData(buf) {
+		t.Fatal("expected gzip snapshot")
+	} else if n := f.row(1000).Count(); n != 2 {
+		t.Fatalf("unexpected count: %d", n)
+	}
+
+	// Append an op after the compressed snapshot.
+	if _, err := f.setBit(1000, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// Close and reopen the fragment & verify the data.
+	if err := f.reopen(); err != nil {
+		t.Fatal(err)
+	} else if n := f.row(1000).Count(); n != 3 {
+		t.Fatalf("unexpected count (reopen): %d", n)
+	}
+
+	// Snapshot again uncompressed and verify the data is still readable.
+	f.compression = CompressionNone
+	if err := f.Snapshot(); err != nil {
+		t.Fatal(err)
+	} else if err := f.reopen(); err != nil {
+		t.Fatal(err)
+	} else if n := f.row(1000).Count(); n != 3 {
+		t.Fatalf("unexpected count (uncompressed): %d", n)
+	}
+}
+
 // Ensure a fragment can iterate over all bits in order.
 func TestFragment_ForEachBit(t *testing.T) {
 	f := mustOpenFragment("i", "f", viewStandard, 0, "")
@@ -2375,6 +2421,38 @@ func TestFragment_RoaringImportTopN(t *testing.T) {
 	}
 }
 
+// Ensure reverting columns restores only those columns.
+func TestFragment_RevertColumns(t *testing.T) {
+	f := mustOpenFragment("i", "f", viewStandard, 0, CacheTypeRanked)
+	defer f.Clean(t)
+
+	if err := f.bulkImport([]uint64{1, 1, 2}, []uint64{1, 2, 2}, &ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	prev := f.storageCopy()
+
+	if err := f.bulkImport([]uint64{1, 3, 3}, []uint64{3, 2, 4}, &ImportOptions{}); err != nil {
+		t.Fatal(err)
+	} else if err := f.bulkImport([]uint64{1}, []uint64{1}, &ImportOptions{Clear: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Columns 1 and 2 are restored, columns 3 and 4 keep their new bits.
+	if err := f.revertColumns(prev, []uint64{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	for rowID, exp := range map[uint64][]uint64{1: {1, 2, 3}, 2: {2}, 3: {4}} {
+		if cols := f.row(rowID).Columns(); !reflect.DeepEqual(cols, exp) {
+			t.Fatalf("row %d: expected %v, got %v", rowID, exp, cols)
+		}
+	}
+	for rowID, exp := range map[uint64]uint64{1: 3, 2: 1, 3: 1} {
+		if n := f.cache.Get(rowID); n != exp {
+			t.Fatalf("row %d: expected cached count %d, got %d", rowID, exp, n)
+		}
+	}
+}
+
 func toRowsCols(roaring []uint64) (rowIDs, colIDs []uint64) {
 	rowIDs, colIDs = make([]uint64, len(roaring)), make([]uint64, len(roaring))
 	for i, bit := range roaring {
@@ -1,6 +1,8 @@
 package pilosa
 
 import (
+	"crypto/sha256"
+	"encoding/binary"
 	"encoding/json"
 )
 
@@ -16,6 +18,11 @@ type QueryRequest struct {
 	// If empty, all shards are included.
 	Shards []uint64
 
+	// If set, the query is executed against only this shard. Data in other
+	// shards is ignored so results may be partial. Cannot be combined with
+	// Shards.
+	SingleShard *uint64
+
 	// Return column attributes, if true.
 	ColumnAttrs bool
 
@@ -28,6 +35,59 @@ type QueryRequest struct {
 	// If true, indicates that query is part of a larger distributed query.
 	// If false, this request is on the originating node.
 	Remote bool
+
+	// If set, Row calls read from this view rather than the standard view.
+	View string
+
+	// If set, columns are removed from row results unless their column
+	// attributes match every key/value pair. Each returned column requires a
+	// lookup in the column attribute store, so filtering large rows is slow.
+	AttrFilter map[string]interface{}
+
+	// If true, results from keyed indexes and fields include both ids and
+	// keys. By default ids are replaced by keys.
+	IncludeKeys bool
+
+	// The principal on whose behalf the query is made. Queries which
+	// reference a field with an access control list must be made by one of
+	// the principals it allows.
+	Principal string
+
+	// If true, Row calls which reference a field that does not exist
+	// return an empty row instead of ErrFieldNotFound.
+	TreatMissingAsEmpty bool
+
+	// If true, results are returned in a deterministic order: TopN pairs
+	// are sorted by descending count and then ascending id. Row columns
+	// and GroupBy results are always sorted.
+	SortResults bool
+
+	// If true, the response may be served from, and is stored in, a cache
+	// of query results on the receiving node. Cached results are used only
+	// until data in the index changes. Ignored on clusters of more than one
+	// node.
+	CacheResults bool
+
+	// If true, errors reading row or column attributes are logged and the
+	// results are returned without those attributes, instead of failing
+	// the query.
+	AttrsBestEffort bool
+
+	// If set, the cost of the query is added to the usage of this account
+	// on the receiving node. See API.AccountUsage.
+	AccountID string
+
+	// If true, TopN calls return a list of PairAttrs holding the attributes
+	// of each row, unless ExcludeRowAttrs is also set.
+	IncludeRowAttrs bool
+
+	// If positive, at most this many shards are computed concurrently by
+	// each node executing the query. Zero means no limit.
+	MaxParallelism int
+
+	// If true, the serialized response is gzipped. The HTTP handler sets the
+	// Content-Encoding header of compressed responses to "gzip".
+	CompressResults bool
 }
 
 // QueryResponse represent a response from a processed query.
@@ -83,6 +143,13 @@ type ImportValueRequest struct {
 	ColumnIDs  []uint64
 	ColumnKeys []string
 	Values     []int64
+
+	// Null, if set, is parallel to Values. Columns marked null have their
+	// value cleared rather than set.
+	Null []bool
+
+	// The principal on whose behalf the import is made.
+	Principal string
 }
 
 type ImportRequest struct {
@@ -94,15 +161,96 @@ type ImportRequest struct {
 	RowKeys    []string
 	ColumnKeys []string
 	Timestamps []int64
+
+	// If true, a node which does not own the shard forwards the import to
+	// the owning nodes instead of returning ErrClusterDoesNotOwnShard.
+	AllowForward bool
+
+	// If set, the import is rejected with ErrImportChecksumMismatch unless
+	// it matches the value returned by Checksum.
+	PayloadChecksum []byte
+
+	// The principal on whose behalf the import is made.
+	Principal string
+
+	// If true, bits which were already set before the import are returned
+	// in the response.
+	ReportDuplicates bool
+
+	// Name of an import transform, optionally followed by a colon and an
+	// argument, which is applied to the row and column ids before they
+	// are imported.
+	Transform string
+
+	// If set, Weights holds a value for each column which is imported into
+	// the int field WeightField along with the bits.
+	WeightField string
+	Weights     []int64
+}
+
+// Checksum returns the SHA-256 hash of the request's row ids, column ids, row
+// keys, column keys and timestamps. Each list is hashed in that order as its
+// length followed by its elements. Lengths, ids and timestamps are written as
+// 8-byte big-endian integers, and each key as its length followed by its bytes.
+func (r *ImportRequest) Checksum() []byte {
+	h := sha256.New()
+	var buf [8]byte
+	writeUint64 := func(v uint64) {
+		binary.BigEndian.PutUint64(buf[:], v)
+		h.Write(buf[:])
+	}
+	writeStrings := func(a []string) {
+		writeUint64(uint64(len(a)))
+		for _, s := range a {
+			writeUint64(uint64(len(s)))
+			h.Write([]byte(s))
+		}
+	}
+
+	for _, ids := range [][]uint64{r.RowIDs, r.ColumnIDs} {
+		writeUint64(uint64(len(ids)))
+		for _, id := range ids {
+			writeUint64(id)
+		}
+	}
+	writeStrings(r.RowKeys)
+	writeStrings(r.ColumnKeys)
+	writeUint64(uint64(len(r.Timestamps)))
+	for _, ts := range r.Timestamps {
+		writeUint64(uint64(ts))
+	}
+
+	// Weights are only included if set, so that the checksum of requests
+	// without them is unchanged.
+	if r.WeightField != "" {
+		writeStrings([]string{r.WeightField})
+		writeUint64(uint64(len(r.Weights)))
+		for _, w := range r.Weights {
+			writeUint64(uint64(w))
+		}
+	}
+	return h.Sum(nil)
 }
 
 type ImportRoaringRequest struct {
 	Clear bool
 	Views map[string][]byte
+
+	// The principal on whose behalf the import is made. It is not encoded;
+	// clients pass it as a URL argument.
+	Principal string
 }
 
 type ImportResponse struct {
 	Err string
+
+	// Bits which were already set, if requested by
+	// ImportRequest.ReportDuplicates.
+	Duplicates []Bit
+
+	// Sequence number of the import within its shard, if the server
+	// orders imports.
+	Sequence uint64
 }
 
 type BlockDataRequest struct {
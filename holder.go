@@ -15,6 +15,7 @@
 package pilosa
 
 import (
+	"compress/gzip"
 	"context"
 	"fmt"
 	"io/ioutil"
@@ -24,6 +25,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -53,6 +55,10 @@ type Holder struct {
 	// Indexes by name.
 	indexes map[string]*Index
 
+	// Index names reserved by PrepareCreateIndex, mapped to the options the
+	// index will be created with when committed.
+	reservedIndexes map[string]IndexOptions
+
 	// Key/ID translation
 	translateFile            *TranslateFile
 	NewPrimaryTranslateStore func(interface{}) TranslateStore
@@ -77,6 +83,30 @@ type Holder struct {
 	// The interval at which the cached row ids are persisted to disk.
 	cacheFlushInterval time.Duration
 
+	// Maximum approximate bytes used by all fragment caches. When exceeded,
+	// the caches of the least recently used fields are evicted. Zero means
+	// no limit. Accessed atomically.
+	maxTotalCacheBytes int64
+
+	// Maximum number of fields which may be created in each index. Zero
+	// means no limit. Accessed atomically.
+	maxFieldsPerIndex int64
+
+	// If non-zero, the next fragment is read ahead during sequential exports.
+	// Accessed atomically.
+	prefetch int32
+
+	// If non-zero, deleted fields are moved to the trash rather than
+	// removed. Accessed atomically.
+	trashDeletedFields int32
+
+	// Gzip level of fragment snapshots. Zero means the default level.
+	// Accessed atomically.
+	snapshotCompressionLevel int32
+
+	// Cost of the queries made for each account.
+	accountUsage accountUsages
+
 	Logger logger.Logger
 }
 
@@ -109,8 +139,9 @@ func (lc *lockedChan) Recv() {
 // NewHolder returns a new instance of Holder.
 func NewHolder() *Holder {
 	return &Holder{
-		indexes: make(map[string]*Index),
-		closing: make(chan struct{}),
+		indexes:         make(map[string]*Index),
+		reservedIndexes: make(map[string]IndexOptions),
+		closing:         make(chan struct{}),
 
 		opened: lockedChan{ch: make(chan struct{})},
 
@@ -353,6 +384,19 @@ func (h *Holder) Indexes() []*Index {
 	return a
 }
 
+// openFileCount returns the number of files the holder's indexes and
+// translate store have open.
+func (h *Holder) openFileCount() int {
+	var n int
+	for _, index := range h.Indexes() {
+		n += index.openFileCount()
+	}
+	if h.translateFile != nil {
+		n += h.translateFile.openFileCount()
+	}
+	return n
+}
+
 // CreateIndex creates an index.
 // An error is returned if the index already exists.
 func (h *Holder) CreateIndex(name string, opt IndexOptions) (*Index, error) {
@@ -362,10 +406,59 @@ func (h *Holder) CreateIndex(name string, opt IndexOptions) (*Index, error) {
 	// Ensure index doesn't already exist.
 	if h.indexes[name] != nil {
 		return nil, newConflictError(ErrIndexExists)
+	} else if _, ok := h.reservedIndexes[name]; ok {
+		return nil, newConflictError(ErrIndexReserved)
 	}
 	return h.createIndex(name, opt)
 }
 
+// prepareIndex reserves an index name so that it can only be created by a
+// later call to commitIndex. Preparing the same name with the same options
+// more than once is allowed.
+func (h *Holder) prepareIndex(name string, opt IndexOptions) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := validateName(name); err != nil {
+		return NewBadRequestError(err)
+	} else if h.indexes[name] != nil {
+		return newConflictError(ErrIndexExists)
+	} else if reserved, ok := h.reservedIndexes[name]; ok && reserved != opt {
+		return newConflictError(ErrIndexReserved)
+	}
+	h.reservedIndexes[name] = opt
+	return nil
+}
+
+// commitIndex creates an index previously reserved by prepareIndex. If the
+// index has already been committed, it is returned.
+func (h *Holder) commitIndex(name string) (*Index, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	opt, ok := h.reservedIndexes[name]
+	if !ok {
+		if index := h.indexes[name]; index != nil {
+			return index, nil
+		}
+		return nil, newNotFoundError(ErrIndexNotReserved)
+	}
+
+	index, err := h.createIndex(name, opt)
+	if err != nil {
+		return nil, err
+	}
+	delete(h.reservedIndexes, name)
+	return index, nil
+}
+
+// abortIndex releases an index name reserved by prepareIndex.
+func (h *Holder) abortIndex(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.reservedIndexes, name)
+}
+
 // CreateIndexIfNotExists returns an index by name.
 // The index is created if it does not already exist.
 func (h *Holder) CreateIndexIfNotExists(name string, opt IndexOptions) (*Index, error) {
@@ -390,6 +483,12 @@ func (h *Holder) createIndex(name string, opt IndexOptions) (*Index, error) {
 		return index, nil
 	}
 
+	if opt.AttrStore != "" {
+		if _, err := attrStoreByName(opt.AttrStore); err != nil {
+			return nil, NewBadRequestError(err)
+		}
+	}
+
 	// Otherwise create a new index.
 	index, err := h.newIndex(h.IndexPath(name), name)
 	if err != nil {
@@ -398,6 +497,7 @@ func (h *Holder) createIndex(name string, opt IndexOptions) (*Index, error) {
 
 	index.keys = opt.Keys
 	index.trackExistence = opt.TrackExistence
+	index.attrStore = opt.AttrStore
 
 	if err := index.Open(); err != nil {
 		return nil, errors.Wrap(err, "opening")
@@ -421,9 +521,94 @@ func (h *Holder) newIndex(path, name string) (*Index, error) {
 	index.broadcaster = h.broadcaster
 	index.newAttrStore = h.NewAttrStore
 	index.columnAttrs = h.NewAttrStore(filepath.Join(index.path, ".data"))
+	index.maxFields = h.MaxFieldsPerIndex
+	index.snapshotCompressionLevel = h.SnapshotCompressionLevel
+	index.trashPath = func() string {
+		if !h.TrashDeletedFields() {
+			return ""
+		}
+		return h.trashPath(name)
+	}
 	return index, nil
 }
 
+// holdIndex returns the named index, or nil if it does not exist, and a func
+// which releases it. reloadIndex waits for held indexes to be released before
+// closing them, and new holds wait for the reload. A caller must not hold the
+// same index twice, as the second hold would wait for a reload which is
+// waiting for the first.
+func (h *Holder) holdIndex(name string) (*Index, func()) {
+	for {
+		index := h.Index(name)
+		if index == nil {
+			return nil, func() {}
+		}
+		index.inUse.RLock()
+
+		// The index may have been replaced while waiting for a reload.
+		if h.Index(name) == index {
+			return index, index.inUse.RUnlock
+		}
+		index.inUse.RUnlock()
+	}
+}
+
+// reloadIndex closes an index and opens it again from its files on disk, so
+// that changes made to them by another process are picked up. It waits for
+// queries and exports holding the index to finish first. If the index cannot
+// be reopened, the old index is opened again and kept. If it cannot be closed
+// cleanly, it is removed from the holder.
+func (h *Holder) reloadIndex(name string) error {
+	for {
+		old := h.Index(name)
+		if old == nil {
+			return newNotFoundError(ErrIndexNotFound)
+		}
+
+		old.inUse.Lock()
+		done, err := h.replaceIndex(name, old)
+		old.inUse.Unlock()
+		if done {
+			return err
+		}
+	}
+}
+
+// replaceIndex closes old and replaces it with a new index opened from the
+// same path. Returns false if old is no longer the named index, as another
+// reload replaced it while waiting.
+func (h *Holder) replaceIndex(name string, old *Index) (bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.index(name) != old {
+		return false, nil
+	}
+
+	h.Logger.Printf("reloading index: %s", name)
+	if err := old.Close(); err != nil {
+		delete(h.indexes, name)
+		return true, errors.Wrap(err, "closing index")
+	}
+
+	index, err := h.newIndex(old.Path(), name)
+	if err != nil {
+		err = errors.Wrap(err, "creating index")
+	} else if err = index.Open(); err != nil {
+		index.Close()
+		err = errors.Wrap(err, "opening index")
+	}
+	if err != nil {
+		if oerr := old.Open(); oerr != nil {
+			delete(h.indexes, name)
+			h.Logger.Printf("reopening old index %s after failed reload: %v", name, oerr)
+		}
+		return true, err
+	}
+	h.indexes[name] = index
+	return true, nil
+}
+
 // DeleteIndex removes an index from the holder.
 func (h *Holder) DeleteIndex(name string) error {
 	h.mu.Lock()
@@ -490,6 +675,7 @@ func (h *Holder) monitorCacheFlush() {
 			return
 		case <-ticker.C:
 			h.flushCaches()
+			h.enforceCacheLimit()
 		}
 	}
 }
@@ -514,6 +700,197 @@ func (h *Holder) flushCaches() {
 	}
 }
 
+// MaxTotalCacheBytes returns the limit on bytes used by all fragment caches.
+func (h *Holder) MaxTotalCacheBytes() int64 {
+	return atomic.LoadInt64(&h.maxTotalCacheBytes)
+}
+
+// SetMaxTotalCacheBytes sets the limit on bytes used by all fragment caches
+// and evicts caches if the new limit is exceeded. Zero or less disables the
+// limit.
+func (h *Holder) SetMaxTotalCacheBytes(n int64) {
+	if n < 0 {
+		n = 0
+	}
+	atomic.StoreInt64(&h.maxTotalCacheBytes, n)
+	h.enforceCacheLimit()
+}
+
+// MaxFieldsPerIndex returns the maximum number of fields which may be created
+// in each index.
+func (h *Holder) MaxFieldsPerIndex() int {
+	return int(atomic.LoadInt64(&h.maxFieldsPerIndex))
+}
+
+// SetMaxFieldsPerIndex sets the maximum number of fields which may be created
+// in each index. Indexes already over the limit remain usable but reject new
+// fields. Zero or less disables the limit.
+func (h *Holder) SetMaxFieldsPerIndex(n int) {
+	if n < 0 {
+		n = 0
+	}
+	atomic.StoreInt64(&h.maxFieldsPerIndex, int64(n))
+}
+
+// Prefetch returns true if fragments are read ahead during sequential exports.
+func (h *Holder) Prefetch() bool {
+	return atomic.LoadInt32(&h.prefetch) != 0
+}
+
+// SetPrefetch enables or disables reading fragments ahead during sequential
+// exports.
+func (h *Holder) SetPrefetch(v bool) {
+	var n int32
+	if v {
+		n = 1
+	}
+	atomic.StoreInt32(&h.prefetch, n)
+}
+
+// TrashDeletedFields returns true if deleted fields are moved to the trash,
+// from which they can be restored until purged.
+func (h *Holder) TrashDeletedFields() bool {
+	return atomic.LoadInt32(&h.trashDeletedFields) != 0
+}
+
+// SetTrashDeletedFields enables or disables moving deleted fields to the
+// trash rather than removing them.
+func (h *Holder) SetTrashDeletedFields(v bool) {
+	var n int32
+	if v {
+		n = 1
+	}
+	atomic.StoreInt32(&h.trashDeletedFields, n)
+}
+
+// SnapshotCompressionLevel returns the gzip level used for fragment snapshots
+// of fields with gzip compression. Zero means the default level.
+func (h *Holder) SnapshotCompressionLevel() int {
+	return int(atomic.LoadInt32(&h.snapshotCompressionLevel))
+}
+
+// SetSnapshotCompressionLevel sets the gzip level used for fragment snapshots,
+// from 1 for the fastest to 9 for the smallest. Zero restores the default
+// level. Fragments already on disk keep their level until they are next
+// snapshotted.
+func (h *Holder) SetSnapshotCompressionLevel(n int) error {
+	if n < 0 || n > gzip.BestCompression {
+		return errors.Errorf("snapshot compression level must be between 0 and %d: %d", gzip.BestCompression, n)
+	}
+	atomic.StoreInt32(&h.snapshotCompressionLevel, int32(n))
+	return nil
+}
+
+// prefetchNextFragment reads the local fragment with the lowest shard after
+// shard in the background, if prefetching is enabled. Exports which iterate
+// over shards in order then find the next fragment already in memory. The
+// returned channel is closed once the read finishes, or is nil if nothing is
+// prefetched.
+func (h *Holder) prefetchNextFragment(index, field, view string, shard uint64) <-chan struct{} {
+	if !h.Prefetch() {
+		return nil
+	}
+	next := h.nextFragment(index, field, view, shard)
+	if next == nil {
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := next.prefetch(); err != nil {
+			h.Logger.Printf("prefetch fragment error: path=%s, err=%s", next.path, err)
+		}
+	}()
+	return done
+}
+
+// nextFragment returns the local fragment with the lowest shard after shard,
+// or nil if there is none.
+func (h *Holder) nextFragment(index, field, view string, shard uint64) *fragment {
+	f := h.Field(index, field)
+	if f == nil {
+		return nil
+	}
+	v := f.view(view)
+	if v == nil {
+		return nil
+	}
+
+	var next *fragment
+	for _, frag := range v.allFragments() {
+		if frag.shard > shard && (next == nil || frag.shard < next.shard) {
+			next = frag
+		}
+	}
+	return next
+}
+
+// cacheBytes returns the approximate number of bytes used by all fragment caches.
+func (h *Holder) cacheBytes() int64 {
+	var n int64
+	for _, index := range h.Indexes() {
+		for _, field := range index.Fields() {
+			for _, view := range field.views() {
+				for _, frag := range view.allFragments() {
+					n += frag.cacheBytes()
+				}
+			}
+		}
+	}
+	return n
+}
+
+// fieldCacheUsage is the combined cache usage of a field's fragments.
+type fieldCacheUsage struct {
+	fragments []*fragment
+	bytes     int64
+	used      int64
+}
+
+// enforceCacheLimit evicts the caches of the least recently used fields
+// until the total cache size is within the limit. It also reports the total
+// cache size as a stat.
+func (h *Holder) enforceCacheLimit() {
+	var total int64
+	var usages []*fieldCacheUsage
+	for _, index := range h.Indexes() {
+		for _, field := range index.Fields() {
+			u := &fieldCacheUsage{}
+			for _, view := range field.views() {
+				for _, frag := range view.allFragments() {
+					u.fragments = append(u.fragments, frag)
+					u.bytes += frag.cacheBytes()
+					if used := atomic.LoadInt64(&frag.cacheUsed); used > u.used {
+						u.used = used
+					}
+				}
+			}
+			if u.bytes > 0 {
+				total += u.bytes
+				usages = append(usages, u)
+			}
+		}
+	}
+
+	max := h.MaxTotalCacheBytes()
+	if max > 0 && total > max {
+		sort.Slice(usages, func(i, j int) bool { return usages[i].used < usages[j].used })
+		for _, u := range usages {
+			if total <= max {
+				break
+			}
+			for _, frag := range u.fragments {
+				if err := frag.evictCache(); err != nil {
+					h.Logger.Printf("ERROR evicting cache: err=%s, path=%s", err, frag.cachePath())
+				}
+			}
+			total -= u.bytes
+		}
+	}
+	h.statsClient().Gauge("cacheBytes", float64(total), 1.0)
+}
+
 // recalculateCaches recalculates caches on every index in the holder. This is
 // probably not practical to call in real-world workloads, but makes writing
 // integration tests much eaiser, since one doesn't have to wait 10 seconds
@@ -524,6 +901,27 @@ func (h *Holder) recalculateCaches() {
 	}
 }
 
+// setStats replaces the stats client of the holder and of every index
+// beneath it. Clients for indexes are derived from sc with the same tags they
+// were created with.
+func (h *Holder) setStats(sc stats.StatsClient) {
+	h.mu.Lock()
+	h.Stats = sc
+	h.mu.Unlock()
+
+	for _, index := range h.Indexes() {
+		index.setStats(sc.WithTags(fmt.Sprintf("index:%s", index.Name())))
+	}
+}
+
+// statsClient returns the stats client used by the holder. It is safe to call
+// while setStats replaces the client.
+func (h *Holder) statsClient() stats.StatsClient {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.Stats
+}
+
 // setFileLimit attempts to set the open file limit to the FileLimit constant defined above.
 func (h *Holder) setFileLimit() {
 	oldLimit := &syscall.Rlimit{}
@@ -722,6 +1120,33 @@ func (s *holderSyncer) SyncHolder() error {
 	return nil
 }
 
+// syncShard synchronizes every fragment of a shard of an index with the other
+// owners of the shard.
+func (s *holderSyncer) syncShard(index string, shard uint64) error {
+	s.mu.Lock() // do not run alongside SyncHolder
+	defer s.mu.Unlock()
+
+	if !s.Cluster.ownsShard(s.Node.ID, index, shard) {
+		return nil
+	}
+	for _, di := range s.Holder.Schema() {
+		if di.Name != index {
+			continue
+		}
+		for _, fi := range di.Fields {
+			for _, vi := range fi.Views {
+				if s.IsClosing() {
+					return nil
+				}
+				if err := s.syncFragment(di.Name, fi.Name, vi.Name, shard); err != nil {
+					return fmt.Errorf("fragment sync error: index=%s, field=%s, view=%s, shard=%d, err=%s", di.Name, fi.Name, vi.Name, shard, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
 // syncIndex synchronizes index attributes with the rest of the cluster.
 func (s *holderSyncer) syncIndex(index string) error {
 	span, ctx := tracing.StartSpanFromContext(context.Background(), "HolderSyncer.syncIndex")
@@ -15,12 +15,17 @@
 package pilosa
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/pilosa/pilosa/roaring"
 )
@@ -174,6 +179,205 @@ func TestHolder_Optn(t *testing.T) {
 
 }
 
+// Ensure the holder evicts the caches of the least recently used fields when
+// the cache limit is exceeded, and that evicted caches reload when queried.
+func TestHolder_EnforceCacheLimit(t *testing.T) {
+	h := newHolder()
+	defer h.Close()
+
+	for rowID := uint64(0); rowID < 10; rowID++ {
+		h.SetBit("i", "f0", rowID, rowID+1)
+		h.SetBit("i", "f1", rowID, 1)
+	}
+	frag0 := h.Field("i", "f0").view(viewStandard).Fragment(0)
+	frag1 := h.Field("i", "f1").view(viewStandard).Fragment(0)
+
+	// Use f0 before f1 so that f0 is evicted first.
+	frag0.topBitmapPairs(nil)
+	time.Sleep(time.Millisecond)
+	frag1.topBitmapPairs(nil)
+
+	if n := h.cacheBytes(); n != 20*cacheEntrySize {
+		t.Fatalf("unexpected cache bytes: %d", n)
+	}
+
+	h.SetMaxTotalCacheBytes(15 * cacheEntrySize)
+	if _, ok := frag0.cache.(*evictedCache); !ok {
+		t.Fatalf("expected f0 cache to be evicted: %T", frag0.cache)
+	} else if _, ok := frag1.cache.(*evictedCache); ok {
+		t.Fatal("expected f1 cache to be retained")
+	} else if n := h.cacheBytes(); n != 10*cacheEntrySize {
+		t.Fatalf("unexpected cache bytes after eviction: %d", n)
+	}
+
+	// Querying the evicted cache reloads it from disk.
+	if pairs := frag0.topBitmapPairs(nil); len(pairs) != 10 {
+		t.Fatalf("unexpected pairs after reload: %v", pairs)
+	} else if _, ok := frag0.cache.(*evictedCache); ok {
+		t.Fatal("expected f0 cache to be reloaded")
+	}
+}
+
+// Ensure the holder reads the next fragment ahead when prefetching is enabled.
+func TestHolder_Prefetch(t *testing.T) {
+	h := newHolder()
+	defer h.Close()
+
+	if h.Prefetch() {
+		t.Fatal("expected prefetch to be disabled by default")
+	}
+	h.SetPrefetch(true)
+	if !h.Prefetch() {
+		t.Fatal("expected prefetch to be enabled")
+	}
+
+	h.SetBit("i", "f", 1, 1)
+	h.SetBit("i", "f", 1, 2*ShardWidth+1)
+	h.SetBit("i", "f", 1, 5*ShardWidth+1)
+	frag := h.Field("i", "f").view(viewStandard).Fragment(2)
+	if err := frag.Snapshot(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The next local fragment is read ahead, skipping missing shards.
+	if next := h.nextFragment("i", "f", viewStandard, 0); next != frag {
+		t.Fatalf("unexpected next fragment: %v", next)
+	} else if fi, err := os.Stat(frag.path); err != nil {
+		t.Fatal(err)
+	} else if n, err := frag.prefetch(); err != nil {
+		t.Fatal(err)
+	} else if n != fi.Size() || n == 0 {
+		t.Fatalf("unexpected prefetch size: %d, file size %d", n, fi.Size())
+	}
+	if done := h.prefetchNextFragment("i", "f", viewStandard, 0); done == nil {
+		t.Fatal("expected prefetch of shard 2")
+	} else {
+		<-done
+	}
+
+	// Prefetching a missing field or after the last shard is a no-op.
+	if done := h.prefetchNextFragment("i", "x", viewStandard, 0); done != nil {
+		t.Fatal("expected no prefetch for missing field")
+	} else if done := h.prefetchNextFragment("i", "f", viewStandard, 5); done != nil {
+		t.Fatal("expected no prefetch after last shard")
+	}
+
+	h.SetPrefetch(false)
+	if done := h.prefetchNextFragment("i", "f", viewStandard, 0); done != nil {
+		t.Fatal("expected no prefetch when disabled")
+	}
+}
+
+func TestHolder_BackupRestoreIndexOwnedShards(t *testing.T) {
+	h := newHolder()
+	defer h.Close()
+
+	h.SetBit("i", "f", 1, 1)
+	h.SetBit("i", "f", 1, 2*ShardWidth+1)
+	ctx := context.Background()
+
+	// Shards which aren't owned are left out of the backup.
+	var buf bytes.Buffer
+	if _, err := h.backupIndex(ctx, "i", &buf, func(shard uint64) bool { return shard != 2 }); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	br := bufio.NewReader(bytes.NewReader(buf.Bytes()))
+	for {
+		rec, err := readBackupRecord(br)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, rec.String())
+	}
+	if exp := []string{"schema", "column attrs", "column keys", "row attrs: field=f", "row keys: field=f", "fragment: field=f, view=standard, shard=0"}; !reflect.DeepEqual(names, exp) {
+		t.Fatalf("unexpected backup records: %v", names)
+	}
+
+	// And are skipped when restoring.
+	buf.Reset()
+	if _, err := h.backupIndex(ctx, "i", &buf, func(uint64) bool { return true }); err != nil {
+		t.Fatal(err)
+	} else if err := h.restoreIndex(ctx, "j", &buf, func(shard uint64) bool { return shard != 0 }); err != nil {
+		t.Fatal(err)
+	}
+	if cols := h.Row("j", "f", 1).Columns(); !reflect.DeepEqual(cols, []uint64{2*ShardWidth + 1}) {
+		t.Fatalf("unexpected columns: %v", cols)
+	}
+}
+
+// Ensure an index is not reloaded while it is held, and that holds taken
+// during the reload get the new index.
+func TestHolder_ReloadIndexWaitsForHolds(t *testing.T) {
+	h := newHolder()
+	defer h.Close()
+
+	h.SetBit("i", "f", 1, 1)
+	old, release := h.holdIndex("i")
+
+	done := make(chan error, 1)
+	go func() { done <- h.reloadIndex("i") }()
+	select {
+	case err := <-done:
+		t.Fatalf("reload did not wait for hold: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if cols := h.Row("i", "f", 1).Columns(); !reflect.DeepEqual(cols, []uint64{1}) {
+		t.Fatalf("unexpected columns while held: %v", cols)
+	}
+
+	release()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	index, release := h.holdIndex("i")
+	defer release()
+	if index == old || index != h.Index("i") {
+		t.Fatal("expected hold to return the reloaded index")
+	} else if cols := h.Row("i", "f", 1).Columns(); !reflect.DeepEqual(cols, []uint64{1}) {
+		t.Fatalf("unexpected columns after reload: %v", cols)
+	}
+}
+
+func TestHolder_SnapshotCompressionLevel(t *testing.T) {
+	h := newHolder()
+	defer h.Close()
+
+	if err := h.SetSnapshotCompressionLevel(10); err == nil {
+		t.Fatal("expected error")
+	} else if err := h.SetSnapshotCompressionLevel(-1); err == nil {
+		t.Fatal("expected error")
+	}
+
+	f, err := h.MustCreateIndexIfNotExists("i", IndexOptions{}).CreateFieldIfNotExists("f", OptFieldCompression(CompressionGzip))
+	if err != nil {
+		t.Fatal(err)
+	} else if _, err := f.SetBit(1, 1, nil); err != nil {
+		t.Fatal(err)
+	}
+	frag := f.view(viewStandard).Fragment(0)
+
+	// The gzip header records whether the fastest or best compression was
+	// used.
+	for _, tt := range []struct {
+		level int
+		xfl   byte
+	}{{9, 2}, {1, 4}, {0, 0}} {
+		if err := h.SetSnapshotCompressionLevel(tt.level); err != nil {
+			t.Fatal(err)
+		} else if err := frag.Snapshot(); err != nil {
+			t.Fatal(err)
+		}
+		if buf, err := ioutil.ReadFile(frag.path); err != nil {
+			t.Fatal(err)
+		} else if !isGzipData(buf) || buf[8] != tt.xfl {
+			t.Fatalf("unexpected gzip header for level %d: %v", tt.level, buf[:10])
+		}
+	}
+}
+
 // Ensure holder can clean up orphaned fragments.
 func TestHolderCleaner_CleanHolder(t *testing.T) {
 	cluster := NewTestCluster(2)
@@ -26,6 +26,7 @@ import (
 
 	"github.com/pilosa/pilosa"
 	"github.com/pilosa/pilosa/test"
+	"github.com/pkg/errors"
 )
 
 func TestHolder_Open(t *testing.T) {
@@ -270,6 +271,72 @@ func TestHolder_DeleteIndex(t *testing.T) {
 	}
 }
 
+// Ensure holder limits the number of fields created per index.
+func TestHolder_MaxFieldsPerIndex(t *testing.T) {
+	hldr := test.MustOpenHolder()
+	defer hldr.Close()
+
+	hldr.SetMaxFieldsPerIndex(2)
+	idx := hldr.MustCreateIndexIfNotExists("i", pilosa.IndexOptions{TrackExistence: true})
+	for _, name := range []string{"f0", "f1"} {
+		if _, err := idx.CreateField(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := idx.CreateField("f2"); err != pilosa.ErrTooManyFields {
+		t.Fatalf("expected too many fields error, got %v", err)
+	}
+
+	// Indexes over a lowered limit still open but reject new fields.
+	if err := hldr.Holder.Close(); err != nil {
+		t.Fatal(err)
+	} else if err := hldr.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	hldr.SetMaxFieldsPerIndex(1)
+	idx2 := hldr.Index("i")
+	if idx2.Field("f0") == nil || idx2.Field("f1") == nil {
+		t.Fatal("expected existing fields")
+	} else if _, err := idx2.CreateField("f2"); err != pilosa.ErrTooManyFields {
+		t.Fatalf("expected too many fields error, got %v", err)
+	}
+
+	// Removing the limit allows new fields.
+	hldr.SetMaxFieldsPerIndex(0)
+	if _, err := idx2.CreateField("f2"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure an index can select the backend of its column attribute store.
+func TestHolder_IndexAttrStore(t *testing.T) {
+	hldr := test.MustOpenHolder()
+	defer hldr.Close()
+
+	hldr.MustCreateIndexIfNotExists("i0", pilosa.IndexOptions{})
+	hldr.MustCreateIndexIfNotExists("i1", pilosa.IndexOptions{AttrStore: "nop"})
+	if _, err := hldr.CreateIndex("i2", pilosa.IndexOptions{AttrStore: "x"}); err == nil {
+		t.Fatal("expected error for unknown attr store")
+	} else if _, ok := errors.Cause(err).(pilosa.BadRequestError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The selected backend is kept when the holder is reopened.
+	if err := hldr.Holder.Close(); err != nil {
+		t.Fatal(err)
+	} else if err := hldr.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	if idx := hldr.Index("i0"); idx.ColumnAttrStore().Path() == "" {
+		t.Fatal("expected default attr store")
+	}
+	if idx := hldr.Index("i1"); idx.Options().AttrStore != "nop" {
+		t.Fatalf("unexpected attr store option: %q", idx.Options().AttrStore)
+	} else if idx.ColumnAttrStore().Path() != "" {
+		t.Fatalf("expected nop attr store, got %s", idx.ColumnAttrStore().Path())
+	}
+}
+
 // Ensure holder can sync with a remote holder.
 func TestHolderSyncer_SyncHolder(t *testing.T) {
 	c := test.MustNewCluster(t, 2)
@@ -40,6 +40,16 @@ type InternalClient struct {
 
 	// The client to use for HTTP communication.
 	httpClient *http.Client
+
+	// Returns the token sent with every request to mark it as coming from
+	// a node of the cluster. Nil for clients used outside of a server.
+	internalToken func() string
+}
+
+// SetInternalToken sets the function returning the token which marks the
+// client's requests as made by a node of the cluster.
+func (c *InternalClient) SetInternalToken(fn func() string) {
+	c.internalToken = fn
 }
 
 // NewInternalClient returns a new instance of InternalClient to connect to host.
@@ -105,9 +115,20 @@ func (c *InternalClient) maxShardByIndex(ctx context.Context) (map[string]uint64
 func (c *InternalClient) Schema(ctx context.Context) ([]*pilosa.IndexInfo, error) {
 	span, ctx := tracing.StartSpanFromContext(ctx, "InternalClient.Schema")
 	defer span.Finish()
+	return c.schema(ctx, c.defaultURI)
+}
+
+// SchemaNode returns all index and field schema information held by the
+// node at uri.
+func (c *InternalClient) SchemaNode(ctx context.Context, uri *pilosa.URI) ([]*pilosa.IndexInfo, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "InternalClient.SchemaNode")
+	defer span.Finish()
+	return c.schema(ctx, uri)
+}
 
+func (c *InternalClient) schema(ctx context.Context, uri *pilosa.URI) ([]*pilosa.IndexInfo, error) {
 	// Execute request against the host.
-	u := c.defaultURI.Path("/schema")
+	u := uri.Path("/schema")
 
 	// Build request.
 	req, err := http.NewRequest("GET", u, nil)
@@ -319,11 +340,16 @@ func (c *InternalClient) Import(ctx context.Context, index, field string, shard
 		return fmt.Errorf("shard nodes: %s", err)
 	}
 
-	// Import to each node.
+	// Import to each node. The primary owner comes first, and replicas are
+	// sent the sequence number it assigned, if any.
 	for _, node := range nodes {
-		if err := c.importNode(ctx, node, index, field, buf, options); err != nil {
+		resp, err := c.importNode(ctx, node, index, field, buf, options)
+		if err != nil {
 			return fmt.Errorf("import node: host=%s, err=%s", node.URI, err)
 		}
+		if options.Sequence == 0 {
+			options.Sequence = resp.Sequence
+		}
 	}
 
 	return nil
@@ -375,7 +401,7 @@ func (c *InternalClient) ImportK(ctx context.Context, index, field string, bits
 	}
 
 	// Import to node.
-	if err := c.importNode(ctx, coord, index, field, buf, options); err != nil {
+	if _, err := c.importNode(ctx, coord, index, field, buf, options); err != nil {
 		return fmt.Errorf("import node: host=%s, err=%s", coord.URI, err)
 	}
 
@@ -436,7 +462,7 @@ func (c *InternalClient) marshalImportPayload(index, field string, shard uint64,
 }
 
 // importNode sends a pre-marshaled import request to a node.
-func (c *InternalClient) importNode(ctx context.Context, node *pilosa.Node, index, field string, buf []byte, opts *pilosa.ImportOptions) error {
+func (c *InternalClient) importNode(ctx context.Context, node *pilosa.Node, index, field string, buf []byte, opts *pilosa.ImportOptions) (*pilosa.ImportResponse, error) {
 	span, ctx := tracing.StartSpanFromContext(ctx, "InternalClient.importNode")
 	defer span.Finish()
 
@@ -451,11 +477,17 @@ func (c *InternalClient) importNode(ctx context.Context, node *pilosa.Node, inde
 	if opts.IgnoreKeyCheck {
 		vals.Set("ignoreKeyCheck", "true")
 	}
+	if opts.RejectOutOfRange {
+		vals.Set("rejectOutOfRange", "true")
+	}
+	if opts.Sequence != 0 {
+		vals.Set("sequence", strconv.FormatUint(opts.Sequence, 10))
+	}
 	url := fmt.Sprintf("%s?%s", u.String(), vals.Encode())
 
 	req, err := http.NewRequest("POST", url, bytes.NewReader(buf))
 	if err != nil {
-		return errors.Wrap(err, "creating request")
+		return nil, errors.Wrap(err, "creating request")
 	}
 	req.Header.Set("Content-Length", strconv.Itoa(len(buf)))
 	req.Header.Set("Content-Type", "application/x-protobuf")
@@ -465,24 +497,24 @@ func (c *InternalClient) importNode(ctx context.Context, node *pilosa.Node, inde
 	// Execute request against the host.
 	resp, err := c.executeRequest(req.WithContext(ctx))
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	// Read body and unmarshal response.
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return errors.Wrap(err, "reading")
+		return nil, errors.Wrap(err, "reading")
 	}
 
 	var isresp pilosa.ImportResponse
 	if err := c.serializer.Unmarshal(body, &isresp); err != nil {
-		return fmt.Errorf("unmarshal import response: %s", err)
+		return nil, fmt.Errorf("unmarshal import response: %s", err)
 	} else if s := isresp.Err; s != "" {
-		return errors.New(s)
+		return nil, errors.New(s)
 	}
 
-	return nil
+	return &isresp, nil
 }
 
 // ImportValue bulk imports field values for a single shard to a host.
@@ -518,7 +550,7 @@ func (c *InternalClient) ImportValue(ctx context.Context, index, field string, s
 
 	// Import to each node.
 	for _, node := range nodes {
-		if err := c.importNode(ctx, node, index, field, buf, options); err != nil {
+		if _, err := c.importNode(ctx, node, index, field, buf, options); err != nil {
 			return fmt.Errorf("import node: host=%s, err=%s", node.URI, err)
 		}
 	}
@@ -557,7 +589,7 @@ func (c *InternalClient) ImportValueK(ctx context.Context, index, field string,
 	}
 
 	// Import to node.
-	if err := c.importNode(ctx, coord, index, field, buf, options); err != nil {
+	if _, err := c.importNode(ctx, coord, index, field, buf, options); err != nil {
 		return fmt.Errorf("import node: host=%s, err=%s", coord.URI, err)
 	}
 
@@ -570,6 +602,7 @@ func (c *InternalClient) marshalImportValuePayload(index, field string, shard ui
 	columnIDs := FieldValues(vals).ColumnIDs()
 	columnKeys := FieldValues(vals).ColumnKeys()
 	values := FieldValues(vals).Values()
+	nulls := FieldValues(vals).Nulls()
 
 	// Marshal data to protobuf.
 	buf, err := c.serializer.Marshal(&pilosa.ImportValueRequest{
@@ -579,6 +612,7 @@ func (c *InternalClient) marshalImportValuePayload(index, field string, shard ui
 		ColumnIDs:  columnIDs,
 		ColumnKeys: columnKeys,
 		Values:     values,
+		Null:       nulls,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("marshal import request: %s", err)
@@ -761,8 +795,9 @@ func (c *InternalClient) CreateFieldWithOptions(ctx context.Context, index, fiel
 
 	// convert pilosa.FieldOptions to fieldOptions
 	fieldOpt := fieldOptions{
-		Type: opt.Type,
-		Keys: &opt.Keys,
+		Type:        opt.Type,
+		Keys:        &opt.Keys,
+		Compression: opt.Compression,
 	}
 	if fieldOpt.Type == "set" {
 		fieldOpt.CacheType = &opt.CacheType
@@ -1010,6 +1045,11 @@ func (c *InternalClient) SendMessage(ctx context.Context, uri *pilosa.URI, msg [
 // is closed.
 func (c *InternalClient) executeRequest(req *http.Request) (*http.Response, error) {
 	tracing.GlobalTracer.InjectHTTPHeaders(req)
+	if c.internalToken != nil {
+		if token := c.internalToken(); token != "" {
+			req.Header.Set(headerInternalToken, token)
+		}
+	}
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		if resp != nil {
@@ -1198,6 +1238,21 @@ func (p FieldValues) Values() []int64 {
 	return other
 }
 
+// Nulls returns a slice marking which values are null. It returns nil if no
+// values are null.
+func (p FieldValues) Nulls() []bool {
+	var other []bool
+	for i := range p {
+		if p[i].Null {
+			if other == nil {
+				other = make([]bool, len(p))
+			}
+			other[i] = true
+		}
+	}
+	return other
+}
+
 // GroupByShard returns a map of field values by shard.
 func (p FieldValues) GroupByShard() map[uint64][]pilosa.FieldValue {
 	m := make(map[uint64][]pilosa.FieldValue)
@@ -15,6 +15,9 @@
 package http
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
 	"context"
 	"crypto/tls"
 	"encoding/json"
@@ -171,28 +174,49 @@ func (h *Handler) Close() error {
 func (h *Handler) populateValidators() {
 	h.validators = map[string]*queryValidationSpec{}
 	h.validators["Home"] = queryValidationSpecRequired()
+	h.validators["PostAggregate"] = queryValidationSpecRequired("field")
+	h.validators["GetClusterCoordinator"] = queryValidationSpecRequired()
+	h.validators["GetClusterMembership"] = queryValidationSpecRequired()
 	h.validators["PostClusterResizeAbort"] = queryValidationSpecRequired()
 	h.validators["PostClusterResizeRemoveNode"] = queryValidationSpecRequired()
 	h.validators["PostClusterResizeSetCoordinator"] = queryValidationSpecRequired()
-	h.validators["GetExport"] = queryValidationSpecRequired("index", "field", "shard")
+	h.validators["GetExport"] = queryValidationSpecRequired("index", "field", "shard").Optional("principal")
+	h.validators["PostImports"] = queryValidationSpecRequired()
+	h.validators["DeleteImport"] = queryValidationSpecRequired()
+	h.validators["PostImportFinish"] = queryValidationSpecRequired()
 	h.validators["GetIndexes"] = queryValidationSpecRequired()
 	h.validators["GetIndex"] = queryValidationSpecRequired()
 	h.validators["PostIndex"] = queryValidationSpecRequired()
+	h.validators["PostIndexPrepare"] = queryValidationSpecRequired()
+	h.validators["PostIndexCommit"] = queryValidationSpecRequired()
+	h.validators["PostIndexAbort"] = queryValidationSpecRequired()
 	h.validators["DeleteIndex"] = queryValidationSpecRequired()
+	h.validators["GetUnderReplicatedShards"] = queryValidationSpecRequired()
+	h.validators["GetAttrSchema"] = queryValidationSpecRequired()
+	h.validators["PostAttrSchema"] = queryValidationSpecRequired()
 	h.validators["PostField"] = queryValidationSpecRequired()
+	h.validators["PostFieldACL"] = queryValidationSpecRequired()
 	h.validators["DeleteField"] = queryValidationSpecRequired()
-	h.validators["PostImport"] = queryValidationSpecRequired().Optional("clear", "ignoreKeyCheck")
-	h.validators["PostImportRoaring"] = queryValidationSpecRequired().Optional("remote", "clear")
-	h.validators["PostQuery"] = queryValidationSpecRequired().Optional("shards", "columnAttrs", "excludeRowAttrs", "excludeColumns")
+	h.validators["GetFieldHistogram"] = queryValidationSpecRequired("dimension")
+	h.validators["GetRowCountEstimate"] = queryValidationSpecRequired("row")
+	h.validators["PostRecomputeMaxShard"] = queryValidationSpecRequired()
+	h.validators["GetOwnershipMap"] = queryValidationSpecRequired()
+	h.validators["PostImport"] = queryValidationSpecRequired().Optional("clear", "ignoreKeyCheck", "importID", "rejectOutOfRange", "sequence")
+	h.validators["PostImportRoaring"] = queryValidationSpecRequired().Optional("remote", "clear", "principal")
+	h.validators["PostQuery"] = queryValidationSpecRequired().Optional("shards", "singleShard", "columnAttrs", "excludeRowAttrs", "excludeColumns", "view", "attrFilter", "includeKeys", "principal", "treatMissingAsEmpty", "sortResults", "cacheResults", "attrsBestEffort", "accountID", "includeRowAttrs", "maxParallelism", "compressResults")
 	h.validators["GetInfo"] = queryValidationSpecRequired()
+	h.validators["GetRecentQueries"] = queryValidationSpecRequired("n")
 	h.validators["RecalculateCaches"] = queryValidationSpecRequired()
 	h.validators["GetSchema"] = queryValidationSpecRequired()
 	h.validators["GetStatus"] = queryValidationSpecRequired()
 	h.validators["GetVersion"] = queryValidationSpecRequired()
 	h.validators["PostClusterMessage"] = queryValidationSpecRequired()
+	h.validators["GetBackup"] = queryValidationSpecRequired()
+	h.validators["PostRestore"] = queryValidationSpecRequired()
 	h.validators["GetFragmentBlockData"] = queryValidationSpecRequired()
 	h.validators["GetFragmentBlocks"] = queryValidationSpecRequired("index", "field", "view", "shard")
 	h.validators["GetFragmentData"] = queryValidationSpecRequired("index", "field", "view", "shard")
+	h.validators["GetFragmentModTimes"] = queryValidationSpecRequired("index", "field").Optional("shard")
 	h.validators["GetFragmentNodes"] = queryValidationSpecRequired("shard", "index")
 	h.validators["PostIndexAttrDiff"] = queryValidationSpecRequired()
 	h.validators["PostFieldAttrDiff"] = queryValidationSpecRequired()
@@ -232,27 +256,61 @@ func (h *Handler) extractTracing(next http.Handler) http.Handler {
 	})
 }
 
+// headerInternalToken is the header with which nodes mark the requests they
+// make to each other.
+const headerInternalToken = "X-Pilosa-Internal-Token"
+
+// markInternalRequests marks the context of requests made by other nodes of
+// the cluster, so that checks already made by the sending node are skipped.
+func (h *Handler) markInternalRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := r.Header.Get(headerInternalToken); token != "" && h.api != nil {
+			r = r.WithContext(h.api.InternalContext(r.Context(), token))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 // newRouter creates a new mux http router.
 func newRouter(handler *Handler) *mux.Router {
 	router := mux.NewRouter()
 	router.HandleFunc("/", handler.handleHome).Methods("GET").Name("Home")
+	router.HandleFunc("/aggregate", handler.handlePostAggregate).Methods("POST").Name("PostAggregate")
+	router.HandleFunc("/cluster/coordinator", handler.handleGetClusterCoordinator).Methods("GET").Name("GetClusterCoordinator")
+	router.HandleFunc("/cluster/membership", handler.handleGetClusterMembership).Methods("GET").Name("GetClusterMembership")
 	router.HandleFunc("/cluster/resize/abort", handler.handlePostClusterResizeAbort).Methods("POST").Name("PostClusterResizeAbort")
 	router.HandleFunc("/cluster/resize/remove-node", handler.handlePostClusterResizeRemoveNode).Methods("POST").Name("PostClusterResizeRemoveNode")
 	router.HandleFunc("/cluster/resize/set-coordinator", handler.handlePostClusterResizeSetCoordinator).Methods("POST").Name("PostClusterResizeSetCoordinator")
 	router.PathPrefix("/debug/pprof/").Handler(http.DefaultServeMux).Methods("GET")
 	router.Handle("/debug/vars", expvar.Handler()).Methods("GET")
 	router.HandleFunc("/export", handler.handleGetExport).Methods("GET").Name("GetExport")
+	router.HandleFunc("/import", handler.handlePostImports).Methods("POST").Name("PostImports")
+	router.HandleFunc("/import/{id}", handler.handleDeleteImport).Methods("DELETE").Name("DeleteImport")
+	router.HandleFunc("/import/{id}/finish", handler.handlePostImportFinish).Methods("POST").Name("PostImportFinish")
 	router.HandleFunc("/index", handler.handleGetIndexes).Methods("GET").Name("GetIndexes")
 	router.HandleFunc("/index/{index}", handler.handleGetIndex).Methods("GET").Name("GetIndex")
 	router.HandleFunc("/index/{index}", handler.handlePostIndex).Methods("POST").Name("PostIndex")
 	router.HandleFunc("/index/{index}", handler.handleDeleteIndex).Methods("DELETE").Name("DeleteIndex")
+	router.HandleFunc("/index/{index}/prepare", handler.handlePostIndexPrepare).Methods("POST").Name("PostIndexPrepare")
+	router.HandleFunc("/index/{index}/commit", handler.handlePostIndexCommit).Methods("POST").Name("PostIndexCommit")
+	router.HandleFunc("/index/{index}/abort", handler.handlePostIndexAbort).Methods("POST").Name("PostIndexAbort")
+	router.HandleFunc("/index/{index}/attr-schema", handler.handleGetAttrSchema).Methods("GET").Name("GetAttrSchema")
+	router.HandleFunc("/index/{index}/attr-schema", handler.handlePostAttrSchema).Methods("POST").Name("PostAttrSchema")
+	router.HandleFunc("/index/{index}/under-replicated-shards", handler.handleGetUnderReplicatedShards).Methods("GET").Name("GetUnderReplicatedShards")
 	//router.HandleFunc("/index/{index}/field", handler.handleGetFields).Methods("GET") // Not implemented.
 	router.HandleFunc("/index/{index}/field/{field}", handler.handlePostField).Methods("POST").Name("PostField")
 	router.HandleFunc("/index/{index}/field/{field}", handler.handleDeleteField).Methods("DELETE").Name("DeleteField")
+	router.HandleFunc("/index/{index}/field/{field}/acl", handler.handlePostFieldACL).Methods("POST").Name("PostFieldACL")
+	router.HandleFunc("/index/{index}/field/{field}/histogram", handler.handleGetFieldHistogram).Methods("GET").Name("GetFieldHistogram")
+	router.HandleFunc("/index/{index}/field/{field}/row-count-estimate", handler.handleGetRowCountEstimate).Methods("GET").Name("GetRowCountEstimate")
+	router.HandleFunc("/index/{index}/ownership", handler.handleGetOwnershipMap).Methods("GET").Name("GetOwnershipMap")
+	router.HandleFunc("/index/{index}/recompute-max-shard", handler.handlePostRecomputeMaxShard).Methods("POST").Name("PostRecomputeMaxShard")
 	router.HandleFunc("/index/{index}/field/{field}/import", handler.handlePostImport).Methods("POST").Name("PostImport")
+	router.HandleFunc("/index/{index}/field/{field}/import-stream", handler.handlePostImportStream).Methods("POST").Name("PostImportStream")
 	router.HandleFunc("/index/{index}/field/{field}/import-roaring/{shard}", handler.handlePostImportRoaring).Methods("POST").Name("PostImportRoaring")
 	router.HandleFunc("/index/{index}/query", handler.handlePostQuery).Methods("POST").Name("PostQuery")
 	router.HandleFunc("/info", handler.handleGetInfo).Methods("GET").Name("GetInfo")
+	router.HandleFunc("/recent-queries", handler.handleGetRecentQueries).Methods("GET").Name("GetRecentQueries")
 	router.HandleFunc("/recalculate-caches", handler.handleRecalculateCaches).Methods("POST").Name("RecalculateCaches")
 	router.HandleFunc("/schema", handler.handleGetSchema).Methods("GET").Name("GetSchema")
 	router.HandleFunc("/status", handler.handleGetStatus).Methods("GET").Name("GetStatus")
@@ -260,21 +318,25 @@ func newRouter(handler *Handler) *mux.Router {
 
 	// /internal endpoints are for internal use only; they may change at any time.
 	// DO NOT rely on these for external applications!
+	router.HandleFunc("/internal/backup", handler.handleGetBackup).Methods("GET").Name("GetBackup")
 	router.HandleFunc("/internal/cluster/message", handler.handlePostClusterMessage).Methods("POST").Name("PostClusterMessage")
 	router.HandleFunc("/internal/fragment/block/data", handler.handleGetFragmentBlockData).Methods("GET").Name("GetFragmentBlockData")
 	router.HandleFunc("/internal/fragment/blocks", handler.handleGetFragmentBlocks).Methods("GET").Name("GetFragmentBlocks")
 	router.HandleFunc("/internal/fragment/data", handler.handleGetFragmentData).Methods("GET").Name("GetFragmentData")
+	router.HandleFunc("/internal/fragment/modtimes", handler.handleGetFragmentModTimes).Methods("GET").Name("GetFragmentModTimes")
 	router.HandleFunc("/internal/fragment/nodes", handler.handleGetFragmentNodes).Methods("GET").Name("GetFragmentNodes")
 	router.HandleFunc("/internal/index/{index}/attr/diff", handler.handlePostIndexAttrDiff).Methods("POST").Name("PostIndexAttrDiff")
 	router.HandleFunc("/internal/index/{index}/field/{field}/attr/diff", handler.handlePostFieldAttrDiff).Methods("POST").Name("PostFieldAttrDiff")
 	router.HandleFunc("/internal/index/{index}/field/{field}/remote-available-shards/{shardID}", handler.handleDeleteRemoteAvailableShard).Methods("DELETE")
 	router.HandleFunc("/internal/nodes", handler.handleGetNodes).Methods("GET").Name("GetNodes")
+	router.HandleFunc("/internal/restore", handler.handlePostRestore).Methods("POST").Name("PostRestore")
 	router.HandleFunc("/internal/shards/max", handler.handleGetShardsMax).Methods("GET").Name("GetShardsMax") // TODO: deprecate, but it's being used by the client
 	router.HandleFunc("/internal/translate/data", handler.handleGetTranslateData).Methods("GET").Name("GetTranslateData")
 	router.HandleFunc("/internal/translate/keys", handler.handlePostTranslateKeys).Methods("POST").Name("PostTranslateKeys")
 
 	router.Use(handler.queryArgValidator)
 	router.Use(handler.extractTracing)
+	router.Use(handler.markInternalRequests)
 	return router
 }
 
@@ -299,7 +361,9 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	longQueryTime := h.api.LongQueryTime()
 	if longQueryTime > 0 && dif > longQueryTime {
-		h.logger.Printf("%s %s %v", r.Method, r.URL.String(), dif)
+		if lvl, err := h.api.LogLevel(r.Context(), pilosa.LogSubsystemQuery); err != nil || lvl != pilosa.LogLevelNone {
+			h.logger.Printf("%s %s %v", r.Method, r.URL.String(), dif)
+		}
 		statsTags = append(statsTags, "slow_query")
 	}
 
@@ -422,6 +486,69 @@ func (h *Handler) handleGetStatus(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleGetRecentQueries handles GET /recent-queries requests.
+func (h *Handler) handleGetRecentQueries(w http.ResponseWriter, r *http.Request) {
+	if !validHeaderAcceptJSON(r.Header) {
+		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		return
+	}
+
+	n, err := strconv.ParseUint(r.URL.Query().Get("n"), 10, 31)
+	if err != nil {
+		http.Error(w, "invalid n argument", http.StatusBadRequest)
+		return
+	}
+
+	entries, err := h.api.RecentQueries(r.Context(), int(n))
+	if err != nil {
+		switch errors.Cause(err).(type) {
+		case pilosa.BadRequestError:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if err := json.NewEncoder(w).Encode(entries); err != nil {
+		h.logger.Printf("write recent queries response error: %s", err)
+	}
+}
+
+// handleGetClusterCoordinator handles GET /cluster/coordinator requests.
+func (h *Handler) handleGetClusterCoordinator(w http.ResponseWriter, r *http.Request) {
+	if !validHeaderAcceptJSON(r.Header) {
+		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		return
+	}
+	id, epoch, err := h.api.Coordinator(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(struct {
+		ID    string `json:"id"`
+		Epoch uint64 `json:"epoch"`
+	}{id, epoch}); err != nil {
+		h.logger.Printf("write coordinator response error: %s", err)
+	}
+}
+
+// handleGetClusterMembership handles GET /cluster/membership requests.
+func (h *Handler) handleGetClusterMembership(w http.ResponseWriter, r *http.Request) {
+	if !validHeaderAcceptJSON(r.Header) {
+		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		return
+	}
+	view, err := h.api.ClusterMembershipView(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(view); err != nil {
+		h.logger.Printf("write cluster membership response error: %s", err)
+	}
+}
+
 func (h *Handler) handleGetInfo(w http.ResponseWriter, r *http.Request) {
 	if !validHeaderAcceptJSON(r.Header) {
 		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
@@ -443,6 +570,42 @@ type getStatusResponse struct {
 	LocalID string         `json:"localID"`
 }
 
+// handlePostAggregate handles POST /aggregate requests.
+func (h *Handler) handlePostAggregate(w http.ResponseWriter, r *http.Request) {
+	if !validHeaderAcceptJSON(r.Header) {
+		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		return
+	}
+
+	buf, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results, err := h.api.AggregateAcrossIndexes(r.Context(), r.URL.Query().Get("field"), string(buf))
+	if err != nil {
+		switch errors.Cause(err).(type) {
+		case pilosa.BadRequestError:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// QueryResponse only implements json.Marshaler on its pointer.
+	resps := make(map[string]*pilosa.QueryResponse, len(results))
+	for name := range results {
+		resp := results[name]
+		resps[name] = &resp
+	}
+
+	if err := json.NewEncoder(w).Encode(resps); err != nil {
+		h.logger.Printf("write aggregate response error: %s", err)
+	}
+}
+
 // handlePostQuery handles /query requests.
 func (h *Handler) handlePostQuery(w http.ResponseWriter, r *http.Request) {
 	// Parse incoming request.
@@ -456,7 +619,16 @@ func (h *Handler) handlePostQuery(w http.ResponseWriter, r *http.Request) {
 	req.Index = mux.Vars(r)["index"]
 
 	resp, err := h.api.Query(r.Context(), req)
-	if err != nil {
+	if errors.Cause(err) == pilosa.ErrForbidden {
+		w.WriteHeader(http.StatusForbidden)
+		h.writeQueryResponse(w, r, &pilosa.QueryResponse{Err: err})
+		return
+	} else if errors.Cause(err) == pilosa.ErrRateLimited {
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusTooManyRequests)
+		h.writeQueryResponse(w, r, &pilosa.QueryResponse{Err: err})
+		return
+	} else if err != nil {
 		switch errors.Cause(resp.Err) {
 		case pilosa.ErrTooManyWrites:
 			w.WriteHeader(http.StatusRequestEntityTooLarge)
@@ -467,6 +639,16 @@ func (h *Handler) handlePostQuery(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Compress the response if requested. Headers must be set before the
+	// status code is written.
+	var out io.Writer = w
+	var gz *gzip.Writer
+	if req.CompressResults {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz = gzip.NewWriter(w)
+		out = gz
+	}
+
 	// Set appropriate status code, if there is an error. It doesn't appear that
 	// resp.Err could ever be set in API.Query, so this code block is probably
 	// doing nothing right now.
@@ -480,8 +662,12 @@ func (h *Handler) handlePostQuery(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Write response back to client.
-	if err := h.writeQueryResponse(w, r, &resp); err != nil {
+	if err := h.writeQueryResponse(out, r, &resp); err != nil {
 		h.logger.Printf("write query response error: %s", err)
+	} else if gz != nil {
+		if err := gz.Close(); err != nil {
+			h.logger.Printf("compress query response error: %s", err)
+		}
 	}
 }
 
@@ -525,6 +711,98 @@ func (h *Handler) handleGetIndex(w http.ResponseWriter, r *http.Request) {
 	http.Error(w, fmt.Sprintf("Index %s Not Found", indexName), http.StatusNotFound)
 }
 
+// handleGetUnderReplicatedShards handles GET /index/<indexname>/under-replicated-shards requests.
+func (h *Handler) handleGetUnderReplicatedShards(w http.ResponseWriter, r *http.Request) {
+	if !validHeaderAcceptJSON(r.Header) {
+		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		return
+	}
+	indexName := mux.Vars(r)["index"]
+
+	shards, err := h.api.UnderReplicatedShards(r.Context(), indexName)
+	if err != nil {
+		switch errors.Cause(err).(type) {
+		case pilosa.NotFoundError:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if shards == nil {
+		shards = []pilosa.ShardReplication{}
+	}
+
+	if err := json.NewEncoder(w).Encode(shards); err != nil {
+		h.logger.Printf("write under-replicated shards response error: %s", err)
+	}
+}
+
+// handleGetAttrSchema handles GET /index/<indexname>/attr-schema requests.
+func (h *Handler) handleGetAttrSchema(w http.ResponseWriter, r *http.Request) {
+	if !validHeaderAcceptJSON(r.Header) {
+		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		return
+	}
+	indexName := mux.Vars(r)["index"]
+
+	schema, err := h.api.ExportAttrSchema(r.Context(), indexName)
+	if err != nil {
+		switch errors.Cause(err).(type) {
+		case pilosa.NotFoundError:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(schema); err != nil {
+		h.logger.Printf("write attr schema response error: %s", err)
+	}
+}
+
+// handlePostAttrSchema handles POST /index/<indexname>/attr-schema requests.
+func (h *Handler) handlePostAttrSchema(w http.ResponseWriter, r *http.Request) {
+	if !validHeaderAcceptJSON(r.Header) {
+		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		return
+	}
+	indexName := mux.Vars(r)["index"]
+
+	resp := successResponse{}
+	var schema pilosa.AttrSchema
+	if err := json.NewDecoder(r.Body).Decode(&schema); err != nil {
+		resp.write(w, pilosa.NewBadRequestError(errors.Wrap(err, "decoding attr schema")))
+		return
+	}
+
+	err := h.api.ImportAttrSchema(r.Context(), indexName, schema)
+	resp.write(w, err)
+}
+
+// handlePostFieldACL handles POST /index/<indexname>/field/<fieldname>/acl requests.
+func (h *Handler) handlePostFieldACL(w http.ResponseWriter, r *http.Request) {
+	if !validHeaderAcceptJSON(r.Header) {
+		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		return
+	}
+	indexName := mux.Vars(r)["index"]
+	fieldName := mux.Vars(r)["field"]
+
+	resp := successResponse{}
+	var req struct {
+		Allowed []string `json:"allowed"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.write(w, pilosa.NewBadRequestError(errors.Wrap(err, "decoding acl")))
+		return
+	}
+
+	err := h.api.SetFieldACL(r.Context(), indexName, fieldName, req.Allowed)
+	resp.write(w, err)
+}
+
 type postIndexRequest struct {
 	Options pilosa.IndexOptions `json:"options"`
 }
@@ -644,6 +922,59 @@ func (h *Handler) handlePostIndex(w http.ResponseWriter, r *http.Request) {
 	resp.write(w, err)
 }
 
+// handlePostIndexPrepare handles POST /index/{index}/prepare requests.
+func (h *Handler) handlePostIndexPrepare(w http.ResponseWriter, r *http.Request) {
+	if !validHeaderAcceptJSON(r.Header) {
+		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		return
+	}
+	indexName := mux.Vars(r)["index"]
+
+	resp := successResponse{}
+
+	// Decode request.
+	req := postIndexRequest{
+		Options: pilosa.IndexOptions{
+			Keys:           false,
+			TrackExistence: true,
+		},
+	}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && err != io.EOF {
+		resp.write(w, err)
+		return
+	}
+	err = h.api.PrepareCreateIndex(r.Context(), indexName, req.Options)
+
+	resp.write(w, err)
+}
+
+// handlePostIndexCommit handles POST /index/{index}/commit requests.
+func (h *Handler) handlePostIndexCommit(w http.ResponseWriter, r *http.Request) {
+	if !validHeaderAcceptJSON(r.Header) {
+		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		return
+	}
+	indexName := mux.Vars(r)["index"]
+
+	resp := successResponse{}
+	_, err := h.api.CommitCreateIndex(r.Context(), indexName)
+	resp.write(w, err)
+}
+
+// handlePostIndexAbort handles POST /index/{index}/abort requests.
+func (h *Handler) handlePostIndexAbort(w http.ResponseWriter, r *http.Request) {
+	if !validHeaderAcceptJSON(r.Header) {
+		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		return
+	}
+	indexName := mux.Vars(r)["index"]
+
+	resp := successResponse{}
+	err := h.api.AbortCreateIndex(r.Context(), indexName)
+	resp.write(w, err)
+}
+
 // handlePostIndexAttrDiff handles POST /internal/index/attr/diff requests.
 func (h *Handler) handlePostIndexAttrDiff(w http.ResponseWriter, r *http.Request) {
 	if !validHeaderAcceptJSON(r.Header) {
@@ -721,6 +1052,16 @@ func (h *Handler) handlePostField(w http.ResponseWriter, r *http.Request) {
 		fos = append(fos, pilosa.OptFieldTypeInt(*req.Options.Min, *req.Options.Max))
 	case pilosa.FieldTypeTime:
 		fos = append(fos, pilosa.OptFieldTypeTime(*req.Options.TimeQuantum, req.Options.NoStandardView))
+		if req.Options.TimeMin != nil || req.Options.TimeMax != nil {
+			var min, max time.Time
+			if req.Options.TimeMin != nil {
+				min = *req.Options.TimeMin
+			}
+			if req.Options.TimeMax != nil {
+				max = *req.Options.TimeMax
+			}
+			fos = append(fos, pilosa.OptFieldTimeRange(min, max))
+		}
 	case pilosa.FieldTypeMutex:
 		fos = append(fos, pilosa.OptFieldTypeMutex(*req.Options.CacheType, *req.Options.CacheSize))
 	case pilosa.FieldTypeBool:
@@ -729,8 +1070,16 @@ func (h *Handler) handlePostField(w http.ResponseWriter, r *http.Request) {
 	if req.Options.Keys != nil {
 		if *req.Options.Keys {
 			fos = append(fos, pilosa.OptFieldKeys())
+		} else {
+			fos = append(fos, pilosa.OptFieldNoKeys())
 		}
 	}
+	if req.Options.Compression != "" {
+		fos = append(fos, pilosa.OptFieldCompression(req.Options.Compression))
+	}
+	if len(req.Options.AllowedRows) > 0 {
+		fos = append(fos, pilosa.OptFieldAllowedRows(req.Options.AllowedRows...))
+	}
 
 	_, err = h.api.CreateField(r.Context(), indexName, fieldName, fos...)
 	resp.write(w, err)
@@ -751,6 +1100,10 @@ type fieldOptions struct {
 	TimeQuantum    *pilosa.TimeQuantum `json:"timeQuantum,omitempty"`
 	Keys           *bool               `json:"keys,omitempty"`
 	NoStandardView bool                `json:"noStandardView,omitempty"`
+	Compression    string              `json:"compression,omitempty"`
+	TimeMin        *time.Time          `json:"timeMin,omitempty"`
+	TimeMax        *time.Time          `json:"timeMax,omitempty"`
+	AllowedRows    []uint64            `json:"allowedRows,omitempty"`
 }
 
 func (o *fieldOptions) validate() error {
@@ -758,6 +1111,20 @@ func (o *fieldOptions) validate() error {
 	defaultCacheType := pilosa.DefaultCacheType
 	defaultCacheSize := uint32(pilosa.DefaultCacheSize)
 
+	switch o.Compression {
+	case "", pilosa.CompressionNone, pilosa.CompressionGzip:
+	default:
+		return pilosa.NewBadRequestError(errors.Errorf("invalid compression: %s", o.Compression))
+	}
+
+	if (o.TimeMin != nil || o.TimeMax != nil) && o.Type != pilosa.FieldTypeTime {
+		return pilosa.NewBadRequestError(errors.New("timeMin and timeMax only apply to field type time"))
+	}
+
+	if len(o.AllowedRows) > 0 && (o.Type == pilosa.FieldTypeInt || o.Type == pilosa.FieldTypeBool) {
+		return pilosa.NewBadRequestError(errors.Errorf("allowedRows does not apply to field type %s", o.Type))
+	}
+
 	switch o.Type {
 	case pilosa.FieldTypeSet, "":
 		// Because FieldTypeSet is the default, its arguments are
@@ -852,6 +1219,117 @@ func (h *Handler) handleDeleteField(w http.ResponseWriter, r *http.Request) {
 	resp.write(w, err)
 }
 
+// handleGetFieldHistogram handles GET /index/{index}/field/{field}/histogram requests.
+func (h *Handler) handleGetFieldHistogram(w http.ResponseWriter, r *http.Request) {
+	if !validHeaderAcceptJSON(r.Header) {
+		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		return
+	}
+
+	indexName := mux.Vars(r)["index"]
+	fieldName := mux.Vars(r)["field"]
+
+	histogram, err := h.api.FieldHistogram(r.Context(), indexName, fieldName, r.URL.Query().Get("dimension"))
+	if err != nil {
+		switch errors.Cause(err).(type) {
+		case pilosa.BadRequestError:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case pilosa.NotFoundError:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(histogram); err != nil {
+		h.logger.Printf("write field histogram response error: %s", err)
+	}
+}
+
+// handleGetRowCountEstimate handles GET /index/{index}/field/{field}/row-count-estimate requests.
+func (h *Handler) handleGetRowCountEstimate(w http.ResponseWriter, r *http.Request) {
+	if !validHeaderAcceptJSON(r.Header) {
+		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		return
+	}
+
+	indexName := mux.Vars(r)["index"]
+	fieldName := mux.Vars(r)["field"]
+	rowID, err := strconv.ParseUint(r.URL.Query().Get("row"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid row", http.StatusBadRequest)
+		return
+	}
+
+	n, err := h.api.EstimateRowCount(r.Context(), indexName, fieldName, rowID)
+	if err != nil {
+		switch errors.Cause(err).(type) {
+		case pilosa.NotFoundError:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(struct {
+		Count uint64 `json:"count"`
+	}{Count: n}); err != nil {
+		h.logger.Printf("write row count estimate response error: %s", err)
+	}
+}
+
+// handleGetOwnershipMap handles GET /index/{index}/ownership requests.
+func (h *Handler) handleGetOwnershipMap(w http.ResponseWriter, r *http.Request) {
+	if !validHeaderAcceptJSON(r.Header) {
+		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		return
+	}
+
+	indexName := mux.Vars(r)["index"]
+	m, err := h.api.OwnershipMap(r.Context(), indexName)
+	if err != nil {
+		switch errors.Cause(err).(type) {
+		case pilosa.NotFoundError:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(m); err != nil {
+		h.logger.Printf("write ownership map response error: %s", err)
+	}
+}
+
+// handlePostRecomputeMaxShard handles POST /index/{index}/recompute-max-shard requests.
+func (h *Handler) handlePostRecomputeMaxShard(w http.ResponseWriter, r *http.Request) {
+	if !validHeaderAcceptJSON(r.Header) {
+		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		return
+	}
+
+	indexName := mux.Vars(r)["index"]
+	maxShard, err := h.api.RecomputeMaxShard(r.Context(), indexName)
+	if err != nil {
+		switch errors.Cause(err).(type) {
+		case pilosa.NotFoundError:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(struct {
+		MaxShard uint64 `json:"maxShard"`
+	}{MaxShard: maxShard}); err != nil {
+		h.logger.Printf("write recompute max shard response error: %s", err)
+	}
+}
+
 // handleDeleteRemoteAvailableShard handles DELETE /field/{field}/available-shards/{shardID} request.
 func (h *Handler) handleDeleteRemoteAvailableShard(w http.ResponseWriter, r *http.Request) {
 	if !validHeaderAcceptJSON(r.Header) {
@@ -954,12 +1432,53 @@ func (h *Handler) readURLQueryRequest(r *http.Request) (*pilosa.QueryRequest, er
 		return nil, errors.New("invalid shard argument")
 	}
 
+	// Parse single shard.
+	var singleShard *uint64
+	if s := q.Get("singleShard"); s != "" {
+		shard, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return nil, errors.New("invalid singleShard argument")
+		}
+		singleShard = &shard
+	}
+
+	// Parse parallelism cap.
+	var maxParallelism int
+	if s := q.Get("maxParallelism"); s != "" {
+		n, err := strconv.ParseUint(s, 10, 31)
+		if err != nil {
+			return nil, errors.New("invalid maxParallelism argument")
+		}
+		maxParallelism = int(n)
+	}
+
+	// Parse attribute filter as a JSON object.
+	var attrFilter map[string]interface{}
+	if s := q.Get("attrFilter"); s != "" {
+		if err := json.Unmarshal([]byte(s), &attrFilter); err != nil {
+			return nil, errors.New("invalid attrFilter argument")
+		}
+	}
+
 	return &pilosa.QueryRequest{
-		Query:           query,
-		Shards:          shards,
-		ColumnAttrs:     q.Get("columnAttrs") == "true",
-		ExcludeRowAttrs: q.Get("excludeRowAttrs") == "true",
-		ExcludeColumns:  q.Get("excludeColumns") == "true",
+		Query:               query,
+		Shards:              shards,
+		SingleShard:         singleShard,
+		ColumnAttrs:         q.Get("columnAttrs") == "true",
+		ExcludeRowAttrs:     q.Get("excludeRowAttrs") == "true",
+		ExcludeColumns:      q.Get("excludeColumns") == "true",
+		View:                q.Get("view"),
+		AttrFilter:          attrFilter,
+		IncludeKeys:         q.Get("includeKeys") == "true",
+		Principal:           q.Get("principal"),
+		TreatMissingAsEmpty: q.Get("treatMissingAsEmpty") == "true",
+		SortResults:         q.Get("sortResults") == "true",
+		CacheResults:        q.Get("cacheResults") == "true",
+		AttrsBestEffort:     q.Get("attrsBestEffort") == "true",
+		AccountID:           q.Get("accountID"),
+		IncludeRowAttrs:     q.Get("includeRowAttrs") == "true",
+		MaxParallelism:      maxParallelism,
+		CompressResults:     q.Get("compressResults") == "true",
 	}, nil
 }
 
@@ -1007,6 +1526,16 @@ func (h *Handler) handlePostImport(w http.ResponseWriter, r *http.Request) {
 	opts := []pilosa.ImportOption{
 		pilosa.OptImportOptionsClear(doClear),
 		pilosa.OptImportOptionsIgnoreKeyCheck(doIgnoreKeyCheck),
+		pilosa.OptImportOptionsID(q.Get("importID")),
+		pilosa.OptImportOptionsRejectOutOfRange(q.Get("rejectOutOfRange") == "true"),
+	}
+	if s := q.Get("sequence"); s != "" {
+		seq, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid sequence", http.StatusBadRequest)
+			return
+		}
+		opts = append(opts, pilosa.OptImportOptionsSequence(seq))
 	}
 
 	// Get index and field type to determine how to handle the
@@ -1031,6 +1560,8 @@ func (h *Handler) handlePostImport(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	resp := &pilosa.ImportResponse{}
+
 	// Unmarshal request based on field type.
 	if field.Type() == pilosa.FieldTypeInt {
 		// Field type: Int
@@ -1045,6 +1576,12 @@ func (h *Handler) handlePostImport(w http.ResponseWriter, r *http.Request) {
 			switch errors.Cause(err) {
 			case pilosa.ErrClusterDoesNotOwnShard:
 				http.Error(w, err.Error(), http.StatusPreconditionFailed)
+			case pilosa.ErrForbidden:
+				http.Error(w, err.Error(), http.StatusForbidden)
+			case pilosa.ErrImportNotFound:
+				http.Error(w, err.Error(), http.StatusNotFound)
+			case pilosa.ErrImportCanceled:
+				http.Error(w, err.Error(), http.StatusConflict)
 			default:
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 			}
@@ -1059,19 +1596,33 @@ func (h *Handler) handlePostImport(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		if err := h.api.Import(r.Context(), req, opts...); err != nil {
+		importResp, err := h.api.ImportWithResponse(r.Context(), req, opts...)
+		if err != nil {
 			switch errors.Cause(err) {
 			case pilosa.ErrClusterDoesNotOwnShard:
 				http.Error(w, err.Error(), http.StatusPreconditionFailed)
+			case pilosa.ErrImportChecksumMismatch, pilosa.ErrImportTimestampOutOfRange, pilosa.ErrImportTransformNotFound, pilosa.ErrImportTransformFailed:
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			case pilosa.ErrForbidden:
+				http.Error(w, err.Error(), http.StatusForbidden)
+			case pilosa.ErrImportNotFound:
+				http.Error(w, err.Error(), http.StatusNotFound)
+			case pilosa.ErrImportCanceled:
+				http.Error(w, err.Error(), http.StatusConflict)
 			default:
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				if _, ok := errors.Cause(err).(pilosa.BadRequestError); ok {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+				} else {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+				}
 			}
 			return
 		}
+		resp = importResp
 	}
 
 	// Marshal response object.
-	buf, e := h.api.Serializer.Marshal(&pilosa.ImportResponse{Err: ""})
+	buf, e := h.api.Serializer.Marshal(resp)
 	if e != nil {
 		http.Error(w, fmt.Sprintf("marshal import response"), http.StatusInternalServerError)
 		return
@@ -1081,11 +1632,142 @@ func (h *Handler) handlePostImport(w http.ResponseWriter, r *http.Request) {
 	w.Write(buf)
 }
 
+// importStreamProtocol is the protocol named in the Upgrade header of import
+// stream requests which acknowledge batches as they are imported.
+const importStreamProtocol = "pilosa-import-stream"
+
+// handlePostImportStream handles /index/{index}/field/{field}/import-stream
+// requests. The body is a stream of batches framed by
+// pilosa.WriteImportStreamFrame and the response is a line of JSON
+// acknowledging each batch. Requests which ask to upgrade the connection to
+// importStreamProtocol receive each ack as soon as its batch is imported;
+// otherwise the acks are written once the whole body has been read.
+func (h *Handler) handlePostImportStream(w http.ResponseWriter, r *http.Request) {
+	indexName := mux.Vars(r)["index"]
+	fieldName := mux.Vars(r)["field"]
+
+	q := r.URL.Query()
+	opts := []pilosa.ImportOption{
+		pilosa.OptImportOptionsClear(q.Get("clear") == "true"),
+		pilosa.OptImportOptionsID(q.Get("importID")),
+		pilosa.OptImportOptionsRejectOutOfRange(q.Get("rejectOutOfRange") == "true"),
+	}
+
+	// Check the field before any response is written.
+	if _, err := h.api.Field(r.Context(), indexName, fieldName); err != nil {
+		switch errors.Cause(err) {
+		case pilosa.ErrIndexNotFound, pilosa.ErrFieldNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if !strings.EqualFold(r.Header.Get("Upgrade"), importStreamProtocol) {
+		var buf bytes.Buffer
+		if err := h.api.ImportStream(r.Context(), indexName, fieldName, r.Body, &buf, opts...); err != nil {
+			h.logger.Printf("import stream error: %s", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := buf.WriteTo(w); err != nil {
+			h.logger.Printf("write import stream acks error: %s", err)
+		}
+		return
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "connection cannot be upgraded", http.StatusInternalServerError)
+		return
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		h.logger.Printf("hijack import stream connection error: %s", err)
+		return
+	}
+	defer conn.Close()
+
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: %s\r\n\r\n", importStreamProtocol)
+	if err := rw.Flush(); err != nil {
+		h.logger.Printf("write import stream upgrade error: %s", err)
+		return
+	}
+	if err := h.api.ImportStream(r.Context(), indexName, fieldName, rw.Reader, flushWriter{rw.Writer}, opts...); err != nil {
+		h.logger.Printf("import stream error: %s", err)
+	}
+}
+
+// flushWriter flushes each write to the underlying writer.
+type flushWriter struct {
+	w *bufio.Writer
+}
+
+func (fw flushWriter) Write(p []byte) (int, error) {
+	n, err := fw.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	return n, fw.w.Flush()
+}
+
+type postImportsResponse struct {
+	ID string `json:"id"`
+}
+
+// handlePostImports handles POST /import requests, which register a new
+// import and return its ID.
+func (h *Handler) handlePostImports(w http.ResponseWriter, r *http.Request) {
+	if !validHeaderAcceptJSON(r.Header) {
+		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		return
+	}
+
+	id, err := h.api.BeginImport(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(postImportsResponse{ID: id}); err != nil {
+		h.logger.Printf("write import response error: %s", err)
+	}
+}
+
+// handleDeleteImport handles DELETE /import/{id} requests, which cancel an
+// in-progress import.
+func (h *Handler) handleDeleteImport(w http.ResponseWriter, r *http.Request) {
+	if !validHeaderAcceptJSON(r.Header) {
+		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		return
+	}
+
+	resp := successResponse{}
+	err := h.api.CancelImport(r.Context(), mux.Vars(r)["id"])
+	resp.write(w, err)
+}
+
+// handlePostImportFinish handles POST /import/{id}/finish requests.
+func (h *Handler) handlePostImportFinish(w http.ResponseWriter, r *http.Request) {
+	if !validHeaderAcceptJSON(r.Header) {
+		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		return
+	}
+
+	resp := successResponse{}
+	err := h.api.FinishImport(r.Context(), mux.Vars(r)["id"])
+	resp.write(w, err)
+}
+
 // handleGetExport handles /export requests.
 func (h *Handler) handleGetExport(w http.ResponseWriter, r *http.Request) {
+	r = r.WithContext(pilosa.WithPrincipal(r.Context(), r.URL.Query().Get("principal")))
 	switch r.Header.Get("Accept") {
 	case "text/csv":
 		h.handleGetExportCSV(w, r)
+	case "application/vnd.apache.parquet":
+		h.handleGetExportParquet(w, r)
+	case "application/x-ndjson":
+		h.handleGetExportNDJSON(w, r)
 	default:
 		http.Error(w, "Not acceptable", http.StatusNotAcceptable)
 	}
@@ -1108,6 +1790,8 @@ func (h *Handler) handleGetExportCSV(w http.ResponseWriter, r *http.Request) {
 			break
 		case pilosa.ErrClusterDoesNotOwnShard:
 			http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		case pilosa.ErrForbidden:
+			http.Error(w, err.Error(), http.StatusForbidden)
 		default:
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
@@ -1115,6 +1799,64 @@ func (h *Handler) handleGetExportCSV(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (h *Handler) handleGetExportNDJSON(w http.ResponseWriter, r *http.Request) {
+	// Parse query parameters.
+	q := r.URL.Query()
+	index, field := q.Get("index"), q.Get("field")
+
+	shard, err := strconv.ParseUint(q.Get("shard"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid shard", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	if err = h.api.ExportNDJSON(r.Context(), index, field, shard, w); err != nil {
+		switch errors.Cause(err) {
+		case pilosa.ErrClusterDoesNotOwnShard:
+			http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		case pilosa.ErrForbidden:
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			if _, ok := errors.Cause(err).(pilosa.NotFoundError); ok {
+				http.Error(w, err.Error(), http.StatusNotFound)
+			} else {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+		}
+		return
+	}
+}
+
+func (h *Handler) handleGetExportParquet(w http.ResponseWriter, r *http.Request) {
+	// Parse query parameters.
+	q := r.URL.Query()
+	index, field := q.Get("index"), q.Get("field")
+
+	shard, err := strconv.ParseUint(q.Get("shard"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid shard", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.apache.parquet")
+	if err = h.api.ExportParquet(r.Context(), index, field, shard, w); err != nil {
+		switch errors.Cause(err) {
+		case pilosa.ErrClusterDoesNotOwnShard:
+			http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		case pilosa.ErrForbidden:
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			if _, ok := errors.Cause(err).(pilosa.NotFoundError); ok {
+				http.Error(w, err.Error(), http.StatusNotFound)
+			} else {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+		}
+		return
+	}
+}
+
 // handleGetFragmentNodes handles /internal/fragment/nodes requests.
 func (h *Handler) handleGetFragmentNodes(w http.ResponseWriter, r *http.Request) {
 	if !validHeaderAcceptJSON(r.Header) {
@@ -1237,6 +1979,61 @@ func (h *Handler) handleGetFragmentData(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+// handleGetBackup handles GET /internal/backup requests.
+func (h *Handler) handleGetBackup(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/x-tar")
+	if err := h.api.BackupNode(r.Context(), w); err != nil {
+		h.logger.Printf("error streaming backup: %s", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handlePostRestore handles POST /internal/restore requests.
+func (h *Handler) handlePostRestore(w http.ResponseWriter, r *http.Request) {
+	if err := h.api.RestoreNode(r.Context(), r.Body); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handleGetFragmentModTimes handles GET /internal/fragment/modtimes requests.
+func (h *Handler) handleGetFragmentModTimes(w http.ResponseWriter, r *http.Request) {
+	if !validHeaderAcceptJSON(r.Header) {
+		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		return
+	}
+	q := r.URL.Query()
+
+	// Return a single fragment if a shard is specified.
+	modTimes := make(map[uint64]time.Time)
+	var err error
+	if s := q.Get("shard"); s != "" {
+		shard, perr := strconv.ParseUint(s, 10, 64)
+		if perr != nil {
+			http.Error(w, "invalid shard", http.StatusBadRequest)
+			return
+		}
+		var t time.Time
+		if t, err = h.api.FragmentModTime(r.Context(), q.Get("index"), q.Get("field"), shard); err == nil {
+			modTimes[shard] = t
+		}
+	} else {
+		modTimes, err = h.api.FragmentModTimes(r.Context(), q.Get("index"), q.Get("field"))
+	}
+	if err != nil {
+		switch errors.Cause(err).(type) {
+		case pilosa.NotFoundError:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(modTimes); err != nil {
+		h.logger.Printf("fragment mod times response encoding error: %s", err)
+	}
+}
+
 // handleGetVersion handles /version requests.
 func (h *Handler) handleGetVersion(w http.ResponseWriter, r *http.Request) {
 	if !validHeaderAcceptJSON(r.Header) {
@@ -1292,10 +2089,16 @@ func (h *Handler) handlePostClusterResizeSetCoordinator(w http.ResponseWriter, r
 		return
 	}
 
-	oldNode, newNode, err := h.api.SetCoordinator(r.Context(), req.ID)
+	setCoordinator := h.api.SetCoordinator
+	if req.Force {
+		setCoordinator = h.api.ForceCoordinator
+	}
+	oldNode, newNode, err := setCoordinator(r.Context(), req.ID)
 	if err != nil {
 		if errors.Cause(err) == pilosa.ErrNodeIDNotExists {
 			http.Error(w, "setting new coordinator: "+err.Error(), http.StatusNotFound)
+		} else if errors.Cause(err) == pilosa.ErrCoordinatorUnreachable {
+			http.Error(w, "setting new coordinator: "+err.Error(), http.StatusServiceUnavailable)
 		} else {
 			http.Error(w, "setting new coordinator: "+err.Error(), http.StatusInternalServerError)
 		}
@@ -1311,7 +2114,8 @@ func (h *Handler) handlePostClusterResizeSetCoordinator(w http.ResponseWriter, r
 }
 
 type setCoordinatorRequest struct {
-	ID string `json:"id"`
+	ID    string `json:"id"`
+	Force bool   `json:"force"`
 }
 
 type setCoordinatorResponse struct {
@@ -1428,6 +2232,10 @@ type defaultClusterMessageResponse struct{}
 // translateStoreBufferSize is the buffer size used for streaming data.
 const translateStoreBufferSize = 1 << 16 // 64k
 
+// headerTranslateEpoch is the header with which a translate data stream
+// reports the epoch of the data file it is read from.
+const headerTranslateEpoch = "X-Pilosa-Translate-Epoch"
+
 // translateStoreBufferSizeMax is the maximum size that the buffer is allowed
 // to grow before raising an error.
 const translateStoreBufferSizeMax = 1 << 22 // 4Mb
@@ -1446,6 +2254,12 @@ func (h *Handler) handleGetTranslateData(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// Report the epoch of the data file so that the client can tell if
+	// its offset still refers to the same data.
+	if e, ok := rdr.(interface{ Epoch() uint64 }); ok {
+		w.Header().Set(headerTranslateEpoch, strconv.FormatUint(e.Epoch(), 10))
+	}
+
 	// Flush header so client can continue.
 	w.WriteHeader(http.StatusOK)
 	if w, ok := w.(http.Flusher); ok {
@@ -1581,6 +2395,7 @@ func (h *Handler) handlePostImportRoaring(w http.ResponseWriter, r *http.Request
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	req.Principal = q.Get("principal")
 
 	urlVars := mux.Vars(r)
 	shard, err := strconv.ParseUint(urlVars["shard"], 10, 64)
@@ -1596,6 +2411,8 @@ func (h *Handler) handlePostImportRoaring(w http.ResponseWriter, r *http.Request
 		resp.Err = err.Error()
 		if _, ok := err.(pilosa.BadRequestError); ok {
 			w.WriteHeader(http.StatusBadRequest)
+		} else if errors.Cause(err) == pilosa.ErrForbidden {
+			w.WriteHeader(http.StatusForbidden)
 		} else {
 			w.WriteHeader(http.StatusInternalServerError)
 		}
@@ -66,6 +66,21 @@ func (s *translateStore) TranslateRowToString(index, frame string, values uint64
 	return "", pilosa.ErrNotImplemented
 }
 
+// TranslateRowsToStrings is not currently implemented.
+func (s *translateStore) TranslateRowsToStrings(index, frame string, values []uint64) ([]string, error) {
+	return nil, pilosa.ErrNotImplemented
+}
+
+// Compact is not currently implemented.
+func (s *translateStore) Compact() error {
+	return pilosa.ErrNotImplemented
+}
+
+// DeleteFieldKeys is not currently implemented.
+func (s *translateStore) DeleteFieldKeys(index, field string) error {
+	return pilosa.ErrNotImplemented
+}
+
 // Reader returns a reader that can stream data from a remote store.
 func (s *translateStore) Reader(ctx context.Context, off int64) (io.ReadCloser, error) {
 	// Generate remote URL.
@@ -94,6 +109,14 @@ func (s *translateStore) Reader(ctx context.Context, off int64) (io.ReadCloser,
 	// Handle error codes or return body as stream.
 	switch resp.StatusCode {
 	case http.StatusOK:
+		if v := resp.Header.Get(headerTranslateEpoch); v != "" {
+			epoch, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				resp.Body.Close()
+				return nil, fmt.Errorf("http: invalid translate store epoch: %q", v)
+			}
+			return &translateStoreReader{ReadCloser: resp.Body, epoch: epoch}, nil
+		}
 		return resp.Body, nil
 	case http.StatusNotImplemented:
 		resp.Body.Close()
@@ -104,3 +127,13 @@ func (s *translateStore) Reader(ctx context.Context, off int64) (io.ReadCloser,
 		return nil, fmt.Errorf("http: invalid translate store endpoint status: code=%d url=%s body=%q", resp.StatusCode, u.String(), bytes.TrimSpace(body))
 	}
 }
+
+// translateStoreReader is a translate data stream which carries the epoch of
+// the remote data file it is read from.
+type translateStoreReader struct {
+	io.ReadCloser
+	epoch uint64
+}
+
+// Epoch returns the epoch of the remote data file.
+func (r *translateStoreReader) Epoch() uint64 { return r.epoch }
@@ -0,0 +1,76 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilosa
+
+import (
+	"sync"
+	"time"
+)
+
+// importHistorySize is the number of imports remembered by each field.
+const importHistorySize = 256
+
+// ImportRecord describes an import into one shard of a field, as returned
+// by API.ImportHistory.
+type ImportRecord struct {
+	// Time the import was applied.
+	Time time.Time `json:"time"`
+
+	Shard uint64 `json:"shard"`
+
+	// Number of columns imported. For set fields, each bit counts as one
+	// column, even if several bits are in the same column.
+	Columns uint64 `json:"columns"`
+
+	// If set, the import cleared the bits or values instead of setting
+	// them.
+	Clear bool `json:"clear"`
+}
+
+// importHistory is a bounded log of the most recent imports into a field.
+type importHistory struct {
+	mu      sync.Mutex
+	records []ImportRecord
+	next    int // index of the oldest record once records is full
+}
+
+// add records an import, discarding the oldest record if the log is full.
+func (h *importHistory) add(shard, columns uint64, clear bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	r := ImportRecord{Time: time.Now().UTC(), Shard: shard, Columns: columns, Clear: clear}
+	if len(h.records) < importHistorySize {
+		h.records = append(h.records, r)
+		return
+	}
+	h.records[h.next] = r
+	h.next = (h.next + 1) % importHistorySize
+}
+
+// last returns up to n of the most recent imports, newest first. If n is
+// not positive, every remembered import is returned.
+func (h *importHistory) last(n int) []ImportRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if n <= 0 || n > len(h.records) {
+		n = len(h.records)
+	}
+	a := make([]ImportRecord, 0, n)
+	for i := 0; i < n; i++ {
+		j := (h.next - 1 - i + 2*len(h.records)) % len(h.records)
+		a = append(a, h.records[j])
+	}
+	return a
+}
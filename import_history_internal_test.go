@@ -0,0 +1,41 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilosa
+
+import (
+	"testing"
+)
+
+func TestImportHistory(t *testing.T) {
+	var h importHistory
+	if records := h.last(0); len(records) != 0 {
+		t.Fatalf("unexpected records: %v", records)
+	}
+
+	// Overfill the log, so the oldest records are discarded.
+	for i := uint64(0); i < importHistorySize+3; i++ {
+		h.add(i, 1, i%2 == 1)
+	}
+	if records := h.last(0); len(records) != importHistorySize {
+		t.Fatalf("unexpected number of records: %d", len(records))
+	} else if first, last := records[0], records[len(records)-1]; first.Shard != importHistorySize+2 || first.Clear || last.Shard != 3 || !last.Clear {
+		t.Fatalf("unexpected records: first=%v, last=%v", first, last)
+	}
+
+	records := h.last(2)
+	if len(records) != 2 || records[0].Shard != importHistorySize+2 || records[1].Shard != importHistorySize+1 {
+		t.Fatalf("unexpected records: %v", records)
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilosa
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// importSequenceTimeout is how long a replica buffers an import while waiting
+// for the imports sequenced before it. Once it expires, the missing imports
+// are skipped so that an import lost between the primary and a replica does
+// not block the shard forever, and the shard is synced with its other owners
+// to recover them.
+const importSequenceTimeout = 10 * time.Second
+
+// importSequencer orders the imports to each shard so that all owners of the
+// shard apply them in the same order. The primary owner assigns each import
+// the next sequence number for the shard, and replicas apply imports in
+// sequence order, buffering imports which arrive early. Imports which were
+// skipped, or which arrive after a later one was applied, are recovered by
+// syncing the shard with its other owners.
+type importSequencer struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	shards  map[importShard]*shardSequence
+	timeout time.Duration
+
+	// resync is called, in its own goroutine, when a replica skips imports
+	// to a shard. At most one call per shard runs at a time.
+	resync    func(index string, shard uint64)
+	resyncing map[importShard]struct{}
+}
+
+type importShard struct {
+	index string
+	shard uint64
+}
+
+type shardSequence struct {
+	applied uint64 // last sequence number applied
+	busy    bool   // an import is being applied
+}
+
+func newImportSequencer() *importSequencer {
+	s := &importSequencer{
+		shards:    make(map[importShard]*shardSequence),
+		timeout:   importSequenceTimeout,
+		resyncing: make(map[importShard]struct{}),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+func (s *importSequencer) unprotectedShard(index string, shard uint64) *shardSequence {
+	key := importShard{index: index, shard: shard}
+	ss := s.shards[key]
+	if ss == nil {
+		ss = &shardSequence{}
+		s.shards[key] = ss
+	}
+	return ss
+}
+
+// wakeOnDone wakes all waiters once ctx is done, so that they can check it.
+// The returned function must be called once the caller stops waiting.
+func (s *importSequencer) wakeOnDone(ctx context.Context) (stop func()) {
+	stopped := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-stopped:
+			return
+		}
+		s.mu.Lock()
+		s.cond.Broadcast()
+		s.mu.Unlock()
+	}()
+	return func() { close(stopped) }
+}
+
+// unprotectedResync calls s.resync for the shard unless a call for it is
+// already running.
+func (s *importSequencer) unprotectedResync(index string, shard uint64) {
+	key := importShard{index: index, shard: shard}
+	if s.resync == nil {
+		return
+	} else if _, ok := s.resyncing[key]; ok {
+		return
+	}
+	s.resyncing[key] = struct{}{}
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			delete(s.resyncing, key)
+			s.mu.Unlock()
+		}()
+		s.resync(index, shard)
+	}()
+}
+
+// assign blocks until no other import to the shard is being applied on this
+// node, or until ctx is done, and returns the next sequence number. The
+// caller applies the import and then calls done with the result, which
+// consumes the sequence number only if the import succeeded.
+func (s *importSequencer) assign(ctx context.Context, index string, shard uint64) (seq uint64, done func(error), err error) {
+	stop := s.wakeOnDone(ctx)
+	defer stop()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ss := s.unprotectedShard(index, shard)
+	for ss.busy {
+		if err := ctx.Err(); err != nil {
+			return 0, nil, err
+		}
+		s.cond.Wait()
+	}
+	if ss.applied == 0 {
+		// Sequences start from the current time so that a restarted
+		// primary does not reuse numbers which replicas have applied.
+		ss.applied = uint64(time.Now().UnixNano())
+	}
+	ss.busy = true
+	seq = ss.applied + 1
+
+	return seq, func(err error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if err == nil {
+			ss.applied = seq
+		}
+		ss.busy = false
+		s.cond.Broadcast()
+	}, nil
+}
+
+// wait blocks until all imports to the shard sequenced before seq have been
+// applied, until the timeout expires, or until ctx is done. Returns
+// ErrImportOutOfOrder if an import with an equal or later sequence number has
+// already been applied, in which case the import must not be applied and the
+// shard is synced with its other owners instead. The caller applies the
+// import and then calls done.
+func (s *importSequencer) wait(ctx context.Context, index string, shard uint64, seq uint64) (done func(), err error) {
+	timeout, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	stop := s.wakeOnDone(timeout)
+	defer stop()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ss := s.unprotectedShard(index, shard)
+	if ss.applied == 0 {
+		// This node has not applied any sequenced imports to the shard,
+		// so there is nothing to order this one against.
+		ss.applied = seq - 1
+	}
+	for ss.busy || (ss.applied+1 < seq && timeout.Err() == nil) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		s.cond.Wait()
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	} else if ss.applied >= seq {
+		// The import was skipped when it did not arrive in time. The
+		// primary has applied it, so the shard is recovered from the
+		// other owners rather than by applying it out of order.
+		s.unprotectedResync(index, shard)
+		return nil, ErrImportOutOfOrder
+	}
+
+	// Any imports still missing are skipped, and recovered by a resync.
+	if ss.applied+1 < seq {
+		s.unprotectedResync(index, shard)
+	}
+	ss.busy = true
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		ss.applied = seq
+		ss.busy = false
+		s.cond.Broadcast()
+	}, nil
+}
@@ -0,0 +1,154 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilosa
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestImportSequencer(t *testing.T) {
+	t.Run("Assign", func(t *testing.T) {
+		s := newImportSequencer()
+		seq, done, err := s.assign(context.Background(), "i", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		done(nil)
+		if next, done, err := s.assign(context.Background(), "i", 0); err != nil {
+			t.Fatal(err)
+		} else if next != seq+1 {
+			t.Fatalf("unexpected sequence: %d, previous %d", next, seq)
+		} else {
+			done(context.Canceled)
+		}
+
+		// Failed imports do not consume a sequence number.
+		if next, done, err := s.assign(context.Background(), "i", 0); err != nil {
+			t.Fatal(err)
+		} else if next != seq+1 {
+			t.Fatalf("unexpected sequence: %d, previous %d", next, seq)
+		} else {
+			done(nil)
+		}
+	})
+
+	// Waiting for a sequence number gives up when ctx is canceled.
+	t.Run("AssignCanceled", func(t *testing.T) {
+		s := newImportSequencer()
+		_, done, err := s.assign(context.Background(), "i", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer done(nil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if _, _, err := s.assign(ctx, "i", 0); err != context.DeadlineExceeded {
+			t.Fatalf("expected deadline exceeded, got %v", err)
+		}
+	})
+
+	t.Run("WaitInOrder", func(t *testing.T) {
+		s := newImportSequencer()
+		ctx := context.Background()
+		done, err := s.wait(ctx, "i", 0, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		done()
+
+		// Sequence 12 waits until 11 is applied.
+		applied := make(chan uint64, 2)
+		go func() {
+			done, err := s.wait(ctx, "i", 0, 12)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			applied <- 12
+			done()
+		}()
+		time.Sleep(10 * time.Millisecond)
+		done, err = s.wait(ctx, "i", 0, 11)
+		if err != nil {
+			t.Fatal(err)
+		}
+		applied <- 11
+		done()
+
+		if a, b := <-applied, <-applied; a != 11 || b != 12 {
+			t.Fatalf("unexpected order: %d, %d", a, b)
+		}
+
+		// Imports which arrive late are not applied.
+		if _, err := s.wait(ctx, "i", 0, 11); err != ErrImportOutOfOrder {
+			t.Fatalf("expected out of order error, got %v", err)
+		}
+	})
+
+	t.Run("WaitTimeout", func(t *testing.T) {
+		s := newImportSequencer()
+		s.timeout = 10 * time.Millisecond
+		resynced := make(chan uint64, 2)
+		s.resync = func(index string, shard uint64) { resynced <- shard }
+		ctx := context.Background()
+		done, err := s.wait(ctx, "i", 0, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		done()
+
+		// Missing imports are skipped once the timeout expires, and the
+		// shard is resynced to recover them.
+		done, err = s.wait(ctx, "i", 0, 13)
+		if err != nil {
+			t.Fatal(err)
+		}
+		done()
+		if shard := <-resynced; shard != 0 {
+			t.Fatalf("unexpected resync: %d", shard)
+		}
+		for resyncing := true; resyncing; {
+			s.mu.Lock()
+			resyncing = len(s.resyncing) > 0
+			s.mu.Unlock()
+		}
+
+		// A skipped import which arrives late also resyncs the shard.
+		if _, err := s.wait(ctx, "i", 0, 11); err != ErrImportOutOfOrder {
+			t.Fatalf("expected out of order error, got %v", err)
+		}
+		if shard := <-resynced; shard != 0 {
+			t.Fatalf("unexpected resync: %d", shard)
+		}
+	})
+
+	t.Run("WaitCanceled", func(t *testing.T) {
+		s := newImportSequencer()
+		done, err := s.wait(context.Background(), "i", 0, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if _, err := s.wait(ctx, "i", 0, 12); err != context.DeadlineExceeded {
+			t.Fatalf("expected deadline exceeded, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,74 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilosa
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// maxImportStreamFrameSize is the largest batch accepted by API.ImportStream.
+const maxImportStreamFrameSize = 256 << 20
+
+// ImportAck acknowledges a batch of an import stream. Batches are numbered
+// from zero in the order they were sent.
+type ImportAck struct {
+	Batch uint64 `json:"batch"`
+
+	// Number of bits, or values for int fields, in the batch.
+	Bits uint64 `json:"bits"`
+
+	// If set, the batch was not imported and the stream ended. Earlier
+	// batches were imported.
+	Error string `json:"error,omitempty"`
+}
+
+// readImportStreamFrame reads a batch framed by its length as a big-endian
+// uint32. Returns io.EOF if the stream ends before the frame starts.
+func readImportStreamFrame(r io.Reader) ([]byte, error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(r, hdr[:]); err == io.EOF {
+		return nil, io.EOF
+	} else if err != nil {
+		return nil, errors.Wrap(err, "reading frame length")
+	}
+
+	n := binary.BigEndian.Uint32(hdr[:])
+	if n > maxImportStreamFrameSize {
+		return nil, errors.Errorf("frame of %d bytes exceeds maximum of %d", n, maxImportStreamFrameSize)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, errors.Wrap(err, "reading frame")
+	}
+	return buf, nil
+}
+
+// WriteImportStreamFrame writes a batch for API.ImportStream to w, framed by
+// its length.
+func WriteImportStreamFrame(w io.Writer, batch []byte) error {
+	if len(batch) > maxImportStreamFrameSize {
+		return errors.Errorf("frame of %d bytes exceeds maximum of %d", len(batch), maxImportStreamFrameSize)
+	}
+	var hdr [4]byte
+	binary.BigEndian.PutUint32(hdr[:], uint32(len(batch)))
+	if _, err := w.Write(hdr[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(batch)
+	return err
+}
@@ -0,0 +1,134 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilosa
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ImportTransform rewrites the row and column IDs of an import in place
+// before they are written. arg is the part of ImportRequest.Transform after
+// the first colon, or empty if there is none. Column IDs must stay within
+// the import's shard.
+type ImportTransform func(arg string, rowIDs, columnIDs []uint64) error
+
+// builtinImportTransforms are available on every server.
+var builtinImportTransforms = map[string]ImportTransform{
+	"rowOffset": rowOffsetTransform,
+	"rowModulo": rowModuloTransform,
+}
+
+// rowOffsetTransform adds the signed integer arg to each row ID.
+func rowOffsetTransform(arg string, rowIDs, columnIDs []uint64) error {
+	offset, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return errors.Wrap(err, "parsing offset")
+	}
+	for i, id := range rowIDs {
+		if offset < 0 && id < uint64(-offset) {
+			return errors.Errorf("row %d is less than offset %d", id, offset)
+		} else if offset > 0 && id > math.MaxUint64-uint64(offset) {
+			return errors.Errorf("row %d overflows with offset %d", id, offset)
+		}
+		rowIDs[i] = uint64(int64(id) + offset)
+	}
+	return nil
+}
+
+// rowModuloTransform replaces each row ID with its remainder when divided by
+// the positive integer arg.
+func rowModuloTransform(arg string, rowIDs, columnIDs []uint64) error {
+	m, err := strconv.ParseUint(arg, 10, 64)
+	if err != nil {
+		return errors.Wrap(err, "parsing modulus")
+	} else if m == 0 {
+		return errors.New("modulus must be positive")
+	}
+	for i, id := range rowIDs {
+		rowIDs[i] = id % m
+	}
+	return nil
+}
+
+// importTransformRegistry holds the transforms registered with
+// API.RegisterImportTransform.
+type importTransformRegistry struct {
+	mu         sync.RWMutex
+	transforms map[string]ImportTransform
+}
+
+// register adds a transform. Returns ErrImportTransformExists if a transform
+// with the same name is built in or already registered.
+func (r *importTransformRegistry) register(name string, fn ImportTransform) error {
+	if name == "" || strings.Contains(name, ":") {
+		return errors.Errorf("invalid transform name: %q", name)
+	} else if fn == nil {
+		return errors.New("transform required")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := builtinImportTransforms[name]; ok {
+		return ErrImportTransformExists
+	} else if _, ok := r.transforms[name]; ok {
+		return ErrImportTransformExists
+	}
+	if r.transforms == nil {
+		r.transforms = make(map[string]ImportTransform)
+	}
+	r.transforms[name] = fn
+	return nil
+}
+
+// apply applies the transform referenced by transform, which is a transform
+// name optionally followed by a colon and an argument, to the IDs of an
+// import.
+func (r *importTransformRegistry) apply(transform string, rowIDs, columnIDs []uint64) error {
+	name, arg := transform, ""
+	if i := strings.Index(transform, ":"); i >= 0 {
+		name, arg = transform[:i], transform[i+1:]
+	}
+
+	r.mu.RLock()
+	fn := r.transforms[name]
+	r.mu.RUnlock()
+	if fn == nil {
+		fn = builtinImportTransforms[name]
+	}
+	if fn == nil {
+		return errors.Wrap(ErrImportTransformNotFound, name)
+	}
+
+	// Record each column's shard so that transforms can't move bits to
+	// shards which this node may not own.
+	shards := make([]uint64, len(columnIDs))
+	for i, id := range columnIDs {
+		shards[i] = id / ShardWidth
+	}
+	if err := fn(arg, rowIDs, columnIDs); err != nil {
+		return errors.Wrapf(ErrImportTransformFailed, "%s: %s", name, err)
+	}
+	for i, id := range columnIDs {
+		if id/ShardWidth != shards[i] {
+			return errors.Wrapf(ErrImportTransformFailed, "%s: column %d moved out of shard %d", name, id, shards[i])
+		}
+	}
+	return nil
+}
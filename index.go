@@ -21,6 +21,7 @@ import (
 	"path/filepath"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gogo/protobuf/proto"
@@ -33,11 +34,19 @@ import (
 
 // Index represents a container for fields.
 type Index struct {
+	// Increased whenever data in the index changes through this node.
+	// Accessed atomically, so kept first for 64-bit alignment.
+	generation uint64
+
 	mu   sync.RWMutex
 	path string
 	name string
 	keys bool // use string keys
 
+	// Held for reading by queries and exports using the index's fragments,
+	// and for writing while the index is reloaded. See Holder.holdIndex.
+	inUse sync.RWMutex
+
 	// Existence tracking.
 	trackExistence bool
 	existenceFld   *Field
@@ -45,11 +54,33 @@ type Index struct {
 	// Fields by name.
 	fields map[string]*Field
 
+	// Returns the maximum number of fields which may be created. Zero or
+	// less means no limit.
+	maxFields func() int
+
+	// Returns the gzip level of fragment snapshots. Zero means the default.
+	snapshotCompressionLevel func() int
+
+	// Returns the directory to which deleted fields are moved, or blank if
+	// they are removed.
+	trashPath func() string
+
 	newAttrStore func(string) AttrStore
 
+	// Name of the backend of the column attribute store, or blank for the
+	// holder's default.
+	attrStore string
+
 	// Column attribute storage and cache.
 	columnAttrs AttrStore
 
+	// Column attribute types registered ahead of data.
+	attrSchema AttrSchema
+
+	// Options for new fields, overridden by the options they are created
+	// with.
+	defaultFieldOptions FieldOptions
+
 	broadcaster broadcaster
 	Stats       stats.StatsClient
 
@@ -64,6 +95,8 @@ func NewIndex(path, name string) (*Index, error) {
 	}
 
 	return &Index{
+		generation: atomic.AddUint64(&lastIndexGeneration, 1),
+
 		path:   path,
 		name:   name,
 		fields: make(map[string]*Field),
@@ -90,6 +123,107 @@ func (i *Index) Keys() bool { return i.keys }
 // ColumnAttrStore returns the storage for column attributes.
 func (i *Index) ColumnAttrStore() AttrStore { return i.columnAttrs }
 
+// AttrSchema returns a copy of the column attribute types registered on the index.
+func (i *Index) AttrSchema() AttrSchema {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	s := make(AttrSchema, len(i.attrSchema))
+	for k, v := range i.attrSchema {
+		s[k] = v
+	}
+	return s
+}
+
+// RegisterAttrSchema adds the column attribute types in s to the index. Once
+// registered, values set for a key must be of its type. Returns an error if a
+// key is already registered with a different type.
+func (i *Index) RegisterAttrSchema(s AttrSchema) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for k, typ := range s {
+		if !isValidAttrSchemaType(typ) {
+			return errors.Wrapf(ErrInvalidAttrSchemaType, "attr %q: %s", k, typ)
+		} else if cur, ok := i.attrSchema[k]; ok && cur != typ {
+			return errors.Wrapf(ErrAttrSchemaConflict, "attr %q is registered as %s", k, cur)
+		}
+	}
+
+	if i.attrSchema == nil {
+		i.attrSchema = make(AttrSchema, len(s))
+	}
+	for k, typ := range s {
+		i.attrSchema[k] = typ
+	}
+	return i.saveMeta()
+}
+
+// DefaultFieldOptions returns the options which new fields inherit.
+func (i *Index) DefaultFieldOptions() FieldOptions {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.defaultFieldOptions
+}
+
+// SetDefaultFieldOptions sets the options which new fields inherit for each
+// option they are not created with. Existing fields are unchanged. Persists
+// to meta file on update.
+func (i *Index) SetDefaultFieldOptions(o FieldOptions) error {
+	switch o.Type {
+	case "", FieldTypeSet, FieldTypeInt, FieldTypeTime, FieldTypeMutex, FieldTypeBool:
+	default:
+		return errors.Wrap(ErrInvalidFieldType, o.Type)
+	}
+	if o.CacheType != "" && !isValidCacheType(o.CacheType) {
+		return ErrInvalidCacheType
+	} else if !isValidCompression(o.Compression) {
+		return ErrInvalidCompression
+	} else if o.TimeQuantum != "" && !o.TimeQuantum.Valid() {
+		return ErrInvalidTimeQuantum
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.defaultFieldOptions = o
+	return i.saveMeta()
+}
+
+// EnableKeys switches the index to string keys. Persists to meta file on
+// update. Returns ErrIndexKeysEnabled if the index already uses keys.
+func (i *Index) EnableKeys() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.keys {
+		return ErrIndexKeysEnabled
+	}
+	i.keys = true
+	if err := i.saveMeta(); err != nil {
+		i.keys = false
+		return errors.Wrap(err, "saving")
+	}
+	return nil
+}
+
+// Generation returns a counter which increases whenever data in the index
+// changes through this node.
+func (i *Index) Generation() uint64 { return atomic.LoadUint64(&i.generation) }
+
+// lastIndexGeneration is the last generation given to any index. Sharing it
+// between indexes means an index which replaces a deleted one of the same
+// name never reuses its generations.
+var lastIndexGeneration uint64
+
+// touch moves the index to a new generation.
+func (i *Index) touch() {
+	for {
+		cur := atomic.LoadUint64(&i.generation)
+		if atomic.CompareAndSwapUint64(&i.generation, cur, atomic.AddUint64(&lastIndexGeneration, 1)) {
+			return
+		}
+	}
+}
+
 // Options returns all options for this index.
 func (i *Index) Options() IndexOptions {
 	i.mu.RLock()
@@ -101,6 +235,7 @@ func (i *Index) options() IndexOptions {
 	return IndexOptions{
 		Keys:           i.keys,
 		TrackExistence: i.trackExistence,
+		AttrStore:      i.attrStore,
 	}
 }
 
@@ -116,6 +251,14 @@ func (i *Index) Open() error {
 		return errors.Wrap(err, "loading meta file")
 	}
 
+	if i.attrStore != "" {
+		newAttrStore, err := attrStoreByName(i.attrStore)
+		if err != nil {
+			return errors.Wrap(err, "selecting attrstore")
+		}
+		i.columnAttrs = newAttrStore(filepath.Join(i.path, ".data"))
+	}
+
 	if err := i.openFields(); err != nil {
 		return errors.Wrap(err, "opening fields")
 	}
@@ -163,6 +306,68 @@ func (i *Index) openFields() error {
 	return nil
 }
 
+// rebuildSchemaFromDisk opens any field directories under the index path
+// which are not yet open and writes inferred options for fields whose meta
+// files are missing. The index meta file is rewritten if it is missing.
+func (i *Index) rebuildSchemaFromDisk() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if _, err := os.Stat(filepath.Join(i.path, ".meta")); os.IsNotExist(err) {
+		if err := i.saveMeta(); err != nil {
+			return errors.Wrap(err, "saving index meta")
+		}
+	} else if err != nil {
+		return errors.Wrap(err, "checking index meta")
+	}
+
+	fis, err := ioutil.ReadDir(i.path)
+	if err != nil {
+		return errors.Wrap(err, "reading directory")
+	}
+	for _, fi := range fis {
+		if !fi.IsDir() {
+			continue
+		}
+		name := fi.Name()
+
+		_, err := os.Stat(filepath.Join(i.fieldPath(name), ".meta"))
+		if err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "checking field meta: %s", name)
+		}
+		missing := os.IsNotExist(err)
+
+		fld := i.field(name)
+		if fld == nil {
+			if fld, err = i.newField(i.fieldPath(name), name); err != nil {
+				return errors.Wrapf(err, "creating field: %s", name)
+			} else if err := fld.Open(); err != nil {
+				return errors.Wrapf(err, "opening field: %s", name)
+			}
+			i.fields[name] = fld
+		}
+		if !missing {
+			continue
+		}
+
+		opt := fld.inferOptions()
+		if name == existenceFieldName {
+			opt = FieldOptions{Type: FieldTypeSet, CacheType: CacheTypeNone}
+		}
+		if err := fld.applyOptions(opt); err != nil {
+			return errors.Wrapf(err, "applying inferred options: %s", name)
+		}
+		fld.mu.Lock()
+		err = fld.saveMeta()
+		fld.mu.Unlock()
+		if err != nil {
+			return errors.Wrapf(err, "saving field meta: %s", name)
+		}
+	}
+	i.touch()
+	return nil
+}
+
 // openExistenceField gets or creates the existence field and associates it to the index.
 func (i *Index) openExistenceField() error {
 	f, err := i.createFieldIfNotExists(existenceFieldName, FieldOptions{CacheType: CacheTypeNone, CacheSize: 0})
@@ -192,6 +397,11 @@ func (i *Index) loadMeta() error {
 	// Copy metadata fields.
 	i.keys = pb.Keys
 	i.trackExistence = pb.TrackExistence
+	i.attrStore = pb.AttrStore
+	i.attrSchema = decodeAttrSchema(pb.AttrSchema)
+	if pb.DefaultFieldOptions != nil {
+		i.defaultFieldOptions = decodeFieldOptions(pb.DefaultFieldOptions)
+	}
 
 	return nil
 }
@@ -202,6 +412,10 @@ func (i *Index) saveMeta() error {
 	buf, err := proto.Marshal(&internal.IndexMeta{
 		Keys:           i.keys,
 		TrackExistence: i.trackExistence,
+		AttrStore:      i.attrStore,
+		AttrSchema:     encodeAttrSchema(i.attrSchema),
+
+		DefaultFieldOptions: encodeFieldOptions(&i.defaultFieldOptions),
 	})
 	if err != nil {
 		return errors.Wrap(err, "marshalling")
@@ -234,6 +448,16 @@ func (i *Index) Close() error {
 	return nil
 }
 
+// openFileCount returns the number of files the index's attribute stores and
+// fragments have open.
+func (i *Index) openFileCount() int {
+	n := attrStoreOpenFileCount(i.ColumnAttrStore())
+	for _, f := range i.Fields() {
+		n += f.openFileCount()
+	}
+	return n
+}
+
 // AvailableShards returns a bitmap of all shards with data in the index.
 func (i *Index) AvailableShards() *roaring.Bitmap {
 	if i == nil {
@@ -293,6 +517,25 @@ func (i *Index) recalculateCaches() {
 	}
 }
 
+// setStats replaces the stats client of the index and its fields.
+func (i *Index) setStats(sc stats.StatsClient) {
+	i.mu.Lock()
+	i.Stats = sc
+	i.mu.Unlock()
+
+	for _, field := range i.Fields() {
+		field.setStats(sc.WithTags(fmt.Sprintf("field:%s", field.Name())))
+	}
+}
+
+// statsClient returns the stats client used by the index. It is safe to call
+// while setStats replaces the client.
+func (i *Index) statsClient() stats.StatsClient {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.Stats
+}
+
 // CreateField creates a field.
 func (i *Index) CreateField(name string, opts ...FieldOption) (*Field, error) {
 	err := validateName(name)
@@ -317,7 +560,7 @@ func (i *Index) CreateField(name string, opts ...FieldOption) (*Field, error) {
 		}
 	}
 
-	return i.createField(name, fo)
+	return i.createField(name, fo.withDefaults(i.defaultFieldOptions))
 }
 
 // CreateFieldIfNotExists creates a field with the given options if it doesn't exist.
@@ -344,7 +587,7 @@ func (i *Index) CreateFieldIfNotExists(name string, opts ...FieldOption) (*Field
 		}
 	}
 
-	return i.createField(name, fo)
+	return i.createField(name, fo.withDefaults(i.defaultFieldOptions))
 }
 
 func (i *Index) createFieldIfNotExists(name string, opt FieldOptions) (*Field, error) {
@@ -364,6 +607,19 @@ func (i *Index) createField(name string, opt FieldOptions) (*Field, error) {
 		return nil, errors.New("field name required")
 	} else if opt.CacheType != "" && !isValidCacheType(opt.CacheType) {
 		return nil, ErrInvalidCacheType
+	} else if !isValidCompression(opt.Compression) {
+		return nil, ErrInvalidCompression
+	}
+
+	// Enforce the field limit. The existence field is not counted.
+	if name != existenceFieldName && i.maxFields != nil {
+		n := len(i.fields)
+		if _, ok := i.fields[existenceFieldName]; ok {
+			n--
+		}
+		if max := i.maxFields(); max > 0 && n >= max {
+			return nil, ErrTooManyFields
+		}
 	}
 
 	// Initialize field.
@@ -403,6 +659,7 @@ func (i *Index) newField(path, name string) (*Field, error) {
 	f.Stats = i.Stats.WithTags(fmt.Sprintf("field:%s", name))
 	f.broadcaster = i.broadcaster
 	f.rowAttrStore = i.newAttrStore(filepath.Join(f.path, ".data"))
+	f.snapshotCompressionLevel = i.snapshotCompressionLevel
 	return f, nil
 }
 
@@ -423,9 +680,10 @@ func (i *Index) DeleteField(name string) error {
 	}
 
 	// Delete field directory.
-	if err := os.RemoveAll(i.fieldPath(name)); err != nil {
-		return errors.Wrap(err, "removing directory")
+	if err := i.removeFieldDir(name); err != nil {
+		return err
 	}
+	i.touch()
 
 	// If the field being deleted is the existence field,
 	// turn off existence tracking on the index.
@@ -468,6 +726,10 @@ func (p indexInfoSlice) Less(i, j int) bool { return p[i].Name < p[j].Name }
 type IndexOptions struct {
 	Keys           bool `json:"keys"`
 	TrackExistence bool `json:"trackExistence"`
+
+	// Name of the backend storing column attributes, such as "nop" or
+	// "bolt". If blank, the server's default is used.
+	AttrStore string `json:"attrStore,omitempty"`
 }
 
 // hasTime returns true if a contains a non-nil time.
@@ -493,4 +755,5 @@ type importData struct {
 type importValueData struct {
 	ColumnIDs []uint64
 	Values    []int64
+	Null      []bool
 }
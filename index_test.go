@@ -211,6 +211,26 @@ func TestIndex_DeleteField(t *testing.T) {
 	}
 }
 
+// Ensure index persists its registered attribute schema.
+func TestIndex_AttrSchema(t *testing.T) {
+	index := test.MustOpenIndex()
+	defer index.Close()
+
+	schema := pilosa.AttrSchema{"name": pilosa.AttrSchemaTypeString, "age": pilosa.AttrSchemaTypeInt}
+	if err := index.RegisterAttrSchema(schema); err != nil {
+		t.Fatal(err)
+	} else if err := index.Reopen(); err != nil {
+		t.Fatal(err)
+	} else if other := index.AttrSchema(); !reflect.DeepEqual(other, schema) {
+		t.Fatalf("unexpected schema: %v", other)
+	}
+
+	// Registering a key with a different type should fail.
+	if err := index.RegisterAttrSchema(pilosa.AttrSchema{"age": pilosa.AttrSchemaTypeFloat}); errors.Cause(err) != pilosa.ErrAttrSchemaConflict {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
 // Ensure index can validate its name.
 func TestIndex_InvalidName(t *testing.T) {
 	path, err := ioutil.TempDir("", "pilosa-index-")
@@ -32,6 +32,20 @@ func (s *translateStore) Reader(ctx context.Context, offset int64) (io.ReadClose
 	return nil, pilosa.ErrReplicationNotSupported
 }
 
+// Compact does nothing because the inmem store does not accumulate unused
+// entries.
+func (s *translateStore) Compact() error {
+	return nil
+}
+
+// DeleteFieldKeys removes the row key mappings of a field.
+func (s *translateStore) DeleteFieldKeys(index, frame string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.rows, frameKey{index, frame})
+	return nil
+}
+
 // TranslateColumnsToUint64 converts value to a uint64 id.
 // If value does not have an associated id then one is created.
 func (s *translateStore) TranslateColumnsToUint64(index string, values []string) ([]uint64, error) {
@@ -196,6 +210,21 @@ func (s *translateStore) TranslateRowToString(index, frame string, value uint64)
 	return "", nil
 }
 
+// TranslateRowsToStrings converts uint64 ids to their associated string values.
+// Ids which are not associated with a string value are returned as blank strings.
+func (s *translateStore) TranslateRowsToStrings(index, frame string, values []uint64) ([]string, error) {
+	ret := make([]string, len(values))
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if idx := s.rows[frameKey{index, frame}]; idx != nil {
+		for i := range values {
+			ret[i] = idx.reverse[values[i]]
+		}
+	}
+	return ret, nil
+}
+
 type frameKey struct {
 	index string
 	frame string
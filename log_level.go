@@ -0,0 +1,126 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilosa
+
+import (
+	"sync"
+
+	"github.com/pilosa/pilosa/logger"
+	"github.com/pkg/errors"
+)
+
+// Subsystems whose logging can be adjusted with API.SetLogLevel.
+const (
+	LogSubsystemBroadcast = "broadcast"
+	LogSubsystemCluster   = "cluster"
+	LogSubsystemImport    = "import"
+	LogSubsystemQuery     = "query"
+)
+
+// Log levels accepted by API.SetLogLevel. At LogLevelInfo, the default,
+// messages are passed to the server's logger unchanged. LogLevelDebug also
+// writes debug messages when the server's logger is not verbose, and
+// LogLevelNone discards all messages.
+const (
+	LogLevelDebug = "debug"
+	LogLevelInfo  = "info"
+	LogLevelNone  = "none"
+)
+
+type logLevel int
+
+const (
+	logLevelNone logLevel = iota
+	logLevelInfo
+	logLevelDebug
+)
+
+// String returns the name of the level.
+func (l logLevel) String() string {
+	switch l {
+	case logLevelDebug:
+		return LogLevelDebug
+	case logLevelNone:
+		return LogLevelNone
+	}
+	return LogLevelInfo
+}
+
+var logLevelsByName = map[string]logLevel{
+	LogLevelDebug: logLevelDebug,
+	LogLevelInfo:  logLevelInfo,
+	LogLevelNone:  logLevelNone,
+}
+
+// logLevels holds the log level of each subsystem.
+type logLevels struct {
+	mu     sync.RWMutex
+	levels map[string]logLevel
+}
+
+// set sets the log level of a subsystem.
+func (l *logLevels) set(subsystem, level string) error {
+	switch subsystem {
+	case LogSubsystemBroadcast, LogSubsystemCluster, LogSubsystemImport, LogSubsystemQuery:
+	default:
+		return errors.Errorf("invalid log subsystem: %q", subsystem)
+	}
+	lvl, ok := logLevelsByName[level]
+	if !ok {
+		return errors.Errorf("invalid log level: %q", level)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.levels == nil {
+		l.levels = make(map[string]logLevel)
+	}
+	l.levels[subsystem] = lvl
+	return nil
+}
+
+// level returns the log level of a subsystem.
+func (l *logLevels) level(subsystem string) logLevel {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if lvl, ok := l.levels[subsystem]; ok {
+		return lvl
+	}
+	return logLevelInfo
+}
+
+// subsystemLogger writes the messages of a subsystem to a logger when the
+// subsystem's level allows them. The level is checked for every message, so
+// changes apply to loggers which are already in use.
+type subsystemLogger struct {
+	levels    *logLevels
+	subsystem string
+	logger    logger.Logger
+}
+
+func (l *subsystemLogger) Printf(format string, v ...interface{}) {
+	if l.levels.level(l.subsystem) >= logLevelInfo {
+		l.logger.Printf(format, v...)
+	}
+}
+
+func (l *subsystemLogger) Debugf(format string, v ...interface{}) {
+	switch l.levels.level(l.subsystem) {
+	case logLevelDebug:
+		l.logger.Printf(format, v...)
+	case logLevelInfo:
+		l.logger.Debugf(format, v...)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilosa
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// Prefixes which distinguish the hashes of leaves and interior nodes of a
+// Merkle tree, so that a subtree cannot be passed off as a leaf.
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
+)
+
+// merkleRoot returns the root of a binary Merkle tree over leaves, using
+// SHA-256. A node without a sibling is promoted to the next level unchanged.
+// The root of a tree without leaves is the hash of nothing.
+func merkleRoot(leaves [][]byte) []byte {
+	if len(leaves) == 0 {
+		sum := sha256.Sum256(nil)
+		return sum[:]
+	}
+
+	level := make([][]byte, len(leaves))
+	for i, leaf := range leaves {
+		h := sha256.New()
+		h.Write([]byte{merkleLeafPrefix})
+		h.Write(leaf)
+		level[i] = h.Sum(nil)
+	}
+
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			h := sha256.New()
+			h.Write([]byte{merkleNodePrefix})
+			h.Write(level[i])
+			h.Write(level[i+1])
+			next = append(next, h.Sum(nil))
+		}
+		level = next
+	}
+	return level[0]
+}
+
+// fragmentBlockLeaf returns the Merkle leaf of a fragment block, which
+// identifies the block by its field, view, shard and id along with its
+// checksum.
+func fragmentBlockLeaf(field, view string, shard uint64, blk FragmentBlock) []byte {
+	buf := make([]byte, 0, 2*8+len(field)+len(view)+2*8+len(blk.Checksum))
+	var n [8]byte
+	for _, s := range []string{field, view} {
+		binary.BigEndian.PutUint64(n[:], uint64(len(s)))
+		buf = append(buf, n[:]...)
+		buf = append(buf, s...)
+	}
+	binary.BigEndian.PutUint64(n[:], shard)
+	buf = append(buf, n[:]...)
+	binary.BigEndian.PutUint64(n[:], uint64(blk.ID))
+	buf = append(buf, n[:]...)
+	return append(buf, blk.Checksum...)
+}
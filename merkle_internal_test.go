@@ -0,0 +1,65 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilosa
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+)
+
+func TestMerkleRoot(t *testing.T) {
+	hash := func(prefix byte, a ...[]byte) []byte {
+		h := sha256.New()
+		h.Write([]byte{prefix})
+		for _, b := range a {
+			h.Write(b)
+		}
+		return h.Sum(nil)
+	}
+	a, b, c := []byte("a"), []byte("b"), []byte("c")
+	la, lb, lc := hash(merkleLeafPrefix, a), hash(merkleLeafPrefix, b), hash(merkleLeafPrefix, c)
+
+	empty := sha256.Sum256(nil)
+	if root := merkleRoot(nil); !bytes.Equal(root, empty[:]) {
+		t.Fatalf("unexpected empty root: %x", root)
+	}
+	if root := merkleRoot([][]byte{a}); !bytes.Equal(root, la) {
+		t.Fatalf("unexpected root of one leaf: %x", root)
+	}
+
+	// The third leaf has no sibling and is promoted.
+	exp := hash(merkleNodePrefix, hash(merkleNodePrefix, la, lb), lc)
+	if root := merkleRoot([][]byte{a, b, c}); !bytes.Equal(root, exp) {
+		t.Fatalf("unexpected root: %x", root)
+	}
+
+	// Order matters.
+	if root := merkleRoot([][]byte{b, a, c}); bytes.Equal(root, exp) {
+		t.Fatal("expected root to depend on leaf order")
+	}
+}
+
+func TestFragmentBlockLeaf(t *testing.T) {
+	blk := FragmentBlock{ID: 1, Checksum: []byte{1, 2, 3}}
+	leaf := fragmentBlockLeaf("f", "standard", 2, blk)
+
+	// Names are length prefixed so they cannot run together.
+	if bytes.Equal(leaf, fragmentBlockLeaf("fs", "tandard", 2, blk)) {
+		t.Fatal("expected distinct leaves")
+	} else if bytes.Equal(leaf, fragmentBlockLeaf("f", "standard", 3, blk)) {
+		t.Fatal("expected distinct leaves for different shards")
+	}
+}
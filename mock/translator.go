@@ -14,7 +14,10 @@ type TranslateStore struct {
 	TranslateColumnToStringFunc  func(index string, values uint64) (string, error)
 	TranslateRowsToUint64Func    func(index, field string, values []string) ([]uint64, error)
 	TranslateRowToStringFunc     func(index, field string, values uint64) (string, error)
+	TranslateRowsToStringsFunc   func(index, field string, values []uint64) ([]string, error)
 	ReaderFunc                   func(ctx context.Context, off int64) (io.ReadCloser, error)
+	CompactFunc                  func() error
+	DeleteFieldKeysFunc          func(index, field string) error
 }
 
 func (s TranslateStore) TranslateColumnsToUint64(index string, values []string) ([]uint64, error) {
@@ -33,6 +36,18 @@ func (s TranslateStore) TranslateRowToString(index, field string, value uint64)
 	return s.TranslateRowToStringFunc(index, field, value)
 }
 
+func (s TranslateStore) TranslateRowsToStrings(index, field string, values []uint64) ([]string, error) {
+	return s.TranslateRowsToStringsFunc(index, field, values)
+}
+
 func (s TranslateStore) Reader(ctx context.Context, off int64) (io.ReadCloser, error) {
 	return s.ReaderFunc(ctx, off)
 }
+
+func (s TranslateStore) Compact() error {
+	return s.CompactFunc()
+}
+
+func (s TranslateStore) DeleteFieldKeys(index, field string) error {
+	return s.DeleteFieldKeysFunc(index, field)
+}
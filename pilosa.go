@@ -27,11 +27,24 @@ var (
 	ErrIndexExists   = errors.New("index already exists")
 	ErrIndexNotFound = errors.New("index not found")
 
+	// ErrIndexKeysEnabled is returned when enabling keys on an index which
+	// already uses them.
+	ErrIndexKeysEnabled = errors.New("index already uses keys")
+
+	// ErrIndexReserved is returned when an index name is held by a prepared
+	// but uncommitted index creation.
+	ErrIndexReserved    = errors.New("index name reserved")
+	ErrIndexNotReserved = errors.New("index not prepared")
+
 	// ErrFieldRequired is returned when no field is specified.
 	ErrFieldRequired = errors.New("field required")
 	ErrFieldExists   = errors.New("field already exists")
 	ErrFieldNotFound = errors.New("field not found")
 
+	// ErrTooManyFields is returned when creating a field would exceed the
+	// maximum number of fields per index.
+	ErrTooManyFields = errors.New("too many fields in index")
+
 	ErrBSIGroupNotFound         = errors.New("bsigroup not found")
 	ErrBSIGroupExists           = errors.New("bsigroup already exists")
 	ErrBSIGroupNameRequired     = errors.New("bsigroup name required")
@@ -43,8 +56,14 @@ var (
 	ErrInvalidRangeOperation    = errors.New("invalid range operation")
 	ErrInvalidBetweenValue      = errors.New("invalid value for between operation")
 
-	ErrInvalidView      = errors.New("invalid view")
-	ErrInvalidCacheType = errors.New("invalid cache type")
+	ErrInvalidView        = errors.New("invalid view")
+	ErrViewNotFound       = errors.New("view not found")
+	ErrInvalidCacheType   = errors.New("invalid cache type")
+	ErrInvalidCompression = errors.New("invalid compression")
+	ErrInvalidFieldType   = errors.New("invalid field type")
+
+	ErrInvalidAttrSchemaType = errors.New("invalid attribute schema type")
+	ErrAttrSchemaConflict    = errors.New("attribute type conflicts with schema")
 
 	ErrName  = errors.New("invalid index or field name, must match [a-z0-9_-]")
 	ErrLabel = errors.New("invalid row or column label, must match [A-Za-z0-9_-]")
@@ -55,6 +74,33 @@ var (
 	ErrQueryCancelled   = errors.New("query cancelled")
 	ErrQueryTimeout     = errors.New("query timeout")
 	ErrTooManyWrites    = errors.New("too many write commands")
+	ErrForbidden        = errors.New("forbidden")
+
+	// ErrRateLimited is returned when a query exceeds its index's query rate
+	// limit. The query may be retried later.
+	ErrRateLimited = errors.New("query rate limit exceeded")
+
+	// ErrImportNotFound is returned when an import ID is not registered.
+	ErrImportNotFound = errors.New("import not found")
+	ErrImportCanceled = errors.New("import canceled")
+
+	// ErrImportChecksumMismatch is returned when an import's payload does
+	// not match its checksum.
+	ErrImportChecksumMismatch = errors.New("import checksum mismatch")
+
+	// ErrImportTimestampOutOfRange is returned when an import which rejects
+	// out of range timestamps has a timestamp outside its field's time range.
+	ErrImportTimestampOutOfRange = errors.New("import timestamp out of range")
+
+	// ErrImportOutOfOrder is returned by the import sequencer when a replica
+	// receives an import after a later sequenced import to the same shard
+	// was applied.
+	ErrImportOutOfOrder = errors.New("import out of order")
+
+	// Errors returned for imports which reference an import transform.
+	ErrImportTransformNotFound = errors.New("import transform not found")
+	ErrImportTransformExists   = errors.New("import transform already exists")
+	ErrImportTransformFailed   = errors.New("import transform failed")
 
 	// TODO(2.0) poorly named - used when a *node* doesn't own a shard. Probably
 	// we won't need this error at all by 2.0 though.
@@ -62,7 +108,15 @@ var (
 
 	ErrNodeIDNotExists    = errors.New("node with provided ID does not exist")
 	ErrNodeNotCoordinator = errors.New("node is not the coordinator")
-	ErrResizeNotRunning   = errors.New("no resize job currently running")
+
+	// ErrNoCoordinatorCandidate is returned when the coordinator steps down
+	// but no other node is ready to replace it.
+	ErrNoCoordinatorCandidate = errors.New("no other node is ready to become coordinator")
+
+	// ErrCoordinatorUnreachable is returned when the node chosen to become
+	// coordinator does not respond.
+	ErrCoordinatorUnreachable = errors.New("new coordinator is unreachable")
+	ErrResizeNotRunning       = errors.New("no resize job currently running")
 
 	ErrNotImplemented            = errors.New("not implemented")
 	ErrFieldsArgumentRequired    = errors.New("fields argument required")
@@ -0,0 +1,141 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilosa
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+
+	"github.com/pilosa/pilosa/lru"
+)
+
+// defaultQueryCacheSize is the number of query responses an API caches.
+const defaultQueryCacheSize = 1000
+
+// queryCache holds query responses keyed by the request and the generation of
+// its index when it was executed. A change to the index's data moves it to a
+// new generation, so older entries are never used again and are evicted as
+// newer ones are added. Generations only move with writes made through the
+// local node, so the cache is only used on single node clusters. Responses
+// are copied in and out so callers never share results.
+type queryCache struct {
+	mu    sync.Mutex
+	cache *lru.Cache
+}
+
+func newQueryCache(maxEntries int) *queryCache {
+	return &queryCache{cache: lru.New(maxEntries)}
+}
+
+func (c *queryCache) get(key string) (QueryResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.cache.Get(key)
+	if !ok {
+		return QueryResponse{}, false
+	}
+	return copyQueryResponse(v.(QueryResponse)), true
+}
+
+func (c *queryCache) add(key string, resp QueryResponse) {
+	resp = copyQueryResponse(resp)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cache.Add(key, resp)
+}
+
+// copyQueryResponse returns a deep copy of the results and column attributes
+// of resp.
+func copyQueryResponse(resp QueryResponse) QueryResponse {
+	other := QueryResponse{Err: resp.Err}
+	if resp.Results != nil {
+		other.Results = make([]interface{}, len(resp.Results))
+		for i, result := range resp.Results {
+			other.Results[i] = copyQueryResult(result)
+		}
+	}
+	if resp.ColumnAttrSets != nil {
+		other.ColumnAttrSets = make([]*ColumnAttrSet, len(resp.ColumnAttrSets))
+		for i, set := range resp.ColumnAttrSets {
+			other.ColumnAttrSets[i] = &ColumnAttrSet{ID: set.ID, Key: set.Key, Attrs: copyAttrs(set.Attrs)}
+		}
+	}
+	return other
+}
+
+// copyQueryResult returns a deep copy of a single query result. Results of
+// value types are returned as is.
+func copyQueryResult(result interface{}) interface{} {
+	switch result := result.(type) {
+	case *Row:
+		if result == nil {
+			return result
+		}
+		return result.clone()
+	case []Pair:
+		return append([]Pair(nil), result...)
+	case []PairAttrs:
+		other := make([]PairAttrs, len(result))
+		for i, p := range result {
+			other[i] = PairAttrs{Pair: p.Pair, Attrs: copyAttrs(p.Attrs)}
+		}
+		return other
+	case RowIDs:
+		return append(RowIDs(nil), result...)
+	case RowIdentifiers:
+		return RowIdentifiers{
+			Rows: append([]uint64(nil), result.Rows...),
+			Keys: append([]string(nil), result.Keys...),
+		}
+	case []GroupCount:
+		other := make([]GroupCount, len(result))
+		for i, gc := range result {
+			other[i] = GroupCount{Group: append([]FieldRow(nil), gc.Group...), Count: gc.Count}
+		}
+		return other
+	default:
+		return result
+	}
+}
+
+// copyAttrs returns a copy of an attribute map. Attribute values are scalars,
+// so they are not copied themselves.
+func copyAttrs(attrs map[string]interface{}) map[string]interface{} {
+	if attrs == nil {
+		return nil
+	}
+	other := make(map[string]interface{}, len(attrs))
+	for k, v := range attrs {
+		other[k] = v
+	}
+	return other
+}
+
+// queryCacheKey returns the cache key for req at the given index generation,
+// or an empty string if the request can't be cached. The principal is left
+// out because queries are authorized before the cache is checked, and the
+// account because usage is recorded before it too.
+func queryCacheKey(req *QueryRequest, generation uint64) string {
+	r := *req
+	r.Principal = ""
+	r.AccountID = ""
+	r.CacheResults = false
+	buf, err := json.Marshal(&r)
+	if err != nil {
+		return ""
+	}
+	return strconv.FormatUint(generation, 10) + ":" + string(buf)
+}
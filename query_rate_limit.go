@@ -0,0 +1,82 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilosa
+
+import (
+	"sync"
+	"time"
+)
+
+// queryRateLimits holds the query rate limit of each index set with
+// API.SetIndexQueryRateLimit. Indexes without a limit are unlimited.
+type queryRateLimits struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// set limits queries to the index to qps per second. A limit of zero removes
+// the index's limit.
+func (l *queryRateLimits) set(index string, qps int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if qps <= 0 {
+		delete(l.buckets, index)
+		return
+	}
+	if l.buckets == nil {
+		l.buckets = make(map[string]*tokenBucket)
+	}
+	l.buckets[index] = newTokenBucket(float64(qps), time.Now())
+}
+
+// allow returns true if a query to the index is within its limit.
+func (l *queryRateLimits) allow(index string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b := l.buckets[index]
+	if b == nil {
+		return true
+	}
+	return b.take(time.Now())
+}
+
+// tokenBucket is a token bucket which refills at rate tokens per second and
+// holds up to rate tokens, so bursts of up to a second's worth of queries are
+// allowed.
+type tokenBucket struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, now time.Time) *tokenBucket {
+	return &tokenBucket{rate: rate, tokens: rate, last: now}
+}
+
+// take removes a token from the bucket, returning false if it is empty.
+func (b *tokenBucket) take(now time.Time) bool {
+	if now.After(b.last) {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.rate {
+			b.tokens = b.rate
+		}
+		b.last = now
+	}
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
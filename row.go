@@ -55,6 +55,26 @@ func (r *Row) IsEmpty() bool {
 	return true
 }
 
+// clone returns a copy of r which shares no data with it.
+func (r *Row) clone() *Row {
+	other := &Row{
+		segments: make([]rowSegment, len(r.segments)),
+		Attrs:    copyAttrs(r.Attrs),
+	}
+	if r.Keys != nil {
+		other.Keys = append([]string(nil), r.Keys...)
+	}
+	for i, s := range r.segments {
+		other.segments[i] = rowSegment{
+			data:     *s.data.Clone(),
+			shard:    s.shard,
+			writable: true,
+			n:        s.n,
+		}
+	}
+	return other
+}
+
 // Merge merges data from other into r.
 func (r *Row) Merge(other *Row) {
 	var segments []rowSegment
@@ -0,0 +1,122 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilosa
+
+import (
+	"sync"
+)
+
+// schemaWatchBufferSize is the number of events buffered for each watcher.
+const schemaWatchBufferSize = 256
+
+// SchemaEventType is the kind of change described by a SchemaEvent.
+type SchemaEventType string
+
+// Schema event types.
+const (
+	SchemaEventCreateIndex SchemaEventType = "createIndex"
+	SchemaEventDeleteIndex SchemaEventType = "deleteIndex"
+	SchemaEventCreateField SchemaEventType = "createField"
+	SchemaEventDeleteField SchemaEventType = "deleteField"
+	SchemaEventCreateView  SchemaEventType = "createView"
+	SchemaEventDeleteView  SchemaEventType = "deleteView"
+)
+
+// SchemaEvent describes an index, field or view which was created or
+// deleted.
+type SchemaEvent struct {
+	Type  SchemaEventType `json:"type"`
+	Index string          `json:"index"`
+	Field string          `json:"field,omitempty"`
+	View  string          `json:"view,omitempty"`
+}
+
+// schemaEventsFromMessage returns the schema events for a cluster message, if
+// the message describes a schema change.
+func schemaEventsFromMessage(m Message) []SchemaEvent {
+	switch obj := m.(type) {
+	case *CreateIndexMessage:
+		return []SchemaEvent{{Type: SchemaEventCreateIndex, Index: obj.Index}}
+	case *CommitCreateIndexMessage:
+		return []SchemaEvent{{Type: SchemaEventCreateIndex, Index: obj.Index}}
+	case *DeleteIndexMessage:
+		return []SchemaEvent{{Type: SchemaEventDeleteIndex, Index: obj.Index}}
+	case *CreateFieldMessage:
+		return []SchemaEvent{{Type: SchemaEventCreateField, Index: obj.Index, Field: obj.Field}}
+	case *DeleteFieldMessage:
+		return []SchemaEvent{{Type: SchemaEventDeleteField, Index: obj.Index, Field: obj.Field}}
+	case *CreateViewMessage:
+		return []SchemaEvent{{Type: SchemaEventCreateView, Index: obj.Index, Field: obj.Field, View: obj.View}}
+	case *DeleteViewMessage:
+		return []SchemaEvent{{Type: SchemaEventDeleteView, Index: obj.Index, Field: obj.Field, View: obj.View}}
+	case *DeleteViewsMessage:
+		evs := make([]SchemaEvent, len(obj.Views))
+		for i, view := range obj.Views {
+			evs[i] = SchemaEvent{Type: SchemaEventDeleteView, Index: obj.Index, Field: obj.Field, View: view}
+		}
+		return evs
+	}
+	return nil
+}
+
+// schemaWatchers sends schema events to the channels returned by
+// API.WatchSchema.
+type schemaWatchers struct {
+	mu    sync.Mutex
+	chans map[chan SchemaEvent]struct{}
+}
+
+// add returns a new channel which receives schema events.
+func (w *schemaWatchers) add() chan SchemaEvent {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.chans == nil {
+		w.chans = make(map[chan SchemaEvent]struct{})
+	}
+	ch := make(chan SchemaEvent, schemaWatchBufferSize)
+	w.chans[ch] = struct{}{}
+	return ch
+}
+
+// remove stops sending events to ch and closes it.
+func (w *schemaWatchers) remove(ch chan SchemaEvent) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.chans, ch)
+	close(ch)
+}
+
+// publish sends the event for m, if any, to every watcher. Watchers whose
+// buffers are full miss the event rather than blocking the caller. Returns
+// the number of watchers which missed it.
+func (w *schemaWatchers) publish(m Message) (dropped int) {
+	evs := schemaEventsFromMessage(m)
+	if len(evs) == 0 {
+		return 0
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for ch := range w.chans {
+		for _, ev := range evs {
+			select {
+			case ch <- ev:
+			default:
+				dropped++
+			}
+		}
+	}
+	return dropped
+}
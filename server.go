@@ -68,11 +68,18 @@ type Server struct { // nolint: maligned
 	metricInterval      time.Duration
 	diagnosticInterval  time.Duration
 	maxWritesPerRequest int
+	queryLogSize        int
+	orderedImports      bool
 	isCoordinator       bool
 	syncer              holderSyncer
 
-	defaultClient InternalClient
-	dataDir       string
+	defaultClient    InternalClient
+	dataDir          string
+	schemaWatchers   schemaWatchers
+	statsSampleRates *stats.SampleRates
+	logLevels        logLevels
+	queryPause       queryPause
+	queryRateLimits  queryRateLimits
 }
 
 // TODO: have this return an interface for Holder instead of concrete object?
@@ -132,6 +139,58 @@ func OptServerMaxWritesPerRequest(n int) ServerOption {
 	}
 }
 
+// OptServerMaxCacheBytes sets the approximate number of bytes all fragment
+// caches may use before the least recently used field caches are evicted.
+func OptServerMaxCacheBytes(n int64) ServerOption {
+	return func(s *Server) error {
+		s.holder.maxTotalCacheBytes = n
+		return nil
+	}
+}
+
+// OptServerPrefetch enables reading the next fragment ahead during exports.
+func OptServerPrefetch(v bool) ServerOption {
+	return func(s *Server) error {
+		s.holder.SetPrefetch(v)
+		return nil
+	}
+}
+
+// OptServerTrashDeletedFields enables moving deleted fields to the trash, from
+// which they can be restored until purged.
+func OptServerTrashDeletedFields(v bool) ServerOption {
+	return func(s *Server) error {
+		s.holder.SetTrashDeletedFields(v)
+		return nil
+	}
+}
+
+// OptServerSnapshotCompressionLevel sets the gzip level used to snapshot
+// fragments of fields with gzip compression. Zero means the default level.
+func OptServerSnapshotCompressionLevel(n int) ServerOption {
+	return func(s *Server) error {
+		return s.holder.SetSnapshotCompressionLevel(n)
+	}
+}
+
+// OptServerOrderedImports enables applying imports to each shard in the same
+// order on all of its owners.
+func OptServerOrderedImports(v bool) ServerOption {
+	return func(s *Server) error {
+		s.orderedImports = v
+		return nil
+	}
+}
+
+// OptServerQueryLogSize sets the number of recently executed queries retained
+// by the server.
+func OptServerQueryLogSize(n int) ServerOption {
+	return func(s *Server) error {
+		s.queryLogSize = n
+		return nil
+	}
+}
+
 func OptServerMetricInterval(dur time.Duration) ServerOption {
 	return func(s *Server) error {
 		s.metricInterval = dur
@@ -158,10 +217,19 @@ func OptServerInternalClient(c InternalClient) ServerOption {
 		s.executor = newExecutor(optExecutorInternalQueryClient(c))
 		s.defaultClient = c
 		s.cluster.InternalClient = c
+		if tc, ok := c.(internalTokenClient); ok {
+			tc.SetInternalToken(s.cluster.internalRequestToken)
+		}
 		return nil
 	}
 }
 
+// internalTokenClient is implemented by internal clients which can mark
+// their requests as coming from a node of the cluster.
+type internalTokenClient interface {
+	SetInternalToken(fn func() string)
+}
+
 // DEPRECATED
 func OptServerPrimaryTranslateStore(store TranslateStore) ServerOption {
 	return func(s *Server) error {
@@ -178,6 +246,22 @@ func OptServerPrimaryTranslateStoreFunc(tf func(interface{}) TranslateStore) Ser
 	}
 }
 
+// setStatsClient replaces the stats client used by the server and everything
+// in its holder. The previous client is not closed since operations already
+// in flight may still be emitting stats to it.
+func (s *Server) setStatsClient(sc stats.StatsClient) {
+	sc.SetLogger(s.logger)
+	sc.Open()
+	sc = stats.NewSampledStatsClient(sc, s.statsSampleRates).WithTags(fmt.Sprintf("NodeID:%s", s.nodeID))
+	s.holder.setStats(sc)
+
+	// The syncer only uses its client while holding its lock, so this waits
+	// for any sync in progress to finish.
+	s.syncer.mu.Lock()
+	s.syncer.Stats = sc.WithTags("HolderSyncer")
+	s.syncer.mu.Unlock()
+}
+
 func OptServerStatsClient(sc stats.StatsClient) ServerOption {
 	return func(s *Server) error {
 		s.holder.Stats = sc
@@ -259,6 +343,9 @@ func NewServer(opts ...ServerOption) (*Server, error) {
 		antiEntropyInterval: time.Minute * 10,
 		metricInterval:      0,
 		diagnosticInterval:  0,
+		queryLogSize:        defaultQueryLogSize,
+
+		statsSampleRates: stats.NewSampleRates(),
 
 		logger: logger.NopLogger,
 	}
@@ -286,7 +373,7 @@ func NewServer(opts ...ServerOption) (*Server, error) {
 	s.holder.Stats.SetLogger(s.logger)
 
 	s.cluster.Path = path
-	s.cluster.logger = s.logger
+	s.cluster.logger = s.subsystemLogger(LogSubsystemCluster)
 	s.cluster.holder = s.holder
 
 	// Get or create NodeID.
@@ -310,14 +397,17 @@ func NewServer(opts ...ServerOption) (*Server, error) {
 		}
 	}
 
-	// Append the NodeID tag to stats.
-	s.holder.Stats = s.holder.Stats.WithTags(fmt.Sprintf("NodeID:%s", s.nodeID))
+	// Append the NodeID tag to stats, and apply sample rates set by
+	// API.SetStatsSampleRate.
+	s.holder.Stats = stats.NewSampledStatsClient(s.holder.Stats, s.statsSampleRates).WithTags(fmt.Sprintf("NodeID:%s", s.nodeID))
 
 	s.executor.Holder = s.holder
 	s.executor.Node = node
 	s.executor.Cluster = s.cluster
 	s.executor.TranslateStore = s.holder.translateFile
 	s.executor.MaxWritesPerRequest = s.maxWritesPerRequest
+	s.executor.queryLog = newQueryLog(s.queryLogSize)
+	s.executor.logger = s.subsystemLogger(LogSubsystemQuery)
 	s.cluster.broadcaster = s
 	s.cluster.maxWritesPerRequest = s.maxWritesPerRequest
 	s.holder.broadcaster = s
@@ -447,7 +537,7 @@ func (s *Server) monitorAntiEntropy() {
 		case <-s.cluster.abortAntiEntropyCh: // receive here so we don't block resizing
 			continue
 		case <-ticker.C:
-			s.holder.Stats.Count("AntiEntropy", 1, 1.0)
+			s.holder.statsClient().Count("AntiEntropy", 1, 1.0)
 		}
 		t := time.Now()
 		if s.cluster.State() == ClusterStateResizing {
@@ -465,7 +555,7 @@ func (s *Server) monitorAntiEntropy() {
 		// Record successful sync in log.
 		s.logger.Printf("holder sync complete")
 		dif := time.Since(t)
-		s.holder.Stats.Histogram("AntiEntropyDuration", float64(dif), 1.0)
+		s.holder.statsClient().Histogram("AntiEntropyDuration", float64(dif), 1.0)
 
 		// Drain tick channel since we just finished anti-entropy. If the AE
 		// process took a long time, we don't want them to pile up on each
@@ -502,6 +592,17 @@ func (s *Server) receiveMessage(m Message) error {
 		if err := s.holder.DeleteIndex(obj.Index); err != nil {
 			return err
 		}
+		s.queryRateLimits.set(obj.Index, 0)
+	case *PrepareCreateIndexMessage:
+		if err := s.holder.prepareIndex(obj.Index, *obj.Meta); err != nil {
+			return err
+		}
+	case *CommitCreateIndexMessage:
+		if _, err := s.holder.commitIndex(obj.Index); err != nil {
+			return err
+		}
+	case *AbortCreateIndexMessage:
+		s.holder.abortIndex(obj.Index)
 	case *CreateFieldMessage:
 		idx := s.holder.Index(obj.Index)
 		if idx == nil {
@@ -513,6 +614,12 @@ func (s *Server) receiveMessage(m Message) error {
 			return err
 		}
 	case *DeleteFieldMessage:
+		// The keys of trashed fields are kept until they are purged.
+		if !s.holder.TrashDeletedFields() {
+			if err := s.holder.translateFile.DeleteFieldKeys(obj.Index, obj.Field); err != nil && err != ErrTranslateStoreReadOnly {
+				return err
+			}
+		}
 		idx := s.holder.Index(obj.Index)
 		if err := idx.DeleteField(obj.Field); err != nil {
 			return err
@@ -540,6 +647,26 @@ func (s *Server) receiveMessage(m Message) error {
 		if err != nil {
 			return err
 		}
+	case *DeleteViewsMessage:
+		f := s.holder.Field(obj.Index, obj.Field)
+		if f == nil {
+			return fmt.Errorf("local field not found: %s", obj.Field)
+		}
+		for _, view := range obj.Views {
+			if err := f.deleteView(view); err != nil && err != ErrInvalidView {
+				return err
+			}
+		}
+	case *RemapRowsMessage:
+		f := s.holder.Field(obj.Index, obj.Field)
+		if f == nil {
+			return fmt.Errorf("local field not found: %s/%s", obj.Index, obj.Field)
+		}
+		if err := f.remapRows(obj.Mapping, func(shard uint64) bool {
+			return s.cluster.ownsShard(s.nodeID, obj.Index, shard)
+		}); err != nil {
+			return err
+		}
 	case *ClusterStatus:
 		err := s.cluster.mergeClusterStatus(obj)
 		if err != nil {
@@ -558,7 +685,9 @@ func (s *Server) receiveMessage(m Message) error {
 	case *SetCoordinatorMessage:
 		s.cluster.setCoordinator(obj.New)
 	case *UpdateCoordinatorMessage:
-		s.cluster.updateCoordinator(obj.New)
+		if _, err := s.cluster.advanceCoordinator(obj.New, obj.Epoch); err != nil {
+			return errors.Wrap(err, "updating coordinator")
+		}
 	case *NodeStateMessage:
 		err := s.cluster.receiveNodeState(obj.NodeID, obj.State)
 		if err != nil {
@@ -566,6 +695,14 @@ func (s *Server) receiveMessage(m Message) error {
 		}
 	case *RecalculateCaches:
 		s.holder.recalculateCaches()
+	case *PingMessage:
+		// Nothing to do; the sender only checks that it was received.
+	case *PauseQueriesMessage:
+		if obj.Paused {
+			s.queryPause.pause()
+		} else {
+			s.queryPause.resume()
+		}
 	case *NodeEvent:
 		err := s.cluster.ReceiveEvent(obj)
 		if err != nil {
@@ -573,13 +710,62 @@ func (s *Server) receiveMessage(m Message) error {
 		}
 	case *NodeStatus:
 		s.handleRemoteStatus(obj)
+	case *SetAttrSchemaMessage:
+		idx := s.holder.Index(obj.Index)
+		if idx == nil {
+			return fmt.Errorf("local index not found: %s", obj.Index)
+		}
+		if err := idx.RegisterAttrSchema(obj.Schema); err != nil {
+			return err
+		}
+	case *SetFieldACLMessage:
+		f := s.holder.Field(obj.Index, obj.Field)
+		if f == nil {
+			return fmt.Errorf("local field not found: %s/%s", obj.Index, obj.Field)
+		}
+		if err := f.SetACL(obj.Allowed); err != nil {
+			return err
+		}
+	case *SetDefaultFieldOptionsMessage:
+		idx := s.holder.Index(obj.Index)
+		if idx == nil {
+			return fmt.Errorf("local index not found: %s", obj.Index)
+		}
+		if err := idx.SetDefaultFieldOptions(*obj.Options); err != nil {
+			return err
+		}
+	case *EnableIndexKeysMessage:
+		idx := s.holder.Index(obj.Index)
+		if idx == nil {
+			return fmt.Errorf("local index not found: %s", obj.Index)
+		}
+		if err := idx.EnableKeys(); err != nil && err != ErrIndexKeysEnabled {
+			return err
+		}
 	}
 
+	s.publishSchemaEvent(m)
 	return nil
 }
 
+// subsystemLogger returns a logger for a subsystem which applies the level set
+// with API.SetLogLevel.
+func (s *Server) subsystemLogger(subsystem string) logger.Logger {
+	return &subsystemLogger{levels: &s.logLevels, subsystem: subsystem, logger: s.logger}
+}
+
+// publishSchemaEvent notifies schema watchers if m describes a schema change.
+func (s *Server) publishSchemaEvent(m Message) {
+	if n := s.schemaWatchers.publish(m); n > 0 {
+		s.subsystemLogger(LogSubsystemBroadcast).Printf("schema event dropped for %d slow watchers: %T", n, m)
+	}
+}
+
 // SendSync represents an implementation of Broadcaster.
 func (s *Server) SendSync(m Message) error {
+	// Messages are broadcast after the change is made locally.
+	s.publishSchemaEvent(m)
+
 	var eg errgroup.Group
 	msg, err := s.serializer.Marshal(m)
 	if err != nil {
@@ -608,12 +794,17 @@ func (s *Server) SendAsync(m Message) error {
 
 // SendTo represents an implementation of Broadcaster.
 func (s *Server) SendTo(to *Node, m Message) error {
+	return s.sendTo(context.Background(), to, m)
+}
+
+// sendTo sends a message to a node, giving up if ctx is done first.
+func (s *Server) sendTo(ctx context.Context, to *Node, m Message) error {
 	msg, err := s.serializer.Marshal(m)
 	if err != nil {
 		return fmt.Errorf("marshaling message: %v", err)
 	}
 	msg = append([]byte{getMessageType(m)}, msg...)
-	return s.defaultClient.SendMessage(context.Background(), &to.URI, msg)
+	return s.defaultClient.SendMessage(ctx, &to.URI, msg)
 }
 
 // node returns the pilosa.node object. It is used by membership protocols to
@@ -635,7 +826,7 @@ func (s *Server) handleRemoteStatus(pb Message) {
 
 		err := s.mergeRemoteStatus(pb.(*NodeStatus))
 		if err != nil {
-			s.logger.Printf("merge remote status: %s", err)
+			s.subsystemLogger(LogSubsystemCluster).Printf("merge remote status: %s", err)
 		}
 	}()
 }
@@ -659,7 +850,7 @@ func (s *Server) mergeRemoteStatus(ns *NodeStatus) error {
 			// if we don't know about a field locally, log an error because
 			// fields should be created and synced prior to shard creation
 			if f == nil {
-				s.logger.Printf("local field not found: %s/%s", is.Name, fs.Name)
+				s.subsystemLogger(LogSubsystemCluster).Printf("local field not found: %s/%s", is.Name, fs.Name)
 				continue
 			}
 			if err := f.AddRemoteAvailableShards(fs.AvailableShards); err != nil {
@@ -744,26 +935,26 @@ func (s *Server) monitorRuntime() {
 			return
 		case <-s.gcNotifier.AfterGC():
 			// GC just ran.
-			s.holder.Stats.Count("garbage_collection", 1, 1.0)
+			s.holder.statsClient().Count("garbage_collection", 1, 1.0)
 		case <-ticker.C:
 		}
 
 		// Record the number of go routines.
-		s.holder.Stats.Gauge("goroutines", float64(runtime.NumGoroutine()), 1.0)
+		s.holder.statsClient().Gauge("goroutines", float64(runtime.NumGoroutine()), 1.0)
 
 		openFiles, err := countOpenFiles()
 		// Open File handles.
 		if err == nil {
-			s.holder.Stats.Gauge("OpenFiles", float64(openFiles), 1.0)
+			s.holder.statsClient().Gauge("OpenFiles", float64(openFiles), 1.0)
 		}
 
 		// Runtime memory metrics.
 		runtime.ReadMemStats(&m)
-		s.holder.Stats.Gauge("HeapAlloc", float64(m.HeapAlloc), 1.0)
-		s.holder.Stats.Gauge("HeapInuse", float64(m.HeapInuse), 1.0)
-		s.holder.Stats.Gauge("StackInuse", float64(m.StackInuse), 1.0)
-		s.holder.Stats.Gauge("Mallocs", float64(m.Mallocs), 1.0)
-		s.holder.Stats.Gauge("Frees", float64(m.Frees), 1.0)
+		s.holder.statsClient().Gauge("HeapAlloc", float64(m.HeapAlloc), 1.0)
+		s.holder.statsClient().Gauge("HeapInuse", float64(m.HeapInuse), 1.0)
+		s.holder.statsClient().Gauge("StackInuse", float64(m.StackInuse), 1.0)
+		s.holder.statsClient().Gauge("Mallocs", float64(m.Mallocs), 1.0)
+		s.holder.statsClient().Gauge("Frees", float64(m.Frees), 1.0)
 	}
 }
 
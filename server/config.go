@@ -17,6 +17,7 @@ package server
 import (
 	"time"
 
+	"github.com/pilosa/pilosa"
 	"github.com/pilosa/pilosa/gossip"
 	"github.com/pilosa/pilosa/toml"
 	"github.com/uber/jaeger-client-go"
@@ -45,6 +46,30 @@ type Config struct {
 	// SetRowAttrs & SetColumnAttrs.
 	MaxWritesPerRequest int `toml:"max-writes-per-request"`
 
+	// QueryLogSize is the number of recently executed queries retained by
+	// each node for debugging.
+	QueryLogSize int `toml:"query-log-size"`
+
+	// MaxCacheBytes limits the approximate memory used by all TopN caches.
+	// Zero means no limit.
+	MaxCacheBytes int64 `toml:"max-cache-bytes"`
+
+	// Prefetch reads the next fragment ahead while exporting a field.
+	Prefetch bool `toml:"prefetch"`
+
+	// SnapshotCompressionLevel is the gzip level, from 1 to 9, used to
+	// snapshot fragments of fields with gzip compression. Zero means the
+	// default level.
+	SnapshotCompressionLevel int `toml:"snapshot-compression-level"`
+
+	// OrderedImports applies imports to each shard in the same order on
+	// all of the nodes which own it.
+	OrderedImports bool `toml:"ordered-imports"`
+
+	// TrashDeletedFields moves deleted fields to the trash, from which they
+	// can be restored until purged, rather than removing them.
+	TrashDeletedFields bool `toml:"trash-deleted-fields"`
+
 	// LogPath configures where Pilosa will write logs.
 	LogPath string `toml:"log-path"`
 
@@ -67,6 +92,10 @@ type Config struct {
 		ReplicaN      int           `toml:"replicas"`
 		Hosts         []string      `toml:"hosts"`
 		LongQueryTime toml.Duration `toml:"long-query-time"`
+
+		// Hasher is the name of the hasher which assigns partitions of
+		// shards to nodes. Every node in the cluster must use the same one.
+		Hasher string `toml:"hasher"`
 	} `toml:"cluster"`
 
 	// Gossip config is based around memberlist.Config.
@@ -110,6 +139,7 @@ func NewConfig() *Config {
 		DataDir:             "~/.pilosa",
 		Bind:                ":10101",
 		MaxWritesPerRequest: 5000,
+		QueryLogSize:        100,
 		// LogPath: "",
 		// Verbose: false,
 		TLS: TLSConfig{},
@@ -121,6 +151,7 @@ func NewConfig() *Config {
 	c.Cluster.ReplicaN = 1
 	c.Cluster.Hosts = []string{}
 	c.Cluster.LongQueryTime = toml.Duration(time.Minute)
+	c.Cluster.Hasher = pilosa.DefaultHasher
 
 	// Gossip config.
 	c.Gossip.Port = "14000"
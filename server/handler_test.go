@@ -16,6 +16,7 @@ package server_test
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/hex"
 	"encoding/json"
@@ -244,6 +245,28 @@ func TestHandler_Endpoints(t *testing.T) {
 		}
 	})
 
+	t.Run("Recent queries args", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, test.MustNewHTTPRequest("GET", "/recent-queries?n=1", nil))
+		if w.Code != gohttp.StatusOK {
+			t.Fatalf("unexpected status code: %d %s", w.Code, w.Body.String())
+		}
+		var entries []pilosa.QueryLogEntry
+		if err := json.Unmarshal(w.Body.Bytes(), &entries); err != nil {
+			t.Fatal(err)
+		} else if len(entries) > 1 {
+			t.Fatalf("unexpected entry count: %d", len(entries))
+		}
+
+		for _, q := range []string{"", "?n=-1", "?n=a"} {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, test.MustNewHTTPRequest("GET", "/recent-queries"+q, nil))
+			if w.Code != gohttp.StatusBadRequest {
+				t.Fatalf("unexpected status code for %q: %d", q, w.Code)
+			}
+		}
+	})
+
 	t.Run("Uint64 protobuf", func(t *testing.T) {
 		w := httptest.NewRecorder()
 		r := test.MustNewHTTPRequest("POST", "/index/i0/query", strings.NewReader("Count(Row(f0=30))"))
@@ -271,6 +294,25 @@ func TestHandler_Endpoints(t *testing.T) {
 		}
 	})
 
+	t.Run("Row JSON compressed", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, test.MustNewHTTPRequest("POST", "/index/i0/query?compressResults=true", strings.NewReader("Row(f0=30)")))
+		if w.Code != gohttp.StatusOK {
+			t.Fatalf("unexpected status code: %d", w.Code)
+		} else if enc := w.Header().Get("Content-Encoding"); enc != "gzip" {
+			t.Fatalf("unexpected content encoding: %q", enc)
+		}
+		gz, err := gzip.NewReader(w.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body, err := ioutil.ReadAll(gz); err != nil {
+			t.Fatal(err)
+		} else if string(body) != `{"results":[{"attrs":{},"columns":[1048577,1048578,3145732]}]}`+"\n" {
+			t.Fatalf("unexpected body: %s", body)
+		}
+	})
+
 	f0 := i0.Field("f0")
 	if err := i0.ColumnAttrStore().SetAttrs((1*pilosa.ShardWidth)+1, map[string]interface{}{"x": "y"}); err != nil {
 		t.Fatal(err)
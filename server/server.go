@@ -250,6 +250,12 @@ func (m *Command) SetupServer() error {
 		pilosa.OptServerDataDir(m.Config.DataDir),
 		pilosa.OptServerReplicaN(m.Config.Cluster.ReplicaN),
 		pilosa.OptServerMaxWritesPerRequest(m.Config.MaxWritesPerRequest),
+		pilosa.OptServerQueryLogSize(m.Config.QueryLogSize),
+		pilosa.OptServerMaxCacheBytes(m.Config.MaxCacheBytes),
+		pilosa.OptServerPrefetch(m.Config.Prefetch),
+		pilosa.OptServerSnapshotCompressionLevel(m.Config.SnapshotCompressionLevel),
+		pilosa.OptServerOrderedImports(m.Config.OrderedImports),
+		pilosa.OptServerTrashDeletedFields(m.Config.TrashDeletedFields),
 		pilosa.OptServerMetricInterval(time.Duration(m.Config.Metric.PollInterval)),
 		pilosa.OptServerDiagnosticsInterval(diagnosticsInterval),
 
@@ -266,6 +272,14 @@ func (m *Command) SetupServer() error {
 		coordinatorOpt,
 	}
 
+	if m.Config.Cluster.Hasher != "" {
+		hasher, err := pilosa.HasherByName(m.Config.Cluster.Hasher)
+		if err != nil {
+			return errors.Wrap(err, "getting cluster hasher")
+		}
+		serverOptions = append(serverOptions, pilosa.OptServerClusterHasher(hasher))
+	}
+
 	if m.Config.Translation.MapSize > 0 {
 		serverOptions = append(
 			serverOptions,
@@ -247,6 +247,90 @@ func (a MultiStatsClient) Close() error {
 	return nil
 }
 
+// SampleRates holds sample rates which override the rates passed to a
+// StatsClient for particular stats. It is safe for concurrent use.
+type SampleRates struct {
+	mu    sync.RWMutex
+	rates map[string]float64
+}
+
+// NewSampleRates returns an empty set of sample rates.
+func NewSampleRates() *SampleRates {
+	return &SampleRates{rates: make(map[string]float64)}
+}
+
+// Set overrides the sample rate of the named stat. A rate of zero or less
+// removes the override.
+func (r *SampleRates) Set(name string, rate float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if rate <= 0 {
+		delete(r.rates, name)
+		return
+	}
+	r.rates[name] = rate
+}
+
+// Rate returns the sample rate for the named stat, or rate if it has no
+// override.
+func (r *SampleRates) Rate(name string, rate float64) float64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if v, ok := r.rates[name]; ok {
+		return v
+	}
+	return rate
+}
+
+// sampledStatsClient applies the overrides in a SampleRates to the stats
+// sent to another client.
+type sampledStatsClient struct {
+	StatsClient
+	rates *SampleRates
+}
+
+// NewSampledStatsClient returns a client which sends stats to c using the
+// sample rates overridden in rates. Clients returned by WithTags share rates.
+func NewSampledStatsClient(c StatsClient, rates *SampleRates) StatsClient {
+	return &sampledStatsClient{StatsClient: c, rates: rates}
+}
+
+// WithTags returns a new client with the additional tags and the same rates.
+func (c *sampledStatsClient) WithTags(tags ...string) StatsClient {
+	return &sampledStatsClient{StatsClient: c.StatsClient.WithTags(tags...), rates: c.rates}
+}
+
+// Count tracks the number of times something occurs per second.
+func (c *sampledStatsClient) Count(name string, value int64, rate float64) {
+	c.StatsClient.Count(name, value, c.rates.Rate(name, rate))
+}
+
+// CountWithCustomTags tracks the number of times something occurs per second
+// with custom tags.
+func (c *sampledStatsClient) CountWithCustomTags(name string, value int64, rate float64, tags []string) {
+	c.StatsClient.CountWithCustomTags(name, value, c.rates.Rate(name, rate), tags)
+}
+
+// Gauge sets the value of a metric.
+func (c *sampledStatsClient) Gauge(name string, value float64, rate float64) {
+	c.StatsClient.Gauge(name, value, c.rates.Rate(name, rate))
+}
+
+// Histogram tracks statistical distribution of a metric.
+func (c *sampledStatsClient) Histogram(name string, value float64, rate float64) {
+	c.StatsClient.Histogram(name, value, c.rates.Rate(name, rate))
+}
+
+// Set tracks number of unique elements.
+func (c *sampledStatsClient) Set(name string, value string, rate float64) {
+	c.StatsClient.Set(name, value, c.rates.Rate(name, rate))
+}
+
+// Timing tracks timing information for a metric.
+func (c *sampledStatsClient) Timing(name string, value time.Duration, rate float64) {
+	c.StatsClient.Timing(name, value, c.rates.Rate(name, rate))
+}
+
 // unionStringSlice returns a sorted set of tags which combine a & b.
 func unionStringSlice(a, b []string) []string {
 	// Sort both sets first.
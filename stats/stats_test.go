@@ -295,6 +295,42 @@ func TestStatsCount_APICalls(t *testing.T) {
 
 }
 
+func TestStatsCount_SampleRate(t *testing.T) {
+	cmd := test.MustRunCluster(t, 1)[0]
+	defer cmd.Close()
+
+	var rates []float64
+	if err := cmd.API.SetStatsClient(&MockStats{
+		mockCount: func(name string, value int64, rate float64) {
+			if name == "createIndex" {
+				rates = append(rates, rate)
+			}
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cmd.API.SetStatsSampleRate(context.Background(), "createIndex", 0.25); err != nil {
+		t.Fatal(err)
+	} else if _, err := cmd.API.CreateIndex(context.Background(), "i0", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.API.SetStatsSampleRate(context.Background(), "createIndex", 0); err != nil {
+		t.Fatal(err)
+	} else if _, err := cmd.API.CreateIndex(context.Background(), "i1", pilosa.IndexOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(rates) != 2 || rates[0] != 0.25 || rates[1] != 1.0 {
+		t.Fatalf("unexpected rates: %v", rates)
+	}
+
+	if err := cmd.API.SetStatsSampleRate(context.Background(), "createIndex", 2); err == nil {
+		t.Fatal("expected error for rate greater than 1")
+	} else if err := cmd.API.SetStatsSampleRate(context.Background(), "", 0.5); err == nil {
+		t.Fatal("expected error for empty category")
+	}
+}
+
 type MockStats struct {
 	mockCount         func(name string, value int64, rate float64)
 	mockCountWithTags func(name string, value int64, rate float64, tags []string)
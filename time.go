@@ -99,6 +99,54 @@ func viewsByTime(name string, t time.Time, q TimeQuantum) []string { // nolint:
 	return a
 }
 
+// viewTimeStart returns the start of the time bucket covered by a time view
+// of name. Returns false if view is not a time view of name.
+func viewTimeStart(name, view string) (time.Time, bool) {
+	t, _, ok := parseTimeView(name, view)
+	return t, ok
+}
+
+// viewTimeEnd returns the end of the time bucket covered by a time view of
+// name, which is the start of the following bucket. Returns false if view is
+// not a time view of name.
+func viewTimeEnd(name, view string) (time.Time, bool) {
+	t, next, ok := parseTimeView(name, view)
+	if !ok {
+		return time.Time{}, false
+	}
+	return next(t), true
+}
+
+// parseTimeView returns the start of the time bucket covered by a time view
+// of name and a function which returns the start of the following bucket.
+func parseTimeView(name, view string) (time.Time, func(time.Time) time.Time, bool) {
+	if !strings.HasPrefix(view, name+"_") {
+		return time.Time{}, nil, false
+	}
+	suffix := view[len(name)+1:]
+
+	var layout string
+	var next func(time.Time) time.Time
+	switch len(suffix) {
+	case 4:
+		layout, next = "2006", func(t time.Time) time.Time { return t.AddDate(1, 0, 0) }
+	case 6:
+		layout, next = "200601", func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }
+	case 8:
+		layout, next = "20060102", func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }
+	case 10:
+		layout, next = "2006010215", func(t time.Time) time.Time { return t.Add(time.Hour) }
+	default:
+		return time.Time{}, nil, false
+	}
+
+	t, err := time.ParseInLocation(layout, suffix, time.UTC)
+	if err != nil {
+		return time.Time{}, nil, false
+	}
+	return t, next, true
+}
+
 // viewsByTimeRange returns a list of views to traverse to query a time range.
 func viewsByTimeRange(name string, start, end time.Time, q TimeQuantum) []string { // nolint: unparam
 	t := start
@@ -64,6 +64,29 @@ func TestViewByTimeUnit(t *testing.T) {
 	})
 }
 
+// Ensure the end of a view's time bucket can be parsed from its name.
+func TestViewTimeEnd(t *testing.T) {
+	for _, tt := range []struct {
+		view string
+		exp  time.Time
+		ok   bool
+	}{
+		{view: "F_2000", exp: time.Date(2001, time.January, 1, 0, 0, 0, 0, time.UTC), ok: true},
+		{view: "F_200012", exp: time.Date(2001, time.January, 1, 0, 0, 0, 0, time.UTC), ok: true},
+		{view: "F_20000102", exp: time.Date(2000, time.January, 3, 0, 0, 0, 0, time.UTC), ok: true},
+		{view: "F_2000010203", exp: time.Date(2000, time.January, 2, 4, 0, 0, 0, time.UTC), ok: true},
+		{view: "F"},
+		{view: "F_20001"},
+		{view: "F_abcd"},
+		{view: "G_2000"},
+	} {
+		end, ok := viewTimeEnd("F", tt.view)
+		if ok != tt.ok || !end.Equal(tt.exp) {
+			t.Fatalf("%s: unexpected end: %s, %v", tt.view, end, ok)
+		}
+	}
+}
+
 // Ensure all applicable field names can be generated when mutating a time bit.
 func TestViewsByTime(t *testing.T) {
 	ts := time.Date(2000, time.January, 2, 3, 4, 5, 6, time.UTC)
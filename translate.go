@@ -10,6 +10,8 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"sync"
 	"syscall"
 	"time"
@@ -20,8 +22,11 @@ import (
 )
 
 const (
-	LogEntryTypeInsertColumn = 1
-	LogEntryTypeInsertRow    = 2
+	LogEntryTypeInsertColumn   = 1
+	LogEntryTypeInsertRow      = 2
+	LogEntryTypeReserveColumns = 3
+	LogEntryTypeDeleteField    = 4
+	LogEntryTypeEpoch          = 5
 )
 
 const (
@@ -34,6 +39,7 @@ var (
 	ErrReplicationNotSupported       = errors.New("pilosa: replication not supported")
 	ErrTranslateStoreReadOnly        = errors.New("pilosa: translate store could not find or create key, translate store read only")
 	ErrTranslateReadTargetUndersized = errors.New("pilosa: translate read target is undersized")
+	ErrTranslateStoreDataReplaced    = errors.New("pilosa: translate store data file replaced")
 )
 
 // TranslateStore is the storage for translation string-to-uint64 values.
@@ -43,10 +49,18 @@ type TranslateStore interface {
 
 	TranslateRowsToUint64(index, field string, values []string) ([]uint64, error)
 	TranslateRowToString(index, field string, values uint64) (string, error)
+	TranslateRowsToStrings(index, field string, values []uint64) ([]string, error)
 
 	// Returns a reader from the given offset of the raw data file.
 	// The returned reader must be closed by the caller when done.
 	Reader(ctx context.Context, off int64) (io.ReadCloser, error)
+
+	// Rewrites the stored mappings without the entries which are no longer
+	// referenced. Existing key/ID assignments are not changed.
+	Compact() error
+
+	// Removes the row key mappings of a field.
+	DeleteFieldKeys(index, field string) error
 }
 
 // Ensure type implements interface.
@@ -61,6 +75,15 @@ type TranslateFile struct {
 	n           int64
 	writeNotify chan struct{}
 
+	// The epoch changes each time the data file is compacted, which changes
+	// the offsets of its entries. It is stored in an entry at the start of
+	// the file, so replicas receive it as they stream the file. Readers
+	// report the epoch they were opened at, and are closed when the data
+	// file is replaced, so replicas can tell when to stream it again from
+	// the start.
+	epoch        uint64
+	dataReplaced chan struct{}
+
 	once    sync.Once
 	wg      sync.WaitGroup
 	closing chan struct{}
@@ -111,10 +134,11 @@ func NewTranslateFile(opts ...TranslateFileOption) *TranslateFile {
 		defaultMapSize = (1 << 31) - 1
 	}
 	f := &TranslateFile{
-		writeNotify: make(chan struct{}),
-		closing:     make(chan struct{}),
-		cols:        make(map[string]*index),
-		rows:        make(map[fieldKey]*index),
+		writeNotify:  make(chan struct{}),
+		dataReplaced: make(chan struct{}),
+		closing:      make(chan struct{}),
+		cols:         make(map[string]*index),
+		rows:         make(map[fieldKey]*index),
 
 		mapSize: defaultMapSize,
 
@@ -141,8 +165,28 @@ func (s *TranslateFile) Open() (err error) {
 	// Open writer & buffered writer.
 	if err := os.MkdirAll(filepath.Dir(s.Path), 0777); err != nil {
 		return errors.Wrapf(err, "mkdir %s", filepath.Dir(s.Path))
-	} else if s.file, err = os.OpenFile(s.Path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666); err != nil {
-		return errors.Wrapf(err, "open file %s", s.Path)
+	} else if err := s.openData(); err != nil {
+		return err
+	}
+
+	// Listen to primaryStoreEvents channel.
+	s.wg.Add(1)
+	go func() { defer s.wg.Done(); s.monitorPrimaryStoreEvents() }()
+
+	return nil
+}
+
+// openData opens and memory maps the data file and replays its entries.
+func (s *TranslateFile) openData() error {
+	return s.openDataFile(s.Path)
+}
+
+// openDataFile opens and memory maps the file at path as the data file and
+// replays its entries.
+func (s *TranslateFile) openDataFile(path string) (err error) {
+	// Open writer & buffered writer.
+	if s.file, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666); err != nil {
+		return errors.Wrapf(err, "open file %s", path)
 	}
 	s.w = bufio.NewWriter(s.file)
 
@@ -155,11 +199,6 @@ func (s *TranslateFile) Open() (err error) {
 	if err := s.replayEntries(); err != nil {
 		return errors.Wrap(err, "replaying log entries")
 	}
-
-	// Listen to primaryStoreEvents channel.
-	s.wg.Add(1)
-	go func() { defer s.wg.Done(); s.monitorPrimaryStoreEvents() }()
-
 	return nil
 }
 
@@ -237,6 +276,22 @@ func (s *TranslateFile) Close() (err error) {
 	return err
 }
 
+// openFileCount returns the number of files the store has open.
+func (s *TranslateFile) openFileCount() int {
+	select {
+	case <-s.closing:
+		return 0
+	default:
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.file == nil {
+		return 0
+	}
+	return 1
+}
+
 // Closing returns a channel that is closed when the store is closed.
 func (s *TranslateFile) Closing() <-chan struct{} {
 	return s.closing
@@ -250,6 +305,19 @@ func (s *TranslateFile) size() int64 {
 	return n
 }
 
+// currentEpoch returns the epoch of the data file.
+func (s *TranslateFile) currentEpoch() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.epoch
+}
+
+// translateEpochReader is implemented by readers returned from
+// TranslateStore.Reader which know the epoch of the data file they stream.
+type translateEpochReader interface {
+	Epoch() uint64
+}
+
 // isReadOnly returns true if this store is being replicated from a primary store.
 func (s *TranslateFile) isReadOnly() bool {
 	return s.PrimaryTranslateStore != nil
@@ -303,6 +371,27 @@ func (s *TranslateFile) applyEntry(entry *LogEntry, offset int64) error {
 	case LogEntryTypeInsertRow:
 		idx = s.row(string(entry.Index), string(entry.Field))
 
+	case LogEntryTypeReserveColumns:
+		// Reservations only move the sequence forward. The single id is
+		// the last reserved id and has no key.
+		idx = s.col(string(entry.Index))
+		for _, id := range entry.IDs {
+			if id > idx.seq {
+				idx.seq = id
+			}
+		}
+		return nil
+
+	case LogEntryTypeDeleteField:
+		delete(s.rows, fieldKey{index: string(entry.Index), field: string(entry.Field)})
+		return nil
+
+	case LogEntryTypeEpoch:
+		if len(entry.IDs) > 0 {
+			s.epoch = entry.IDs[0]
+		}
+		return nil
+
 	default:
 		return fmt.Errorf("enterprise.TranslateFile.applyEntry(): unknown log entry type: 0x%20x", entry.Type)
 	}
@@ -329,7 +418,7 @@ func (s *TranslateFile) applyEntry(entry *LogEntry, offset int64) error {
 
 func (s *TranslateFile) replayEntries() error {
 	// Build a reader from the memory-map data.
-	fi, err := os.Stat(s.Path)
+	fi, err := s.file.Stat()
 	if err != nil {
 		return err
 	}
@@ -408,6 +497,20 @@ func (s *TranslateFile) replicate(ctx context.Context) error {
 	}
 	defer rc.Close()
 
+	// If the primary's data file was compacted since this store last
+	// streamed from it, the offset points into different data, so discard
+	// the local data and stream the primary's file from the start.
+	if r, ok := rc.(translateEpochReader); ok && off > 0 && r.Epoch() != s.currentEpoch() {
+		s.logger.Printf("pilosa: primary translate store epoch changed, replicating from start")
+		rc.Close()
+		if err := s.reset(); err != nil {
+			return errors.Wrap(err, "resetting")
+		} else if rc, err = s.PrimaryTranslateStore.Reader(ctx, 0); err != nil {
+			return err
+		}
+		defer rc.Close()
+	}
+
 	// Wrap in bufferred I/O so it implements io.ByteReader.
 	bufr := bufio.NewReader(rc)
 
@@ -665,6 +768,26 @@ func (s *TranslateFile) TranslateRowsToUint64(index, field string, values []stri
 	return ret, nil
 }
 
+// findRowKeys returns the ids assigned to row keys without assigning ids to
+// new keys. Keys which have no id are returned in missing.
+func (s *TranslateFile) findRowKeys(index, field string, keys []string) (ids []uint64, missing []string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids = make([]uint64, len(keys))
+	idx := s.rows[fieldKey{index: index, field: field}]
+	for i, key := range keys {
+		if idx != nil {
+			if id, ok := idx.idByKey([]byte(key)); ok {
+				ids[i] = id
+				continue
+			}
+		}
+		missing = append(missing, key)
+	}
+	return ids, missing
+}
+
 func (s *TranslateFile) TranslateRowToString(index, field string, id uint64) (string, error) {
 	s.mu.RLock()
 	if idx := s.rows[fieldKey{index, field}]; idx != nil {
@@ -677,6 +800,417 @@ func (s *TranslateFile) TranslateRowToString(index, field string, id uint64) (st
 	return "", nil
 }
 
+// TranslateRowsToStrings returns the key for each row id. Ids without a key
+// are returned as blank strings.
+func (s *TranslateFile) TranslateRowsToStrings(index, field string, ids []uint64) ([]string, error) {
+	ret := make([]string, len(ids))
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	idx := s.rows[fieldKey{index, field}]
+	if idx == nil {
+		return ret, nil
+	}
+	for i, id := range ids {
+		if key, ok := idx.keyByID(id); ok {
+			ret[i] = string(key)
+		}
+	}
+	return ret, nil
+}
+
+// reserveColumnIDs advances the column id sequence of index by n and returns
+// the first of the n reserved ids. Reserved ids are never assigned to keys by
+// the store.
+func (s *TranslateFile) reserveColumnIDs(index string, n uint64) (uint64, error) {
+	if s.isReadOnly() {
+		return 0, ErrTranslateStoreReadOnly
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx := s.col(index)
+	start := idx.seq + 1
+	if end := idx.seq + n; end < idx.seq {
+		return 0, errors.New("column id sequence overflow")
+	} else if err := s.appendEntry(&LogEntry{
+		Type:  LogEntryTypeReserveColumns,
+		Index: []byte(index),
+		IDs:   []uint64{end},
+		Keys:  [][]byte{nil},
+	}); err != nil {
+		return 0, err
+	}
+	return start, nil
+}
+
+// reserveColumnIDsThrough moves the column id sequence of an index forward
+// to at least end, so keys created later are assigned larger ids.
+func (s *TranslateFile) reserveColumnIDsThrough(index string, end uint64) error {
+	if s.isReadOnly() {
+		return ErrTranslateStoreReadOnly
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if end <= s.col(index).seq {
+		return nil
+	}
+	return s.appendEntry(&LogEntry{
+		Type:  LogEntryTypeReserveColumns,
+		Index: []byte(index),
+		IDs:   []uint64{end},
+		Keys:  [][]byte{nil},
+	})
+}
+
+// backfillColumnKeys assigns each id its decimal string as a key, skipping
+// ids which already have a key, and moves the sequence past the largest id
+// so keys created later don't reuse them.
+func (s *TranslateFile) backfillColumnKeys(index string, ids []uint64) error {
+	if s.isReadOnly() {
+		return ErrTranslateStoreReadOnly
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx := s.col(index)
+	entry := &LogEntry{Type: LogEntryTypeInsertColumn, Index: []byte(index)}
+	for _, id := range ids {
+		key := []byte(strconv.FormatUint(id, 10))
+		if _, ok := idx.keyByID(id); ok {
+			continue
+		} else if existing, ok := idx.idByKey(key); ok {
+			return fmt.Errorf("translate key %q already assigned id %d: index=%s", key, existing, index)
+		}
+		entry.IDs = append(entry.IDs, id)
+		entry.Keys = append(entry.Keys, key)
+	}
+	if len(entry.IDs) == 0 {
+		return nil
+	}
+	return s.appendEntry(entry)
+}
+
+// restore reads log entries written by another store from r and appends the
+// id/key pairs which are not already present. Ids are preserved, so an error
+// is returned if a key or id is already assigned differently.
+func (s *TranslateFile) restore(r io.Reader) error {
+	if s.isReadOnly() {
+		return ErrTranslateStoreReadOnly
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	br := bufio.NewReader(r)
+	for {
+		var entry LogEntry
+		if _, err := entry.ReadFrom(br); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return errors.Wrap(err, "reading entry")
+		}
+
+		var idx *index
+		switch entry.Type {
+		case LogEntryTypeInsertColumn:
+			idx = s.col(string(entry.Index))
+		case LogEntryTypeInsertRow:
+			idx = s.row(string(entry.Index), string(entry.Field))
+		case LogEntryTypeReserveColumns:
+			if len(entry.IDs) > 0 && entry.IDs[0] > s.col(string(entry.Index)).seq {
+				if err := s.appendEntry(&entry); err != nil {
+					return errors.Wrap(err, "appending entry")
+				}
+			}
+			continue
+		case LogEntryTypeDeleteField:
+			if s.rows[fieldKey{index: string(entry.Index), field: string(entry.Field)}] != nil {
+				if err := s.appendEntry(&entry); err != nil {
+					return errors.Wrap(err, "appending entry")
+				}
+			}
+			continue
+		case LogEntryTypeEpoch:
+			// Epochs only describe the data file they were written to.
+			continue
+		default:
+			return fmt.Errorf("unknown log entry type: 0x%02x", entry.Type)
+		}
+
+		other := &LogEntry{Type: entry.Type, Index: entry.Index, Field: entry.Field}
+		for i, id := range entry.IDs {
+			if existing, ok := idx.idByKey(entry.Keys[i]); ok {
+				if existing != id {
+					return fmt.Errorf("translate key %q already assigned id %d: index=%s, field=%s", entry.Keys[i], existing, entry.Index, entry.Field)
+				}
+				continue
+			} else if _, ok := idx.keyByID(id); ok {
+				return fmt.Errorf("translate id %d already assigned: index=%s, field=%s", id, entry.Index, entry.Field)
+			}
+			other.IDs = append(other.IDs, id)
+			other.Keys = append(other.Keys, entry.Keys[i])
+		}
+		if len(other.IDs) == 0 {
+			continue
+		}
+		if err := s.appendEntry(other); err != nil {
+			return errors.Wrap(err, "appending entry")
+		}
+	}
+}
+
+// columnKeyEntries returns log entries holding the column keys of an index,
+// ordered by id, followed by a reservation through its column sequence. Ids
+// may be reserved beyond the last key, so the reservation keeps restored
+// stores from reusing them. Returns nil if the index has no keys.
+func (s *TranslateFile) columnKeyEntries(index string) []*LogEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	idx := s.cols[index]
+	if idx == nil {
+		return nil
+	}
+	return []*LogEntry{
+		keyLogEntry(LogEntryTypeInsertColumn, index, "", idx),
+		{Type: LogEntryTypeReserveColumns, Index: []byte(index), IDs: []uint64{idx.seq}, Keys: [][]byte{nil}},
+	}
+}
+
+// rowKeyEntries returns log entries holding the row keys of a field, ordered
+// by id. Returns nil if the field has no keys.
+func (s *TranslateFile) rowKeyEntries(index, field string) []*LogEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	idx := s.rows[fieldKey{index: index, field: field}]
+	if idx == nil {
+		return nil
+	}
+	return []*LogEntry{keyLogEntry(LogEntryTypeInsertRow, index, field, idx)}
+}
+
+// keyLogEntry returns an entry of type typ holding every id/key pair in idx,
+// ordered by id. Keys are copied out of the data file.
+func keyLogEntry(typ uint8, index, field string, idx *index) *LogEntry {
+	ids := make([]uint64, 0, len(idx.offsetsByID))
+	for id := range idx.offsetsByID {
+		ids = append(ids, id)
+	}
+	sort.Sort(uint64Slice(ids))
+
+	entry := &LogEntry{Type: typ, Index: []byte(index), Field: []byte(field)}
+	for _, id := range ids {
+		key, _ := idx.keyByID(id)
+		entry.IDs = append(entry.IDs, id)
+		entry.Keys = append(entry.Keys, append([]byte(nil), key...))
+	}
+	return entry
+}
+
+// DeleteFieldKeys removes the row key mappings of a field by appending a
+// deletion to the log, which replicas apply as they stream it. The deleted
+// pairs stay in the data file until it is compacted.
+func (s *TranslateFile) DeleteFieldKeys(index, field string) error {
+	if s.isReadOnly() {
+		return ErrTranslateStoreReadOnly
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.rows[fieldKey{index: index, field: field}] == nil {
+		return nil
+	}
+	return s.appendEntry(&LogEntry{
+		Type:  LogEntryTypeDeleteField,
+		Index: []byte(index),
+		Field: []byte(field),
+	})
+}
+
+// Compact rewrites the data file with only the id/key pairs which are still
+// referenced, such as the latest of a pair written more than once. Pairs are
+// written in their original order so that replaying the file gives the same
+// assignments.
+//
+// Compaction changes the offsets which replicas stream from, so the compacted
+// file starts a new epoch and open readers are closed. Replicas then stream
+// the file again from the start.
+func (s *TranslateFile) Compact() error {
+	if s.isReadOnly() {
+		return ErrTranslateStoreReadOnly
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.closing:
+		return ErrTranslateStoreClosed
+	default:
+	}
+
+	// Collect the offsets of referenced keys in each index.
+	type pair struct {
+		typ          uint8
+		index, field string
+		idx          *index
+		id           uint64
+		offset       int64
+	}
+	var pairs []pair
+	add := func(typ uint8, index, field string, idx *index) {
+		offsets := make(map[int64]uint64, len(idx.offsetsByID))
+		for id, offset := range idx.offsetsByID {
+			offsets[offset] = id
+		}
+		for _, e := range idx.elems {
+			if e.hash != 0 {
+				offsets[e.offset] = e.id
+			}
+		}
+		for offset, id := range offsets {
+			pairs = append(pairs, pair{typ: typ, index: index, field: field, idx: idx, id: id, offset: offset})
+		}
+	}
+	for name, idx := range s.cols {
+		add(LogEntryTypeInsertColumn, name, "", idx)
+	}
+	for key, idx := range s.rows {
+		add(LogEntryTypeInsertRow, key.index, key.field, idx)
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].offset < pairs[j].offset })
+
+	// Write pairs to a temporary file, grouping consecutive pairs for the
+	// same index into one entry.
+	path := s.Path + ".compacting"
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return errors.Wrap(err, "creating file")
+	}
+	defer os.Remove(path)
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+
+	// Start a new epoch.
+	epoch := uint64(time.Now().UnixNano())
+	if epoch <= s.epoch {
+		epoch = s.epoch + 1
+	}
+	if _, err := (&LogEntry{Type: LogEntryTypeEpoch, IDs: []uint64{epoch}, Keys: [][]byte{nil}}).WriteTo(w); err != nil {
+		return errors.Wrap(err, "writing epoch")
+	}
+
+	var entry *LogEntry
+	for i, p := range pairs {
+		if entry == nil || p.idx != pairs[i-1].idx {
+			if entry != nil {
+				if _, err := entry.WriteTo(w); err != nil {
+					return errors.Wrap(err, "writing entry")
+				}
+			}
+			entry = &LogEntry{Type: p.typ, Index: []byte(p.index)}
+			if p.typ == LogEntryTypeInsertRow {
+				entry.Field = []byte(p.field)
+			}
+		}
+		entry.IDs = append(entry.IDs, p.id)
+		entry.Keys = append(entry.Keys, p.idx.lookupKey(p.offset))
+	}
+	if entry != nil {
+		if _, err := entry.WriteTo(w); err != nil {
+			return errors.Wrap(err, "writing entry")
+		}
+	}
+
+	// Keep column id reservations beyond the last assigned id.
+	maxIDs := make(map[*index]uint64)
+	for _, p := range pairs {
+		if p.id > maxIDs[p.idx] {
+			maxIDs[p.idx] = p.id
+		}
+	}
+	for name, idx := range s.cols {
+		if idx.seq <= maxIDs[idx] {
+			continue
+		}
+		entry := &LogEntry{Type: LogEntryTypeReserveColumns, Index: []byte(name), IDs: []uint64{idx.seq}, Keys: [][]byte{nil}}
+		if _, err := entry.WriteTo(w); err != nil {
+			return errors.Wrap(err, "writing entry")
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return errors.Wrap(err, "flushing")
+	} else if err := f.Sync(); err != nil {
+		return errors.Wrap(err, "syncing")
+	} else if err := f.Close(); err != nil {
+		return errors.Wrap(err, "closing")
+	}
+
+	return s.replaceData(path)
+}
+
+// replaceData replaces the data file with the file at path and rebuilds the
+// indexes from it. The new file is opened and replayed before it is renamed
+// over the data file, and the old file is only unmapped once that succeeds,
+// so the store keeps using the old file if anything fails. Open readers are
+// closed since their offsets no longer match. Must be called under a write
+// lock.
+func (s *TranslateFile) replaceData(path string) error {
+	file, w, data, n, epoch, cols, rows := s.file, s.w, s.data, s.n, s.epoch, s.cols, s.rows
+	s.file, s.w, s.data, s.n, s.epoch = nil, nil, nil, 0, 0
+	s.cols = make(map[string]*index)
+	s.rows = make(map[fieldKey]*index)
+
+	err := s.openDataFile(path)
+	if err == nil {
+		err = errors.Wrap(os.Rename(path, s.Path), "renaming")
+	}
+	if err != nil {
+		if s.data != nil {
+			_ = syscall.Munmap(s.data)
+		}
+		if s.file != nil {
+			_ = s.file.Close()
+		}
+		s.file, s.w, s.data, s.n, s.epoch, s.cols, s.rows = file, w, data, n, epoch, cols, rows
+		return err
+	}
+
+	// Nothing refers to the old file once readers have been told to stop.
+	close(s.dataReplaced)
+	s.dataReplaced = make(chan struct{})
+	if err := file.Close(); err != nil {
+		s.logger.Printf("pilosa: closing replaced translate data file: %s", err)
+	}
+	if err := syscall.Munmap(data); err != nil {
+		s.logger.Printf("pilosa: unmapping replaced translate data file: %s", err)
+	}
+	return nil
+}
+
+// reset discards all data so that the store can be replicated again from the
+// start of its primary's data file.
+func (s *TranslateFile) reset() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := s.Path + ".resetting"
+	if err := ioutil.WriteFile(path, nil, 0666); err != nil {
+		return errors.Wrap(err, "creating file")
+	}
+	defer os.Remove(path)
+	return s.replaceData(path)
+}
+
 // Reader returns a reader that streams the underlying data file.
 func (s *TranslateFile) Reader(ctx context.Context, offset int64) (io.ReadCloser, error) {
 	rc := newTranslateFileReader(ctx, s, offset)
@@ -1041,6 +1575,9 @@ type translateFileReader struct {
 	offset int64
 	notify <-chan struct{}
 
+	epoch    uint64          // epoch of the data file when opened
+	replaced <-chan struct{} // closed when the data file is replaced
+
 	once    sync.Once
 	closing chan struct{}
 }
@@ -1058,8 +1595,18 @@ func newTranslateFileReader(ctx context.Context, store *TranslateFile, offset in
 
 // Open initializes the reader.
 func (r *translateFileReader) Open() (err error) {
-	r.file, err = os.Open(r.store.Path)
-	return err
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	if r.file, err = os.Open(r.store.Path); err != nil {
+		return err
+	}
+	r.epoch, r.replaced = r.store.epoch, r.store.dataReplaced
+	return nil
+}
+
+// Epoch returns the epoch of the data file which the reader streams.
+func (r *translateFileReader) Epoch() uint64 {
+	return r.epoch
 }
 
 // Close closes the underlying file reader.
@@ -1092,6 +1639,8 @@ func (r *translateFileReader) Read(p []byte) (n int, err error) {
 			return 0, ErrTranslateStoreReaderClosed
 		case <-r.store.Closing():
 			return 0, ErrTranslateStoreClosed
+		case <-r.replaced:
+			return 0, ErrTranslateStoreDataReplaced
 		case <-notify:
 			continue
 		}
@@ -1100,7 +1649,16 @@ func (r *translateFileReader) Read(p []byte) (n int, err error) {
 
 // read writes the bytes for zero or more valid entries to p.
 func (r *translateFileReader) read(p []byte) (n int, err error) {
-	sz := r.store.size()
+	// Hold the store's lock so that the data file is not replaced while it
+	// is read.
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+	select {
+	case <-r.replaced:
+		return 0, ErrTranslateStoreDataReplaced
+	default:
+	}
+	sz := r.store.n
 
 	// Exit if there is no new data.
 	if sz < r.offset {
@@ -1166,7 +1724,22 @@ func (s nopTranslateStore) TranslateRowToString(index, field string, values uint
 	return "", nil
 }
 
+// TranslateRowsToStrings is a no-op implementation of the TranslateStore TranslateRowsToStrings method.
+func (s nopTranslateStore) TranslateRowsToStrings(index, field string, values []uint64) ([]string, error) {
+	return []string{}, nil
+}
+
 // Reader is a no-op implementation of the TranslateStore Reader method.
 func (s nopTranslateStore) Reader(ctx context.Context, off int64) (io.ReadCloser, error) {
 	return ioutil.NopCloser(bytes.NewReader(nil)), nil
 }
+
+// Compact is a no-op implementation of the TranslateStore Compact method.
+func (s nopTranslateStore) Compact() error {
+	return nil
+}
+
+// DeleteFieldKeys is a no-op implementation of the TranslateStore DeleteFieldKeys method.
+func (s nopTranslateStore) DeleteFieldKeys(index, field string) error {
+	return nil
+}
@@ -177,6 +177,13 @@ func TestTranslateFile_TranslateRow(t *testing.T) {
 		t.Fatalf("unexpected value: %s", value)
 	}
 
+	// Ensure that multiple values can be looked up at once.
+	if values, err := s.TranslateRowsToStrings("IDX0", "FIELD0", []uint64{2, 1000, 1}); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(values, []string{"bar", "", "foo"}) {
+		t.Fatalf("unexpected values: %#v", values)
+	}
+
 	// Reopen the store.
 	if err := s.Reopen(); err != nil {
 		t.Fatal(err)
@@ -204,6 +211,45 @@ func TestTranslateFile_TranslateRow(t *testing.T) {
 	}
 }
 
+func TestTranslateFile_DeleteFieldKeys(t *testing.T) {
+	s := MustOpenTranslateFile()
+	defer s.MustClose()
+
+	if _, err := s.TranslateRowsToUint64("IDX0", "FIELD0", []string{"foo", "bar"}); err != nil {
+		t.Fatal(err)
+	} else if _, err := s.TranslateRowsToUint64("IDX0", "FIELD1", []string{"foo"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Deleting a field without keys does nothing.
+	if err := s.DeleteFieldKeys("IDX0", "FIELD2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteFieldKeys("IDX0", "FIELD0"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The deletion survives a reopen, and only affects the deleted field.
+	if err := s.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := s.TranslateRowToString("IDX0", "FIELD0", 2); err != nil {
+		t.Fatal(err)
+	} else if value != "" {
+		t.Fatalf("unexpected value: %s", value)
+	}
+	if ids, err := s.TranslateRowsToUint64("IDX0", "FIELD0", []string{"bar"}); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(ids, []uint64{1}) {
+		t.Fatalf("unexpected id: %#v", ids)
+	}
+	if value, err := s.TranslateRowToString("IDX0", "FIELD1", 1); err != nil {
+		t.Fatal(err)
+	} else if value != "foo" {
+		t.Fatalf("unexpected value: %s", value)
+	}
+}
+
 func TestTranslateFile_TranslateRow_Large(t *testing.T) {
 	s := MustOpenTranslateFile()
 	defer s.MustClose()
@@ -744,6 +790,147 @@ func TestTranslateFile_ReassignPrimaryTranslateStore(t *testing.T) {
 	})
 }
 
+func TestTranslateFile_Compact(t *testing.T) {
+	s := NewTranslateFile()
+	defer s.MustClose()
+
+	// Write a log where "foo" and row "bar" are each written twice.
+	f, err := os.OpenFile(s.Path, os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range []*pilosa.LogEntry{
+		{Type: pilosa.LogEntryTypeInsertColumn, Index: []byte("IDX0"), IDs: []uint64{1, 2}, Keys: [][]byte{[]byte("foo"), []byte("bar")}},
+		{Type: pilosa.LogEntryTypeInsertRow, Index: []byte("IDX0"), Field: []byte("FIELD0"), IDs: []uint64{1}, Keys: [][]byte{[]byte("bar")}},
+		{Type: pilosa.LogEntryTypeInsertColumn, Index: []byte("IDX0"), IDs: []uint64{1}, Keys: [][]byte{[]byte("foo")}},
+		{Type: pilosa.LogEntryTypeInsertRow, Index: []byte("IDX0"), Field: []byte("FIELD0"), IDs: []uint64{1}, Keys: [][]byte{[]byte("bar")}},
+	} {
+		if _, err := entry.WriteTo(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	} else if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.Stat(s.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Open readers are stopped once the data file is replaced.
+	rc, err := s.Reader(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	epoch := rc.(interface{ Epoch() uint64 }).Epoch()
+
+	if err := s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rc.Read(make([]byte, 1024)); err != pilosa.ErrTranslateStoreDataReplaced {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// New readers stream the compacted file at a new epoch.
+	if rc, err := s.Reader(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	} else if e := rc.(interface{ Epoch() uint64 }).Epoch(); e == epoch {
+		t.Fatalf("expected new epoch: %d", e)
+	} else if err := rc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if after, err := os.Stat(s.Path); err != nil {
+		t.Fatal(err)
+	} else if after.Size() >= before.Size() {
+		t.Fatalf("expected file to shrink: before=%d, after=%d", before.Size(), after.Size())
+	}
+
+	verify := func() {
+		t.Helper()
+		if ids, err := s.TranslateColumnsToUint64("IDX0", []string{"foo", "bar"}); err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(ids, []uint64{1, 2}) {
+			t.Fatalf("unexpected ids: %v", ids)
+		}
+		if value, err := s.TranslateRowToString("IDX0", "FIELD0", 1); err != nil {
+			t.Fatal(err)
+		} else if value != "bar" {
+			t.Fatalf("unexpected value: %s", value)
+		}
+	}
+	verify()
+
+	// New keys continue the existing sequence.
+	if ids, err := s.TranslateColumnsToUint64("IDX0", []string{"baz"}); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(ids, []uint64{3}) {
+		t.Fatalf("unexpected ids: %v", ids)
+	}
+
+	if err := s.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	verify()
+	if value, err := s.TranslateColumnToString("IDX0", 3); err != nil {
+		t.Fatal(err)
+	} else if value != "baz" {
+		t.Fatalf("unexpected value: %s", value)
+	}
+}
+
+func TestTranslateFile_Compact_Replica(t *testing.T) {
+	primary := MustOpenTranslateFile()
+	defer primary.MustClose()
+
+	replica := NewTranslateFile()
+	replica.SetPrimaryStore("primary", primary)
+	if err := replica.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer replica.MustClose()
+
+	// Write keys, some of which are written twice so compaction drops data.
+	for i := 0; i < 2; i++ {
+		if _, err := primary.TranslateColumnsToUint64("IDX0", []string{"foo", "bar"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := retryFor(2*time.Second, func() error {
+		if value, err := replica.TranslateColumnToString("IDX0", 2); err != nil {
+			return err
+		} else if value != "bar" {
+			return fmt.Errorf("unexpected column 2 value: %s", value)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Compact the primary and write a new key after it.
+	if err := primary.Compact(); err != nil {
+		t.Fatal(err)
+	} else if _, err := primary.TranslateColumnsToUint64("IDX0", []string{"baz"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The replica streams the compacted file again and keeps all keys.
+	if err := retryFor(5*time.Second, func() error {
+		for id, exp := range map[uint64]string{1: "foo", 2: "bar", 3: "baz"} {
+			if value, err := replica.TranslateColumnToString("IDX0", id); err != nil {
+				return err
+			} else if value != exp {
+				return fmt.Errorf("unexpected column %d value: %q", id, value)
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func BenchmarkTranslateFile_TranslateColumnsToUint64(b *testing.B) {
 	const batchSize = 1000
 
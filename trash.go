@@ -0,0 +1,181 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilosa
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// trashDirName is the directory under the holder path which holds deleted
+// fields, by index, when they are kept. Each field is stored as
+// <field>.<unix nano time of deletion>.
+const trashDirName = ".trash"
+
+// trashedFieldName returns the name under which a field deleted at t is kept.
+func trashedFieldName(field string, t time.Time) string {
+	return field + "." + strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// parseTrashedFieldName returns the field name and time of deletion of a
+// field kept under name.
+func parseTrashedFieldName(name string) (field string, t time.Time, ok bool) {
+	i := strings.LastIndexByte(name, '.')
+	if i < 0 {
+		return "", time.Time{}, false
+	}
+	ns, err := strconv.ParseInt(name[i+1:], 10, 64)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return name[:i], time.Unix(0, ns), true
+}
+
+// trashPath returns the directory holding the deleted fields of an index.
+func (h *Holder) trashPath(index string) string {
+	return filepath.Join(h.Path, trashDirName, index)
+}
+
+// removeFieldDir deletes the directory of a field, or moves it to the trash
+// if deleted fields are kept.
+func (i *Index) removeFieldDir(name string) error {
+	var dir string
+	if i.trashPath != nil {
+		dir = i.trashPath()
+	}
+	if dir == "" {
+		return errors.Wrap(os.RemoveAll(i.fieldPath(name)), "removing directory")
+	}
+
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return errors.Wrap(err, "creating trash directory")
+	}
+	return errors.Wrap(os.Rename(i.fieldPath(name), filepath.Join(dir, trashedFieldName(name, time.Now()))), "moving to trash")
+}
+
+// restoreField opens a field from the directory of a deleted field, which is
+// moved back into the index.
+func (i *Index) restoreField(name, path string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.field(name) != nil {
+		return newConflictError(ErrFieldExists)
+	}
+	if err := os.Rename(path, i.fieldPath(name)); err != nil {
+		return errors.Wrap(err, "moving from trash")
+	}
+
+	f, err := i.newField(i.fieldPath(name), name)
+	if err != nil {
+		return errors.Wrap(err, "creating field")
+	} else if err := f.Open(); err != nil {
+		return errors.Wrap(err, "opening field")
+	}
+	i.fields[name] = f
+
+	if name == existenceFieldName {
+		i.trackExistence = true
+		i.existenceFld = f
+		if err := i.saveMeta(); err != nil {
+			return errors.Wrap(err, "saving existence meta data")
+		}
+	}
+	i.touch()
+	return nil
+}
+
+// restoreField moves the most recently deleted field of the given name out
+// of the trash and back into its index.
+func (h *Holder) restoreField(indexName, fieldName string) error {
+	index := h.Index(indexName)
+	if index == nil {
+		return newNotFoundError(ErrIndexNotFound)
+	} else if index.Field(fieldName) != nil {
+		return newConflictError(ErrFieldExists)
+	}
+
+	fis, err := ioutil.ReadDir(h.trashPath(indexName))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "reading trash")
+	}
+	var latest string
+	var latestTime time.Time
+	for _, fi := range fis {
+		if name, t, ok := parseTrashedFieldName(fi.Name()); ok && name == fieldName && t.After(latestTime) {
+			latest, latestTime = fi.Name(), t
+		}
+	}
+	if latest == "" {
+		return newNotFoundError(ErrFieldNotFound)
+	}
+	return index.restoreField(fieldName, filepath.Join(h.trashPath(indexName), latest))
+}
+
+// purgeTrash permanently removes the fields which were deleted more than
+// olderThan ago. The row keys of a purged field are deleted once no copies of
+// it are left, unless the index has a field of the same name.
+func (h *Holder) purgeTrash(olderThan time.Duration) error {
+	root := filepath.Join(h.Path, trashDirName)
+	indexes, err := ioutil.ReadDir(root)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return errors.Wrap(err, "reading trash")
+	}
+
+	now := time.Now()
+	for _, ii := range indexes {
+		fis, err := ioutil.ReadDir(filepath.Join(root, ii.Name()))
+		if err != nil {
+			return errors.Wrap(err, "reading trash")
+		}
+
+		// Count the copies of each field, and remove those past the grace
+		// period.
+		kept := make(map[string]int)
+		purged := make(map[string]struct{})
+		for _, fi := range fis {
+			name, t, ok := parseTrashedFieldName(fi.Name())
+			if !ok {
+				continue
+			} else if now.Sub(t) < olderThan {
+				kept[name]++
+				continue
+			}
+			if err := os.RemoveAll(filepath.Join(root, ii.Name(), fi.Name())); err != nil {
+				return errors.Wrapf(err, "removing %s/%s", ii.Name(), fi.Name())
+			}
+			h.Logger.Printf("purged deleted field: index=%s, field=%s", ii.Name(), name)
+			purged[name] = struct{}{}
+		}
+
+		for name := range purged {
+			if kept[name] > 0 || h.Field(ii.Name(), name) != nil {
+				continue
+			}
+			if err := h.translateFile.DeleteFieldKeys(ii.Name(), name); err != nil && err != ErrTranslateStoreReadOnly {
+				return errors.Wrapf(err, "deleting field keys: index=%s, field=%s", ii.Name(), name)
+			}
+		}
+	}
+	return nil
+}
@@ -48,6 +48,11 @@ type view struct {
 	cacheType string
 	cacheSize uint32
 
+	compression string
+
+	// Returns the gzip level of fragment snapshots. Set by the field.
+	snapshotCompressionLevel func() int
+
 	// Fragments by shard.
 	fragments map[uint64]*fragment
 
@@ -69,6 +74,8 @@ func newView(path, index, field, name string, fieldOptions FieldOptions) *view {
 		cacheType: fieldOptions.CacheType,
 		cacheSize: fieldOptions.CacheSize,
 
+		compression: fieldOptions.Compression,
+
 		fragments: make(map[uint64]*fragment),
 
 		broadcaster: NopBroadcaster,
@@ -130,6 +137,8 @@ func (v *view) openFragments() error {
 		shard, err := strconv.ParseUint(filepath.Base(fi.Name()), 10, 64)
 		if err != nil {
 			continue
+		} else if v.fragments[shard] != nil {
+			continue
 		}
 
 		frag := v.newFragment(v.fragmentPath(shard), shard)
@@ -143,6 +152,29 @@ func (v *view) openFragments() error {
 	return nil
 }
 
+// openMissingFragments opens fragments which exist on disk but are not open
+// in the view, and returns their shards.
+func (v *view) openMissingFragments() ([]uint64, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	opened := make(map[uint64]struct{}, len(v.fragments))
+	for shard := range v.fragments {
+		opened[shard] = struct{}{}
+	}
+	if err := v.openFragments(); err != nil {
+		return nil, err
+	}
+
+	var shards []uint64
+	for shard := range v.fragments {
+		if _, ok := opened[shard]; !ok {
+			shards = append(shards, shard)
+		}
+	}
+	return shards, nil
+}
+
 // close closes the view and its fragments.
 func (v *view) close() error {
 	v.mu.Lock()
@@ -197,6 +229,15 @@ func (v *view) allFragments() []*fragment {
 	return other
 }
 
+// openFileCount returns the number of files the view's fragments have open.
+func (v *view) openFileCount() int {
+	var n int
+	for _, frag := range v.allFragments() {
+		n += frag.openFileCount()
+	}
+	return n
+}
+
 // recalculateCaches recalculates the cache on every fragment in the view.
 func (v *view) recalculateCaches() {
 	for _, fragment := range v.allFragments() {
@@ -204,6 +245,17 @@ func (v *view) recalculateCaches() {
 	}
 }
 
+// setStats replaces the stats client of the view and its fragments.
+func (v *view) setStats(sc stats.StatsClient) {
+	v.mu.Lock()
+	v.stats = sc
+	v.mu.Unlock()
+
+	for _, frag := range v.allFragments() {
+		frag.setStats(sc.WithTags(fmt.Sprintf("shard:%d", frag.shard)))
+	}
+}
+
 // CreateFragmentIfNotExists returns a fragment in the view by shard.
 func (v *view) CreateFragmentIfNotExists(shard uint64) (*fragment, error) {
 	frag, msg, err := v.createFragmentIfNotExists(shard)
@@ -251,6 +303,8 @@ func (v *view) newFragment(path string, shard uint64) *fragment {
 	frag := newFragment(path, v.index, v.field, v.name, shard)
 	frag.CacheType = v.cacheType
 	frag.CacheSize = v.cacheSize
+	frag.compression = v.compression
+	frag.compressionLevel = v.snapshotCompressionLevel
 	frag.Logger = v.logger
 	frag.stats = v.stats.WithTags(fmt.Sprintf("shard:%d", shard))
 	if v.fieldType == FieldTypeMutex {
@@ -118,3 +118,43 @@ type errorBroadcaster struct {
 func (errorBroadcaster) SendSync(Message) error {
 	return errors.New("intentional error")
 }
+
+// Ensure view opens fragments which exist on disk but were not loaded.
+func TestView_OpenMissingFragments(t *testing.T) {
+	v := mustOpenView("i", "f", "v")
+	defer v.close()
+
+	for _, shard := range []uint64{0, 2} {
+		frag, err := v.CreateFragmentIfNotExists(shard)
+		if err != nil {
+			t.Fatal(err)
+		} else if _, err := frag.setBit(1, shard*ShardWidth+1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Drop shard 2 from the view while leaving its file on disk.
+	if err := v.Fragment(2).Close(); err != nil {
+		t.Fatal(err)
+	}
+	delete(v.fragments, 2)
+
+	shards, err := v.openMissingFragments()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(shards) != 1 || shards[0] != 2 {
+		t.Fatalf("unexpected shards: %v", shards)
+	}
+	if ok, err := v.Fragment(2).bit(1, 2*ShardWidth+1); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatal("expected bit to be set")
+	}
+
+	// A second call finds nothing new.
+	if shards, err := v.openMissingFragments(); err != nil {
+		t.Fatal(err)
+	} else if len(shards) != 0 {
+		t.Fatalf("unexpected shards: %v", shards)
+	}
+}